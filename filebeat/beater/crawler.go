@@ -148,6 +148,18 @@ func (c *crawler) startInput(
 	c.log.Infof("Starting input (ID: %d)", id)
 	runner.Start()
 
+	// Inputs configured with run_once stop by themselves once all their
+	// data was read, wait for them when running once.
+	if runOnce, _ := config.Bool("run_once", -1); c.once && runOnce {
+		if w, ok := runner.(interface{ Wait() }); ok {
+			c.wg.Add(1)
+			go func() {
+				defer c.wg.Done()
+				w.Wait()
+			}()
+		}
+	}
+
 	return nil
 }
 
@@ -18,11 +18,14 @@
 package beater
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/elastic/beats/v7/filebeat/config"
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/statestore"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/bolt"
 	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
 	"github.com/elastic/elastic-agent-libs/logp"
 	"github.com/elastic/elastic-agent-libs/paths"
@@ -35,16 +38,29 @@ type filebeatStore struct {
 }
 
 func openStateStore(info beat.Info, logger *logp.Logger, cfg config.Registry) (*filebeatStore, error) {
-	memlog, err := memlog.New(logger, memlog.Settings{
-		Root:     paths.Resolve(paths.Data, cfg.Path),
-		FileMode: cfg.Permissions,
-	})
+	var reg backend.Registry
+	var err error
+	switch cfg.Backend {
+	case "", "memlog":
+		reg, err = memlog.New(logger, memlog.Settings{
+			Root:     paths.Resolve(paths.Data, cfg.Path),
+			FileMode: cfg.Permissions,
+		})
+	case "bolt":
+		reg, err = bolt.New(logger, bolt.Settings{
+			Root:         paths.Resolve(paths.Data, cfg.Path),
+			FileMode:     cfg.Permissions,
+			SyncInterval: cfg.FlushTimeout,
+		})
+	default:
+		err = fmt.Errorf("unknown registry backend '%v'", cfg.Backend)
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	return &filebeatStore{
-		registry:      statestore.NewRegistry(memlog),
+		registry:      statestore.NewRegistry(reg),
 		storeName:     info.Beat,
 		cleanInterval: cfg.CleanInterval,
 	}, nil
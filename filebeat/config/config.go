@@ -56,6 +56,7 @@ type Registry struct {
 	FlushTimeout  time.Duration `config:"flush"`
 	CleanInterval time.Duration `config:"cleanup_interval"`
 	MigrateFile   string        `config:"migrate_file"`
+	Backend       string        `config:"backend"`
 }
 
 var DefaultConfig = Config{
@@ -65,6 +66,7 @@ var DefaultConfig = Config{
 		MigrateFile:   "",
 		CleanInterval: 5 * time.Minute,
 		FlushTimeout:  time.Second,
+		Backend:       "memlog",
 	},
 	ShutdownTimeout:    0,
 	OverwritePipelines: false,
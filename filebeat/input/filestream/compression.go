@@ -0,0 +1,134 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressionNone = "none"
+	compressionAuto = "auto"
+
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// detectCompression returns the compression format of r based on the magic
+// bytes at its beginning. An empty string is returned if the content is not
+// compressed in any of the supported formats.
+func detectCompression(r io.ReaderAt) (string, error) {
+	var header [4]byte
+	n, err := r.ReadAt(header[:], 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(header[:n], gzipMagic):
+		return compressionGzip, nil
+	case bytes.HasPrefix(header[:n], zstdMagic):
+		return compressionZstd, nil
+	default:
+		return "", nil
+	}
+}
+
+// detectFileCompression returns the compression format of the file under path.
+func detectFileCompression(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return detectCompression(f)
+}
+
+// newDecompressor returns a reader for the decompressed content of r.
+func newDecompressor(format string, r io.Reader) (io.ReadCloser, error) {
+	switch format {
+	case compressionGzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return decompressor{gr}, nil
+	case compressionZstd:
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decompressor{zr.IOReadCloser()}, nil
+	default:
+		return nil, fmt.Errorf("unsupported compression format %q", format)
+	}
+}
+
+// decompressor reports an unexpected end of the compressed stream as io.EOF.
+// Rotated files are usually compressed after they have been picked up by the
+// scanner, so a compressed file can be read before it is complete. Its
+// remaining content is read the next time the file is harvested.
+type decompressor struct {
+	io.ReadCloser
+}
+
+func (d decompressor) Read(p []byte) (int, error) {
+	n, err := d.ReadCloser.Read(p)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// openDecompressor returns a reader for the decompressed content of f
+// positioned at offset. Offsets of compressed files refer to the
+// decompressed content, so the first offset bytes are decompressed and
+// discarded.
+func openDecompressor(f *os.File, format string, offset int64) (io.ReadCloser, error) {
+	dec, err := newDecompressor(format, f)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		// the header of the compressed stream is not complete yet
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		_, err = io.CopyN(io.Discard, dec, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			dec.Close()
+			return nil, fmt.Errorf("failed to skip %d bytes of decompressed content: %w", offset, err)
+		}
+	}
+
+	return dec, nil
+}
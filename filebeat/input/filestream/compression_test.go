@@ -0,0 +1,244 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package filestream
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+	"github.com/elastic/beats/v7/libbeat/common/file"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func TestDetectCompression(t *testing.T) {
+	testCases := map[string]struct {
+		content  []byte
+		expected string
+	}{
+		"gzip":  {content: compress(t, compressionGzip, "line\n"), expected: compressionGzip},
+		"zstd":  {content: compress(t, compressionZstd, "line\n"), expected: compressionZstd},
+		"plain": {content: []byte("line\n"), expected: ""},
+		"empty": {content: nil, expected: ""},
+		"short": {content: []byte{0x1f}, expected: ""},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			format, err := detectCompression(bytes.NewReader(tc.content))
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, format)
+		})
+	}
+}
+
+func TestReadCompressedFile(t *testing.T) {
+	lines := []string{"first line", "second line", "third line"}
+	content := strings.Join(lines, "\n") + "\n"
+
+	for _, format := range []string{compressionGzip, compressionZstd} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "app.log.1")
+			require.NoError(t, os.WriteFile(path, compress(t, format, content), 0o644))
+
+			inp := compressionTestInput(t, compressionAuto)
+			require.Equal(t, lines, readLines(t, inp, path, 0))
+
+			// offsets refer to the decompressed content
+			offset := int64(len(lines[0]) + 1)
+			require.Equal(t, lines[1:], readLines(t, inp, path, offset))
+
+			// nothing is left to read after the end of the content
+			require.Empty(t, readLines(t, inp, path, int64(len(content))))
+		})
+	}
+}
+
+func TestReadIncompleteCompressedFile(t *testing.T) {
+	line := "a line of a log file being compressed"
+	content := strings.Repeat(line+"\n", 1024)
+
+	for _, format := range []string{compressionGzip, compressionZstd} {
+		t.Run(format, func(t *testing.T) {
+			compressed := compress(t, format, content)
+			path := filepath.Join(t.TempDir(), "app.log.1")
+			require.NoError(t, os.WriteFile(path, compressed[:len(compressed)/2], 0o644))
+
+			inp := compressionTestInput(t, compressionAuto)
+			lines := readLines(t, inp, path, 0)
+			require.Less(t, len(lines), 1024)
+
+			// the rest of the file is read from the last offset once it is complete
+			require.NoError(t, os.WriteFile(path, compressed, 0o644))
+			offset := int64(len(lines) * len(line+"\n"))
+			rest := readLines(t, inp, path, offset)
+			require.Len(t, rest, 1024-len(lines))
+		})
+	}
+}
+
+func TestReadCompressedFileDisabled(t *testing.T) {
+	content := strings.Repeat("line\n", 1024)
+	path := filepath.Join(t.TempDir(), "app.log.1.gz")
+	require.NoError(t, os.WriteFile(path, compress(t, compressionGzip, content), 0o644))
+
+	// the file is read as it is, archived files are closed on EOF
+	inp := compressionTestInput(t, compressionNone)
+	lines := readSource(t, inp, testFileSource(t, path, true), 0)
+	require.NotContains(t, lines, "line")
+}
+
+func TestInvalidCompressionConfig(t *testing.T) {
+	c := conf.MustNewConfigFrom(map[string]interface{}{
+		"paths":       []string{"/var/log/*.log"},
+		"compression": "bzip2",
+	})
+	_, _, err := configure(c)
+	require.ErrorContains(t, err, `invalid compression "bzip2"`)
+}
+
+func TestFingerprintCompressedFile(t *testing.T) {
+	dir := t.TempDir()
+	content := strings.Repeat("a line of a rotated log file\n", 128)
+
+	plainPath := filepath.Join(dir, "app.log")
+	gzipPath := filepath.Join(dir, "app.log.1.gz")
+	zstdPath := filepath.Join(dir, "app.log.2.zst")
+	smallPath := filepath.Join(dir, "app.log.3.gz")
+	require.NoError(t, os.WriteFile(plainPath, []byte(content), 0o644))
+	require.NoError(t, os.WriteFile(gzipPath, compress(t, compressionGzip, content), 0o644))
+	require.NoError(t, os.WriteFile(zstdPath, compress(t, compressionZstd, content), 0o644))
+	require.NoError(t, os.WriteFile(smallPath, compress(t, compressionGzip, content[:512]), 0o644))
+
+	// the same content has the same fingerprint, so each file is scanned on its own
+	scan := func(path string, decompress bool) (loginp.FileDescriptor, bool) {
+		cfg := fileScannerConfig{
+			Fingerprint: fingerprintConfig{
+				Enabled: true,
+				Offset:  512,
+				Length:  1024,
+			},
+		}
+		s, err := newFileScanner([]string{path}, cfg)
+		require.NoError(t, err)
+		s.decompress = decompress
+
+		fd, ok := s.GetFiles()[path]
+		return fd, ok
+	}
+
+	plain, ok := scan(plainPath, true)
+	require.True(t, ok)
+	require.NotEmpty(t, plain.Fingerprint)
+	require.Empty(t, plain.Compression)
+
+	gz, ok := scan(gzipPath, true)
+	require.True(t, ok)
+	require.Equal(t, plain.Fingerprint, gz.Fingerprint)
+	require.Equal(t, compressionGzip, gz.Compression)
+
+	zst, ok := scan(zstdPath, true)
+	require.True(t, ok)
+	require.Equal(t, plain.Fingerprint, zst.Fingerprint)
+	require.Equal(t, compressionZstd, zst.Compression)
+
+	_, ok = scan(smallPath, true)
+	require.False(t, ok, "file with too little decompressed content must be ignored")
+
+	// without decompression the compressed bytes are fingerprinted
+	_, ok = scan(gzipPath, false)
+	require.False(t, ok, "compressed file is smaller than the fingerprint")
+}
+
+func compressionTestInput(t *testing.T, compression string) *filestream {
+	c := conf.MustNewConfigFrom(map[string]interface{}{
+		"paths":       []string{"/var/log/*.log"},
+		"compression": compression,
+	})
+	_, h, err := configure(c)
+	require.NoError(t, err)
+	return h.(*filestream)
+}
+
+func readLines(t *testing.T, inp *filestream, path string, offset int64) []string {
+	t.Helper()
+	return readSource(t, inp, testFileSource(t, path, false), offset)
+}
+
+func readSource(t *testing.T, inp *filestream, fs fileSource, offset int64) []string {
+	t.Helper()
+
+	r, truncated, err := inp.open(logp.L(), context.TODO(), fs, offset)
+	require.NoError(t, err)
+	require.False(t, truncated)
+	defer r.Close()
+
+	var lines []string
+	for {
+		msg, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return lines
+		}
+		require.NoError(t, err)
+		lines = append(lines, string(msg.Content))
+	}
+}
+
+func testFileSource(t *testing.T, path string, archived bool) fileSource {
+	t.Helper()
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	return fileSource{
+		newPath:  path,
+		archived: archived,
+		desc:     loginp.FileDescriptor{Filename: path, Info: file.ExtendFileInfo(fi)},
+	}
+}
+
+func compress(t *testing.T, format, content string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch format {
+	case compressionGzip:
+		w = gzip.NewWriter(&buf)
+	case compressionZstd:
+		zw, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		w = zw
+	}
+	_, err := w.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
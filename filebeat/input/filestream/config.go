@@ -49,6 +49,9 @@ type config struct {
 	IgnoreInactive ignoreInactiveType `config:"ignore_inactive"`
 	Rotation       *conf.Namespace    `config:"rotation"`
 	TakeOver       bool               `config:"take_over"`
+	// RunOnce reads the files found by a single scan until EOF, then stops
+	// the input.
+	RunOnce bool `config:"run_once"`
 }
 
 type closerConfig struct {
@@ -71,12 +74,14 @@ type stateChangeCloserConfig struct {
 type readerConfig struct {
 	Backoff        backoffConfig           `config:"backoff"`
 	BufferSize     int                     `config:"buffer_size"`
+	Compression    string                  `config:"compression"`
 	Encoding       string                  `config:"encoding"`
 	ExcludeLines   []match.Matcher         `config:"exclude_lines"`
 	IncludeLines   []match.Matcher         `config:"include_lines"`
 	LineTerminator readfile.LineTerminator `config:"line_terminator"`
 	MaxBytes       int                     `config:"message_max_bytes" validate:"min=0,nonzero"`
 	Tail           bool                    `config:"seek_to_tail"`
+	TimeFilter     timeFilterConfig        `config:"time_filter"`
 
 	Parsers parser.Config `config:",inline"`
 }
@@ -131,9 +136,11 @@ func defaultReaderConfig() readerConfig {
 			Max:  10 * time.Second,
 		},
 		BufferSize:     16 * humanize.KiByte,
+		Compression:    compressionNone,
 		LineTerminator: readfile.AutoLineTerminator,
 		MaxBytes:       10 * humanize.MiByte,
 		Tail:           false,
+		TimeFilter:     defaultTimeFilterConfig(),
 	}
 }
 
@@ -142,6 +149,12 @@ func (c *config) Validate() error {
 		return fmt.Errorf("no path is configured")
 	}
 
+	switch c.Reader.Compression {
+	case compressionNone, compressionAuto:
+	default:
+		return fmt.Errorf("invalid compression %q, must be %q or %q", c.Reader.Compression, compressionNone, compressionAuto)
+	}
+
 	return nil
 }
 
@@ -197,6 +197,12 @@ type copyTruncateFileProspector struct {
 
 // Run starts the fileProspector which accepts FS events from a file watcher.
 func (p *copyTruncateFileProspector) Run(ctx input.Context, s loginp.StateMetadataUpdater, hg loginp.HarvesterGroup) {
+	if p.runOnce {
+		// Rotated files are read like any other file when reading once.
+		p.fileProspector.Run(ctx, s, hg)
+		return
+	}
+
 	log := ctx.Logger.With("prospector", copyTruncateProspectorDebugKey)
 	log.Debug("Starting prospector")
 	defer log.Debug("Prospector has stopped")
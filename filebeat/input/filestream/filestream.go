@@ -41,9 +41,12 @@ var (
 
 // logFile contains all log related data
 type logFile struct {
-	file      *os.File
-	log       *logp.Logger
-	readerCtx ctxtool.CancelContext
+	file *os.File
+	// decompressor reads the decompressed content of file if it is
+	// compressed, it is nil otherwise.
+	decompressor io.ReadCloser
+	log          *logp.Logger
+	readerCtx    ctxtool.CancelContext
 
 	closeAfterInterval time.Duration
 	closeOnEOF         bool
@@ -72,11 +75,27 @@ func newFileReader(
 		return nil, err
 	}
 
+	return newLogFile(log, canceler, f, nil, offset, config, closerConfig), nil
+}
+
+// newLogFile creates a new log instance reading from f, or from dec if
+// the file is compressed. The offset is the current position of the reader
+// in the (decompressed) content.
+func newLogFile(
+	log *logp.Logger,
+	canceler input.Canceler,
+	f *os.File,
+	dec io.ReadCloser,
+	offset int64,
+	config readerConfig,
+	closerConfig closerConfig,
+) *logFile {
 	readerCtx := ctxtool.WithCancelContext(ctxtool.FromCanceller(canceler))
 	tg := unison.TaskGroupWithCancel(readerCtx)
 
 	l := &logFile{
 		file:               f,
+		decompressor:       dec,
 		log:                log,
 		closeAfterInterval: closerConfig.Reader.AfterInterval,
 		closeOnEOF:         closerConfig.Reader.OnEOF,
@@ -93,7 +112,7 @@ func newFileReader(
 
 	l.startFileMonitoringIfNeeded()
 
-	return l, nil
+	return l
 }
 
 // Read reads from the reader and updates the offset
@@ -102,7 +121,7 @@ func (f *logFile) Read(buf []byte) (int, error) {
 	totalN := 0
 
 	for f.readerCtx.Err() == nil {
-		n, err := f.file.Read(buf)
+		n, err := f.read(buf)
 		if n > 0 {
 			f.offset += int64(n)
 			f.lastTimeRead = time.Now()
@@ -135,6 +154,13 @@ func (f *logFile) Read(buf []byte) (int, error) {
 	return 0, ErrClosed
 }
 
+func (f *logFile) read(buf []byte) (int, error) {
+	if f.decompressor != nil {
+		return f.decompressor.Read(buf)
+	}
+	return f.file.Read(buf)
+}
+
 func (f *logFile) startFileMonitoringIfNeeded() {
 	if f.closeInactive > 0 || f.closeRemoved || f.closeRenamed {
 		err := f.tg.Go(func(ctx context.Context) error {
@@ -245,6 +271,12 @@ func (f *logFile) handleEOF() error {
 		return io.EOF
 	}
 
+	// the size of a compressed file cannot be compared to the offset
+	// in its decompressed content
+	if f.decompressor != nil {
+		return nil
+	}
+
 	// Refetch fileinfo to check if the file was truncated.
 	// Errors if the file was removed/rotated after reading and before
 	// calling the stat function
@@ -266,6 +298,9 @@ func (f *logFile) handleEOF() error {
 // Close
 func (f *logFile) Close() error {
 	f.readerCtx.Cancel()
+	if f.decompressor != nil {
+		_ = f.decompressor.Close()
+	}
 	err := f.file.Close()
 	_ = f.tg.Stop() // Wait until all resources are released for sure.
 	return err
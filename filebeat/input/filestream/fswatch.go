@@ -44,6 +44,9 @@ const (
 	DefaultFingerprintSize int64 = 1024 // 1KB
 	scannerDebugKey              = "scanner"
 	watcherDebugKey              = "file_watcher"
+
+	watcherModePoll    = "poll"
+	watcherModeInotify = "inotify"
 )
 
 var (
@@ -56,10 +59,23 @@ type fileWatcherConfig struct {
 	// ResendOnModTime  if a file has been changed according to modtime but the size is the same
 	// it is still considered truncation.
 	ResendOnModTime bool `config:"resend_on_touch"`
+	// Mode selects how file changes are detected, either by periodic scans
+	// or by inotify events.
+	Mode string `config:"mode"`
+	// RescanInterval is the time between two full scans in inotify mode.
+	RescanInterval time.Duration `config:"rescan_interval" validate:"positive,nonzero"`
 	// Scanner is the configuration of the scanner.
 	Scanner fileScannerConfig `config:",inline"`
 }
 
+func (c *fileWatcherConfig) Validate() error {
+	switch c.Mode {
+	case watcherModePoll, watcherModeInotify:
+		return nil
+	}
+	return fmt.Errorf("invalid scanner mode %q, must be %q or %q", c.Mode, watcherModePoll, watcherModeInotify)
+}
+
 // fileWatcher gets the list of files from a FSWatcher and creates events by
 // comparing the files between its last two runs.
 type fileWatcher struct {
@@ -70,7 +86,7 @@ type fileWatcher struct {
 	events  chan loginp.FSEvent
 }
 
-func newFileWatcher(paths []string, ns *conf.Namespace) (loginp.FSWatcher, error) {
+func newFileWatcher(paths []string, ns *conf.Namespace, compression string) (loginp.FSWatcher, error) {
 	var config *conf.C
 	if ns == nil {
 		config = conf.NewConfig()
@@ -78,10 +94,10 @@ func newFileWatcher(paths []string, ns *conf.Namespace) (loginp.FSWatcher, error
 		config = ns.Config()
 	}
 
-	return newScannerWatcher(paths, config)
+	return newScannerWatcher(paths, config, compression)
 }
 
-func newScannerWatcher(paths []string, c *conf.C) (loginp.FSWatcher, error) {
+func newScannerWatcher(paths []string, c *conf.C, compression string) (loginp.FSWatcher, error) {
 	config := defaultFileWatcherConfig()
 	err := c.Unpack(&config)
 	if err != nil {
@@ -91,19 +107,26 @@ func newScannerWatcher(paths []string, c *conf.C) (loginp.FSWatcher, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &fileWatcher{
+	scanner.decompress = compression == compressionAuto
+	w := &fileWatcher{
 		log:     logp.NewLogger(watcherDebugKey),
 		cfg:     config,
 		prev:    make(map[string]loginp.FileDescriptor, 0),
 		scanner: scanner,
 		events:  make(chan loginp.FSEvent),
-	}, nil
+	}
+	if config.Mode == watcherModeInotify {
+		return newInotifyWatcher(w, scanner)
+	}
+	return w, nil
 }
 
 func defaultFileWatcherConfig() fileWatcherConfig {
 	return fileWatcherConfig{
 		Interval:        10 * time.Second,
 		ResendOnModTime: false,
+		Mode:            watcherModePoll,
+		RescanInterval:  time.Minute,
 		Scanner:         defaultFileScannerConfig(),
 	}
 }
@@ -125,7 +148,14 @@ func (w *fileWatcher) watch(ctx unison.Canceler) {
 	w.log.Debug("Start next scan")
 
 	paths := w.scanner.GetFiles()
+	w.sendEvents(ctx, w.prev, paths)
+	w.prev = paths
+}
 
+// sendEvents creates events by comparing the files in paths with the files
+// in prev. Both maps are modified: files found in paths are removed from prev,
+// and empty new files are removed from paths.
+func (w *fileWatcher) sendEvents(ctx unison.Canceler, prev, paths map[string]loginp.FileDescriptor) {
 	// for debugging purposes
 	writtenCount := 0
 	truncatedCount := 0
@@ -139,7 +169,7 @@ func (w *fileWatcher) watch(ctx unison.Canceler) {
 	for path, fd := range paths {
 		// if the scanner found a new path or an existing path
 		// with a different file, it is a new file
-		prevDesc, ok := w.prev[path]
+		prevDesc, ok := prev[path]
 		sfd := fd // to avoid memory aliasing
 		if !ok || !loginp.SameFile(&prevDesc, &sfd) {
 			newFilesByName[path] = &sfd
@@ -178,12 +208,12 @@ func (w *fileWatcher) watch(ctx unison.Canceler) {
 		}
 
 		// delete from previous state to mark that we've seen the existing file again
-		delete(w.prev, path)
+		delete(prev, path)
 	}
 
 	// remaining files in the prev map are the ones that are missing
 	// either because they have been deleted or renamed
-	for remainingPath, remainingDesc := range w.prev {
+	for remainingPath, remainingDesc := range prev {
 		var e loginp.FSEvent
 
 		id := remainingDesc.FileID()
@@ -227,8 +257,6 @@ func (w *fileWatcher) watch(ctx unison.Canceler) {
 		"removed", removedCount,
 		"created", createdCount,
 	).Debugf("File scan complete")
-
-	w.prev = paths
 }
 
 func createEvent(path string, fd loginp.FileDescriptor) loginp.FSEvent {
@@ -293,6 +321,9 @@ type fileScanner struct {
 	log        *logp.Logger
 	hasher     hash.Hash
 	readBuffer []byte
+	// decompress is true if compressed files are read decompressed,
+	// their fingerprint is computed from the decompressed content.
+	decompress bool
 }
 
 func newFileScanner(paths []string, config fileScannerConfig) (*fileScanner, error) {
@@ -412,6 +443,29 @@ func (s *fileScanner) GetFiles() map[string]loginp.FileDescriptor {
 	return fdByName
 }
 
+// getFile returns the file descriptor of a single file if it matches the
+// configured paths.
+func (s *fileScanner) getFile(filename string) (loginp.FileDescriptor, error) {
+	if !s.matchesPaths(filename) {
+		return loginp.FileDescriptor{}, fmt.Errorf("file %q does not match the configured paths", filename)
+	}
+	it, err := s.getIngestTarget(filename)
+	if err != nil {
+		return loginp.FileDescriptor{}, err
+	}
+	return s.toFileDescriptor(&it)
+}
+
+// matchesPaths checks if the filename matches any of the configured paths.
+func (s *fileScanner) matchesPaths(filename string) bool {
+	for _, path := range s.paths {
+		if ok, _ := filepath.Match(path, filename); ok {
+			return true
+		}
+	}
+	return false
+}
+
 type ingestTarget struct {
 	filename         string
 	originalFilename string
@@ -477,43 +531,88 @@ func (s *fileScanner) toFileDescriptor(it *ingestTarget) (fd loginp.FileDescript
 	fd.Filename = it.filename
 	fd.Info = it.info
 
-	if s.cfg.Fingerprint.Enabled {
-		fileSize := it.info.Size()
-		// we should not open the file if we know it's too small
-		minSize := s.cfg.Fingerprint.Offset + s.cfg.Fingerprint.Length
-		if fileSize < minSize {
-			return fd, fmt.Errorf("filesize of %q is %d bytes, expected at least %d bytes for fingerprinting: %w", fd.Filename, fileSize, minSize, errFileTooSmall)
-		}
-
-		file, err := os.Open(it.originalFilename)
+	if s.decompress {
+		fd.Compression, err = detectFileCompression(it.originalFilename)
 		if err != nil {
-			return fd, fmt.Errorf("failed to open %q for fingerprinting: %w", it.originalFilename, err)
+			return fd, fmt.Errorf("failed to detect compression of %q: %w", it.originalFilename, err)
 		}
-		defer file.Close()
+	}
 
-		if s.cfg.Fingerprint.Offset != 0 {
-			_, err = file.Seek(s.cfg.Fingerprint.Offset, io.SeekStart)
-			if err != nil {
-				return fd, fmt.Errorf("failed to seek %q for fingerprinting: %w", fd.Filename, err)
-			}
-		}
+	if !s.cfg.Fingerprint.Enabled {
+		return fd, nil
+	}
+
+	if fd.Compression != "" {
+		fd.Fingerprint, err = s.decompressedFingerprint(it, fd.Compression)
+		return fd, err
+	}
+
+	fileSize := it.info.Size()
+	// we should not open the file if we know it's too small
+	minSize := s.cfg.Fingerprint.Offset + s.cfg.Fingerprint.Length
+	if fileSize < minSize {
+		return fd, fmt.Errorf("filesize of %q is %d bytes, expected at least %d bytes for fingerprinting: %w", fd.Filename, fileSize, minSize, errFileTooSmall)
+	}
 
-		s.hasher.Reset()
-		lr := io.LimitReader(file, s.cfg.Fingerprint.Length)
-		written, err := io.CopyBuffer(s.hasher, lr, s.readBuffer)
+	file, err := os.Open(it.originalFilename)
+	if err != nil {
+		return fd, fmt.Errorf("failed to open %q for fingerprinting: %w", it.originalFilename, err)
+	}
+	defer file.Close()
+
+	if s.cfg.Fingerprint.Offset != 0 {
+		_, err = file.Seek(s.cfg.Fingerprint.Offset, io.SeekStart)
 		if err != nil {
-			return fd, fmt.Errorf("failed to compute hash for first %d bytes of %q: %w", s.cfg.Fingerprint.Length, fd.Filename, err)
-		}
-		if written != s.cfg.Fingerprint.Length {
-			return fd, fmt.Errorf("failed to read %d bytes from %q to compute fingerprint, read only %d", written, fd.Filename, s.cfg.Fingerprint.Length)
+			return fd, fmt.Errorf("failed to seek %q for fingerprinting: %w", fd.Filename, err)
 		}
+	}
 
-		fd.Fingerprint = hex.EncodeToString(s.hasher.Sum(nil))
+	s.hasher.Reset()
+	lr := io.LimitReader(file, s.cfg.Fingerprint.Length)
+	written, err := io.CopyBuffer(s.hasher, lr, s.readBuffer)
+	if err != nil {
+		return fd, fmt.Errorf("failed to compute hash for first %d bytes of %q: %w", s.cfg.Fingerprint.Length, fd.Filename, err)
+	}
+	if written != s.cfg.Fingerprint.Length {
+		return fd, fmt.Errorf("failed to read %d bytes from %q to compute fingerprint, read only %d", written, fd.Filename, s.cfg.Fingerprint.Length)
 	}
 
+	fd.Fingerprint = hex.EncodeToString(s.hasher.Sum(nil))
+
 	return fd, nil
 }
 
+// decompressedFingerprint computes the fingerprint of a compressed file from
+// its decompressed content, so a file keeps its fingerprint after it is compressed.
+func (s *fileScanner) decompressedFingerprint(it *ingestTarget, format string) (string, error) {
+	file, err := os.Open(it.originalFilename)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %q for fingerprinting: %w", it.originalFilename, err)
+	}
+	defer file.Close()
+
+	minSize := s.cfg.Fingerprint.Offset + s.cfg.Fingerprint.Length
+	dec, err := openDecompressor(file, format, s.cfg.Fingerprint.Offset)
+	if err != nil {
+		return "", fmt.Errorf("failed to decompress %q for fingerprinting: %w", it.filename, err)
+	}
+	defer dec.Close()
+
+	s.hasher.Reset()
+	lr := io.LimitReader(dec, s.cfg.Fingerprint.Length)
+	written, err := io.CopyBuffer(s.hasher, lr, s.readBuffer)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute hash for first %d decompressed bytes of %q: %w", s.cfg.Fingerprint.Length, it.filename, err)
+	}
+	if written != s.cfg.Fingerprint.Length {
+		// the size of the decompressed content is not known before reading it,
+		// the file might also still be being compressed
+		return "", fmt.Errorf("decompressed content of %q is shorter than the %d bytes required for fingerprinting: %w", it.filename, minSize, errFileTooSmall)
+	}
+
+	return hex.EncodeToString(s.hasher.Sum(nil)), nil
+}
+
 func (s *fileScanner) isFileExcluded(file string) bool {
 	return len(s.cfg.ExcludedFiles) > 0 && s.matchAny(s.cfg.ExcludedFiles, file)
 }
@@ -0,0 +1,359 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package filestream
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/elastic/go-concert/unison"
+
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	// inotifyDirMask selects the events reported for watched directories.
+	// Events of files in the directory are reported with the file name.
+	inotifyDirMask = unix.IN_CREATE | unix.IN_MODIFY | unix.IN_ATTRIB |
+		unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_DELETE |
+		unix.IN_DELETE_SELF | unix.IN_MOVE_SELF | unix.IN_ONLYDIR
+
+	// inotifyBufferSize is large enough to hold many events with file
+	// names of up to NAME_MAX bytes.
+	inotifyBufferSize = 64 * 1024
+)
+
+// inotifyWatcher creates the same events as fileWatcher, but only checks the
+// files reported by inotify instead of scanning all paths periodically. A full
+// scan is still run every rescan_interval, and whenever inotify cannot tell
+// which files changed, e.g. because a directory was created or events were
+// lost. Changes to the targets of symlinks are only detected by full scans.
+type inotifyWatcher struct {
+	*fileWatcher
+	scanner *fileScanner
+	notify  *inotify
+}
+
+func newInotifyWatcher(w *fileWatcher, scanner *fileScanner) (loginp.FSWatcher, error) {
+	notify, err := newInotify(w.log)
+	if err != nil {
+		return nil, err
+	}
+	return &inotifyWatcher{
+		fileWatcher: w,
+		scanner:     scanner,
+		notify:      notify,
+	}, nil
+}
+
+func (w *inotifyWatcher) Run(ctx unison.Canceler) {
+	defer close(w.events)
+	defer w.notify.close()
+
+	notifications := w.notify.run()
+
+	// run initial scan before waiting for changes
+	w.rescan(ctx)
+
+	interval := w.cfg.RescanInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			w.rescan(ctx)
+
+		case batch, ok := <-notifications:
+			if !ok {
+				// inotify failed, continue with regular scans.
+				w.log.Warnf("Reading inotify events failed, scanning files every %v", w.cfg.Interval)
+				notifications = nil
+				ticker.Reset(w.cfg.Interval)
+				continue
+			}
+
+			changed := map[string]struct{}{}
+			rescan := false
+			for more := true; more; {
+				for _, n := range batch {
+					if n.rescan {
+						rescan = true
+					} else if w.scanner.matchesPaths(n.path) {
+						changed[n.path] = struct{}{}
+					}
+				}
+				// merge all pending notifications
+				select {
+				case batch, more = <-notifications:
+				default:
+					more = false
+				}
+			}
+
+			if rescan {
+				w.rescan(ctx)
+				ticker.Reset(interval)
+			} else if len(changed) > 0 {
+				w.update(ctx, changed)
+			}
+		}
+	}
+}
+
+// rescan updates the watched directories and scans all paths. Directories
+// are watched first, so changes during the scan are not missed.
+func (w *inotifyWatcher) rescan(ctx unison.Canceler) {
+	w.notify.watch(watchDirs(w.scanner.paths))
+	w.watch(ctx)
+}
+
+// update creates events for the changed files.
+func (w *inotifyWatcher) update(ctx unison.Canceler, changed map[string]struct{}) {
+	w.log.Debugf("Checking %d changed files", len(changed))
+
+	prev := make(map[string]loginp.FileDescriptor, len(changed))
+	paths := make(map[string]loginp.FileDescriptor, len(changed))
+	for path := range changed {
+		if fd, ok := w.prev[path]; ok {
+			prev[path] = fd
+		}
+		fd, err := w.scanner.getFile(path)
+		if err != nil {
+			w.log.Debugf("cannot create a file descriptor for %q: %s", path, err)
+			continue
+		}
+		paths[path] = fd
+	}
+
+	w.sendEvents(ctx, prev, paths)
+
+	for path := range changed {
+		delete(w.prev, path)
+	}
+	for path, fd := range paths {
+		w.prev[path] = fd
+	}
+}
+
+// watchDirs returns the existing directories that can contain files matching
+// the patterns. For patterns with wildcards in the directory, every directory
+// level from the last directory without wildcards down to the directory of
+// the files is watched, so new directories at any level trigger a rescan.
+// Patterns using `**` are expanded by the scanner into one pattern per depth
+// before, so their directories are watched up to the recursive glob depth.
+func watchDirs(patterns []string) map[string]struct{} {
+	dirs := map[string]struct{}{}
+	add := func(dir string) {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs[dir] = struct{}{}
+		}
+	}
+
+	for _, pattern := range patterns {
+		dir := filepath.Dir(pattern)
+		if !hasMeta(dir) {
+			add(dir)
+			continue
+		}
+
+		var levels []string
+		for ; hasMeta(dir); dir = filepath.Dir(dir) {
+			levels = append(levels, dir)
+		}
+		add(dir)
+		for _, level := range levels {
+			matches, _ := filepath.Glob(level)
+			for _, m := range matches {
+				add(m)
+			}
+		}
+	}
+	return dirs
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}
+
+// inotifyEvent is a change reported by inotify.
+type inotifyEvent struct {
+	// path is the file that changed.
+	path string
+	// rescan is true if changes cannot be tracked by file.
+	rescan bool
+}
+
+// inotify watches directories with inotify.
+type inotify struct {
+	log  *logp.Logger
+	fd   int
+	file *os.File
+	done chan struct{}
+
+	mu   sync.Mutex
+	dirs map[string]int
+	wds  map[int]string
+}
+
+func newInotify(log *logp.Logger) (*inotify, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize inotify: %w", err)
+	}
+	return &inotify{
+		log:  log,
+		fd:   fd,
+		file: os.NewFile(uintptr(fd), "inotify"),
+		done: make(chan struct{}),
+		dirs: map[string]int{},
+		wds:  map[int]string{},
+	}, nil
+}
+
+// watch sets the watched directories to dirs.
+func (n *inotify) watch(dirs map[string]struct{}) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for dir, wd := range n.dirs {
+		if _, ok := dirs[dir]; ok {
+			continue
+		}
+		n.log.Debugf("Stop watching directory %q", dir)
+		// the watch is already gone if the directory has been removed
+		_, _ = unix.InotifyRmWatch(n.fd, uint32(wd))
+		delete(n.dirs, dir)
+		delete(n.wds, wd)
+	}
+
+	for dir := range dirs {
+		if _, ok := n.dirs[dir]; ok {
+			continue
+		}
+		wd, err := unix.InotifyAddWatch(n.fd, dir, inotifyDirMask)
+		if err != nil {
+			n.log.Warnf("Failed to watch directory %q, changes are detected by the next scan: %v", dir, err)
+			continue
+		}
+		n.log.Debugf("Watching directory %q", dir)
+		n.dirs[dir] = wd
+		n.wds[wd] = dir
+	}
+}
+
+// run reads the inotify events in the background. The returned channel is
+// closed when reading fails or the watcher is closed.
+func (n *inotify) run() <-chan []inotifyEvent {
+	ch := make(chan []inotifyEvent)
+	go func() {
+		defer close(ch)
+
+		buf := make([]byte, inotifyBufferSize)
+		for {
+			size, err := n.file.Read(buf)
+			if err != nil {
+				if !errors.Is(err, os.ErrClosed) {
+					n.log.Errorf("Failed to read inotify events: %v", err)
+				}
+				return
+			}
+
+			batch := n.parse(buf[:size])
+			if len(batch) == 0 {
+				continue
+			}
+			select {
+			case ch <- batch:
+			case <-n.done:
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// parse translates raw inotify events.
+func (n *inotify) parse(buf []byte) []inotifyEvent {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	var events []inotifyEvent
+	for len(buf) >= unix.SizeofInotifyEvent {
+		wd := int(int32(binary.NativeEndian.Uint32(buf[0:4])))
+		mask := binary.NativeEndian.Uint32(buf[4:8])
+		nameLen := int(binary.NativeEndian.Uint32(buf[12:16]))
+		end := unix.SizeofInotifyEvent + nameLen
+		if end > len(buf) {
+			break
+		}
+		name := strings.TrimRight(string(buf[unix.SizeofInotifyEvent:end]), "\x00")
+		buf = buf[end:]
+
+		switch {
+		case mask&unix.IN_Q_OVERFLOW != 0:
+			n.log.Debug("inotify event queue overflowed")
+			events = append(events, inotifyEvent{rescan: true})
+
+		case mask&unix.IN_IGNORED != 0:
+			// the watch was removed, because the directory was deleted
+			if dir, ok := n.wds[wd]; ok {
+				delete(n.wds, wd)
+				delete(n.dirs, dir)
+			}
+			events = append(events, inotifyEvent{rescan: true})
+
+		case mask&(unix.IN_DELETE_SELF|unix.IN_MOVE_SELF) != 0:
+			events = append(events, inotifyEvent{rescan: true})
+
+		case mask&unix.IN_ISDIR != 0:
+			// created, moved or removed directories can change the files
+			// matching the paths
+			if mask&(unix.IN_CREATE|unix.IN_MOVED_FROM|unix.IN_MOVED_TO|unix.IN_DELETE) != 0 {
+				events = append(events, inotifyEvent{rescan: true})
+			}
+
+		default:
+			dir, ok := n.wds[wd]
+			if !ok || name == "" {
+				continue
+			}
+			events = append(events, inotifyEvent{path: filepath.Join(dir, name)})
+		}
+	}
+	return events
+}
+
+func (n *inotify) close() {
+	close(n.done)
+	n.file.Close()
+}
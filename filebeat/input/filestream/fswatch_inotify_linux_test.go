@@ -0,0 +1,270 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package filestream
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/filebeat/input/file"
+	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
+	commonfile "github.com/elastic/beats/v7/libbeat/common/file"
+)
+
+func TestInotifyWatcher(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "*.log")}
+	// full scans only run on startup, all changes must be reported by inotify
+	cfgStr := `
+scanner:
+  mode: inotify
+  check_interval: 1h
+  rescan_interval: 1h
+  resend_on_touch: true
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing := filepath.Join(dir, "existing.log")
+	require.NoError(t, os.WriteFile(existing, []byte("hello"), 0777))
+
+	fw := createWatcherWithConfig(t, paths, cfgStr)
+	go fw.Run(ctx)
+
+	t.Run("detects existing files on startup", func(t *testing.T) {
+		requireEqualEvents(t, loginp.FSEvent{
+			NewPath:    existing,
+			Op:         loginp.OpCreate,
+			Descriptor: descriptor(existing, 5),
+		}, fw.Event())
+	})
+
+	filename := filepath.Join(dir, "created.log")
+
+	t.Run("detects a new file", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filename, []byte("hello"), 0777))
+
+		requireEqualEvents(t, loginp.FSEvent{
+			NewPath:    filename,
+			Op:         loginp.OpCreate,
+			Descriptor: descriptor(filename, 5),
+		}, fw.Event())
+	})
+
+	t.Run("ignores files not matching the paths", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("hello"), 0777))
+	})
+
+	t.Run("detects a file write", func(t *testing.T) {
+		f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0777)
+		require.NoError(t, err)
+		_, err = f.WriteString("world")
+		require.NoError(t, err)
+		f.Close()
+
+		requireEqualEvents(t, loginp.FSEvent{
+			NewPath:    filename,
+			OldPath:    filename,
+			Op:         loginp.OpWrite,
+			Descriptor: descriptor(filename, 10),
+		}, fw.Event())
+	})
+
+	renamed := filepath.Join(dir, "renamed.log")
+
+	t.Run("detects a file rename", func(t *testing.T) {
+		require.NoError(t, os.Rename(filename, renamed))
+
+		requireEqualEvents(t, loginp.FSEvent{
+			NewPath:    renamed,
+			OldPath:    filename,
+			Op:         loginp.OpRename,
+			Descriptor: descriptor(renamed, 10),
+		}, fw.Event())
+	})
+
+	t.Run("detects a file truncate", func(t *testing.T) {
+		require.NoError(t, os.Truncate(renamed, 2))
+
+		requireEqualEvents(t, loginp.FSEvent{
+			NewPath:    renamed,
+			OldPath:    renamed,
+			Op:         loginp.OpTruncate,
+			Descriptor: descriptor(renamed, 2),
+		}, fw.Event())
+	})
+
+	t.Run("emits truncate on touch when resend_on_touch is enabled", func(t *testing.T) {
+		ts := time.Now().Local().Add(time.Hour)
+		require.NoError(t, os.Chtimes(renamed, ts, ts))
+
+		requireEqualEvents(t, loginp.FSEvent{
+			NewPath:    renamed,
+			OldPath:    renamed,
+			Op:         loginp.OpTruncate,
+			Descriptor: descriptor(renamed, 2),
+		}, fw.Event())
+	})
+
+	t.Run("detects a file remove", func(t *testing.T) {
+		require.NoError(t, os.Remove(renamed))
+
+		requireEqualEvents(t, loginp.FSEvent{
+			OldPath:    renamed,
+			Op:         loginp.OpDelete,
+			Descriptor: descriptor(renamed, 2),
+		}, fw.Event())
+	})
+}
+
+func TestInotifyWatcherNewDirectory(t *testing.T) {
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "*", "*.log")}
+	cfgStr := `
+scanner:
+  mode: inotify
+  check_interval: 1h
+  rescan_interval: 1h
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fw := createWatcherWithConfig(t, paths, cfgStr)
+	go fw.Run(ctx)
+
+	// wait for the initial scan
+	time.Sleep(100 * time.Millisecond)
+
+	// the new directory triggers a full scan, which finds the file and
+	// starts watching the directory
+	subdir := filepath.Join(dir, "app")
+	require.NoError(t, os.Mkdir(subdir, 0777))
+	filename := filepath.Join(subdir, "app.log")
+	require.NoError(t, os.WriteFile(filename, []byte("hello"), 0777))
+
+	requireEqualEvents(t, loginp.FSEvent{
+		NewPath:    filename,
+		Op:         loginp.OpCreate,
+		Descriptor: descriptor(filename, 5),
+	}, fw.Event())
+
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0777)
+	require.NoError(t, err)
+	_, err = f.WriteString("world")
+	require.NoError(t, err)
+	f.Close()
+
+	requireEqualEvents(t, loginp.FSEvent{
+		NewPath:    filename,
+		OldPath:    filename,
+		Op:         loginp.OpWrite,
+		Descriptor: descriptor(filename, 10),
+	}, fw.Event())
+}
+
+func TestWatchDirs(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "logs"), 0777))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "b", "logs"), 0777))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "c"), 0777))
+
+	dirs := watchDirs([]string{
+		filepath.Join(dir, "c", "*.log"),
+		filepath.Join(dir, "*", "logs", "*.log"),
+		filepath.Join(dir, "missing", "*.log"),
+	})
+	require.Equal(t, map[string]struct{}{
+		dir:                             {},
+		filepath.Join(dir, "a"):         {},
+		filepath.Join(dir, "a", "logs"): {},
+		filepath.Join(dir, "b"):         {},
+		filepath.Join(dir, "b", "logs"): {},
+		filepath.Join(dir, "c"):         {},
+	}, dirs)
+}
+
+func TestWatchDirsRecursiveGlob(t *testing.T) {
+	dir := t.TempDir()
+	deep := filepath.Join(dir, "a", "b", "c", "d")
+	require.NoError(t, os.MkdirAll(deep, 0777))
+
+	patterns, err := file.GlobPatterns(filepath.Join(dir, "**", "*.log"), RecursiveGlobDepth)
+	require.NoError(t, err)
+
+	dirs := watchDirs(patterns)
+	for _, d := range []string{
+		dir,
+		filepath.Join(dir, "a"),
+		filepath.Join(dir, "a", "b"),
+		filepath.Join(dir, "a", "b", "c"),
+		deep,
+	} {
+		assert.Contains(t, dirs, d)
+	}
+	assert.Len(t, dirs, 5)
+}
+
+func TestInotifyWatcherNewNestedDirectory(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0777))
+	paths := []string{filepath.Join(dir, "**", "*.log")}
+	cfgStr := `
+scanner:
+  mode: inotify
+  check_interval: 1h
+  rescan_interval: 1h
+`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fw := createWatcherWithConfig(t, paths, cfgStr)
+	go fw.Run(ctx)
+
+	// wait for the initial scan
+	time.Sleep(100 * time.Millisecond)
+
+	// a directory created below an existing nested directory is found
+	subdir := filepath.Join(dir, "a", "b", "c")
+	require.NoError(t, os.Mkdir(subdir, 0777))
+	filename := filepath.Join(subdir, "app.log")
+	require.NoError(t, os.WriteFile(filename, []byte("hello"), 0777))
+
+	requireEqualEvents(t, loginp.FSEvent{
+		NewPath:    filename,
+		Op:         loginp.OpCreate,
+		Descriptor: descriptor(filename, 5),
+	}, fw.Event())
+}
+
+func descriptor(filename string, size int64) loginp.FileDescriptor {
+	return loginp.FileDescriptor{
+		Filename: filename,
+		Info:     commonfile.ExtendFileInfo(&testFileInfo{name: filepath.Base(filename), size: size}),
+	}
+}
@@ -834,7 +834,7 @@ scanner:
 		err = ns.Unpack(cfg)
 		require.NoError(t, err)
 
-		_, err = newFileWatcher(paths, ns)
+		_, err = newFileWatcher(paths, ns, compressionNone)
 		require.Error(t, err)
 		require.Contains(t, err.Error(), "fingerprint size 1 bytes cannot be smaller than 64 bytes")
 	})
@@ -894,6 +894,16 @@ func BenchmarkGetFilesWithFingerprint(b *testing.B) {
 	}
 }
 
+func TestFileWatcherInvalidMode(t *testing.T) {
+	cfg, err := conf.NewConfigWithYAML([]byte("scanner.mode: fanotify"), "")
+	require.NoError(t, err)
+	ns := &conf.Namespace{}
+	require.NoError(t, ns.Unpack(cfg))
+
+	_, err = newFileWatcher([]string{"/var/log/*.log"}, ns, compressionNone)
+	require.ErrorContains(t, err, "invalid scanner mode")
+}
+
 func createWatcherWithConfig(t *testing.T, paths []string, cfgStr string) loginp.FSWatcher {
 	cfg, err := conf.NewConfigWithYAML([]byte(cfgStr), cfgStr)
 	require.NoError(t, err)
@@ -902,7 +912,7 @@ func createWatcherWithConfig(t *testing.T, paths []string, cfgStr string) loginp
 	err = ns.Unpack(cfg)
 	require.NoError(t, err)
 
-	fw, err := newFileWatcher(paths, ns)
+	fw, err := newFileWatcher(paths, ns, compressionNone)
 	require.NoError(t, err)
 
 	return fw
@@ -63,6 +63,7 @@ type filestream struct {
 	encodingFactory encoding.EncodingFactory
 	closerConfig    closerConfig
 	parsers         parser.Config
+	timeFilter      *timeFilter
 	takeOver        bool
 }
 
@@ -90,6 +91,11 @@ func configure(cfg *conf.C) (loginp.Prospector, loginp.Harvester, error) {
 		return nil, nil, err
 	}
 
+	// Reading the files once requires closing them at EOF.
+	if config.RunOnce {
+		config.Close.Reader.OnEOF = true
+	}
+
 	prospector, err := newProspector(config)
 	if err != nil {
 		return nil, nil, fmt.Errorf("cannot create prospector: %w", err)
@@ -100,11 +106,17 @@ func configure(cfg *conf.C) (loginp.Prospector, loginp.Harvester, error) {
 		return nil, nil, fmt.Errorf("unknown encoding('%v')", config.Reader.Encoding)
 	}
 
+	timeFilter, err := newTimeFilter(config.Reader.TimeFilter)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	filestream := &filestream{
 		readerConfig:    config.Reader,
 		encodingFactory: encodingFactory,
 		closerConfig:    config.Close,
 		parsers:         config.Reader.Parsers,
+		timeFilter:      timeFilter,
 		takeOver:        config.TakeOver,
 	}
 
@@ -193,7 +205,7 @@ func (inp *filestream) open(
 	offset int64,
 ) (reader.Reader, bool, error) {
 
-	f, encoding, truncated, err := inp.openFile(log, fs.newPath, offset)
+	f, dec, encoding, truncated, err := inp.openFile(log, fs.newPath, offset)
 	if err != nil {
 		return nil, truncated, err
 	}
@@ -204,13 +216,16 @@ func (inp *filestream) open(
 
 	ok := false // used for cleanup
 	defer cleanup.IfNot(&ok, cleanup.IgnoreError(f.Close))
+	if dec != nil {
+		defer cleanup.IfNot(&ok, cleanup.IgnoreError(dec.Close))
+	}
 
 	log.Debug("newLogFileReader with config.MaxBytes:", inp.readerConfig.MaxBytes)
 
-	// if the file is archived, it means that it is not going to be updated in the future
-	// thus, when EOF is reached, it can be closed
+	// if the file is archived or compressed, it means that it is not going to be
+	// updated in the future thus, when EOF is reached, it can be closed
 	closerCfg := inp.closerConfig
-	if fs.archived && !inp.closerConfig.Reader.OnEOF {
+	if (fs.archived || dec != nil) && !inp.closerConfig.Reader.OnEOF {
 		closerCfg = closerConfig{
 			Reader: readerCloserConfig{
 				OnEOF:         true,
@@ -222,9 +237,14 @@ func (inp *filestream) open(
 	// NewLineReader uses additional buffering to deal with encoding and testing
 	// for new lines in input stream. Simple 8-bit based encodings, or plain
 	// don't require 'complicated' logic.
-	logReader, err := newFileReader(log, canceler, f, inp.readerConfig, closerCfg)
-	if err != nil {
-		return nil, truncated, err
+	var logReader *logFile
+	if dec != nil {
+		logReader = newLogFile(log, canceler, f, dec, offset, inp.readerConfig, closerCfg)
+	} else {
+		logReader, err = newFileReader(log, canceler, f, inp.readerConfig, closerCfg)
+		if err != nil {
+			return nil, truncated, err
+		}
 	}
 
 	dbgReader, err := debug.AppendReaders(logReader)
@@ -267,38 +287,56 @@ func (inp *filestream) open(
 // the file system is scanned.
 //
 // openFile will also detect and hadle file truncation. If a file is truncated
-// then the 3rd return value is true.
+// then the 4th return value is true.
+//
+// If decompression is enabled and the file is compressed, the 2nd return value
+// is a reader of the decompressed content positioned at offset.
 func (inp *filestream) openFile(
 	log *logp.Logger,
 	path string,
 	offset int64,
-) (*os.File, encoding.Encoding, bool, error) {
+) (*os.File, io.ReadCloser, encoding.Encoding, bool, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
+		return nil, nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
 	}
 
 	// it must be checked if the file is not a named pipe before we try to open it
 	// if it is a named pipe os.OpenFile fails, so there is no need to try opening it.
 	if fi.Mode()&os.ModeNamedPipe != 0 {
-		return nil, nil, false, fmt.Errorf("failed to open file %s, named pipes are not supported", fi.Name())
+		return nil, nil, nil, false, fmt.Errorf("failed to open file %s, named pipes are not supported", fi.Name())
 	}
 
 	f, err := file.ReadOpen(path)
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("failed opening %s: %w", path, err)
+		return nil, nil, nil, false, fmt.Errorf("failed opening %s: %w", path, err)
 	}
 	ok := false
 	defer cleanup.IfNot(&ok, cleanup.IgnoreError(f.Close))
 
 	fi, err = f.Stat()
 	if err != nil {
-		return nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
+		return nil, nil, nil, false, fmt.Errorf("failed to stat source file %s: %w", path, err)
 	}
 
 	err = checkFileBeforeOpening(fi)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, nil, nil, false, err
+	}
+
+	if inp.readerConfig.Compression == compressionAuto {
+		format, err := detectCompression(f)
+		if err != nil {
+			return nil, nil, nil, false, fmt.Errorf("failed to detect compression of %s: %w", path, err)
+		}
+		if format != "" {
+			dec, encoding, err := inp.openCompressed(f, format, offset)
+			if err != nil {
+				return nil, nil, nil, false, err
+			}
+			ok = true // no need to close the file
+			return f, dec, encoding, false, nil
+		}
 	}
 
 	truncated := false
@@ -311,19 +349,45 @@ func (inp *filestream) openFile(
 	}
 	err = inp.initFileOffset(f, offset)
 	if err != nil {
-		return nil, nil, truncated, err
+		return nil, nil, nil, truncated, err
 	}
 
-	encoding, err := inp.encodingFactory(f)
+	encoding, err := inp.initEncoding(f)
 	if err != nil {
-		if errors.Is(err, transform.ErrShortSrc) {
-			return nil, nil, truncated, fmt.Errorf("initialising encoding for '%v' failed due to file being too short", f)
-		}
-		return nil, nil, truncated, fmt.Errorf("initialising encoding for '%v' failed: %w", f, err)
+		return nil, nil, nil, truncated, err
 	}
 
 	ok = true // no need to close the file
-	return f, encoding, truncated, nil
+	return f, nil, encoding, truncated, nil
+}
+
+// openCompressed opens the decompressed content of f and checks for the encoding.
+// The size of a compressed file says nothing about the size of its content,
+// so truncation is not detected for compressed files.
+func (inp *filestream) openCompressed(f *os.File, format string, offset int64) (io.ReadCloser, encoding.Encoding, error) {
+	dec, err := openDecompressor(f, format, offset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s compressed file %s: %w", format, f.Name(), err)
+	}
+
+	encoding, err := inp.initEncoding(dec)
+	if err != nil {
+		dec.Close()
+		return nil, nil, err
+	}
+
+	return dec, encoding, nil
+}
+
+func (inp *filestream) initEncoding(r io.Reader) (encoding.Encoding, error) {
+	encoding, err := inp.encodingFactory(r)
+	if err != nil {
+		if errors.Is(err, transform.ErrShortSrc) {
+			return nil, fmt.Errorf("initialising encoding for '%v' failed due to file being too short", r)
+		}
+		return nil, fmt.Errorf("initialising encoding for '%v' failed: %w", r, err)
+	}
+	return encoding, nil
 }
 
 func checkFileBeforeOpening(fi os.FileInfo) error {
@@ -381,7 +445,7 @@ func (inp *filestream) readFromSource(
 		s.Offset += int64(message.Bytes) + int64(message.Offset)
 
 		metrics.MessagesRead.Inc()
-		if message.IsEmpty() || inp.isDroppedLine(log, string(message.Content)) {
+		if message.IsEmpty() || inp.isDroppedLine(log, string(message.Content)) || inp.isOutOfTimeRange(log, message) {
 			continue
 		}
 
@@ -422,6 +486,19 @@ func (inp *filestream) isDroppedLine(log *logp.Logger, line string) bool {
 	return false
 }
 
+// isOutOfTimeRange decides if the line is dropped because its timestamp is
+// out of the range of the time_filter options.
+func (inp *filestream) isOutOfTimeRange(log *logp.Logger, message reader.Message) bool {
+	if inp.timeFilter == nil {
+		return false
+	}
+	drop, err := inp.timeFilter.drop(message)
+	if err != nil {
+		log.Debugf("Cannot read timestamp of line: %v", err)
+	}
+	return drop
+}
+
 func matchAny(matchers []match.Matcher, text string) bool {
 	for _, m := range matchers {
 		if m.MatchString(text) {
@@ -202,6 +202,35 @@ func TestFilestreamCloseEOF(t *testing.T) {
 	env.requireOffsetInRegistry(testlogName, id, expectedOffset)
 }
 
+func TestFilestreamRunOnce(t *testing.T) {
+	env := newInputTestingEnvironment(t)
+
+	id := "fake-ID-" + uuid.Must(uuid.NewV4()).String()
+	inp := env.mustCreateInput(map[string]interface{}{
+		"id":       id,
+		"paths":    []string{env.abspath("*.log")},
+		"run_once": true,
+		"parsers": []map[string]interface{}{
+			{
+				"ndjson": map[string]interface{}{
+					"target": "",
+				},
+			},
+		},
+		"time_filter.start_time": "2024-05-01T00:00:00Z",
+		"time_filter.field":      "time",
+	})
+
+	env.mustWriteToFile("first.log", []byte(`{"time":"2024-04-30T23:59:59Z","message":"old"}`+"\n"+`{"time":"2024-05-01T00:00:00Z","message":"new"}`+"\n"))
+	env.mustWriteToFile("second.log", []byte(`{"time":"2024-05-02T00:00:00Z","message":"newer"}`+"\n"))
+
+	// The input stops by itself once all files have been read.
+	env.startInput(context.Background(), inp)
+	env.waitUntilInputStops()
+
+	require.ElementsMatch(t, []string{"new", "newer"}, env.getOutputMessages())
+}
+
 // test_empty_lines from test_harvester.py
 func TestFilestreamEmptyLine(t *testing.T) {
 	env := newInputTestingEnvironment(t)
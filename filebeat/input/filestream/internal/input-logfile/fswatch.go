@@ -64,6 +64,9 @@ type FileDescriptor struct {
 	Info file.ExtendedFileInfo
 	// Fingerprint is a computed hash of the file header
 	Fingerprint string
+	// Compression is the compression format of the file if it is read
+	// decompressed, it is empty otherwise.
+	Compression string
 }
 
 // FileID returns a unique file ID
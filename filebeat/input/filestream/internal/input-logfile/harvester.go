@@ -115,6 +115,8 @@ type HarvesterGroup interface {
 	Stop(Source)
 	// StopHarvesters cancels all running Harvesters.
 	StopHarvesters() error
+	// Wait waits until all running Harvesters have returned.
+	Wait()
 }
 
 type defaultHarvesterGroup struct {
@@ -295,6 +297,12 @@ func (hg *defaultHarvesterGroup) Stop(s Source) {
 	})
 }
 
+// Wait waits until all running Harvesters have returned. Harvesters are not
+// cancelled, Wait only returns once they stopped by themselves.
+func (hg *defaultHarvesterGroup) Wait() {
+	hg.tg.Wait()
+}
+
 // StopHarvesters stops all running Harvesters.
 func (hg *defaultHarvesterGroup) StopHarvesters() error {
 	return hg.tg.Stop()
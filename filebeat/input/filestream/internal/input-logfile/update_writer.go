@@ -156,6 +156,7 @@ func (ch *updateChan) Recv(ctx context.Context) ([]scheduledUpdate, error) {
 		}
 	}
 
+	ch.mutex.Unlock()
 	return nil, ctx.Err()
 }
 
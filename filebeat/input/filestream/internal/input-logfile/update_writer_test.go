@@ -110,6 +110,28 @@ func TestUpdateChan_SendRecv(t *testing.T) {
 		assert.Equal(t, ctx.Err(), err)
 	})
 
+	t.Run("channel is usable after cancelled read", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.TODO())
+		cancel()
+
+		ch := newUpdateChan()
+		_, err := ch.Recv(ctx)
+		require.Equal(t, ctx.Err(), err)
+
+		// Recv must release the lock when returning early.
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			ch.Send(scheduledUpdate{op: makeTestUpdateOp("test"), n: 1})
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("send blocked after cancelled read")
+		}
+		assert.Len(t, ch.TryRecv(), 1)
+	})
+
 	t.Run("wait for send", func(t *testing.T) {
 		ch := newUpdateChan()
 
@@ -120,6 +120,12 @@ func (g *Group) Go(fn func(context.Context) error) error {
 	return nil
 }
 
+// Wait waits until all running tasks have finished, without stopping the
+// task group.
+func (g *Group) Wait() {
+	g.wg.Wait()
+}
+
 // Stop stops the task group accepting new goroutines and waits until all
 // running tasks to finish or the stop timeout to elapse, whatever
 // happens first. It returns an error if the timout is reached, nil otherwise.
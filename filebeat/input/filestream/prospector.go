@@ -20,6 +20,7 @@ package filestream
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"time"
 
 	loginp "github.com/elastic/beats/v7/filebeat/input/filestream/internal/input-logfile"
@@ -58,6 +59,7 @@ type fileProspector struct {
 	ignoreInactiveSince ignoreInactiveType
 	cleanRemoved        bool
 	stateChangeCloser   stateChangeCloserConfig
+	runOnce             bool
 }
 
 func (p *fileProspector) Init(
@@ -134,6 +136,11 @@ func (p *fileProspector) Run(ctx input.Context, s loginp.StateMetadataUpdater, h
 
 	defer p.stopHarvesterGroup(log, hg)
 
+	if p.runOnce {
+		p.readOnce(log, ctx, s, hg)
+		return
+	}
+
 	var tg unison.MultiErrGroup
 
 	tg.Go(func() error {
@@ -163,6 +170,36 @@ func (p *fileProspector) Run(ctx input.Context, s loginp.StateMetadataUpdater, h
 	}
 }
 
+// readOnce starts a Harvester for each file found by a single scan and waits
+// until all of them have reached EOF.
+func (p *fileProspector) readOnce(log *logp.Logger, ctx input.Context, s loginp.StateMetadataUpdater, hg loginp.HarvesterGroup) {
+	ignoreInactiveSince := getIgnoreSince(p.ignoreInactiveSince, ctx.Agent)
+
+	files := p.filewatcher.GetFiles()
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if ctx.Cancelation.Err() != nil {
+			return
+		}
+		fd := files[path]
+		if fd.Info.Size() == 0 {
+			continue
+		}
+
+		fe := createEvent(path, fd)
+		src := p.identifier.GetSource(fe)
+		p.onFSEvent(loggerWithEvent(log, fe, src), ctx, fe, src, s, hg, ignoreInactiveSince)
+	}
+
+	hg.Wait()
+	log.Infof("Finished reading %d files once", len(paths))
+}
+
 func (p *fileProspector) onFSEvent(
 	log *logp.Logger,
 	ctx input.Context,
@@ -280,6 +317,15 @@ func (p *fileProspector) onRename(log *logp.Logger, ctx input.Context, fe loginp
 			log.Errorf("Failed to update cursor meta data of entry %s: %v", src.Name(), err)
 		}
 
+		// the file was compressed after rotation, the harvester of the original
+		// file is restarted, so the rest of the file is read from the
+		// decompressed content starting at the last published offset.
+		if fe.Descriptor.Compression != "" {
+			log.Debugf("File %s has been compressed into %s, restarting harvester", fe.OldPath, fe.NewPath)
+			hg.Restart(ctx, src)
+			return
+		}
+
 		if p.stateChangeCloser.Renamed {
 			log.Debugf("Stopping harvester as file %s has been renamed and close.on_state_change.renamed is enabled.", src.Name())
 
@@ -260,6 +260,32 @@ func TestProspectorNewAndUpdatedFiles(t *testing.T) {
 	}
 }
 
+func TestProspectorRunOnce(t *testing.T) {
+	now := time.Now()
+	p := fileProspector{
+		filewatcher: newMockFileWatcherWithFiles(map[string]loginp.FileDescriptor{
+			"/path/to/b": createTestFileDescriptorWithInfo(&testFileInfo{"/path/to/b", 5, now, nil}),
+			"/path/to/a": createTestFileDescriptorWithInfo(&testFileInfo{"/path/to/a", 5, now, nil}),
+			"/path/to/c": createTestFileDescriptorWithInfo(&testFileInfo{"/path/to/c", 0, now, nil}),
+		}),
+		identifier: mustPathIdentifier(false),
+		runOnce:    true,
+	}
+	ctx := input.Context{Logger: logp.L(), Cancelation: context.Background()}
+	hg := newTestHarvesterGroup()
+
+	p.Run(ctx, newMockMetadataUpdater(), hg)
+
+	// Empty files are skipped, the prospector waits for the harvesters to
+	// finish before returning.
+	assert.Equal(t, []harvesterEvent{
+		harvesterStart("path::/path/to/a"),
+		harvesterStart("path::/path/to/b"),
+		harvesterGroupWait{},
+		harvesterGroupStop{},
+	}, hg.events)
+}
+
 // TestProspectorHarvesterUpdateIgnoredFiles checks if the prospector can
 // save the size of an ignored file to the registry. If the ignored
 // file is updated, and has to be collected, a new harvester is started.
@@ -421,6 +447,22 @@ func TestProspectorRenamedFile(t *testing.T) {
 				harvesterGroupStop{},
 			},
 		},
+		"one compressed file with rename tracker": {
+			events: []loginp.FSEvent{
+				{
+					Op:         loginp.OpRename,
+					OldPath:    "/old/path/to/file",
+					NewPath:    "/old/path/to/file.gz",
+					Descriptor: createTestCompressedFileDescriptor(),
+				},
+			},
+			trackRename:  true,
+			closeRenamed: true,
+			expectedEvents: []harvesterEvent{
+				harvesterRestart("path::/old/path/to/file.gz"),
+				harvesterGroupStop{},
+			},
+		},
 	}
 
 	for name, test := range testCases {
@@ -474,6 +516,10 @@ type harvesterGroupStop struct{}
 
 func (h harvesterGroupStop) String() string { return "stop" }
 
+type harvesterGroupWait struct{}
+
+func (h harvesterGroupWait) String() string { return "wait" }
+
 type testHarvesterGroup struct {
 	events []harvesterEvent
 }
@@ -498,6 +544,10 @@ func (t *testHarvesterGroup) Stop(s loginp.Source) {
 	t.events = append(t.events, harvesterStop(s.Name()))
 }
 
+func (t *testHarvesterGroup) Wait() {
+	t.events = append(t.events, harvesterGroupWait{})
+}
+
 func (t *testHarvesterGroup) StopHarvesters() error {
 	t.events = append(t.events, harvesterGroupStop{})
 	return nil
@@ -742,6 +792,12 @@ func createTestFileDescriptor() loginp.FileDescriptor {
 	return createTestFileDescriptorWithInfo(&testFileInfo{})
 }
 
+func createTestCompressedFileDescriptor() loginp.FileDescriptor {
+	fd := createTestFileDescriptor()
+	fd.Compression = compressionGzip
+	return fd
+}
+
 func createTestFileDescriptorWithInfo(fi fs.FileInfo) loginp.FileDescriptor {
 	return loginp.FileDescriptor{
 		Info:        file.ExtendFileInfo(fi),
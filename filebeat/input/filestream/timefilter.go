@@ -0,0 +1,186 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package filestream
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/beats/v7/libbeat/reader"
+)
+
+const (
+	timeLayoutUnix   = "UNIX"
+	timeLayoutUnixMs = "UNIX_MS"
+)
+
+// timeFilterConfig configures the filtering of lines by their timestamp.
+// Lines are only published if their timestamp is between StartTime and
+// EndTime.
+type timeFilterConfig struct {
+	StartTime string `config:"start_time"`
+	EndTime   string `config:"end_time"`
+	// Field is the field holding the timestamp of the line, usually set by
+	// a parser. `message` refers to the content of the line.
+	Field        string            `config:"field"`
+	Layouts      []string          `config:"layouts"`
+	Timezone     *cfgtype.Timezone `config:"timezone"`
+	DropUnparsed bool              `config:"drop_unparsed"`
+}
+
+func defaultTimeFilterConfig() timeFilterConfig {
+	return timeFilterConfig{
+		Layouts: []string{time.RFC3339},
+	}
+}
+
+func (c *timeFilterConfig) Validate() error {
+	_, err := newTimeFilter(*c)
+	return err
+}
+
+// timeFilter drops the lines with a timestamp before start or after end.
+type timeFilter struct {
+	start, end   time.Time
+	field        string
+	layouts      []string
+	loc          *time.Location
+	dropUnparsed bool
+}
+
+// newTimeFilter returns the time filter of the configuration, or nil if
+// neither start_time nor end_time is set.
+func newTimeFilter(c timeFilterConfig) (*timeFilter, error) {
+	if c.StartTime == "" && c.EndTime == "" {
+		return nil, nil
+	}
+	if c.Field == "" {
+		return nil, errors.New("time_filter.field must be set when time_filter.start_time or time_filter.end_time is set")
+	}
+	if len(c.Layouts) == 0 {
+		return nil, errors.New("time_filter.layouts must be set when time_filter.field is set")
+	}
+
+	f := &timeFilter{
+		field:        c.Field,
+		layouts:      c.Layouts,
+		loc:          c.Timezone.Location(),
+		dropUnparsed: c.DropUnparsed,
+	}
+	var err error
+	if f.start, err = parseTimeBound(c.StartTime, f.loc); err != nil {
+		return nil, fmt.Errorf("invalid time_filter.start_time: %w", err)
+	}
+	if f.end, err = parseTimeBound(c.EndTime, f.loc); err != nil {
+		return nil, fmt.Errorf("invalid time_filter.end_time: %w", err)
+	}
+	if !f.start.IsZero() && !f.end.IsZero() && !f.start.Before(f.end) {
+		return nil, errors.New("time_filter.start_time must be before time_filter.end_time")
+	}
+	return f, nil
+}
+
+// parseTimeBound parses an RFC3339 time or a date. It returns the zero time
+// if s is empty.
+func parseTimeBound(s string, loc *time.Location) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation(time.DateOnly, s, loc)
+}
+
+// drop reports whether the message must be dropped because its timestamp is
+// out of the configured range. Messages whose timestamp cannot be read are
+// dropped only if drop_unparsed is set, the error is returned to be logged.
+func (f *timeFilter) drop(m reader.Message) (bool, error) {
+	ts, err := f.timestamp(m)
+	if err != nil {
+		return f.dropUnparsed, err
+	}
+	if !f.start.IsZero() && ts.Before(f.start) {
+		return true, nil
+	}
+	if !f.end.IsZero() && ts.After(f.end) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f *timeFilter) timestamp(m reader.Message) (time.Time, error) {
+	var value interface{}
+	switch f.field {
+	case "message":
+		value = string(m.Content)
+	default:
+		v, err := m.Fields.GetValue(f.field)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to get time field %s: %w", f.field, err)
+		}
+		value = v
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case common.Time:
+		return time.Time(v), nil
+	}
+
+	var errs []error
+	for _, layout := range f.layouts {
+		ts, err := f.parse(value, layout)
+		if err == nil {
+			return ts, nil
+		}
+		errs = append(errs, err)
+	}
+	return time.Time{}, fmt.Errorf("failed to parse time field %s: %w", f.field, errors.Join(errs...))
+}
+
+func (f *timeFilter) parse(value interface{}, layout string) (time.Time, error) {
+	switch layout {
+	case timeLayoutUnix, timeLayoutUnixMs:
+		var n float64
+		if i, ok := common.TryToInt(value); ok {
+			n = float64(i)
+		} else if n, ok = common.TryToFloat64(value); !ok {
+			return time.Time{}, errors.New("could not parse time field as int or float")
+		}
+		if layout == timeLayoutUnixMs {
+			return time.Unix(0, int64(n*float64(time.Millisecond))), nil
+		}
+		return time.Unix(0, int64(n*float64(time.Second))), nil
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return time.Time{}, fmt.Errorf("unexpected type %T for time field", value)
+		}
+		ts, err := time.ParseInLocation(layout, s, f.loc)
+		if err == nil && ts.Year() == 0 {
+			// Use the current year for layouts without year.
+			ts = ts.AddDate(time.Now().In(ts.Location()).Year(), 0, 0)
+		}
+		return ts, err
+	}
+}
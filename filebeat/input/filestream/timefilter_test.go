@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package filestream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/beats/v7/libbeat/reader"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestTimeFilterConfig(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"disabled": {
+			config: map[string]interface{}{},
+		},
+		"rfc3339 and date": {
+			config: map[string]interface{}{
+				"time_filter.start_time": "2024-05-01T00:00:00Z",
+				"time_filter.end_time":   "2024-05-02",
+				"time_filter.field":      "time",
+			},
+		},
+		"missing field": {
+			config: map[string]interface{}{"time_filter.start_time": "2024-05-01"},
+			err:    "time_filter.field must be set",
+		},
+		"invalid start_time": {
+			config: map[string]interface{}{
+				"time_filter.start_time": "yesterday",
+				"time_filter.field":      "time",
+			},
+			err: "invalid time_filter.start_time",
+		},
+		"end_time before start_time": {
+			config: map[string]interface{}{
+				"time_filter.start_time": "2024-05-02",
+				"time_filter.end_time":   "2024-05-01",
+				"time_filter.field":      "time",
+			},
+			err: "time_filter.start_time must be before time_filter.end_time",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := conf.MustNewConfigFrom(test.config)
+			require.NoError(t, c.SetString("paths", 0, "/var/log/*.log"))
+			config := defaultConfig()
+			err := c.Unpack(&config)
+			if test.err != "" {
+				require.ErrorContains(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTimeFilterDrop(t *testing.T) {
+	tests := map[string]struct {
+		config   timeFilterConfig
+		message  reader.Message
+		drop     bool
+		parseErr bool
+	}{
+		"timestamp in range": {
+			config:  timeFilterConfig{StartTime: "2024-05-01", Field: "time", Layouts: []string{time.RFC3339}},
+			message: reader.Message{Fields: mapstr.M{"time": time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}},
+		},
+		"timestamp before start_time": {
+			config:  timeFilterConfig{StartTime: "2024-05-01", Field: "time", Layouts: []string{time.RFC3339}},
+			message: reader.Message{Fields: mapstr.M{"time": time.Date(2024, 4, 30, 23, 59, 0, 0, time.UTC)}},
+			drop:    true,
+		},
+		"field after end_time": {
+			config: timeFilterConfig{
+				EndTime: "2024-05-01T00:00:00Z",
+				Field:   "log.time",
+				Layouts: []string{time.RFC3339},
+			},
+			message: reader.Message{Fields: mapstr.M{"log": mapstr.M{"time": "2024-05-01T00:00:01Z"}}},
+			drop:    true,
+		},
+		"second layout and timezone": {
+			config: timeFilterConfig{
+				StartTime: "2024-05-01T00:00:00Z",
+				Field:     "time",
+				Layouts:   []string{time.RFC3339, time.DateTime},
+				Timezone:  mustTimezone(t, "+0200"),
+			},
+			// 2024-04-30T23:30:00Z
+			message: reader.Message{Fields: mapstr.M{"time": "2024-05-01 01:30:00"}},
+			drop:    true,
+		},
+		"unix milliseconds": {
+			config: timeFilterConfig{
+				StartTime: "2024-05-01T00:00:00Z",
+				Field:     "ts",
+				Layouts:   []string{timeLayoutUnixMs},
+			},
+			message: reader.Message{Fields: mapstr.M{"ts": int64(1714521600001)}},
+		},
+		"whole message": {
+			config: timeFilterConfig{
+				StartTime: "2024-05-01T00:00:00Z",
+				Field:     "message",
+				Layouts:   []string{time.RFC3339},
+			},
+			message: reader.Message{Content: []byte("2024-04-01T00:00:00Z")},
+			drop:    true,
+		},
+		"unparsed is kept": {
+			config:   timeFilterConfig{StartTime: "2024-05-01", Field: "time", Layouts: []string{time.RFC3339}},
+			message:  reader.Message{Fields: mapstr.M{"time": "not a time"}},
+			parseErr: true,
+		},
+		"missing field with drop_unparsed": {
+			config:   timeFilterConfig{StartTime: "2024-05-01", Field: "time", Layouts: []string{time.RFC3339}, DropUnparsed: true},
+			message:  reader.Message{Fields: mapstr.M{}},
+			drop:     true,
+			parseErr: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, err := newTimeFilter(test.config)
+			require.NoError(t, err)
+
+			drop, err := f.drop(test.message)
+			assert.Equal(t, test.drop, drop)
+			assert.Equal(t, test.parseErr, err != nil, "unexpected error: %v", err)
+		})
+	}
+}
+
+func mustTimezone(t *testing.T, tz string) *cfgtype.Timezone {
+	loc, err := cfgtype.NewTimezone(tz)
+	require.NoError(t, err)
+	return loc
+}
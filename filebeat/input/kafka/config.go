@@ -53,6 +53,7 @@ type kafkaInputConfig struct {
 	Password                 string            `config:"password"`
 	Sasl                     kafka.SaslConfig  `config:"sasl"`
 	ExpandEventListFromField string            `config:"expand_event_list_from_field"`
+	Decoding                 *decodingConfig   `config:"decoding"`
 	Parsers                  parser.Config     `config:",inline"`
 }
 
@@ -144,6 +145,10 @@ func (c *kafkaInputConfig) Validate() error {
 	if c.Username != "" && c.Password == "" {
 		return fmt.Errorf("password must be set when username is configured")
 	}
+
+	if c.Decoding != nil && c.ExpandEventListFromField != "" {
+		return errors.New("decoding cannot be used with expand_event_list_from_field")
+	}
 	return nil
 }
 
@@ -0,0 +1,390 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/elastic/beats/v7/libbeat/common/avro"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+// decodingConfig configures the decoding of records encoded with the
+// Confluent wire format, where the value starts with a magic byte and the
+// ID of the schema in the schema registry.
+type decodingConfig struct {
+	SchemaRegistry *schemaRegistryConfig `config:"schema_registry"`
+	// SchemaDir is a directory of schemas named after their ID, such as
+	// 42.avsc for Avro schemas or 42.json for JSON schemas.
+	SchemaDir string `config:"schema_dir"`
+	// Target is the field the decoded record is stored under. If empty, the
+	// record is stored at the root of the event.
+	Target string `config:"target"`
+	// IncludeHeaders adds the record headers to the kafka.header field.
+	IncludeHeaders bool `config:"include_headers"`
+}
+
+type schemaRegistryConfig struct {
+	URL      string            `config:"url" validate:"required"`
+	Username string            `config:"username"`
+	Password string            `config:"password"`
+	TLS      *tlscommon.Config `config:"ssl"`
+	Timeout  time.Duration     `config:"timeout" validate:"positive"`
+}
+
+// Validate checks a schema source is configured.
+func (c *decodingConfig) Validate() error {
+	if c.SchemaRegistry == nil && c.SchemaDir == "" {
+		return errors.New("decoding requires schema_registry.url or schema_dir")
+	}
+	if c.SchemaRegistry != nil {
+		if _, err := url.Parse(c.SchemaRegistry.URL); err != nil {
+			return fmt.Errorf("invalid schema_registry.url: %w", err)
+		}
+	}
+	return nil
+}
+
+// Schema types of the schema registry.
+const (
+	schemaTypeAvro     = "AVRO"
+	schemaTypeJSON     = "JSON"
+	schemaTypeProtobuf = "PROTOBUF"
+)
+
+// wireFormatMagic is the first byte of values in the Confluent wire format.
+const wireFormatMagic = 0x0
+
+// schemaRetryInterval is the time before retrying to fetch a schema that
+// could not be fetched.
+const schemaRetryInterval = time.Minute
+
+var errNotWireFormat = errors.New("value is not encoded with the schema registry wire format")
+
+type schema struct {
+	schemaType string
+	avro       *avro.Schema
+	protobuf   protoreflect.FileDescriptor
+}
+
+// schemaSource fetches schemas by ID. The definition of Protobuf schemas is a
+// serialized FileDescriptorSet whose last file is the schema, preceded by the
+// files it imports.
+type schemaSource interface {
+	fetch(id uint32) (schemaType string, definition string, err error)
+}
+
+// recordDecoder decodes record values with the schemas of a schema source.
+// It is safe for concurrent use by the partition consumers.
+type recordDecoder struct {
+	sources []schemaSource
+	target  string
+
+	mu       sync.Mutex
+	schemas  map[uint32]*schema
+	failures map[uint32]time.Time
+}
+
+func newRecordDecoder(c *decodingConfig) (*recordDecoder, error) {
+	d := &recordDecoder{
+		target:   c.Target,
+		schemas:  map[uint32]*schema{},
+		failures: map[uint32]time.Time{},
+	}
+	if c.SchemaDir != "" {
+		d.sources = append(d.sources, schemaDir(c.SchemaDir))
+	}
+	if c.SchemaRegistry != nil {
+		r, err := newSchemaRegistry(c.SchemaRegistry)
+		if err != nil {
+			return nil, err
+		}
+		d.sources = append(d.sources, r)
+	}
+	return d, nil
+}
+
+// decode returns the ID of the schema of the value and the decoded fields.
+func (d *recordDecoder) decode(value []byte) (uint32, mapstr.M, error) {
+	if len(value) < 5 || value[0] != wireFormatMagic {
+		return 0, nil, errNotWireFormat
+	}
+	id := binary.BigEndian.Uint32(value[1:5])
+	s, err := d.schema(id)
+	if err != nil {
+		return id, nil, err
+	}
+
+	var v interface{}
+	switch s.schemaType {
+	case schemaTypeAvro:
+		v, err = s.avro.Decode(value[5:])
+	case schemaTypeJSON:
+		err = json.Unmarshal(value[5:], &v)
+	case schemaTypeProtobuf:
+		v, err = decodeProtobuf(s.protobuf, value[5:])
+	default:
+		err = fmt.Errorf("unsupported schema type %s", s.schemaType)
+	}
+	if err != nil {
+		return id, nil, fmt.Errorf("failed to decode value with schema %d: %w", id, err)
+	}
+
+	record, ok := toMapStr(v)
+	if !ok {
+		record = mapstr.M{"value": v}
+	}
+	if d.target == "" {
+		return id, record, nil
+	}
+	fields := mapstr.M{}
+	_, _ = fields.Put(d.target, record)
+	return id, fields, nil
+}
+
+func toMapStr(v interface{}) (mapstr.M, bool) {
+	switch v := v.(type) {
+	case mapstr.M:
+		return v, true
+	case map[string]interface{}:
+		return mapstr.M(v), true
+	}
+	return nil, false
+}
+
+// schema returns the schema of an ID, fetching it the first time it is used.
+func (d *recordDecoder) schema(id uint32) (*schema, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s, ok := d.schemas[id]; ok {
+		return s, nil
+	}
+	if failed, ok := d.failures[id]; ok && time.Since(failed) < schemaRetryInterval {
+		return nil, fmt.Errorf("schema %d is not available", id)
+	}
+
+	s, err := d.fetch(id)
+	if err != nil {
+		d.failures[id] = time.Now()
+		return nil, err
+	}
+	delete(d.failures, id)
+	d.schemas[id] = s
+	return s, nil
+}
+
+func (d *recordDecoder) fetch(id uint32) (*schema, error) {
+	var errs []error
+	for _, source := range d.sources {
+		schemaType, definition, err := source.fetch(id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		s := &schema{schemaType: schemaType}
+		switch schemaType {
+		case schemaTypeAvro:
+			s.avro, err = avro.Parse(definition)
+			if err != nil {
+				return nil, fmt.Errorf("schema %d: %w", id, err)
+			}
+		case schemaTypeJSON:
+		case schemaTypeProtobuf:
+			s.protobuf, err = parseProtobufSchema(definition)
+			if err != nil {
+				return nil, fmt.Errorf("schema %d: %w", id, err)
+			}
+		default:
+			return nil, fmt.Errorf("schema %d: unknown schema type %q", id, schemaType)
+		}
+		return s, nil
+	}
+	return nil, fmt.Errorf("failed to fetch schema %d: %w", id, errors.Join(errs...))
+}
+
+// schemaDir reads schemas from files named after their ID.
+type schemaDir string
+
+func (dir schemaDir) fetch(id uint32) (string, string, error) {
+	for _, f := range []struct{ ext, schemaType string }{
+		{".avsc", schemaTypeAvro},
+		{".json", schemaTypeJSON},
+	} {
+		b, err := os.ReadFile(filepath.Join(string(dir), strconv.FormatUint(uint64(id), 10)+f.ext))
+		if err == nil {
+			return f.schemaType, string(b), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", "", err
+		}
+	}
+	return "", "", fmt.Errorf("schema %d not found in %s", id, dir)
+}
+
+// schemaRegistry fetches schemas from a Confluent compatible schema registry.
+type schemaRegistry struct {
+	url      string
+	username string
+	password string
+	client   *http.Client
+}
+
+func newSchemaRegistry(c *schemaRegistryConfig) (*schemaRegistry, error) {
+	tls, err := tlscommon.LoadTLSConfig(c.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load schema_registry.ssl: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if tls != nil {
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tls.BuildModuleClientConfig(u.Hostname())
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	return &schemaRegistry{
+		url:      strings.TrimSuffix(c.URL, "/"),
+		username: c.Username,
+		password: c.Password,
+		client:   &http.Client{Transport: transport, Timeout: timeout},
+	}, nil
+}
+
+// registrySchema is a schema returned by the schema registry.
+type registrySchema struct {
+	Schema     string `json:"schema"`
+	SchemaType string `json:"schemaType"`
+	References []struct {
+		Name    string `json:"name"`
+		Subject string `json:"subject"`
+		Version int    `json:"version"`
+	} `json:"references"`
+}
+
+func (r *schemaRegistry) fetch(id uint32) (string, string, error) {
+	path := fmt.Sprintf("/schemas/ids/%d", id)
+	var result registrySchema
+	if err := r.get(path, &result); err != nil {
+		return "", "", err
+	}
+	switch result.SchemaType {
+	case "":
+		// The schema type is omitted for Avro schemas.
+		return schemaTypeAvro, result.Schema, nil
+	case schemaTypeProtobuf:
+		// Protobuf schemas are fetched again as serialized file descriptors,
+		// together with the schemas they import.
+		set := &descriptorpb.FileDescriptorSet{}
+		if err := r.fetchProtobuf(path, fmt.Sprintf("%d.proto", id), set, map[string]bool{}); err != nil {
+			return "", "", err
+		}
+		b, err := proto.Marshal(set)
+		if err != nil {
+			return "", "", err
+		}
+		return schemaTypeProtobuf, string(b), nil
+	}
+	return result.SchemaType, result.Schema, nil
+}
+
+// fetchProtobuf adds the file descriptor of the Protobuf schema at path to
+// set, after the descriptors of its references.
+func (r *schemaRegistry) fetchProtobuf(path, name string, set *descriptorpb.FileDescriptorSet, seen map[string]bool) error {
+	var result registrySchema
+	if err := r.get(path+"?format=serialized", &result); err != nil {
+		return err
+	}
+	for _, ref := range result.References {
+		if seen[ref.Name] {
+			continue
+		}
+		seen[ref.Name] = true
+		refPath := fmt.Sprintf("/subjects/%s/versions/%d", url.PathEscape(ref.Subject), ref.Version)
+		if err := r.fetchProtobuf(refPath, ref.Name, set, seen); err != nil {
+			return fmt.Errorf("failed to fetch reference %s: %w", ref.Name, err)
+		}
+	}
+
+	b, err := base64.StdEncoding.DecodeString(result.Schema)
+	if err != nil {
+		return fmt.Errorf("invalid serialized protobuf schema: %w", err)
+	}
+	fd := &descriptorpb.FileDescriptorProto{}
+	if err := proto.Unmarshal(b, fd); err != nil {
+		return fmt.Errorf("invalid serialized protobuf schema: %w", err)
+	}
+	// Imports refer to references by their name.
+	fd.Name = &name
+	set.File = append(set.File, fd)
+	return nil
+}
+
+// get requests path from the schema registry and decodes the response into v.
+func (r *schemaRegistry) get(path string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, r.url+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json, application/json")
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("schema registry returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid schema registry response: %w", err)
+	}
+	return nil
+}
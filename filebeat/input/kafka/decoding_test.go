@@ -0,0 +1,370 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/sarama"
+)
+
+const userSchema = `{"type": "record", "name": "User", "namespace": "com.example", "fields": [
+	{"name": "name", "type": "string"},
+	{"name": "age", "type": ["null", "int"]}
+]}`
+
+// wireFormat encodes a value with the schema registry wire format.
+func wireFormat(id uint32, payload []byte) []byte {
+	b := []byte{wireFormatMagic, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(b[1:], id)
+	return append(b, payload...)
+}
+
+// avroUser encodes a User record.
+func avroUser(name string, age int64) []byte {
+	b := binary.AppendVarint(nil, int64(len(name)))
+	b = append(b, name...)
+	b = binary.AppendVarint(b, 1)
+	return binary.AppendVarint(b, age)
+}
+
+// newTestRegistry starts a schema registry serving the schemas and counting
+// the requests.
+func newTestRegistry(t *testing.T, schemas map[string]string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if user, pass, ok := r.BasicAuth(); !ok || user != "beats" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		schema, ok := schemas[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error_code":40403,"message":"Schema not found"}`)
+			return
+		}
+		fmt.Fprint(w, schema)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestRecordDecoderSchemaRegistry(t *testing.T) {
+	srv, requests := newTestRegistry(t, map[string]string{
+		"/schemas/ids/1": fmt.Sprintf(`{"schema": %q}`, userSchema),
+		"/schemas/ids/2": `{"schemaType": "JSON", "schema": "{\"type\": \"object\"}"}`,
+	})
+
+	d, err := newRecordDecoder(&decodingConfig{
+		SchemaRegistry: &schemaRegistryConfig{URL: srv.URL + "/", Username: "beats", Password: "secret"},
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		id, fields, err := d.decode(wireFormat(1, avroUser("alice", 42)))
+		require.NoError(t, err)
+		assert.Equal(t, uint32(1), id)
+		assert.Equal(t, mapstr.M{"name": "alice", "age": int32(42)}, fields)
+	}
+	assert.Equal(t, int32(1), requests.Load(), "schemas must be cached")
+
+	_, fields, err := d.decode(wireFormat(2, []byte(`{"user": {"name": "bob"}}`)))
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"user": map[string]interface{}{"name": "bob"}}, fields)
+
+	_, _, err = d.decode(wireFormat(4, []byte{0}))
+	assert.ErrorContains(t, err, "Schema not found")
+	requestsBefore := requests.Load()
+	_, _, err = d.decode(wireFormat(4, []byte{0}))
+	assert.ErrorContains(t, err, "schema 4 is not available")
+	assert.Equal(t, requestsBefore, requests.Load(), "failures must not be retried immediately")
+
+	_, _, err = d.decode([]byte("plain text"))
+	assert.ErrorIs(t, err, errNotWireFormat)
+
+	_, _, err = d.decode(wireFormat(1, []byte{0xff}))
+	assert.ErrorContains(t, err, "failed to decode value with schema 1")
+}
+
+// Protobuf schemas of the test registry. user.proto imports address.proto,
+// registered under the subject address.
+var (
+	addressProto = &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("ignored.proto"),
+		Package: proto.String("example"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Address"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("city"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+			},
+		}},
+	}
+	userProto = &descriptorpb.FileDescriptorProto{
+		Package:    proto.String("example"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"address.proto", "google/protobuf/timestamp.proto"},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Role"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("GUEST"), Number: proto.Int32(0)},
+				{Name: proto.String("ADMIN"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("name"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum()},
+				{Name: proto.String("age"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum()},
+				{Name: proto.String("role"), Number: proto.Int32(3), Type: descriptorpb.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".example.Role")},
+				{Name: proto.String("address"), Number: proto.Int32(4), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".example.Address")},
+				{Name: proto.String("created"), Number: proto.Int32(5), Type: descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".google.protobuf.Timestamp")},
+				{Name: proto.String("tags"), Number: proto.Int32(6), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()},
+				{Name: proto.String("token"), Number: proto.Int32(7), Type: descriptorpb.FieldDescriptorProto_TYPE_BYTES.Enum()},
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("Login"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					{Name: proto.String("success"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum()},
+				},
+			}},
+		}},
+	}
+)
+
+// serializedSchema returns a schema registry response with a serialized
+// Protobuf schema.
+func serializedSchema(t *testing.T, fd *descriptorpb.FileDescriptorProto, references string) string {
+	b, err := proto.Marshal(fd)
+	require.NoError(t, err)
+	return fmt.Sprintf(`{"schemaType": "PROTOBUF", "schema": %q, "references": [%s]}`,
+		base64.StdEncoding.EncodeToString(b), references)
+}
+
+func TestRecordDecoderProtobuf(t *testing.T) {
+	const reference = `{"name": "address.proto", "subject": "address", "version": 2}`
+	srv, _ := newTestRegistry(t, map[string]string{
+		"/schemas/ids/3":                                 `{"schemaType": "PROTOBUF", "schema": "syntax = \"proto3\";"}`,
+		"/schemas/ids/3?format=serialized":               serializedSchema(t, userProto, reference),
+		"/subjects/address/versions/2?format=serialized": serializedSchema(t, addressProto, ""),
+	})
+
+	d, err := newRecordDecoder(&decodingConfig{
+		SchemaRegistry: &schemaRegistryConfig{URL: srv.URL, Username: "beats", Password: "secret"},
+	})
+	require.NoError(t, err)
+
+	s, err := d.schema(3)
+	require.NoError(t, err)
+	userDesc := s.protobuf.Messages().ByName("User")
+	user := dynamicpb.NewMessage(userDesc)
+	fields := userDesc.Fields()
+	user.Set(fields.ByName("name"), protoreflect.ValueOfString("alice"))
+	user.Set(fields.ByName("age"), protoreflect.ValueOfInt64(42))
+	user.Set(fields.ByName("role"), protoreflect.ValueOfEnum(1))
+	user.Mutable(fields.ByName("address")).Message().Set(
+		fields.ByName("address").Message().Fields().ByName("city"), protoreflect.ValueOfString("Berlin"))
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	user.Set(fields.ByName("created"), protoreflect.ValueOfMessage(timestamppb.New(created).ProtoReflect()))
+	user.Mutable(fields.ByName("tags")).List().Append(protoreflect.ValueOfString("a"))
+	user.Set(fields.ByName("token"), protoreflect.ValueOfBytes([]byte("hi")))
+	payload, err := proto.Marshal(user)
+	require.NoError(t, err)
+
+	// A single 0 stands for the first message of the schema.
+	id, decoded, err := d.decode(wireFormat(3, append([]byte{0}, payload...)))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(3), id)
+	assert.Equal(t, mapstr.M{
+		"name":    "alice",
+		"age":     int64(42),
+		"role":    "ADMIN",
+		"address": map[string]interface{}{"city": "Berlin"},
+		"created": created,
+		"tags":    []interface{}{"a"},
+		"token":   "aGk=",
+	}, decoded)
+
+	loginDesc := userDesc.Messages().ByName("Login")
+	login := dynamicpb.NewMessage(loginDesc)
+	login.Set(loginDesc.Fields().ByName("success"), protoreflect.ValueOfBool(true))
+	payload, err = proto.Marshal(login)
+	require.NoError(t, err)
+
+	// The indexes [0, 0] stand for the first message nested in the first
+	// message of the schema.
+	indexes := binary.AppendVarint(nil, 2)
+	indexes = binary.AppendVarint(indexes, 0)
+	indexes = binary.AppendVarint(indexes, 0)
+	_, decoded, err = d.decode(wireFormat(3, append(indexes, payload...)))
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"success": true}, decoded)
+
+	_, _, err = d.decode(wireFormat(3, []byte{2, 4}))
+	assert.ErrorContains(t, err, "message index 2 not found in schema")
+}
+
+func TestRecordDecoderSchemaDir(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "7.avsc"), []byte(userSchema), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "8.avsc"), []byte(`"string"`), 0o600))
+
+	d, err := newRecordDecoder(&decodingConfig{SchemaDir: dir, Target: "user"})
+	require.NoError(t, err)
+
+	_, fields, err := d.decode(wireFormat(7, avroUser("carol", 7)))
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"user": mapstr.M{"name": "carol", "age": int32(7)}}, fields)
+
+	_, fields, err = d.decode(wireFormat(8, append(binary.AppendVarint(nil, 2), "hi"...)))
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{"user": mapstr.M{"value": "hi"}}, fields)
+
+	_, _, err = d.decode(wireFormat(9, nil))
+	assert.ErrorContains(t, err, "schema 9 not found in "+dir)
+}
+
+func TestDecodingConfig(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"no schema source": {
+			config: map[string]interface{}{"decoding": map[string]interface{}{"target": "x"}},
+			err:    "decoding requires schema_registry.url or schema_dir",
+		},
+		"missing registry url": {
+			config: map[string]interface{}{"decoding.schema_registry.username": "beats"},
+			err:    "string value is not set accessing 'decoding.schema_registry.url'",
+		},
+		"with expand_event_list_from_field": {
+			config: map[string]interface{}{
+				"decoding.schema_dir":          "/etc/schemas",
+				"expand_event_list_from_field": "records",
+			},
+			err: "decoding cannot be used with expand_event_list_from_field",
+		},
+		"valid": {
+			config: map[string]interface{}{"decoding.schema_registry.url": "http://localhost:8081"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := conf.MustNewConfigFrom(map[string]interface{}{
+				"hosts":    "localhost:9092",
+				"topics":   "messages",
+				"group_id": "filebeat",
+			})
+			require.NoError(t, c.Merge(tc.config))
+			config := defaultConfig()
+			err := c.Unpack(&config)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+type testClaim struct {
+	messages chan *sarama.ConsumerMessage
+}
+
+func (c *testClaim) Topic() string                            { return "users" }
+func (c *testClaim) Partition() int32                         { return 0 }
+func (c *testClaim) InitialOffset() int64                     { return 0 }
+func (c *testClaim) HighWaterMarkOffset() int64               { return 0 }
+func (c *testClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestRecordReaderDecoding(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "1.avsc"), []byte(userSchema), 0o600))
+	d, err := newRecordDecoder(&decodingConfig{SchemaDir: dir})
+	require.NoError(t, err)
+
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	claim := &testClaim{messages: make(chan *sarama.ConsumerMessage, 2)}
+	claim.messages <- &sarama.ConsumerMessage{
+		Value:     wireFormat(1, avroUser("alice", 42)),
+		Offset:    1,
+		Timestamp: ts,
+		Headers:   []*sarama.RecordHeader{{Key: []byte("trace.id"), Value: []byte("abc")}},
+	}
+	claim.messages <- &sarama.ConsumerMessage{Value: []byte("not encoded"), Offset: 2, Timestamp: ts}
+	close(claim.messages)
+
+	r := &recordReader{
+		claim: claim,
+		groupHandler: &groupHandler{
+			version:        "2.1.0",
+			decoder:        d,
+			includeHeaders: true,
+		},
+		log: logp.NewLogger("test"),
+	}
+
+	msg, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, mapstr.M{
+		"name": "alice",
+		"age":  int32(42),
+		"kafka": mapstr.M{
+			"topic":     "users",
+			"partition": int32(0),
+			"offset":    int64(1),
+			"key":       "",
+			"headers":   []string{"trace.id: abc"},
+			"header":    mapstr.M{"trace.id": "abc"},
+			"schema_id": uint32(1),
+		},
+	}, msg.Fields)
+	assert.Equal(t, ts, msg.Ts)
+
+	msg, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "not encoded", msg.Fields["message"])
+	assert.Equal(t, mapstr.M{"message": errNotWireFormat.Error()}, msg.Fields["error"])
+	kafkaFields, ok := msg.Fields["kafka"].(mapstr.M)
+	require.True(t, ok)
+	assert.NotContains(t, kafkaFields, "schema_id")
+}
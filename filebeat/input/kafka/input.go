@@ -71,13 +71,22 @@ func configure(cfg *conf.C) (input.Input, error) {
 }
 
 func NewInput(config kafkaInputConfig, saramaConfig *sarama.Config) (*kafkaInput, error) {
-	return &kafkaInput{config: config, saramaConfig: saramaConfig}, nil
+	input := &kafkaInput{config: config, saramaConfig: saramaConfig}
+	if config.Decoding != nil {
+		decoder, err := newRecordDecoder(config.Decoding)
+		if err != nil {
+			return nil, fmt.Errorf("initializing decoding: %w", err)
+		}
+		input.decoder = decoder
+	}
+	return input, nil
 }
 
 type kafkaInput struct {
 	config          kafkaInputConfig
 	saramaConfig    *sarama.Config
 	saramaWaitGroup sync.WaitGroup // indicates a sarama consumer group is active
+	decoder         *recordDecoder
 }
 
 func (input *kafkaInput) Name() string { return pluginName }
@@ -195,6 +204,8 @@ func (input *kafkaInput) runConsumerGroup(log *logp.Logger, client beat.Client,
 		parsers: input.config.Parsers,
 		// expandEventListFromField will be assigned the configuration option expand_event_list_from_field
 		expandEventListFromField: input.config.ExpandEventListFromField,
+		decoder:                  input.decoder,
+		includeHeaders:           input.config.Decoding != nil && input.config.Decoding.IncludeHeaders,
 		log:                      log,
 	}
 
@@ -240,6 +251,16 @@ func arrayForKafkaHeaders(headers []*sarama.RecordHeader) []string {
 	return array
 }
 
+// mapForKafkaHeaders returns the headers as fields named after their keys.
+// If a key is repeated, the last value is kept.
+func mapForKafkaHeaders(headers []*sarama.RecordHeader) mapstr.M {
+	m := mapstr.M{}
+	for _, header := range headers {
+		m[string(header.Key)] = string(header.Value)
+	}
+	return m
+}
+
 // A barebones implementation of context.Context wrapped around the done
 // channels that are more common in the beats codebase.
 // TODO(faec): Generalize this to a common utility in a shared library
@@ -279,7 +300,10 @@ type groupHandler struct {
 	// if the fileset using this input expects to receive multiple messages bundled under a specific field then this value is assigned
 	// ex. in this case are the azure fielsets where the events are found under the json object "records"
 	expandEventListFromField string // TODO
-	log                      *logp.Logger
+	// decoder decodes values encoded with the schema registry wire format, if configured.
+	decoder        *recordDecoder
+	includeHeaders bool
+	log            *logp.Logger
 }
 
 func (h *groupHandler) Setup(session sarama.ConsumerGroupSession) error {
@@ -363,9 +387,37 @@ func (m *recordReader) Next() (reader.Message, error) {
 	ackHandler := func() {
 		m.groupHandler.ack(msg)
 	}
+	if m.groupHandler.decoder != nil {
+		return m.decodeMessage(timestamp, msg.Value, kafkaFields, ackHandler), nil
+	}
 	return composeMessage(timestamp, msg.Value, kafkaFields, ackHandler), nil
 }
 
+// decodeMessage decodes the value with its schema. Values that cannot be
+// decoded are kept in the message field, with the error in error.message.
+func (m *recordReader) decodeMessage(timestamp time.Time, content []byte, kafkaFields mapstr.M, ackHandler func()) reader.Message {
+	id, fields, err := m.groupHandler.decoder.decode(content)
+	if !errors.Is(err, errNotWireFormat) {
+		kafkaFields["schema_id"] = id
+	}
+	if err != nil {
+		m.log.Debugw("Failed to decode kafka record", "error", err)
+		message := composeMessage(timestamp, content, kafkaFields, ackHandler)
+		message.Fields["error"] = mapstr.M{"message": err.Error()}
+		return message
+	}
+
+	fields.DeepUpdate(mapstr.M{"kafka": kafkaFields})
+	return reader.Message{
+		Ts:      timestamp,
+		Content: content,
+		Fields:  fields,
+		Private: eventMeta{
+			ackHandler: ackHandler,
+		},
+	}
+}
+
 type listFromFieldReader struct {
 	claim        sarama.ConsumerGroupClaim
 	groupHandler *groupHandler
@@ -450,6 +502,9 @@ func composeEventMetadata(claim sarama.ConsumerGroupClaim, handler *groupHandler
 	}
 	if versionOk && version.IsAtLeast(sarama.V0_11_0_0) {
 		kafkaFields["headers"] = arrayForKafkaHeaders(msg.Headers)
+		if handler.includeHeaders {
+			kafkaFields["header"] = mapForKafkaHeaders(msg.Headers)
+		}
 	}
 	return timestamp, kafkaFields
 }
@@ -0,0 +1,144 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kafka
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/protobuf"
+)
+
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// parseProtobufSchema builds the file descriptor of a Protobuf schema from a
+// serialized FileDescriptorSet whose last file is the schema.
+func parseProtobufSchema(definition string) (protoreflect.FileDescriptor, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal([]byte(definition), &set); err != nil {
+		return nil, fmt.Errorf("invalid protobuf schema: %w", err)
+	}
+	if len(set.File) == 0 {
+		return nil, errors.New("empty protobuf schema")
+	}
+	files, err := protobuf.RegisterFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf schema: %w", err)
+	}
+	return files.FindFileByPath(set.File[len(set.File)-1].GetName())
+}
+
+// decodeProtobuf decodes a Protobuf value of the wire format. The value
+// starts with the indexes of its message type in the schema, a varint count
+// followed by a varint per nesting level. A single 0 stands for the first
+// message of the schema.
+func decodeProtobuf(fd protoreflect.FileDescriptor, value []byte) (interface{}, error) {
+	count, n := binary.Varint(value)
+	if n <= 0 || count < 0 {
+		return nil, errors.New("invalid message indexes")
+	}
+	value = value[n:]
+	indexes := []int64{0}
+	if count > 0 {
+		indexes = make([]int64, count)
+		for i := range indexes {
+			indexes[i], n = binary.Varint(value)
+			if n <= 0 {
+				return nil, errors.New("invalid message indexes")
+			}
+			value = value[n:]
+		}
+	}
+
+	messages := fd.Messages()
+	var desc protoreflect.MessageDescriptor
+	for _, i := range indexes {
+		if i < 0 || i >= int64(messages.Len()) {
+			return nil, fmt.Errorf("message index %d not found in schema", i)
+		}
+		desc = messages.Get(int(i))
+		messages = desc.Messages()
+	}
+
+	msg := dynamicpb.NewMessage(desc)
+	if err := proto.Unmarshal(value, msg); err != nil {
+		return nil, err
+	}
+	return messageValue(msg), nil
+}
+
+// messageValue converts a message to an object keyed by field name. Fields
+// that are not set are omitted, enums are converted to their names, bytes to
+// base64 encoded strings and timestamps to dates.
+func messageValue(msg protoreflect.Message) interface{} {
+	if msg.Descriptor().FullName() == timestampName {
+		fields := msg.Descriptor().Fields()
+		ts := &timestamppb.Timestamp{
+			Seconds: msg.Get(fields.ByName("seconds")).Int(),
+			Nanos:   int32(msg.Get(fields.ByName("nanos")).Int()),
+		}
+		return ts.AsTime()
+	}
+
+	m := map[string]interface{}{}
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.IsMap():
+			values := map[string]interface{}{}
+			v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+				values[k.String()] = fieldValue(fd.MapValue(), v)
+				return true
+			})
+			m[string(fd.Name())] = values
+		case fd.IsList():
+			list := v.List()
+			values := make([]interface{}, list.Len())
+			for i := range values {
+				values[i] = fieldValue(fd, list.Get(i))
+			}
+			m[string(fd.Name())] = values
+		default:
+			m[string(fd.Name())] = fieldValue(fd, v)
+		}
+		return true
+	})
+	return m
+}
+
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) interface{} {
+	switch fd.Kind() {
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		return messageValue(v.Message())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name())
+		}
+		return int32(v.Enum())
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes())
+	}
+	return v.Interface()
+}
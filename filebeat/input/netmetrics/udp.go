@@ -108,11 +108,11 @@ func (m *UDP) Log(data []byte, timestamp time.Time) {
 
 // poll periodically gets UDP buffer and packet drops stats from the OS.
 func (m *UDP) poll(addr, addr6 []string, each time.Duration, log *logp.Logger) {
-	hasUnspecified, addrIsUnspecified, badAddr := containsUnspecifiedAddr(addr)
+	_, addrIsUnspecified, badAddr := containsUnspecifiedAddr(addr)
 	if badAddr != nil {
 		log.Warnf("failed to parse IPv4 addrs for metric collection %q", badAddr)
 	}
-	hasUnspecified6, addrIsUnspecified6, badAddr := containsUnspecifiedAddr(addr6)
+	_, addrIsUnspecified6, badAddr := containsUnspecifiedAddr(addr6)
 	if badAddr != nil {
 		log.Warnf("failed to parse IPv6 addrs for metric collection %q", badAddr)
 	}
@@ -123,13 +123,13 @@ func (m *UDP) poll(addr, addr6 []string, each time.Duration, log *logp.Logger) {
 	// if the constructed address values are malformed we panic early
 	// within the period of system testing.
 	want4 := true
-	rx, drops, err := procNetUDP("/proc/net/udp", addr, hasUnspecified, addrIsUnspecified)
+	rx, drops, err := procNetUDP("/proc/net/udp", addr, addrIsUnspecified)
 	if err != nil {
 		want4 = false
 		log.Infof("did not get initial udp stats from /proc: %v", err)
 	}
 	want6 := true
-	rx6, drops6, err := procNetUDP("/proc/net/udp6", addr6, hasUnspecified6, addrIsUnspecified6)
+	rx6, drops6, err := procNetUDP("/proc/net/udp6", addr6, addrIsUnspecified6)
 	if err != nil {
 		want6 = false
 		log.Infof("did not get initial udp6 stats from /proc: %v", err)
@@ -146,7 +146,7 @@ func (m *UDP) poll(addr, addr6 []string, each time.Duration, log *logp.Logger) {
 		select {
 		case <-t.C:
 			var found bool
-			rx, drops, err := procNetUDP("/proc/net/udp", addr, hasUnspecified, addrIsUnspecified)
+			rx, drops, err := procNetUDP("/proc/net/udp", addr, addrIsUnspecified)
 			if err != nil {
 				if want4 {
 					log.Warnf("failed to get udp stats from /proc: %v", err)
@@ -155,7 +155,7 @@ func (m *UDP) poll(addr, addr6 []string, each time.Duration, log *logp.Logger) {
 				found = true
 				want4 = true
 			}
-			rx6, drops6, err := procNetUDP("/proc/net/udp6", addr6, hasUnspecified6, addrIsUnspecified6)
+			rx6, drops6, err := procNetUDP("/proc/net/udp6", addr6, addrIsUnspecified6)
 			if err != nil {
 				if want6 {
 					log.Warnf("failed to get udp6 stats from /proc: %v", err)
@@ -188,11 +188,12 @@ func (m *UDP) Registry() *monitoring.Registry {
 // for the socket on the provided address formatted in hex, xxxxxxxx:xxxx or
 // the IPv6 equivalent.
 // This function is only useful on linux due to its dependence on the /proc
-// filesystem, but is kept in this file for simplicity. If hasUnspecified
-// is true, all addresses listed in the file in path are considered, and the
-// sum of rx_queue and drops matching the addr ports is returned where the
-// corresponding addrIsUnspecified is true.
-func procNetUDP(path string, addr []string, hasUnspecified bool, addrIsUnspecified []bool) (rx, drops int64, err error) {
+// filesystem, but is kept in this file for simplicity. Only the port of
+// the addresses where the corresponding addrIsUnspecified is true is
+// matched. The sum of rx_queue and drops of all matching sockets is
+// returned, there is one line for each of the sockets sharing an address
+// with SO_REUSEPORT.
+func procNetUDP(path string, addr []string, addrIsUnspecified []bool) (rx, drops int64, err error) {
 	if len(addr) == 0 {
 		return 0, 0, nil
 	}
@@ -235,11 +236,6 @@ func procNetUDP(path string, addr []string, hasUnspecified bool, addrIsUnspecifi
 				return 0, 0, fmt.Errorf("failed to parse drops: %w", err)
 			}
 			drops += v
-
-			if hasUnspecified {
-				continue
-			}
-			return rx, drops, nil
 		}
 	}
 	if found {
@@ -29,8 +29,8 @@ func TestProcNetUDP(t *testing.T) {
 		path := "testdata/proc_net_udp.txt"
 		t.Run("with_match", func(t *testing.T) {
 			addr := []string{ipV4(net.IP{0x0a, 0x64, 0x08, 0x25}, 0x1bbe)}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			rx, drops, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -41,8 +41,8 @@ func TestProcNetUDP(t *testing.T) {
 
 		t.Run("leading_zero", func(t *testing.T) {
 			addr := []string{ipV4(net.IP{0x00, 0x7f, 0x01, 0x00}, 0x1eef)}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			rx, drops, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -51,10 +51,22 @@ func TestProcNetUDP(t *testing.T) {
 			assert.EqualValues(t, 2, drops)
 		})
 
+		t.Run("reuse_port", func(t *testing.T) {
+			addr := []string{ipV4(net.IP{0x0a, 0x64, 0x08, 0x25}, 0x2328)}
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Nil(t, bad)
+			assert.EqualValues(t, 5, rx)
+			assert.EqualValues(t, 12, drops)
+		})
+
 		t.Run("unspecified", func(t *testing.T) {
 			addr := []string{ipV4(net.ParseIP("0.0.0.0"), 0x1bbe)}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			rx, drops, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -68,8 +80,8 @@ func TestProcNetUDP(t *testing.T) {
 				ipV4(net.IP{0xde, 0xad, 0xbe, 0xef}, 0xf00d),
 				ipV4(net.IP{0xba, 0x1d, 0xfa, 0xce}, 0x1135),
 			}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			_, _, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			_, _, err := procNetUDP(path, addr, addrIsUnspecified)
 			assert.Nil(t, bad)
 			if assert.Error(t, err) {
 				assert.Contains(t, err.Error(), "entry not found")
@@ -78,8 +90,8 @@ func TestProcNetUDP(t *testing.T) {
 
 		t.Run("bad_addrs", func(t *testing.T) {
 			addr := []string{"FOO:BAR", "BAR:BAZ"}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			_, _, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			_, _, err := procNetUDP(path, addr, addrIsUnspecified)
 			assert.EqualValues(t, addr, bad)
 			if assert.Error(t, err) {
 				assert.Contains(t, err.Error(), "entry not found")
@@ -91,8 +103,8 @@ func TestProcNetUDP(t *testing.T) {
 		path := "testdata/proc_net_udp6.txt"
 		t.Run("with_match", func(t *testing.T) {
 			addr := []string{ipV6(net.IP{0: 0x7f, 3: 0x01, 15: 0}, 0x1bbd)}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			rx, drops, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -103,8 +115,8 @@ func TestProcNetUDP(t *testing.T) {
 
 		t.Run("leading_zero", func(t *testing.T) {
 			addr := []string{ipV6(net.IP{1: 0x7f, 2: 0x81, 15: 0}, 0x1eef)}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			rx, drops, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -115,8 +127,8 @@ func TestProcNetUDP(t *testing.T) {
 
 		t.Run("unspecified", func(t *testing.T) {
 			addr := []string{ipV6(net.ParseIP("[::]"), 0x1bbd)}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			rx, drops, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			rx, drops, err := procNetUDP(path, addr, addrIsUnspecified)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -130,8 +142,8 @@ func TestProcNetUDP(t *testing.T) {
 				ipV6(net.IP{0xde, 0xad, 0xbe, 0xef, 0xde, 0xad, 0xbe, 0xef, 0xde, 0xad, 0xbe, 0xef, 0xde, 0xad, 0xbe, 0xef}, 0xf00d),
 				ipV6(net.IP{0xba, 0x1d, 0xfa, 0xce, 0xba, 0x1d, 0xfa, 0xce, 0xba, 0x1d, 0xfa, 0xce, 0xba, 0x1d, 0xfa, 0xce}, 0x1135),
 			}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			_, _, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			_, _, err := procNetUDP(path, addr, addrIsUnspecified)
 			assert.Nil(t, bad)
 			if assert.Error(t, err) {
 				assert.Contains(t, err.Error(), "entry not found")
@@ -140,8 +152,8 @@ func TestProcNetUDP(t *testing.T) {
 
 		t.Run("bad_addrs", func(t *testing.T) {
 			addr := []string{"FOO:BAR", "BAR:BAZ"}
-			hasUnspecified, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
-			_, _, err := procNetUDP(path, addr, hasUnspecified, addrIsUnspecified)
+			_, addrIsUnspecified, bad := containsUnspecifiedAddr(addr)
+			_, _, err := procNetUDP(path, addr, addrIsUnspecified)
 			assert.EqualValues(t, addr, bad)
 			if assert.Error(t, err) {
 				assert.Contains(t, err.Error(), "entry not found")
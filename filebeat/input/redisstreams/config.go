@@ -0,0 +1,124 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redisstreams
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+type config struct {
+	Host     string            `config:"host" validate:"required"`
+	Network  string            `config:"network"`
+	Username string            `config:"username"`
+	Password string            `config:"password"`
+	DB       int               `config:"db" validate:"min=0"`
+	TLS      *tlscommon.Config `config:"ssl"`
+	Timeout  time.Duration     `config:"timeout" validate:"positive"`
+
+	Streams  []string `config:"streams" validate:"required"`
+	Group    string   `config:"group" validate:"required"`
+	Consumer string   `config:"consumer"`
+	// InitialOffset is the position a new consumer group starts reading from.
+	InitialOffset initialOffset `config:"initial_offset"`
+
+	BatchSize      int           `config:"batch_size" validate:"min=1"`
+	BlockTimeout   time.Duration `config:"block_timeout" validate:"positive"`
+	Claim          claimConfig   `config:"claim"`
+	Target         string        `config:"target"`
+	ConnectBackoff time.Duration `config:"connect_backoff" validate:"min=0"`
+	WaitClose      time.Duration `config:"wait_close" validate:"min=0"`
+}
+
+// claimConfig configures how pending entries of other consumers, which may
+// have died, are claimed.
+type claimConfig struct {
+	Enabled  bool          `config:"enabled"`
+	MinIdle  time.Duration `config:"min_idle" validate:"positive"`
+	Interval time.Duration `config:"interval" validate:"positive"`
+}
+
+type initialOffset string
+
+const (
+	initialOffsetOldest initialOffset = "oldest"
+	initialOffsetNewest initialOffset = "newest"
+)
+
+// Unpack validates and unpacks the "initial_offset" config option.
+func (off *initialOffset) Unpack(value string) error {
+	switch o := initialOffset(value); o {
+	case initialOffsetOldest, initialOffsetNewest:
+		*off = o
+		return nil
+	}
+	return fmt.Errorf("invalid initial_offset '%s'", value)
+}
+
+// groupStartID returns the ID a new consumer group starts reading after.
+func (off initialOffset) groupStartID() string {
+	if off == initialOffsetNewest {
+		return "$"
+	}
+	return "0"
+}
+
+func defaultConfig() config {
+	return config{
+		Network:        "tcp",
+		Timeout:        30 * time.Second,
+		InitialOffset:  initialOffsetOldest,
+		BatchSize:      100,
+		BlockTimeout:   5 * time.Second,
+		ConnectBackoff: 30 * time.Second,
+		WaitClose:      2 * time.Second,
+		Claim: claimConfig{
+			Enabled:  true,
+			MinIdle:  5 * time.Minute,
+			Interval: time.Minute,
+		},
+	}
+}
+
+// Validate validates the config.
+func (c *config) Validate() error {
+	switch c.Network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return fmt.Errorf("invalid network '%s', expected tcp, tcp4, tcp6 or unix", c.Network)
+	}
+	if c.BlockTimeout >= c.Timeout {
+		return errors.New("block_timeout must be lower than timeout")
+	}
+	if c.Username != "" && c.Password == "" {
+		return errors.New("password must be set when username is configured")
+	}
+	return nil
+}
+
+// consumerName returns the configured consumer name or the hostname.
+func (c *config) consumerName() (string, error) {
+	if c.Consumer != "" {
+		return c.Consumer, nil
+	}
+	return os.Hostname()
+}
@@ -0,0 +1,302 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redisstreams
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	rd "github.com/gomodule/redigo/redis"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// entry is an entry of a stream.
+type entry struct {
+	stream string
+	id     string
+	// fields are the field/value pairs of the entry. They are nil if the
+	// entry was deleted while pending.
+	fields []string
+}
+
+// ackRef references a published entry to acknowledge.
+type ackRef struct {
+	stream string
+	id     string
+}
+
+// consumer reads the streams as a member of a consumer group.
+type consumer struct {
+	conn    rd.Conn
+	config  *config
+	name    string
+	log     *logp.Logger
+	publish func(entry)
+}
+
+// createGroups creates the consumer group of every stream, and the streams,
+// if they do not exist yet.
+func (c *consumer) createGroups() error {
+	for _, stream := range c.config.Streams {
+		_, err := c.conn.Do("XGROUP", "CREATE", stream, c.config.Group, c.config.InitialOffset.groupStartID(), "MKSTREAM")
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return fmt.Errorf("failed to create consumer group %s of stream %s: %w", c.config.Group, stream, err)
+		}
+	}
+	return nil
+}
+
+// readPending publishes the entries delivered to this consumer but never
+// acknowledged, for example because Filebeat stopped before the events were
+// published.
+func (c *consumer) readPending() error {
+	ids := make(map[string]string, len(c.config.Streams))
+	for _, stream := range c.config.Streams {
+		ids[stream] = "0"
+	}
+
+	for len(ids) > 0 {
+		streams := make([]string, 0, len(ids))
+		for _, stream := range c.config.Streams {
+			if _, ok := ids[stream]; ok {
+				streams = append(streams, stream)
+			}
+		}
+		entries, err := c.readGroup(streams, func(stream string) string { return ids[stream] }, false)
+		if err != nil {
+			return err
+		}
+
+		read := map[string]bool{}
+		for _, e := range entries {
+			read[e.stream] = true
+			ids[e.stream] = e.id
+		}
+		for _, stream := range streams {
+			if !read[stream] {
+				delete(ids, stream)
+			}
+		}
+		if err := c.handle(entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// read publishes new entries, waiting up to block_timeout for them.
+func (c *consumer) read() (int, error) {
+	entries, err := c.readGroup(c.config.Streams, func(string) string { return ">" }, true)
+	if err != nil {
+		return 0, err
+	}
+	return len(entries), c.handle(entries)
+}
+
+func (c *consumer) readGroup(streams []string, id func(string) string, block bool) ([]entry, error) {
+	args := rd.Args{"GROUP", c.config.Group, c.name, "COUNT", c.config.BatchSize}
+	if block {
+		args = args.Add("BLOCK", c.config.BlockTimeout.Milliseconds())
+	}
+	args = args.Add("STREAMS")
+	for _, stream := range streams {
+		args = args.Add(stream)
+	}
+	for _, stream := range streams {
+		args = args.Add(id(stream))
+	}
+
+	reply, err := c.conn.Do("XREADGROUP", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read streams: %w", err)
+	}
+	return parseStreams(reply)
+}
+
+// claim publishes the entries pending for longer than claim.min_idle in
+// other consumers of the group, after claiming them.
+func (c *consumer) claim() (int, error) {
+	minIdle := c.config.Claim.MinIdle.Milliseconds()
+	claimed := 0
+	for _, stream := range c.config.Streams {
+		reply, err := rd.Values(c.conn.Do("XPENDING", stream, c.config.Group, "IDLE", minIdle, "-", "+", c.config.BatchSize))
+		if err != nil {
+			return claimed, fmt.Errorf("failed to list pending entries of stream %s: %w", stream, err)
+		}
+
+		args := rd.Args{stream, c.config.Group, c.name, minIdle}
+		n := len(args)
+		for _, p := range reply {
+			fields, err := rd.Values(p, nil)
+			if err != nil || len(fields) < 2 {
+				return claimed, fmt.Errorf("unexpected pending entry %v", p)
+			}
+			id, _ := rd.String(fields[0], nil)
+			owner, _ := rd.String(fields[1], nil)
+			if owner != c.name {
+				args = args.Add(id)
+			}
+		}
+		if len(args) == n {
+			continue
+		}
+
+		entriesReply, err := c.conn.Do("XCLAIM", args...)
+		if err != nil {
+			return claimed, fmt.Errorf("failed to claim pending entries of stream %s: %w", stream, err)
+		}
+		entries, err := parseEntries(stream, entriesReply)
+		if err != nil {
+			return claimed, err
+		}
+		if len(entries) > 0 {
+			c.log.Infow("Claimed pending entries of other consumers", "stream", stream, "entries", len(entries))
+		}
+		claimed += len(entries)
+		if err := c.handle(entries); err != nil {
+			return claimed, err
+		}
+	}
+	return claimed, nil
+}
+
+// handle publishes the entries. Entries deleted while pending cannot be
+// published, they are acknowledged to remove them from the group.
+func (c *consumer) handle(entries []entry) error {
+	for _, e := range entries {
+		if e.fields == nil {
+			if _, err := c.conn.Do("XACK", e.stream, c.config.Group, e.id); err != nil {
+				return fmt.Errorf("failed to acknowledge deleted entry %s of stream %s: %w", e.id, e.stream, err)
+			}
+			continue
+		}
+		c.publish(e)
+	}
+	return nil
+}
+
+// parseStreams parses the reply of XREADGROUP, which is nil if the command
+// timed out.
+func parseStreams(reply interface{}) ([]entry, error) {
+	if reply == nil {
+		return nil, nil
+	}
+	streams, err := rd.Values(reply, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected reply: %w", err)
+	}
+
+	var entries []entry
+	for _, s := range streams {
+		values, err := rd.Values(s, nil)
+		if err != nil || len(values) != 2 {
+			return nil, fmt.Errorf("unexpected stream reply %v", s)
+		}
+		name, err := rd.String(values[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected stream name: %w", err)
+		}
+		streamEntries, err := parseEntries(name, values[1])
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, streamEntries...)
+	}
+	return entries, nil
+}
+
+// parseEntries parses a list of entries, as returned by XRANGE or XCLAIM.
+func parseEntries(stream string, reply interface{}) ([]entry, error) {
+	values, err := rd.Values(reply, nil)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected entries of stream %s: %w", stream, err)
+	}
+
+	entries := make([]entry, 0, len(values))
+	for _, v := range values {
+		if v == nil {
+			// Entries deleted before being claimed.
+			continue
+		}
+		parts, err := rd.Values(v, nil)
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected entry of stream %s: %v", stream, v)
+		}
+		id, err := rd.String(parts[0], nil)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected entry ID of stream %s: %w", stream, err)
+		}
+		e := entry{stream: stream, id: id}
+		if parts[1] != nil {
+			e.fields, err = rd.Strings(parts[1], nil)
+			if err != nil {
+				return nil, fmt.Errorf("unexpected fields of entry %s of stream %s: %w", id, stream, err)
+			}
+			if e.fields == nil {
+				e.fields = []string{}
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// toEvent converts an entry to an event. The field/value pairs are added
+// under target and the timestamp of the event is the time of the entry ID.
+func toEvent(e entry, target, group string) beat.Event {
+	fields := mapstr.M{}
+	for i := 0; i+1 < len(e.fields); i += 2 {
+		key := e.fields[i]
+		if target != "" {
+			key = target + "." + key
+		}
+		_, _ = fields.Put(key, e.fields[i+1])
+	}
+	_, _ = fields.Put("redis.stream", mapstr.M{
+		"name":  e.stream,
+		"id":    e.id,
+		"group": group,
+	})
+
+	timestamp, err := idTime(e.id)
+	if err != nil {
+		timestamp = time.Now()
+	}
+	return beat.Event{
+		Timestamp: timestamp,
+		Fields:    fields,
+		Private:   ackRef{stream: e.stream, id: e.id},
+	}
+}
+
+// idTime returns the time of an entry ID, whose first part is a Unix time in
+// milliseconds.
+func idTime(id string) (time.Time, error) {
+	ms, _, _ := strings.Cut(id, "-")
+	t, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil {
+		return time.Time{}, errors.New("invalid entry ID")
+	}
+	return time.UnixMilli(t).UTC(), nil
+}
@@ -0,0 +1,274 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package redisstreams
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	rd "github.com/gomodule/redigo/redis"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/acker"
+	"github.com/elastic/beats/v7/libbeat/common/backoff"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const pluginName = "redis_streams"
+
+// Plugin creates a new redis_streams input plugin.
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       pluginName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "Redis Streams input",
+		Doc:        "The Redis Streams input reads entries from Redis streams as a member of a consumer group",
+		Manager:    input.ConfigureWith(configure),
+	}
+}
+
+func configure(cfg *conf.C) (input.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+	return newInput(config)
+}
+
+func newInput(config config) (*redisStreamsInput, error) {
+	consumer, err := config.consumerName()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get consumer name: %w", err)
+	}
+
+	in := &redisStreamsInput{config: config, consumer: consumer}
+	if config.TLS.IsEnabled() {
+		tlsConfig, err := tlscommon.LoadTLSConfig(config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		in.dial = dialer(config, tlsConfig.ToConfig())
+	} else {
+		in.dial = dialer(config, nil)
+	}
+	return in, nil
+}
+
+type redisStreamsInput struct {
+	config   config
+	consumer string
+	dial     func() (rd.Conn, error)
+}
+
+func (in *redisStreamsInput) Name() string { return pluginName }
+
+func (in *redisStreamsInput) Test(_ input.TestContext) error {
+	conn, err := in.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", in.config.Host, err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Do("PING")
+	return err
+}
+
+func (in *redisStreamsInput) Run(ctx input.Context, pipeline beat.Pipeline) error {
+	log := ctx.Logger.With("host", in.config.Host, "group", in.config.Group, "consumer", in.consumer)
+
+	pool := &rd.Pool{
+		MaxIdle: 2,
+		Dial:    in.dial,
+	}
+	defer pool.Close()
+
+	// Entries are acknowledged in the background, once the events have
+	// been acknowledged by the outputs.
+	acks := make(chan []ackRef, 16)
+	stopped := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		in.runAcker(log, pool, acks, stopped)
+	}()
+	defer wg.Wait()
+	defer close(stopped)
+
+	client, err := pipeline.ConnectWith(beat.ClientConfig{
+		EventListener: acker.ConnectionOnly(
+			acker.EventPrivateReporter(func(_ int, events []interface{}) {
+				refs := make([]ackRef, 0, len(events))
+				for _, event := range events {
+					if ref, ok := event.(ackRef); ok {
+						refs = append(refs, ref)
+					}
+				}
+				select {
+				case acks <- refs:
+				case <-stopped:
+				}
+			}),
+		),
+		WaitClose: in.config.WaitClose,
+	})
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	log.Info("Starting Redis Streams input")
+	defer log.Info("Redis Streams input stopped")
+
+	// If the consumer fails, we use exponential backoff with jitter up to
+	// 8 * the initial backoff interval.
+	connectDelay := backoff.NewEqualJitterBackoff(
+		ctx.Cancelation.Done(),
+		in.config.ConnectBackoff,
+		8*in.config.ConnectBackoff,
+	)
+
+	for ctx.Cancelation.Err() == nil {
+		err := in.consume(ctx, log, pool.Get(), client, connectDelay)
+		if err != nil && ctx.Cancelation.Err() == nil {
+			log.Errorw("Error consuming Redis streams", "error", err)
+			connectDelay.Wait()
+		}
+	}
+
+	if errors.Is(ctx.Cancelation.Err(), context.Canceled) {
+		return nil
+	}
+	return ctx.Cancelation.Err()
+}
+
+// consume reads the streams until the input is stopped or an error occurs.
+func (in *redisStreamsInput) consume(ctx input.Context, log *logp.Logger, conn rd.Conn, client beat.Client, connectDelay backoff.Backoff) error {
+	defer conn.Close()
+
+	c := &consumer{
+		conn:   conn,
+		config: &in.config,
+		name:   in.consumer,
+		log:    log,
+		publish: func(e entry) {
+			client.Publish(toEvent(e, in.config.Target, in.config.Group))
+		},
+	}
+	if err := c.createGroups(); err != nil {
+		return err
+	}
+	if err := c.readPending(); err != nil {
+		return err
+	}
+	connectDelay.Reset()
+
+	var lastClaim time.Time
+	for ctx.Cancelation.Err() == nil {
+		if in.config.Claim.Enabled && time.Since(lastClaim) >= in.config.Claim.Interval {
+			if _, err := c.claim(); err != nil {
+				return err
+			}
+			lastClaim = time.Now()
+		}
+		if _, err := c.read(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runAcker acknowledges the entries of the published events until stopped
+// is closed. Entries that fail to be acknowledged stay pending, they are
+// published again when the input restarts or when another consumer claims
+// them.
+func (in *redisStreamsInput) runAcker(log *logp.Logger, pool *rd.Pool, acks <-chan []ackRef, stopped <-chan struct{}) {
+	for {
+		select {
+		case <-stopped:
+			// Acknowledge what the pipeline acknowledged before closing.
+			for {
+				select {
+				case refs := <-acks:
+					if err := ack(pool.Get(), in.config.Group, refs); err != nil {
+						log.Errorw("Error acknowledging entries", "error", err)
+					}
+				default:
+					return
+				}
+			}
+		case refs := <-acks:
+			if err := ack(pool.Get(), in.config.Group, refs); err != nil {
+				log.Errorw("Error acknowledging entries", "error", err)
+			}
+		}
+	}
+}
+
+// ack acknowledges the entries and closes the connection.
+func ack(conn rd.Conn, group string, refs []ackRef) error {
+	defer conn.Close()
+
+	var streams []string
+	ids := map[string][]interface{}{}
+	for _, ref := range refs {
+		if _, ok := ids[ref.stream]; !ok {
+			streams = append(streams, ref.stream)
+			ids[ref.stream] = []interface{}{ref.stream, group}
+		}
+		ids[ref.stream] = append(ids[ref.stream], ref.id)
+	}
+
+	for _, stream := range streams {
+		if _, err := conn.Do("XACK", ids[stream]...); err != nil {
+			return fmt.Errorf("failed to acknowledge entries of stream %s: %w", stream, err)
+		}
+	}
+	return nil
+}
+
+// dialer returns a function connecting to Redis, authenticating and
+// selecting the configured database.
+func dialer(cfg config, tlsConfig *tls.Config) func() (rd.Conn, error) {
+	return func() (rd.Conn, error) {
+		dialOptions := []rd.DialOption{
+			rd.DialUsername(cfg.Username),
+			rd.DialPassword(cfg.Password),
+			rd.DialDatabase(cfg.DB),
+			rd.DialConnectTimeout(cfg.Timeout),
+			rd.DialReadTimeout(cfg.Timeout),
+			rd.DialWriteTimeout(cfg.Timeout),
+		}
+		if tlsConfig != nil {
+			dialOptions = append(dialOptions,
+				rd.DialUseTLS(true),
+				rd.DialTLSConfig(tlsConfig),
+			)
+		}
+		return rd.Dial(cfg.Network, cfg.Host, dialOptions...)
+	}
+}
@@ -0,0 +1,276 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package redisstreams
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	rd "github.com/gomodule/redigo/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// fakeConn is a Redis connection replying to commands with scripted
+// replies, and recording the commands.
+type fakeConn struct {
+	replies  []interface{}
+	commands []string
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Send(string, ...interface{}) error { return nil }
+
+func (c *fakeConn) Receive() (interface{}, error) { return nil, nil }
+
+func (c *fakeConn) Do(cmd string, args ...interface{}) (interface{}, error) {
+	c.commands = append(c.commands, strings.TrimSpace(fmt.Sprintln(append([]interface{}{cmd}, args...)...)))
+	if len(c.replies) == 0 {
+		return nil, fmt.Errorf("unexpected command %s", cmd)
+	}
+	reply := c.replies[0]
+	c.replies = c.replies[1:]
+	if err, ok := reply.(error); ok {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// entryReply returns the reply of a stream entry.
+func entryReply(id string, fields ...string) interface{} {
+	if fields == nil {
+		return []interface{}{[]byte(id), nil}
+	}
+	values := make([]interface{}, len(fields))
+	for i, f := range fields {
+		values[i] = []byte(f)
+	}
+	return []interface{}{[]byte(id), values}
+}
+
+// streamReply returns the reply of XREADGROUP for a stream.
+func streamReply(name string, entries ...interface{}) interface{} {
+	return []interface{}{[]byte(name), entries}
+}
+
+func testConfig(t *testing.T, settings map[string]interface{}) config {
+	c := conf.MustNewConfigFrom(map[string]interface{}{
+		"host":    "localhost:6379",
+		"streams": []string{"orders", "payments"},
+		"group":   "filebeat",
+	})
+	require.NoError(t, c.Merge(settings))
+	config := defaultConfig()
+	require.NoError(t, c.Unpack(&config))
+	return config
+}
+
+func newTestConsumer(t *testing.T, conn *fakeConn) (*consumer, *[]entry) {
+	config := testConfig(t, map[string]interface{}{"batch_size": 2})
+	var published []entry
+	return &consumer{
+		conn:    conn,
+		config:  &config,
+		name:    "beat-1",
+		log:     logp.NewLogger("redis_streams"),
+		publish: func(e entry) { published = append(published, e) },
+	}, &published
+}
+
+func TestConfig(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"missing streams": {
+			config: map[string]interface{}{"host": "localhost:6379", "group": "filebeat"},
+			err:    "missing required field accessing 'streams'",
+		},
+		"invalid initial_offset": {
+			config: map[string]interface{}{"initial_offset": "latest"},
+			err:    "invalid initial_offset 'latest'",
+		},
+		"block_timeout above timeout": {
+			config: map[string]interface{}{"block_timeout": "1m"},
+			err:    "block_timeout must be lower than timeout",
+		},
+		"username without password": {
+			config: map[string]interface{}{"username": "beats"},
+			err:    "password must be set when username is configured",
+		},
+		"invalid network": {
+			config: map[string]interface{}{"network": "udp"},
+			err:    "invalid network 'udp'",
+		},
+		"valid": {
+			config: map[string]interface{}{"initial_offset": "newest", "claim.enabled": false},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			settings := map[string]interface{}{
+				"host":    "localhost:6379",
+				"streams": []string{"orders"},
+				"group":   "filebeat",
+			}
+			if _, ok := tc.config["host"]; ok {
+				settings = map[string]interface{}{}
+			}
+			c := conf.MustNewConfigFrom(settings)
+			require.NoError(t, c.Merge(tc.config))
+			config := defaultConfig()
+			err := c.Unpack(&config)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestCreateGroups(t *testing.T) {
+	conn := &fakeConn{replies: []interface{}{"OK", rd.Error("BUSYGROUP Consumer Group name already exists")}}
+	c, _ := newTestConsumer(t, conn)
+	require.NoError(t, c.createGroups())
+	assert.Equal(t, []string{
+		"XGROUP CREATE orders filebeat 0 MKSTREAM",
+		"XGROUP CREATE payments filebeat 0 MKSTREAM",
+	}, conn.commands)
+
+	conn = &fakeConn{replies: []interface{}{rd.Error("WRONGTYPE Key is not a stream")}}
+	c, _ = newTestConsumer(t, conn)
+	assert.ErrorContains(t, c.createGroups(), "failed to create consumer group filebeat of stream orders")
+}
+
+func TestReadPending(t *testing.T) {
+	conn := &fakeConn{replies: []interface{}{
+		[]interface{}{
+			streamReply("orders", entryReply("1-0", "id", "a"), entryReply("2-0")),
+			streamReply("payments"),
+		},
+		"OK", // XACK of the deleted entry
+		[]interface{}{streamReply("orders", entryReply("3-0", "id", "c"))},
+		[]interface{}{streamReply("orders")},
+	}}
+	c, published := newTestConsumer(t, conn)
+	require.NoError(t, c.readPending())
+
+	assert.Equal(t, []string{
+		"XREADGROUP GROUP filebeat beat-1 COUNT 2 STREAMS orders payments 0 0",
+		"XACK orders filebeat 2-0",
+		"XREADGROUP GROUP filebeat beat-1 COUNT 2 STREAMS orders 2-0",
+		"XREADGROUP GROUP filebeat beat-1 COUNT 2 STREAMS orders 3-0",
+	}, conn.commands)
+	assert.Equal(t, []entry{
+		{stream: "orders", id: "1-0", fields: []string{"id", "a"}},
+		{stream: "orders", id: "3-0", fields: []string{"id", "c"}},
+	}, *published)
+}
+
+func TestRead(t *testing.T) {
+	conn := &fakeConn{replies: []interface{}{
+		[]interface{}{
+			streamReply("orders", entryReply("1-0", "id", "a")),
+			streamReply("payments", entryReply("1-1", "amount", "10")),
+		},
+		nil, // timeout
+	}}
+	c, published := newTestConsumer(t, conn)
+
+	n, err := c.read()
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	n, err = c.read()
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	assert.Equal(t, "XREADGROUP GROUP filebeat beat-1 COUNT 2 BLOCK 5000 STREAMS orders payments > >", conn.commands[0])
+	assert.Len(t, *published, 2)
+}
+
+func TestClaim(t *testing.T) {
+	conn := &fakeConn{replies: []interface{}{
+		[]interface{}{
+			[]interface{}{[]byte("1-0"), []byte("beat-2"), int64(400000), int64(1)},
+			[]interface{}{[]byte("2-0"), []byte("beat-1"), int64(400000), int64(1)},
+			[]interface{}{[]byte("3-0"), []byte("beat-3"), int64(400000), int64(2)},
+		},
+		[]interface{}{entryReply("1-0", "id", "a"), nil},
+		[]interface{}{},
+	}}
+	c, published := newTestConsumer(t, conn)
+
+	n, err := c.claim()
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{
+		"XPENDING orders filebeat IDLE 300000 - + 2",
+		"XCLAIM orders filebeat beat-1 300000 1-0 3-0",
+		"XPENDING payments filebeat IDLE 300000 - + 2",
+	}, conn.commands)
+	assert.Equal(t, []entry{{stream: "orders", id: "1-0", fields: []string{"id", "a"}}}, *published)
+}
+
+func TestAck(t *testing.T) {
+	conn := &fakeConn{replies: []interface{}{int64(2), int64(1)}}
+	err := ack(conn, "filebeat", []ackRef{
+		{stream: "orders", id: "1-0"},
+		{stream: "payments", id: "1-1"},
+		{stream: "orders", id: "2-0"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"XACK orders filebeat 1-0 2-0",
+		"XACK payments filebeat 1-1",
+	}, conn.commands)
+}
+
+func TestToEvent(t *testing.T) {
+	e := entry{stream: "orders", id: "1700000000123-4", fields: []string{"id", "a", "customer.name", "alice"}}
+
+	event := toEvent(e, "", "filebeat")
+	assert.Equal(t, time.UnixMilli(1700000000123).UTC(), event.Timestamp)
+	assert.Equal(t, mapstr.M{
+		"id":       "a",
+		"customer": mapstr.M{"name": "alice"},
+		"redis": mapstr.M{"stream": mapstr.M{
+			"name":  "orders",
+			"id":    "1700000000123-4",
+			"group": "filebeat",
+		}},
+	}, event.Fields)
+	assert.Equal(t, ackRef{stream: "orders", id: "1700000000123-4"}, event.Private)
+
+	event = toEvent(e, "order", "filebeat")
+	v, err := event.Fields.GetValue("order.customer.name")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", v)
+}
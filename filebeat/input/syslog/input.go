@@ -320,6 +320,9 @@ func newBeatEvent(timestamp time.Time, metadata inputsource.NetworkMetadata, fie
 	if metadata.RemoteAddr != nil {
 		event.Fields.Put("log.source.address", metadata.RemoteAddr.String())
 	}
+	if metadata.Proxy != nil {
+		event.Fields.DeepUpdate(metadata.Proxy.Fields())
+	}
 	return event
 }
 
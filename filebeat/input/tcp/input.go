@@ -123,6 +123,9 @@ func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
 					},
 				}
 			}
+			if metadata.Proxy != nil {
+				evt.Fields.DeepUpdate(metadata.Proxy.Fields())
+			}
 
 			publisher.Publish(evt)
 
@@ -101,7 +101,7 @@ func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
 	metrics := newInputMetrics(ctx.ID, s.config.Path, log)
 	defer metrics.close()
 
-	server, err := unix.New(log, &s.config.Config, func(data []byte, _ inputsource.NetworkMetadata) {
+	server, err := unix.New(log, &s.config.Config, func(data []byte, metadata inputsource.NetworkMetadata) {
 		log.Debugw("Data received", "bytes", len(data))
 		evt := beat.Event{
 			Timestamp: time.Now(),
@@ -109,6 +109,9 @@ func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
 				"message": string(data),
 			},
 		}
+		if metadata.Proxy != nil {
+			evt.Fields.DeepUpdate(metadata.Proxy.Fields())
+		}
 		publisher.Publish(evt)
 
 		// This must be called after publisher.Publish to measure
@@ -152,6 +152,12 @@ func (r *runner) Start() {
 	}()
 }
 
+// Wait waits until the input has returned, either because it was stopped or
+// because it completed by itself.
+func (r *runner) Wait() {
+	r.wg.Wait()
+}
+
 func (r *runner) Stop() {
 	r.sig.Cancel()
 	r.wg.Wait()
@@ -24,6 +24,7 @@ import (
 	"strings"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
@@ -51,6 +52,7 @@ func DatagramReaderFactory(family inputsource.Family, logger *logp.Logger, callb
 				//
 				// On Unix based system, the buffer will be truncated but no error will be returned.
 				length, addr, err := conn.ReadFrom(buffer)
+				addr, proxy := proxyproto.HeaderFromAddr(addr)
 				if err != nil {
 					if family == inputsource.FamilyUnix {
 						logger.Info("connection handler error", err)
@@ -74,13 +76,13 @@ func DatagramReaderFactory(family inputsource.Family, logger *logp.Logger, callb
 					// On Windows send the current buffer and mark it as truncated.
 					// The buffer will have content but length will return 0, addr will be nil.
 					if family == inputsource.FamilyUDP && isLargerThanBuffer(err) {
-						callback(buffer, inputsource.NetworkMetadata{RemoteAddr: addr, Truncated: true})
+						callback(buffer, inputsource.NetworkMetadata{RemoteAddr: addr, Truncated: true, Proxy: proxy, PacketConn: conn})
 						continue
 					}
 				}
 
 				if length > 0 {
-					callback(buffer[:length], inputsource.NetworkMetadata{RemoteAddr: addr})
+					callback(buffer[:length], inputsource.NetworkMetadata{RemoteAddr: addr, Proxy: proxy, PacketConn: conn})
 				}
 			}
 			logger.Debug("end of connection handling")
@@ -20,6 +20,7 @@ package dgram
 import (
 	"context"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/elastic/go-concert/ctxtool"
@@ -40,6 +41,9 @@ type ListenerFactory func() (net.PacketConn, error)
 type ListenerConfig struct {
 	Timeout        time.Duration
 	MaxMessageSize cfgtype.ByteSize
+	// Readers is the number of connections created with the ListenerFactory,
+	// each one read by its own goroutine. Defaults to 1.
+	Readers int
 }
 
 type Listener struct {
@@ -78,42 +82,81 @@ func (l *Listener) Run(ctx context.Context) error {
 }
 
 func (l *Listener) doRun(ctx context.Context) {
-	conn, err := l.listener()
+	conns, err := l.listen()
 	if err != nil {
 		l.log.Debugw("Cannot connect", "error", err)
 		return
 	}
 
+	// All connections are closed as soon as one of the readers stops, so
+	// that they are created again together.
 	connCtx, connCancel := ctxtool.WithFunc(ctx, func() {
-		conn.Close()
+		closeAll(conns)
 	})
 	defer connCancel()
 
-	err = l.connectAndRun(connCtx, conn)
-	if err != nil {
-		l.log.Debugw("Error while processing input", "error", err)
+	var wg sync.WaitGroup
+	for _, conn := range conns {
+		wg.Add(1)
+		go func(conn net.PacketConn) {
+			defer wg.Done()
+			defer connCancel()
+
+			err := l.connectAndRun(connCtx, conn)
+			if err != nil {
+				l.log.Debugw("Error while processing input", "error", err)
+			}
+		}(conn)
 	}
+	wg.Wait()
 }
 
 func (l *Listener) Start() error {
 	l.log.Info("Started listening for " + l.family.String() + " connection")
 
-	conn, err := l.listener()
+	conns, err := l.listen()
 	if err != nil {
 		return err
 	}
 
-	l.tg.Go(func(ctx context.Context) error {
-		connCtx, connCancel := ctxtool.WithFunc(ctxtool.FromCanceller(ctx), func() {
-			conn.Close()
-		})
-		defer connCancel()
+	for _, conn := range conns {
+		l.tg.Go(func(ctx context.Context) error {
+			connCtx, connCancel := ctxtool.WithFunc(ctxtool.FromCanceller(ctx), func() {
+				conn.Close()
+			})
+			defer connCancel()
 
-		return l.connectAndRun(ctxtool.FromCanceller(connCtx), conn)
-	})
+			return l.connectAndRun(ctxtool.FromCanceller(connCtx), conn)
+		})
+	}
 	return nil
 }
 
+// listen creates the configured number of connections.
+func (l *Listener) listen() ([]net.PacketConn, error) {
+	readers := l.config.Readers
+	if readers < 1 {
+		readers = 1
+	}
+
+	conns := make([]net.PacketConn, 0, readers)
+	for i := 0; i < readers; i++ {
+		conn, err := l.listener()
+		if err != nil {
+			closeAll(conns)
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	return conns, nil
+}
+
+func closeAll(conns []net.PacketConn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
 func (l *Listener) connectAndRun(ctx context.Context, conn net.PacketConn) error {
 	defer l.log.Recover("Panic handling datagram")
 
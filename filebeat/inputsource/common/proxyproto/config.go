@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package proxyproto
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// defaultHeaderTimeout is the time allowed to receive the header when no
+// header_timeout is configured.
+const defaultHeaderTimeout = 5 * time.Second
+
+// Config is the PROXY protocol configuration of a network input.
+type Config struct {
+	// Enabled reads a PROXY protocol header at the beginning of every
+	// connection or datagram.
+	Enabled bool `config:"enabled"`
+	// Required rejects connections and datagrams without a header.
+	Required bool `config:"required"`
+	// TrustedProxies are the addresses and CIDR ranges allowed to send a
+	// header. If empty, all peers are allowed.
+	TrustedProxies []string `config:"trusted_proxies"`
+	// HeaderTimeout is the time allowed to receive the header of a connection.
+	HeaderTimeout time.Duration `config:"header_timeout" validate:"positive"`
+}
+
+// Validate checks the trusted proxies are addresses or CIDR ranges.
+func (c *Config) Validate() error {
+	_, err := parseTrustedProxies(c.TrustedProxies)
+	return err
+}
+
+// policy decides which peers may send a header.
+type policy struct {
+	required      bool
+	trusted       []*net.IPNet
+	headerTimeout time.Duration
+}
+
+func newPolicy(c Config) (*policy, error) {
+	trusted, err := parseTrustedProxies(c.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	timeout := c.HeaderTimeout
+	if timeout == 0 {
+		timeout = defaultHeaderTimeout
+	}
+	return &policy{required: c.Required, trusted: trusted, headerTimeout: timeout}, nil
+}
+
+// isTrusted returns true if the peer may send a header. Peers without an
+// IP address, such as Unix socket clients, are always trusted.
+func (p *policy) isTrusted(addr net.Addr) bool {
+	if len(p.trusted) == 0 {
+		return true
+	}
+
+	var ip net.IP
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		ip = addr.IP
+	case *net.UDPAddr:
+		ip = addr.IP
+	default:
+		return true
+	}
+	for _, n := range p.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy CIDR %q: %w", proxy, err)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
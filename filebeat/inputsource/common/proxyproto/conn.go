@@ -0,0 +1,250 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package proxyproto
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// Conn is a connection that starts with a PROXY protocol header. The header
+// is read on the first call to Read, RemoteAddr or LocalAddr, so accepting
+// connections never blocks on slow clients.
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	policy *policy
+
+	once   sync.Once
+	header *Header
+	err    error
+
+	mu           sync.Mutex
+	readDeadline time.Time
+}
+
+// Read reads data from the connection, after the header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the source address of the header, or the address of
+// the peer if the connection has no header.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.header != nil && c.header.SourceAddr != nil {
+		return c.header.SourceAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address of the header, or the local
+// address if the connection has no header.
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.header != nil && c.header.DestinationAddr != nil {
+		return c.header.DestinationAddr
+	}
+	return c.Conn.LocalAddr()
+}
+
+// ProxyAddr returns the address of the peer, which is the proxy if the
+// connection has a header.
+func (c *Conn) ProxyAddr() net.Addr {
+	return c.Conn.RemoteAddr()
+}
+
+// Header returns the header of the connection, or nil if the connection has
+// no header.
+func (c *Conn) Header() *Header {
+	c.once.Do(c.readHeader)
+	return c.header
+}
+
+// SetDeadline sets the read and write deadlines of the connection.
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+// SetReadDeadline sets the read deadline of the connection.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *Conn) readHeader() {
+	// Limit the time to receive the header and restore the deadline set by
+	// the user afterwards.
+	c.mu.Lock()
+	deadline := time.Now().Add(c.policy.headerTimeout)
+	if !c.readDeadline.IsZero() && c.readDeadline.Before(deadline) {
+		deadline = c.readDeadline
+	}
+	_ = c.Conn.SetReadDeadline(deadline)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		_ = c.Conn.SetReadDeadline(c.readDeadline)
+		c.mu.Unlock()
+	}()
+
+	peer := c.Conn.RemoteAddr()
+	h, err := ReadHeader(c.reader)
+	switch {
+	case errors.Is(err, ErrNoHeader):
+		if c.policy.required {
+			c.err = fmt.Errorf("%w received from %v", ErrNoHeader, peer)
+		}
+	case err != nil:
+		c.err = fmt.Errorf("failed to read PROXY protocol header from %v: %w", peer, err)
+	case !c.policy.isTrusted(peer):
+		c.err = fmt.Errorf("PROXY protocol header received from untrusted peer %v", peer)
+	default:
+		c.header = h
+	}
+}
+
+// HeaderFromConn returns the header of a connection accepted by a listener
+// created with NewListener, or nil if the connection has no header. TLS
+// connections are unwrapped.
+func HeaderFromConn(conn net.Conn) *Header {
+	for {
+		switch c := conn.(type) {
+		case *Conn:
+			return c.Header()
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+}
+
+type listener struct {
+	net.Listener
+	policy *policy
+}
+
+// NewListener wraps l to read a PROXY protocol header from accepted
+// connections. TLS listeners must wrap the returned listener, as the header
+// is sent before the TLS handshake.
+func NewListener(l net.Listener, c Config) (net.Listener, error) {
+	p, err := newPolicy(c)
+	if err != nil {
+		return nil, err
+	}
+	return &listener{Listener: l, policy: p}, nil
+}
+
+// Accept accepts the next connection.
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), policy: l.policy}, nil
+}
+
+type packetConn struct {
+	net.PacketConn
+	policy *policy
+	log    *logp.Logger
+}
+
+// Addr is the address of a datagram received with a PROXY protocol header.
+// It is the source address of the header, or the address of the peer if the
+// header has none.
+type Addr struct {
+	net.Addr
+	Header *Header
+}
+
+// HeaderFromAddr returns the address and the header of a datagram read from
+// a connection created with NewPacketConn. The header is nil if the datagram
+// has none.
+func HeaderFromAddr(addr net.Addr) (net.Addr, *Header) {
+	if a, ok := addr.(*Addr); ok {
+		return a.Addr, a.Header
+	}
+	return addr, nil
+}
+
+// NewPacketConn wraps c to strip the version 2 PROXY protocol header from
+// received datagrams. The address of a datagram with a header is an *Addr
+// holding the source address and the header, see HeaderFromAddr. Datagrams
+// with an invalid header, or rejected by the configuration, are dropped.
+func NewPacketConn(c net.PacketConn, cfg Config, log *logp.Logger) (net.PacketConn, error) {
+	p, err := newPolicy(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &packetConn{PacketConn: c, policy: p, log: log}, nil
+}
+
+// ReadFrom reads the next accepted datagram.
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		n, addr, err := c.PacketConn.ReadFrom(b)
+		if err != nil || n == 0 {
+			return n, addr, err
+		}
+
+		h, length, err := ParseDatagram(b[:n])
+		switch {
+		case errors.Is(err, ErrNoHeader):
+			if !c.policy.required {
+				return n, addr, nil
+			}
+			c.log.Debugw("Dropping datagram without PROXY protocol header", "remote_address", addr)
+			continue
+		case err != nil:
+			c.log.Debugw("Dropping datagram with invalid PROXY protocol header", "remote_address", addr, "error", err)
+			continue
+		case !c.policy.isTrusted(addr):
+			c.log.Debugw("Dropping datagram with PROXY protocol header from untrusted peer", "remote_address", addr)
+			continue
+		}
+
+		n = copy(b, b[length:n])
+		if h.SourceAddr != nil {
+			addr = h.SourceAddr
+		}
+		return n, &Addr{Addr: addr, Header: h}, nil
+	}
+}
+
+// WriteTo writes a datagram to addr, unwrapping addresses returned by ReadFrom.
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	addr, _ = HeaderFromAddr(addr)
+	return c.PacketConn.WriteTo(b, addr)
+}
@@ -0,0 +1,236 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package proxyproto
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const headerV1 = "PROXY TCP4 192.0.2.1 198.51.100.2 51234 514\r\n"
+
+func TestListener(t *testing.T) {
+	tests := map[string]struct {
+		config     Config
+		data       string
+		remoteAddr string
+		header     bool
+		err        string
+	}{
+		"header": {
+			config:     Config{Enabled: true},
+			data:       headerV1 + "hello",
+			remoteAddr: "192.0.2.1:51234",
+			header:     true,
+		},
+		"no header": {
+			config: Config{Enabled: true},
+			data:   "hello",
+		},
+		"required header": {
+			config: Config{Enabled: true, Required: true},
+			data:   "hello",
+			err:    "no PROXY protocol header received",
+		},
+		"trusted peer": {
+			config:     Config{Enabled: true, TrustedProxies: []string{"10.0.0.0/8", "127.0.0.1"}},
+			data:       headerV1 + "hello",
+			remoteAddr: "192.0.2.1:51234",
+			header:     true,
+		},
+		"untrusted peer": {
+			config: Config{Enabled: true, TrustedProxies: []string{"10.0.0.0/8"}},
+			data:   headerV1 + "hello",
+			err:    "PROXY protocol header received from untrusted peer",
+		},
+		"untrusted peer without header": {
+			config: Config{Enabled: true, TrustedProxies: []string{"10.0.0.0/8"}},
+			data:   "hello",
+		},
+		"invalid header": {
+			config: Config{Enabled: true},
+			data:   "PROXY TCP4 192.0.2.1\r\nhello",
+			err:    "invalid PROXY protocol header",
+		},
+		"header timeout": {
+			config: Config{Enabled: true, HeaderTimeout: 50 * time.Millisecond},
+			data:   "PROXY TCP4",
+			err:    "i/o timeout",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			l, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(t, err)
+			l, err = NewListener(l, tc.config)
+			require.NoError(t, err)
+			defer l.Close()
+
+			client, err := net.Dial("tcp", l.Addr().String())
+			require.NoError(t, err)
+			defer client.Close()
+			_, err = client.Write([]byte(tc.data))
+			require.NoError(t, err)
+
+			conn, err := l.Accept()
+			require.NoError(t, err)
+			defer conn.Close()
+
+			b := make([]byte, 5)
+			_, err = io.ReadFull(conn, b)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(b))
+
+			if tc.remoteAddr != "" {
+				assert.Equal(t, tc.remoteAddr, conn.RemoteAddr().String())
+			} else {
+				assert.Equal(t, client.LocalAddr().String(), conn.RemoteAddr().String())
+			}
+			assert.Equal(t, client.LocalAddr().String(), conn.(*Conn).ProxyAddr().String())
+			assert.Equal(t, tc.header, HeaderFromConn(conn) != nil)
+		})
+	}
+}
+
+func TestConnKeepsReadDeadline(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	l, err = NewListener(l, Config{Enabled: true, HeaderTimeout: time.Minute})
+	require.NoError(t, err)
+	defer l.Close()
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer client.Close()
+	_, err = client.Write([]byte(headerV1))
+	require.NoError(t, err)
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// The deadline set before the header is read must still apply to the
+	// data read after it.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestPacketConn(t *testing.T) {
+	tests := map[string]struct {
+		config Config
+		data   [][]byte
+		want   []string
+		addr   string
+	}{
+		"header": {
+			config: Config{Enabled: true},
+			data: [][]byte{
+				append(headerV2(byte(CommandProxy), 0x12, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514)), "hello"...),
+			},
+			want: []string{"hello"},
+			addr: "192.0.2.1:51234",
+		},
+		"no header": {
+			config: Config{Enabled: true},
+			data:   [][]byte{[]byte("hello")},
+			want:   []string{"hello"},
+		},
+		"required header": {
+			config: Config{Enabled: true, Required: true},
+			data: [][]byte{
+				[]byte("dropped"),
+				append(headerV2(byte(CommandProxy), 0x12, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514)), "hello"...),
+			},
+			want: []string{"hello"},
+			addr: "192.0.2.1:51234",
+		},
+		"untrusted peer": {
+			config: Config{Enabled: true, TrustedProxies: []string{"10.0.0.0/8"}},
+			data: [][]byte{
+				append(headerV2(byte(CommandProxy), 0x12, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514)), "dropped"...),
+				[]byte("hello"),
+			},
+			want: []string{"hello"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+			require.NoError(t, err)
+			pc, err = NewPacketConn(pc, tc.config, logp.NewLogger("test"))
+			require.NoError(t, err)
+			defer pc.Close()
+
+			client, err := net.Dial("udp", pc.LocalAddr().String())
+			require.NoError(t, err)
+			defer client.Close()
+			for _, d := range tc.data {
+				_, err = client.Write(d)
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+			for _, want := range tc.want {
+				b := make([]byte, 1024)
+				n, addr, err := pc.ReadFrom(b)
+				require.NoError(t, err)
+				assert.Equal(t, want, string(b[:n]))
+				addr, h := HeaderFromAddr(addr)
+				if tc.addr != "" {
+					assert.Equal(t, tc.addr, addr.String())
+					require.NotNil(t, h)
+					assert.Equal(t, "198.51.100.2:514", h.DestinationAddr.String())
+				} else {
+					assert.Equal(t, client.LocalAddr().String(), addr.String())
+					assert.Nil(t, h)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	var c Config
+	err := conf.MustNewConfigFrom(map[string]interface{}{
+		"enabled":         true,
+		"trusted_proxies": []string{"10.0.0.0/8", "not an address"},
+	}).Unpack(&c)
+	require.ErrorContains(t, err, `invalid trusted proxy address "not an address"`)
+
+	err = conf.MustNewConfigFrom(map[string]interface{}{
+		"trusted_proxies": []string{"10.0.0.0/33"},
+	}).Unpack(&c)
+	require.ErrorContains(t, err, `invalid trusted proxy CIDR "10.0.0.0/33"`)
+}
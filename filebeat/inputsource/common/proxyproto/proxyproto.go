@@ -0,0 +1,376 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package proxyproto implements the receiving side of the PROXY protocol
+// versions 1 and 2, as used by HAProxy and load balancers to forward the
+// address of the original client.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var (
+	// ErrNoHeader is returned when the data does not start with a PROXY
+	// protocol header.
+	ErrNoHeader = errors.New("no PROXY protocol header")
+	// ErrInvalidHeader is returned when a PROXY protocol header is malformed.
+	ErrInvalidHeader = errors.New("invalid PROXY protocol header")
+)
+
+var (
+	signatureV1 = []byte("PROXY ")
+	signatureV2 = []byte("\r\n\r\n\x00\r\nQUIT\n")
+)
+
+const (
+	// maxLengthV1 is the maximum length of a version 1 header, including the
+	// CRLF.
+	maxLengthV1 = 107
+	// headerLengthV2 is the length of the fixed part of a version 2 header.
+	headerLengthV2 = 16
+)
+
+// Command is the command of a PROXY protocol header.
+type Command byte
+
+const (
+	// CommandLocal is sent for connections established by the proxy itself,
+	// for example health checks. The addresses of the connection are used.
+	CommandLocal Command = 0x0
+	// CommandProxy is sent for connections relayed on behalf of a client.
+	CommandProxy Command = 0x1
+)
+
+// TLV types defined by the PROXY protocol specification.
+const (
+	TLVTypeALPN      byte = 0x01
+	TLVTypeAuthority byte = 0x02
+	TLVTypeCRC32C    byte = 0x03
+	TLVTypeNoop      byte = 0x04
+	TLVTypeUniqueID  byte = 0x05
+	TLVTypeSSL       byte = 0x20
+	TLVTypeNetNS     byte = 0x30
+)
+
+// TLV is a type-length-value vector of a version 2 header.
+type TLV struct {
+	Type  byte
+	Value []byte
+}
+
+// Header is a decoded PROXY protocol header.
+type Header struct {
+	Version int
+	Command Command
+	// SourceAddr and DestinationAddr are the addresses of the original
+	// connection. They are nil if the header does not carry addresses, for
+	// example for the LOCAL command or the UNKNOWN protocol.
+	SourceAddr      net.Addr
+	DestinationAddr net.Addr
+	TLVs            []TLV
+}
+
+// TLV returns the value of the first TLV of type t.
+func (h *Header) TLV(t byte) ([]byte, bool) {
+	for _, tlv := range h.TLVs {
+		if tlv.Type == t {
+			return tlv.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Fields returns the source and destination fields of the event.
+func (h *Header) Fields() mapstr.M {
+	fields := mapstr.M{}
+	addAddrFields(fields, "source", h.SourceAddr)
+	addAddrFields(fields, "destination", h.DestinationAddr)
+	if authority, ok := h.TLV(TLVTypeAuthority); ok && len(authority) > 0 {
+		_, _ = fields.Put("destination.domain", string(authority))
+	}
+	return fields
+}
+
+func addAddrFields(fields mapstr.M, key string, addr net.Addr) {
+	switch addr := addr.(type) {
+	case *net.TCPAddr:
+		fields[key] = mapstr.M{"ip": addr.IP.String(), "port": addr.Port}
+	case *net.UDPAddr:
+		fields[key] = mapstr.M{"ip": addr.IP.String(), "port": addr.Port}
+	case *net.UnixAddr:
+		if addr.Name != "" {
+			fields[key] = mapstr.M{"address": addr.Name}
+		}
+	}
+}
+
+// ReadHeader reads a version 1 or version 2 header from r. ErrNoHeader is
+// returned, and nothing is consumed from r, if the data does not start with
+// a header. ReadHeader only blocks waiting for more data while the data
+// received so far is the beginning of a header signature.
+func ReadHeader(r *bufio.Reader) (*Header, error) {
+	for n := 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrNoHeader
+			}
+			return nil, err
+		}
+
+		switch {
+		case bytes.HasPrefix(signatureV1, b):
+			if n == len(signatureV1) {
+				return readV1(r)
+			}
+		case bytes.HasPrefix(signatureV2, b):
+			if n == len(signatureV2) {
+				return readV2(r)
+			}
+		default:
+			return nil, ErrNoHeader
+		}
+	}
+}
+
+// ParseDatagram parses the version 2 header at the beginning of a datagram.
+// It returns the header and its length. ErrNoHeader is returned if the
+// datagram does not start with a header.
+func ParseDatagram(b []byte) (*Header, int, error) {
+	if !bytes.HasPrefix(b, signatureV2) {
+		return nil, 0, ErrNoHeader
+	}
+	if len(b) < headerLengthV2 {
+		return nil, 0, fmt.Errorf("%w: truncated header", ErrInvalidHeader)
+	}
+	length := headerLengthV2 + int(binary.BigEndian.Uint16(b[14:16]))
+	if len(b) < length {
+		return nil, 0, fmt.Errorf("%w: truncated header", ErrInvalidHeader)
+	}
+	h, err := parseV2(b[:headerLengthV2], b[headerLengthV2:length])
+	if err != nil {
+		return nil, 0, err
+	}
+	return h, length, nil
+}
+
+func readV1(r *bufio.Reader) (*Header, error) {
+	var line []byte
+	for n := len(signatureV1) + 1; ; n++ {
+		b, err := r.Peek(n)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+		}
+		if b[n-1] == '\n' {
+			line = b
+			break
+		}
+		if n == maxLengthV1 {
+			return nil, fmt.Errorf("%w: version 1 header longer than %d bytes", ErrInvalidHeader, maxLengthV1)
+		}
+	}
+
+	h, err := parseV1(line)
+	if err != nil {
+		return nil, err
+	}
+	_, _ = r.Discard(len(line))
+	return h, nil
+}
+
+func parseV1(line []byte) (*Header, error) {
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, fmt.Errorf("%w: version 1 header does not end with CRLF", ErrInvalidHeader)
+	}
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+
+	h := &Header{Version: 1, Command: CommandProxy}
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		// The receiver must ignore the rest of the line and use the
+		// addresses of the connection.
+		return h, nil
+	}
+	if len(parts) != 6 {
+		return nil, fmt.Errorf("%w: version 1 header has %d fields", ErrInvalidHeader, len(parts))
+	}
+
+	var ipLen int
+	switch parts[1] {
+	case "TCP4":
+		ipLen = net.IPv4len
+	case "TCP6":
+		ipLen = net.IPv6len
+	default:
+		return nil, fmt.Errorf("%w: unsupported protocol %q", ErrInvalidHeader, parts[1])
+	}
+
+	src, err := parseV1Addr(parts[2], parts[4], ipLen)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseV1Addr(parts[3], parts[5], ipLen)
+	if err != nil {
+		return nil, err
+	}
+	h.SourceAddr, h.DestinationAddr = src, dst
+	return h, nil
+}
+
+func parseV1Addr(ip, port string, ipLen int) (*net.TCPAddr, error) {
+	addr := net.ParseIP(ip)
+	if addr == nil || strings.Contains(ip, ":") != (ipLen == net.IPv6len) {
+		return nil, fmt.Errorf("%w: invalid address %q", ErrInvalidHeader, ip)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || (len(port) > 1 && port[0] == '0') {
+		return nil, fmt.Errorf("%w: invalid port %q", ErrInvalidHeader, port)
+	}
+	return &net.TCPAddr{IP: addr, Port: int(p)}, nil
+}
+
+func readV2(r *bufio.Reader) (*Header, error) {
+	head, err := r.Peek(headerLengthV2)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	head = append([]byte(nil), head...)
+	_, _ = r.Discard(headerLengthV2)
+
+	body := make([]byte, binary.BigEndian.Uint16(head[14:16]))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidHeader, err)
+	}
+	return parseV2(head, body)
+}
+
+// Address families and transport protocols of version 2 headers.
+const (
+	familyUnspec = 0x0
+	familyInet   = 0x1
+	familyInet6  = 0x2
+	familyUnix   = 0x3
+
+	protoUnspec = 0x0
+	protoStream = 0x1
+	protoDgram  = 0x2
+)
+
+// lengthUnixAddr is the length of an address of the AF_UNIX family.
+const lengthUnixAddr = 108
+
+func parseV2(head, body []byte) (*Header, error) {
+	if version := head[12] >> 4; version != 2 {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidHeader, version)
+	}
+	h := &Header{Version: 2, Command: Command(head[12] & 0x0f)}
+	if h.Command != CommandLocal && h.Command != CommandProxy {
+		return nil, fmt.Errorf("%w: unsupported command %d", ErrInvalidHeader, h.Command)
+	}
+
+	family, proto := head[13]>>4, head[13]&0x0f
+	if proto > protoDgram {
+		return nil, fmt.Errorf("%w: unsupported transport protocol %d", ErrInvalidHeader, proto)
+	}
+
+	var addrLen int
+	switch family {
+	case familyUnspec:
+	case familyInet:
+		addrLen = 2*net.IPv4len + 4
+	case familyInet6:
+		addrLen = 2*net.IPv6len + 4
+	case familyUnix:
+		addrLen = 2 * lengthUnixAddr
+	default:
+		return nil, fmt.Errorf("%w: unsupported address family %d", ErrInvalidHeader, family)
+	}
+	if len(body) < addrLen {
+		return nil, fmt.Errorf("%w: %d bytes are too short for the addresses", ErrInvalidHeader, len(body))
+	}
+
+	tlvs, err := parseTLVs(body[addrLen:])
+	if err != nil {
+		return nil, err
+	}
+	h.TLVs = tlvs
+
+	// The addresses of LOCAL commands and unspecified protocols must be
+	// ignored by the receiver.
+	if h.Command == CommandLocal || family == familyUnspec || proto == protoUnspec {
+		return h, nil
+	}
+
+	switch family {
+	case familyInet, familyInet6:
+		ipLen := (addrLen - 4) / 2
+		srcIP := net.IP(append([]byte(nil), body[:ipLen]...))
+		dstIP := net.IP(append([]byte(nil), body[ipLen:2*ipLen]...))
+		srcPort := int(binary.BigEndian.Uint16(body[2*ipLen:]))
+		dstPort := int(binary.BigEndian.Uint16(body[2*ipLen+2:]))
+		if proto == protoStream {
+			h.SourceAddr = &net.TCPAddr{IP: srcIP, Port: srcPort}
+			h.DestinationAddr = &net.TCPAddr{IP: dstIP, Port: dstPort}
+		} else {
+			h.SourceAddr = &net.UDPAddr{IP: srcIP, Port: srcPort}
+			h.DestinationAddr = &net.UDPAddr{IP: dstIP, Port: dstPort}
+		}
+	case familyUnix:
+		network := "unix"
+		if proto == protoDgram {
+			network = "unixgram"
+		}
+		h.SourceAddr = &net.UnixAddr{Name: unixName(body[:lengthUnixAddr]), Net: network}
+		h.DestinationAddr = &net.UnixAddr{Name: unixName(body[lengthUnixAddr:addrLen]), Net: network}
+	}
+	return h, nil
+}
+
+func parseTLVs(b []byte) ([]TLV, error) {
+	var tlvs []TLV
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return nil, fmt.Errorf("%w: truncated TLV", ErrInvalidHeader)
+		}
+		length := 3 + int(binary.BigEndian.Uint16(b[1:3]))
+		if len(b) < length {
+			return nil, fmt.Errorf("%w: truncated TLV of type 0x%02x", ErrInvalidHeader, b[0])
+		}
+		if b[0] != TLVTypeNoop {
+			tlvs = append(tlvs, TLV{Type: b[0], Value: append([]byte(nil), b[3:length]...)})
+		}
+		b = b[length:]
+	}
+	return tlvs, nil
+}
+
+func unixName(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
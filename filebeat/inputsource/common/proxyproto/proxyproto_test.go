@@ -0,0 +1,220 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package proxyproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// headerV2 builds a version 2 header.
+func headerV2(command, familyProto byte, addrs []byte, tlvs ...TLV) []byte {
+	body := append([]byte(nil), addrs...)
+	for _, tlv := range tlvs {
+		body = append(body, tlv.Type, 0, 0)
+		binary.BigEndian.PutUint16(body[len(body)-2:], uint16(len(tlv.Value)))
+		body = append(body, tlv.Value...)
+	}
+	b := append([]byte(nil), signatureV2...)
+	b = append(b, 0x20|command, familyProto, 0, 0)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(body)))
+	return append(b, body...)
+}
+
+func inetAddrs(src, dst string, srcPort, dstPort uint16) []byte {
+	srcIP, dstIP := net.ParseIP(src), net.ParseIP(dst)
+	if ip4 := srcIP.To4(); ip4 != nil {
+		srcIP, dstIP = ip4, dstIP.To4()
+	}
+	b := append(append([]byte(nil), srcIP...), dstIP...)
+	b = binary.BigEndian.AppendUint16(b, srcPort)
+	return binary.BigEndian.AppendUint16(b, dstPort)
+}
+
+func TestReadHeader(t *testing.T) {
+	unixAddrs := make([]byte, 2*lengthUnixAddr)
+	copy(unixAddrs, "/run/client.sock")
+	copy(unixAddrs[lengthUnixAddr:], "/run/server.sock")
+
+	tests := map[string]struct {
+		data   string
+		header *Header
+		err    error
+	}{
+		"v1 tcp4": {
+			data: "PROXY TCP4 192.0.2.1 198.51.100.2 51234 514\r\nhello",
+			header: &Header{
+				Version:         1,
+				Command:         CommandProxy,
+				SourceAddr:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51234},
+				DestinationAddr: &net.TCPAddr{IP: net.ParseIP("198.51.100.2"), Port: 514},
+			},
+		},
+		"v1 tcp6": {
+			data: "PROXY TCP6 2001:db8::1 2001:db8::2 51234 514\r\nhello",
+			header: &Header{
+				Version:         1,
+				Command:         CommandProxy,
+				SourceAddr:      &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51234},
+				DestinationAddr: &net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 514},
+			},
+		},
+		"v1 unknown": {
+			data:   "PROXY UNKNOWN ffff::1 ffff::2 1 2\r\nhello",
+			header: &Header{Version: 1, Command: CommandProxy},
+		},
+		"v1 mismatched family": {
+			data: "PROXY TCP4 2001:db8::1 2001:db8::2 51234 514\r\nhello",
+			err:  ErrInvalidHeader,
+		},
+		"v1 invalid port": {
+			data: "PROXY TCP4 192.0.2.1 198.51.100.2 65536 514\r\nhello",
+			err:  ErrInvalidHeader,
+		},
+		"v1 missing CRLF": {
+			data: "PROXY TCP4 192.0.2.1 198.51.100.2 51234 514\nhello",
+			err:  ErrInvalidHeader,
+		},
+		"v1 too long": {
+			data: "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n",
+			err:  ErrInvalidHeader,
+		},
+		"v2 tcp4 with TLVs": {
+			data: string(headerV2(byte(CommandProxy), 0x11, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514),
+				TLV{Type: TLVTypeAuthority, Value: []byte("logs.example.com")},
+				TLV{Type: TLVTypeNoop, Value: []byte{0, 0}},
+				TLV{Type: 0xEA, Value: []byte("\x01vpce-0123")},
+			)) + "hello",
+			header: &Header{
+				Version:         2,
+				Command:         CommandProxy,
+				SourceAddr:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 51234},
+				DestinationAddr: &net.TCPAddr{IP: net.ParseIP("198.51.100.2").To4(), Port: 514},
+				TLVs: []TLV{
+					{Type: TLVTypeAuthority, Value: []byte("logs.example.com")},
+					{Type: 0xEA, Value: []byte("\x01vpce-0123")},
+				},
+			},
+		},
+		"v2 udp6": {
+			data: string(headerV2(byte(CommandProxy), 0x22, inetAddrs("2001:db8::1", "2001:db8::2", 51234, 514))) + "hello",
+			header: &Header{
+				Version:         2,
+				Command:         CommandProxy,
+				SourceAddr:      &net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 51234},
+				DestinationAddr: &net.UDPAddr{IP: net.ParseIP("2001:db8::2"), Port: 514},
+			},
+		},
+		"v2 unix": {
+			data: string(headerV2(byte(CommandProxy), 0x31, unixAddrs)) + "hello",
+			header: &Header{
+				Version:         2,
+				Command:         CommandProxy,
+				SourceAddr:      &net.UnixAddr{Name: "/run/client.sock", Net: "unix"},
+				DestinationAddr: &net.UnixAddr{Name: "/run/server.sock", Net: "unix"},
+			},
+		},
+		"v2 local": {
+			data:   string(headerV2(byte(CommandLocal), 0x11, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514))) + "hello",
+			header: &Header{Version: 2, Command: CommandLocal},
+		},
+		"v2 truncated addresses": {
+			data: string(headerV2(byte(CommandProxy), 0x21, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514))) + "hello",
+			err:  ErrInvalidHeader,
+		},
+		"v2 truncated TLV": {
+			data: string(headerV2(byte(CommandProxy), 0x11, append(inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514), TLVTypeAuthority, 0))) + "hello",
+			err:  ErrInvalidHeader,
+		},
+		"v2 unsupported command": {
+			data: string(headerV2(0x2, 0x11, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514))) + "hello",
+			err:  ErrInvalidHeader,
+		},
+		"no header": {
+			data: "hello",
+			err:  ErrNoHeader,
+		},
+		"data that starts like a header": {
+			data: "PROXIMITY",
+			err:  ErrNoHeader,
+		},
+		"short data": {
+			data: "PRO",
+			err:  ErrNoHeader,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tc.data))
+			h, err := ReadHeader(r)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				if tc.err == ErrNoHeader {
+					rest, _ := io.ReadAll(r)
+					assert.Equal(t, tc.data, string(rest), "no data must be consumed")
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.header, h)
+
+			rest, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "hello", string(rest))
+		})
+	}
+}
+
+func TestParseDatagram(t *testing.T) {
+	header := headerV2(byte(CommandProxy), 0x12, inetAddrs("192.0.2.1", "198.51.100.2", 51234, 514))
+
+	h, n, err := ParseDatagram(append(append([]byte(nil), header...), "hello"...))
+	require.NoError(t, err)
+	assert.Equal(t, len(header), n)
+	assert.Equal(t, &net.UDPAddr{IP: net.ParseIP("192.0.2.1").To4(), Port: 51234}, h.SourceAddr)
+
+	_, _, err = ParseDatagram([]byte("hello"))
+	assert.ErrorIs(t, err, ErrNoHeader)
+
+	_, _, err = ParseDatagram(header[:len(header)-1])
+	assert.ErrorIs(t, err, ErrInvalidHeader)
+}
+
+func TestHeaderFields(t *testing.T) {
+	h := &Header{
+		SourceAddr:      &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 51234},
+		DestinationAddr: &net.TCPAddr{IP: net.ParseIP("198.51.100.2"), Port: 514},
+		TLVs:            []TLV{{Type: TLVTypeAuthority, Value: []byte("logs.example.com")}},
+	}
+	assert.Equal(t, mapstr.M{
+		"source":      mapstr.M{"ip": "192.0.2.1", "port": 51234},
+		"destination": mapstr.M{"ip": "198.51.100.2", "port": 514, "domain": "logs.example.com"},
+	}, h.Fields())
+
+	assert.Equal(t, mapstr.M{}, (&Header{Version: 2, Command: CommandLocal}).Fields())
+}
@@ -19,6 +19,8 @@ package inputsource
 
 import (
 	"net"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 )
 
 // Network interface implemented by TCP and UDP input source.
@@ -32,6 +34,12 @@ type NetworkMetadata struct {
 	RemoteAddr net.Addr
 	Truncated  bool
 	TLS        *TLSMetadata
+	// Proxy is the PROXY protocol header of the connection, if any. When set,
+	// RemoteAddr is the source address of the header.
+	Proxy *proxyproto.Header
+	// PacketConn is the connection a datagram was read from, so that replies
+	// can be sent to RemoteAddr from the same local address.
+	PacketConn net.PacketConn
 }
 
 // TLSMetadata defines information about the current SSL connection.
@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
 	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
 )
@@ -37,6 +38,7 @@ type Config struct {
 	MaxConnections int                     `config:"max_connections"`
 	TLS            *tlscommon.ServerConfig `config:"ssl"`
 	Network        string                  `config:"network"`
+	ProxyProtocol  proxyproto.Config       `config:"proxy_protocol"`
 }
 
 const (
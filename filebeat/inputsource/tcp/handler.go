@@ -23,6 +23,7 @@ import (
 	"net"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
 )
 
@@ -31,6 +32,7 @@ func MetadataCallback(conn net.Conn) inputsource.NetworkMetadata {
 	return inputsource.NetworkMetadata{
 		RemoteAddr: conn.RemoteAddr(),
 		TLS:        extractSSLInformation(conn),
+		Proxy:      proxyproto.HeaderFromConn(conn),
 	}
 }
 
@@ -25,6 +25,7 @@ import (
 	"golang.org/x/net/netutil"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
 	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
 )
@@ -65,24 +66,25 @@ func New(
 }
 
 func (s *Server) createServer() (net.Listener, error) {
-	var l net.Listener
-	var err error
-	network := s.network()
-	if s.tlsConfig != nil {
-		t := s.tlsConfig.BuildServerConfig(s.config.Host)
-		l, err = tls.Listen(network, s.config.Host, t)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		l, err = net.Listen(network, s.config.Host)
+	l, err := net.Listen(s.network(), s.config.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.config.MaxConnections > 0 {
+		l = netutil.LimitListener(l, s.config.MaxConnections)
+	}
+
+	// The PROXY protocol header is sent before the TLS handshake.
+	if s.config.ProxyProtocol.Enabled {
+		l, err = proxyproto.NewListener(l, s.config.ProxyProtocol)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if s.config.MaxConnections > 0 {
-		return netutil.LimitListener(l, s.config.MaxConnections), nil
+	if s.tlsConfig != nil {
+		l = tls.NewListener(l, s.tlsConfig.BuildServerConfig(s.config.Host))
 	}
 	return l, nil
 }
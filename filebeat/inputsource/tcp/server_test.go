@@ -321,6 +321,43 @@ func TestReceiveNewEventsConcurrently(t *testing.T) {
 	}
 }
 
+func TestReceiveEventsWithProxyProtocol(t *testing.T) {
+	ch := make(chan *info, 2)
+	to := func(message []byte, mt inputsource.NetworkMetadata) {
+		ch <- &info{message: string(message), mt: mt}
+	}
+	cfg, err := conf.NewConfigFrom(map[string]interface{}{
+		"host":           "127.0.0.1:0",
+		"proxy_protocol": map[string]interface{}{"enabled": true},
+	})
+	require.NoError(t, err)
+	config := defaultConfig
+	require.NoError(t, cfg.Unpack(&config))
+
+	factory := streaming.SplitHandlerFactory(inputsource.FamilyTCP, logp.NewLogger("test"), MetadataCallback, to, bufio.ScanLines)
+	server, err := New(&config, factory)
+	require.NoError(t, err)
+	require.NoError(t, server.Start())
+	defer server.Stop()
+
+	conn, err := net.Dial("tcp", server.Listener.Listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	fmt.Fprint(conn, "PROXY TCP4 192.0.2.1 198.51.100.2 51234 514\r\nfirst\nsecond\n")
+
+	for _, want := range []string{"first", "second"} {
+		select {
+		case event := <-ch:
+			assert.Equal(t, want, event.message)
+			assert.Equal(t, "192.0.2.1:51234", event.mt.RemoteAddr.String())
+			require.NotNil(t, event.mt.Proxy)
+			assert.Equal(t, "198.51.100.2:514", event.mt.Proxy.DestinationAddr.String())
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout waiting for events")
+		}
+	}
+}
+
 func randomString(l int) string {
 	charsets := []byte("abcdefghijklmnopqrstuvwzyzABCDEFGHIJKLMNOPQRSTUVWZYZ0123456789")
 	message := make([]byte, l)
@@ -0,0 +1,93 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build linux
+
+package udp
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+	"golang.org/x/sys/unix"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/dgram"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// batchReader is implemented by ipv4.PacketConn and ipv6.PacketConn.
+type batchReader interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// batchReaderFactory returns a handler reading up to batchSize datagrams
+// with a single recvmmsg call.
+func batchReaderFactory(logger *logp.Logger, callback inputsource.NetworkFunc, batchSize int) dgram.HandlerFactory {
+	return func(config dgram.ListenerConfig) dgram.ConnectionHandler {
+		return dgram.ConnectionHandler(func(ctx context.Context, conn net.PacketConn) error {
+			reader := newBatchReader(conn)
+			msgs := make([]ipv4.Message, batchSize)
+			for i := range msgs {
+				msgs[i].Buffers = [][]byte{make([]byte, config.MaxMessageSize)}
+			}
+
+			for ctx.Err() == nil {
+				n, err := reader.ReadBatch(msgs, 0)
+				if err != nil {
+					// don't log any deadline events.
+					var netErr net.Error
+					if errors.As(err, &netErr) && netErr.Timeout() {
+						continue
+					}
+					if errors.Is(err, net.ErrClosed) {
+						logger.Info("Connection has been closed")
+						return nil
+					}
+					logger.Errorf("Error reading from the socket %s", err)
+					continue
+				}
+
+				for i := range msgs[:n] {
+					msg := &msgs[i]
+					if msg.N == 0 {
+						continue
+					}
+					callback(msg.Buffers[0][:msg.N], inputsource.NetworkMetadata{
+						RemoteAddr: msg.Addr,
+						Truncated:  msg.Flags&unix.MSG_TRUNC != 0,
+						PacketConn: conn,
+					})
+					// The callback may keep a reference to the data.
+					msg.Buffers[0] = make([]byte, config.MaxMessageSize)
+				}
+			}
+			logger.Debug("end of connection handling")
+			return nil
+		})
+	}
+}
+
+func newBatchReader(conn net.PacketConn) batchReader {
+	if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok && addr.IP.To4() != nil {
+		return ipv4.NewPacketConn(conn)
+	}
+	return ipv6.NewPacketConn(conn)
+}
@@ -20,18 +20,36 @@ package udp
 import (
 	"errors"
 	"fmt"
+	"net"
 	"time"
 
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
 )
 
 // Config options for the UDPServer
 type Config struct {
-	Host           string           `config:"host"`
-	MaxMessageSize cfgtype.ByteSize `config:"max_message_size" validate:"positive,nonzero"`
-	Timeout        time.Duration    `config:"timeout"`
-	ReadBuffer     cfgtype.ByteSize `config:"read_buffer" validate:"positive"`
-	Network        string           `config:"network"`
+	Host           string            `config:"host"`
+	MaxMessageSize cfgtype.ByteSize  `config:"max_message_size" validate:"positive,nonzero"`
+	Timeout        time.Duration     `config:"timeout"`
+	ReadBuffer     cfgtype.ByteSize  `config:"read_buffer" validate:"positive"`
+	Network        string            `config:"network"`
+	ProxyProtocol  proxyproto.Config `config:"proxy_protocol"`
+	Multicast      MulticastConfig   `config:"multicast"`
+	// Readers is the number of sockets bound to Host with SO_REUSEPORT,
+	// each one read in parallel.
+	Readers int `config:"readers" validate:"positive"`
+	// BatchSize is the maximum number of datagrams read with a single
+	// recvmmsg call on Linux.
+	BatchSize int `config:"batch_size" validate:"positive"`
+}
+
+// MulticastConfig configures the multicast groups joined by the server.
+type MulticastConfig struct {
+	Groups []string `config:"groups"`
+	// Interface is the name of the network interface used to join the
+	// groups. The system default is used if it is empty.
+	Interface string `config:"interface"`
 }
 
 const (
@@ -40,7 +58,12 @@ const (
 	networkUDP6 = "udp6"
 )
 
-var ErrInvalidNetwork = errors.New("invalid network value")
+var (
+	ErrInvalidNetwork        = errors.New("invalid network value")
+	ErrInvalidMulticastGroup = errors.New("invalid multicast group")
+	ErrBatchWithProxyProto   = errors.New("batch_size cannot be used with proxy_protocol")
+	ErrReadersWithMulticast  = errors.New("readers cannot be used with multicast.groups")
+)
 
 // Validate validates the Config option for the udp input.
 func (c *Config) Validate() error {
@@ -49,5 +72,19 @@ func (c *Config) Validate() error {
 	default:
 		return fmt.Errorf("%w: %s, expected: %v or %v or %v", ErrInvalidNetwork, c.Network, networkUDP, networkUDP4, networkUDP6)
 	}
+	for _, group := range c.Multicast.Groups {
+		ip := net.ParseIP(group)
+		if ip == nil || !ip.IsMulticast() {
+			return fmt.Errorf("%w: %s", ErrInvalidMulticastGroup, group)
+		}
+	}
+	// Every socket bound to the port receives a copy of the multicast
+	// datagrams, so they would be read once per reader.
+	if c.Readers > 1 && len(c.Multicast.Groups) > 0 {
+		return ErrReadersWithMulticast
+	}
+	if c.BatchSize > 1 && c.ProxyProtocol.Enabled {
+		return ErrBatchWithProxyProto
+	}
 	return nil
 }
@@ -22,6 +22,8 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 )
 
 func TestValidate(t *testing.T) {
@@ -46,6 +48,45 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: ErrInvalidNetwork,
 		},
+		{
+			name: "multicast",
+			cfg: Config{
+				Host: "0.0.0.0:8080",
+				Multicast: MulticastConfig{
+					Groups: []string{"239.0.0.1", "ff02::1"},
+				},
+			},
+		},
+		{
+			name: "invalidmulticastgroup",
+			cfg: Config{
+				Host: "0.0.0.0:8080",
+				Multicast: MulticastConfig{
+					Groups: []string{"10.0.0.1"},
+				},
+			},
+			wantErr: ErrInvalidMulticastGroup,
+		},
+		{
+			name: "readerswithmulticast",
+			cfg: Config{
+				Host:    "0.0.0.0:8080",
+				Readers: 4,
+				Multicast: MulticastConfig{
+					Groups: []string{"239.0.0.1"},
+				},
+			},
+			wantErr: ErrReadersWithMulticast,
+		},
+		{
+			name: "batchwithproxyprotocol",
+			cfg: Config{
+				Host:          "localhost:8080",
+				BatchSize:     16,
+				ProxyProtocol: proxyproto.Config{Enabled: true},
+			},
+			wantErr: ErrBatchWithProxyProto,
+		},
 	}
 
 	for _, network := range []string{networkUDP, networkUDP4, networkUDP6} {
@@ -0,0 +1,52 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package udp
+
+import (
+	"fmt"
+	"net"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// joinGroups makes the connection join the configured multicast groups.
+func joinGroups(conn *net.UDPConn, config MulticastConfig) error {
+	var ifi *net.Interface
+	if config.Interface != "" {
+		var err error
+		ifi, err = net.InterfaceByName(config.Interface)
+		if err != nil {
+			return fmt.Errorf("failed to get multicast interface %s: %w", config.Interface, err)
+		}
+	}
+
+	for _, group := range config.Groups {
+		addr := &net.UDPAddr{IP: net.ParseIP(group)}
+		var err error
+		if addr.IP.To4() != nil {
+			err = ipv4.NewPacketConn(conn).JoinGroup(ifi, addr)
+		} else {
+			err = ipv6.NewPacketConn(conn).JoinGroup(ifi, addr)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to join multicast group %s: %w", group, err)
+		}
+	}
+	return nil
+}
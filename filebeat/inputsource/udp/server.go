@@ -18,10 +18,13 @@
 package udp
 
 import (
+	"context"
 	"net"
+	"strconv"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
 	"github.com/elastic/beats/v7/filebeat/inputsource/common/dgram"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
@@ -33,18 +36,23 @@ const Name = "udp"
 type Server struct {
 	*dgram.Listener
 	config *Config
+	log    *logp.Logger
 
 	localaddress string
 }
 
 // New returns a new UDPServer instance.
 func New(config *Config, callback inputsource.NetworkFunc) *Server {
-	server := &Server{config: config}
 	log := logp.NewLogger("udp").With("address", config.Host)
+	server := &Server{config: config, log: log}
 	factory := dgram.DatagramReaderFactory(inputsource.FamilyUDP, log, callback)
+	if config.BatchSize > 1 {
+		factory = batchReaderFactory(log, callback, config.BatchSize)
+	}
 	server.Listener = dgram.NewListener(inputsource.FamilyUDP, config.Host, factory, server.createConn, &dgram.ListenerConfig{
 		Timeout:        config.Timeout,
 		MaxMessageSize: config.MaxMessageSize,
+		Readers:        config.Readers,
 	})
 	return server
 }
@@ -56,10 +64,35 @@ func (u *Server) createConn() (net.PacketConn, error) {
 	if err != nil {
 		return nil, err
 	}
-	listener, err := net.ListenUDP(network, udpAdddr)
+
+	var lc net.ListenConfig
+	if u.config.Readers > 1 {
+		lc.Control = reusePort
+		// All the sockets must be bound to the same port when the
+		// configured one is picked by the system.
+		if udpAdddr.Port == 0 && u.localaddress != "" {
+			_, port, err := net.SplitHostPort(u.localaddress)
+			if err != nil {
+				return nil, err
+			}
+			udpAdddr.Port, err = strconv.Atoi(port)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	conn, err := lc.ListenPacket(context.Background(), network, udpAdddr.String())
 	if err != nil {
 		return nil, err
 	}
+	listener := conn.(*net.UDPConn)
+
+	if len(u.config.Multicast.Groups) > 0 {
+		if err := joinGroups(listener, u.config.Multicast); err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
 
 	if int(u.config.ReadBuffer) != 0 {
 		if err := listener.SetReadBuffer(int(u.config.ReadBuffer)); err != nil {
@@ -69,6 +102,9 @@ func (u *Server) createConn() (net.PacketConn, error) {
 
 	u.localaddress = listener.LocalAddr().String()
 
+	if u.config.ProxyProtocol.Enabled {
+		return proxyproto.NewPacketConn(listener, u.config.ProxyProtocol, u.log)
+	}
 	return listener, err
 }
 
@@ -18,6 +18,7 @@
 package udp
 
 import (
+	"fmt"
 	"net"
 	"runtime"
 	"testing"
@@ -26,6 +27,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 )
 
 const (
@@ -114,3 +116,194 @@ func testReceiveEventFromUDPWithNetwork(t *testing.T, network string) {
 		})
 	}
 }
+
+func TestReceiveEventFromUDPReaders(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("SO_REUSEPORT is not supported on Windows")
+	}
+
+	const count = 20
+	ch := make(chan info, count)
+	config := &Config{
+		Host:           "localhost:0",
+		MaxMessageSize: maxMessageSize,
+		Timeout:        timeout,
+		Network:        networkUDP4,
+		Readers:        4,
+		BatchSize:      8,
+	}
+	fn := func(message []byte, metadata inputsource.NetworkMetadata) {
+		ch <- info{message: message, mt: metadata}
+	}
+	s := New(config, fn)
+	err := s.Start()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial(s.network(), s.localaddress)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	for i := 0; i < count; i++ {
+		_, err = conn.Write([]byte("Hello world"))
+		if !assert.NoError(t, err) {
+			return
+		}
+	}
+	_, err = conn.Write([]byte("Hello world not so nice"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for i := 0; i < count; i++ {
+		info := <-ch
+		assert.Equal(t, []byte("Hello world"), info.message)
+		assert.NotNil(t, info.mt.RemoteAddr)
+		assert.False(t, info.mt.Truncated)
+	}
+	info := <-ch
+	assert.Equal(t, []byte("Hello world not so n"), info.message)
+	assert.Equal(t, runtime.GOOS == "linux", info.mt.Truncated)
+}
+
+func TestReceiveEventFromUDPProxyProtocol(t *testing.T) {
+	ch := make(chan info, 1)
+	config := &Config{
+		Host:           "localhost:0",
+		MaxMessageSize: 1024,
+		Timeout:        timeout,
+		Network:        networkUDP4,
+		ProxyProtocol:  proxyproto.Config{Enabled: true},
+	}
+	fn := func(message []byte, metadata inputsource.NetworkMetadata) {
+		ch <- info{message: message, mt: metadata}
+	}
+	s := New(config, fn)
+	err := s.Start()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer s.Stop()
+
+	conn, err := net.Dial(s.network(), s.localaddress)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	// Version 2 PROXY header for UDP over IPv4 from 192.0.2.1:51234 to
+	// 198.51.100.2:514.
+	header := []byte("\r\n\r\n\x00\r\nQUIT\n\x21\x12\x00\x0c")
+	header = append(header, 192, 0, 2, 1, 198, 51, 100, 2, 0xc8, 0x22, 0x02, 0x02)
+	_, err = conn.Write(append(header, "hello"...))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	select {
+	case info := <-ch:
+		assert.Equal(t, "hello", string(info.message))
+		assert.Equal(t, "192.0.2.1:51234", info.mt.RemoteAddr.String())
+		if assert.NotNil(t, info.mt.Proxy) {
+			assert.Equal(t, "198.51.100.2:514", info.mt.Proxy.DestinationAddr.String())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the event")
+	}
+}
+
+func TestReplyFromPacketConn(t *testing.T) {
+	for _, batchSize := range []int{0, 8} {
+		t.Run(fmt.Sprintf("batch_size=%d", batchSize), func(t *testing.T) {
+			config := &Config{
+				Host:           "localhost:0",
+				MaxMessageSize: maxMessageSize,
+				Timeout:        timeout,
+				Network:        networkUDP4,
+				BatchSize:      batchSize,
+			}
+			fn := func(message []byte, metadata inputsource.NetworkMetadata) {
+				_, err := metadata.PacketConn.WriteTo(append([]byte("re: "), message...), metadata.RemoteAddr)
+				assert.NoError(t, err)
+			}
+			s := New(config, fn)
+			err := s.Start()
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer s.Stop()
+
+			// A connected socket only receives datagrams from the address
+			// it sent to.
+			conn, err := net.Dial(s.network(), s.localaddress)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer conn.Close()
+
+			_, err = conn.Write([]byte("ping"))
+			if !assert.NoError(t, err) {
+				return
+			}
+			buf := make([]byte, maxMessageSize)
+			err = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+			if !assert.NoError(t, err) {
+				return
+			}
+			n, err := conn.Read(buf)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "re: ping", string(buf[:n]))
+		})
+	}
+}
+
+func TestReceiveEventFromUDPMulticast(t *testing.T) {
+	const group = "239.255.0.1"
+
+	ch := make(chan info, 1)
+	config := &Config{
+		Host:           "0.0.0.0:0",
+		MaxMessageSize: maxMessageSize,
+		Timeout:        timeout,
+		Network:        networkUDP4,
+		Multicast: MulticastConfig{
+			Groups: []string{group},
+		},
+	}
+	fn := func(message []byte, metadata inputsource.NetworkMetadata) {
+		ch <- info{message: message, mt: metadata}
+	}
+	s := New(config, fn)
+	err := s.Start()
+	if err != nil {
+		t.Skipf("cannot join multicast group: %v", err)
+	}
+	defer s.Stop()
+
+	_, port, err := net.SplitHostPort(s.localaddress)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn, err := net.Dial(networkUDP4, net.JoinHostPort(group, port))
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("Hello world"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	select {
+	case info := <-ch:
+		assert.Equal(t, []byte("Hello world"), info.message)
+	case <-time.After(timeout):
+		t.Fatal("timeout waiting for multicast datagram")
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
 	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
 )
@@ -55,6 +56,7 @@ type Config struct {
 	LineDelimiter  string                `config:"line_delimiter"`
 	Framing        streaming.FramingType `config:"framing"`
 	SocketType     SocketType            `config:"socket_type"`
+	ProxyProtocol  proxyproto.Config     `config:"proxy_protocol"`
 }
 
 // Validate validates the Config option for the unix input.
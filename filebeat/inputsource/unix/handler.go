@@ -21,9 +21,19 @@ import (
 	"net"
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 )
 
 // MetadataCallback returns common metadata about a unix connection
 func MetadataCallback(conn net.Conn) inputsource.NetworkMetadata {
-	return inputsource.NetworkMetadata{}
+	// unix sockets have an empty `RemoteAddr` value, only the source address
+	// of a PROXY protocol header is reported.
+	h := proxyproto.HeaderFromConn(conn)
+	if h == nil {
+		return inputsource.NetworkMetadata{}
+	}
+	return inputsource.NetworkMetadata{
+		RemoteAddr: h.SourceAddr,
+		Proxy:      h,
+	}
 }
@@ -26,6 +26,7 @@ import (
 
 	"github.com/elastic/beats/v7/filebeat/inputsource"
 	"github.com/elastic/beats/v7/filebeat/inputsource/common/dgram"
+	"github.com/elastic/beats/v7/filebeat/inputsource/common/proxyproto"
 	"github.com/elastic/beats/v7/filebeat/inputsource/common/streaming"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
@@ -46,6 +47,7 @@ type streamServer struct {
 type datagramServer struct {
 	*dgram.Listener
 	config *Config
+	log    *logp.Logger
 }
 
 // New creates a new unix server.
@@ -66,7 +68,7 @@ func New(log *logp.Logger, config *Config, nf inputsource.NetworkFunc) (Server,
 		return server, nil
 
 	case DatagramSocket:
-		server := &datagramServer{config: config}
+		server := &datagramServer{config: config, log: log}
 		factory := dgram.DatagramReaderFactory(inputsource.FamilyUnix, log, nf)
 		server.Listener = dgram.NewListener(inputsource.FamilyUnix, config.Path, factory, server.createConn, &dgram.ListenerConfig{
 			Timeout:        config.Timeout,
@@ -98,7 +100,11 @@ func (s *streamServer) createServer() (net.Listener, error) {
 	}
 
 	if s.config.MaxConnections > 0 {
-		return netutil.LimitListener(l, s.config.MaxConnections), nil
+		l = netutil.LimitListener(l, s.config.MaxConnections)
+	}
+
+	if s.config.ProxyProtocol.Enabled {
+		return proxyproto.NewListener(l, s.config.ProxyProtocol)
 	}
 	return l, nil
 }
@@ -124,5 +130,9 @@ func (s *datagramServer) createConn() (net.PacketConn, error) {
 	if err := setSocketMode(s.config.Path, s.config.Mode); err != nil {
 		return nil, err
 	}
+
+	if s.config.ProxyProtocol.Enabled {
+		return proxyproto.NewPacketConn(conn, s.config.ProxyProtocol, s.log)
+	}
 	return conn, nil
 }
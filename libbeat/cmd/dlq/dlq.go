@@ -0,0 +1,201 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package dlq implements the dlq command, which inspects and replays events
+// written to the dead letter files of the Elasticsearch output.
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
+)
+
+const esOutputName = "elasticsearch"
+
+// options holds the flags shared by all dlq subcommands.
+type options struct {
+	path   string
+	index  string
+	status int
+	reason string
+	since  string
+	until  string
+}
+
+func (o *options) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.path, "path", "", "Directory of the dead letter files, defaults to the dead_letter_file.path setting of the Elasticsearch output")
+	cmd.Flags().StringVar(&o.index, "index", "", "Only select events targeting an index matching this pattern, e.g. logs-*")
+	cmd.Flags().IntVar(&o.status, "status", 0, "Only select events rejected with this status code")
+	cmd.Flags().StringVar(&o.reason, "reason", "", "Only select events whose rejection reason contains this text")
+	cmd.Flags().StringVar(&o.since, "since", "", "Only select events rejected at or after this time, as RFC 3339 timestamp or duration before now, e.g. 24h")
+	cmd.Flags().StringVar(&o.until, "until", "", "Only select events rejected before this time, as RFC 3339 timestamp or duration before now, e.g. 1h")
+}
+
+// filter returns the filter configured by the flags.
+func (o *options) filter(now time.Time) (deadletter.Filter, error) {
+	f := deadletter.Filter{
+		Index:  o.index,
+		Status: o.status,
+		Reason: o.reason,
+	}
+
+	var err error
+	if f.Since, err = parseTime(o.since, now); err != nil {
+		return f, fmt.Errorf("invalid --since: %w", err)
+	}
+	if f.Until, err = parseTime(o.until, now); err != nil {
+		return f, fmt.Errorf("invalid --until: %w", err)
+	}
+	return f, f.Validate()
+}
+
+// hasFilter reports whether any of the filter flags is set.
+func (o *options) hasFilter() bool {
+	return o.index != "" || o.status != 0 || o.reason != "" || o.since != "" || o.until != ""
+}
+
+// parseTime parses an RFC 3339 timestamp or a duration that is subtracted
+// from now. An empty string returns the zero time.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// deadLetterConfig returns the dead letter file settings of the configured
+// Elasticsearch output. The defaults are returned for other outputs.
+func deadLetterConfig(b *instance.Beat) (deadletter.Config, error) {
+	config := deadletter.DefaultConfig()
+	if b.Config.Output.Name() != esOutputName {
+		return config, nil
+	}
+
+	cfg := b.Config.Output.Config()
+	if !cfg.HasField("dead_letter_file") {
+		return config, nil
+	}
+	sub, err := cfg.Child("dead_letter_file", -1)
+	if err != nil {
+		return config, err
+	}
+	if err := sub.Unpack(&config); err != nil {
+		return config, fmt.Errorf("invalid dead_letter_file settings: %w", err)
+	}
+	return config, nil
+}
+
+// selection is a set of records read from the dead letter files.
+type selection struct {
+	filter deadletter.Filter
+	files  []string
+
+	// records are the records matching the filter, in file order.
+	records []deadletter.Record
+
+	// skipped counts the records per file that do not match the filter.
+	skipped map[string]int
+}
+
+// selectRecords reads all records in dir that match the filter.
+func selectRecords(dir string, filter deadletter.Filter) (*selection, error) {
+	files, err := deadletter.Files(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letter files in %v: %w", dir, err)
+	}
+
+	s := &selection{filter: filter, files: files, skipped: map[string]int{}}
+	for _, path := range files {
+		err := deadletter.ReadFile(path, func(r deadletter.Record) error {
+			if filter.Match(&r) {
+				s.records = append(s.records, r)
+			} else {
+				s.skipped[path]++
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// removeSelected removes the selected records from the dead letter files.
+// Files that only hold selected records are deleted, others are rewritten
+// with the remaining records. The directory must have been locked with
+// deadletter.Lock before the records were selected, so no records are
+// appended to the files in the meantime.
+func removeSelected(s *selection) error {
+	for _, path := range s.files {
+		if s.skipped[path] == 0 {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := rewriteFile(path, s.filter); err != nil {
+			return fmt.Errorf("failed to remove events from %v: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// rewriteFile replaces the file with the records that do not match the
+// filter.
+func rewriteFile(path string, filter deadletter.Filter) error {
+	tmpPath := path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	enc := json.NewEncoder(f)
+	err = deadletter.ReadFile(path, func(r deadletter.Record) error {
+		if filter.Match(&r) {
+			return nil
+		}
+		return enc.Encode(r)
+	})
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+func fatalf(msg string, vs ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg, vs...)
+	fmt.Fprintln(os.Stderr)
+	os.Exit(1)
+}
@@ -0,0 +1,114 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package dlq
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	ts, err := parseTime("", now)
+	require.NoError(t, err)
+	assert.True(t, ts.IsZero())
+
+	ts, err = parseTime("90m", now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-90*time.Minute), ts)
+
+	ts, err = parseTime("2024-04-30T08:00:00Z", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 4, 30, 8, 0, 0, 0, time.UTC), ts)
+
+	_, err = parseTime("yesterday", now)
+	assert.Error(t, err)
+}
+
+func TestRemoveSelected(t *testing.T) {
+	dir := t.TempDir()
+	writeRecords(t, filepath.Join(dir, "rejected-20240501.ndjson"), "logs-a", "logs-a")
+	writeRecords(t, filepath.Join(dir, "rejected-20240502.ndjson"), "logs-a", "logs-b")
+
+	sel, err := selectRecords(dir, deadletter.Filter{Index: "logs-a"})
+	require.NoError(t, err)
+	require.Len(t, sel.records, 3)
+
+	require.NoError(t, removeSelected(sel))
+
+	files, err := deadletter.Files(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "rejected-20240502.ndjson")}, files)
+
+	sel, err = selectRecords(dir, deadletter.Filter{})
+	require.NoError(t, err)
+	require.Len(t, sel.records, 1)
+	assert.Equal(t, "logs-b", sel.records[0].Index)
+}
+
+func TestPrintSummary(t *testing.T) {
+	mappingError := deadletter.ErrorMessage([]byte(`{"type":"mapper_parsing_exception","reason":"failed to parse"}`))
+	records := []deadletter.Record{
+		{Index: "logs-a", Status: 400, Error: mappingError},
+		{Index: "logs-b", Status: 403, Error: deadletter.ErrorMessage([]byte("index closed"))},
+		{Index: "logs-a", Status: 400, Error: mappingError},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, printSummary(&buf, records))
+	assert.Equal(t, ""+
+		"COUNT  INDEX   STATUS  REASON\n"+
+		"2      logs-a  400     mapper_parsing_exception: failed to parse\n"+
+		"1      logs-b  403     index closed\n"+
+		"3      total           \n",
+		buf.String())
+}
+
+func writeRecords(t *testing.T, path string, indices ...string) {
+	t.Helper()
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, index := range indices {
+		require.NoError(t, enc.Encode(deadletter.Record{
+			Index: index,
+			Event: json.RawMessage(`{"message":"test"}`),
+		}))
+	}
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0600))
+}
+
+func mustConfig(t *testing.T, m mapstr.M) *config.C {
+	t.Helper()
+	cfg, err := config.NewConfigFrom(m)
+	require.NoError(t, err)
+	return cfg
+}
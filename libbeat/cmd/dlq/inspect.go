@@ -0,0 +1,126 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dlq
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
+)
+
+// GenInspectCmd generates the command that prints the events in the dead
+// letter files.
+func GenInspectCmd(settings instance.Settings) *cobra.Command {
+	var opts options
+	var summary bool
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Print the events rejected by Elasticsearch",
+		Long: "Print the events written to the dead letter files of the Elasticsearch output as one JSON " +
+			"record per line, or a summary of the rejection reasons.",
+		Run: func(cmd *cobra.Command, args []string) {
+			b, err := instance.NewInitializedBeat(settings)
+			if err != nil {
+				fatalf("Failed to initialize 'dlq' command: %+v.", err)
+			}
+			config, err := deadLetterConfig(b)
+			if err != nil {
+				fatalf("Error reading dead letter settings: %+v.", err)
+			}
+			if opts.path != "" {
+				config.Path = opts.path
+			}
+			filter, err := opts.filter(time.Now())
+			if err != nil {
+				fatalf("%+v.", err)
+			}
+
+			sel, err := selectRecords(config.Dir(), filter)
+			if err != nil {
+				fatalf("Error reading dead letter files: %+v.", err)
+			}
+
+			if summary {
+				err = printSummary(os.Stdout, sel.records)
+			} else {
+				err = printRecords(os.Stdout, sel.records)
+			}
+			if err != nil {
+				fatalf("Error printing dead letter events: %+v.", err)
+			}
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().BoolVar(&summary, "summary", false, "Print the number of events per index and rejection reason instead of the events")
+	return cmd
+}
+
+func printRecords(w io.Writer, records []deadletter.Record) error {
+	enc := json.NewEncoder(w)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printSummary(w io.Writer, records []deadletter.Record) error {
+	type key struct {
+		index  string
+		status int
+		reason string
+	}
+	counts := map[key]int{}
+	for _, r := range records {
+		counts[key{r.Index, r.Status, r.Reason()}]++
+	}
+
+	keys := make([]key, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		if keys[i].index != keys[j].index {
+			return keys[i].index < keys[j].index
+		}
+		return keys[i].reason < keys[j].reason
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNT\tINDEX\tSTATUS\tREASON")
+	for _, k := range keys {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", counts[k], k.index, k.status, k.reason)
+	}
+	fmt.Fprintf(tw, "%d\ttotal\t\t\n", len(records))
+	return tw.Flush()
+}
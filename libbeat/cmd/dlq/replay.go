@@ -0,0 +1,499 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package dlq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/beat/events"
+	"github.com/elastic/beats/v7/libbeat/cmd/instance"
+	"github.com/elastic/beats/v7/libbeat/idxmgmt"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const defaultBatchSize = 50
+
+// GenReplayCmd generates the command that publishes the events in the dead
+// letter files again through the configured output.
+func GenReplayCmd(settings instance.Settings) *cobra.Command {
+	var opts options
+	var remove bool
+	var maxRetries int
+
+	cmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Publish the events rejected by Elasticsearch again",
+		Long: "Publish the events written to the dead letter files of the Elasticsearch output again " +
+			"through the configured output, for example after fixing a mapping. Events rejected again " +
+			"by Elasticsearch are written to new dead letter files.",
+		Run: func(cmd *cobra.Command, args []string) {
+			b, err := instance.NewInitializedBeat(settings)
+			if err != nil {
+				fatalf("Failed to initialize 'dlq' command: %+v.", err)
+			}
+			dlConfig, err := deadLetterConfig(b)
+			if err != nil {
+				fatalf("Error reading dead letter settings: %+v.", err)
+			}
+			if opts.path != "" {
+				dlConfig.Path = opts.path
+			}
+			filter, err := opts.filter(time.Now())
+			if err != nil {
+				fatalf("%+v.", err)
+			}
+
+			dir := dlConfig.Dir()
+			// Events rejected by an interrupted replay are replayed as well.
+			if err := restoreReplayed(dir); err != nil {
+				fatalf("Error moving the events rejected during a previous replay to %v: %+v.", dir, err)
+			}
+			if remove {
+				// Writers only append to new files while the lock is held,
+				// so the selected records can be removed safely.
+				unlock, err := deadletter.Lock(dir)
+				switch {
+				case errors.Is(err, deadletter.ErrLocked):
+					fatalf("Cannot remove replayed events while %v is being written to, stop the beat or replay without --remove.", dir)
+				case errors.Is(err, os.ErrNotExist):
+					fmt.Println("No events to replay.")
+					return
+				case err != nil:
+					fatalf("%+v.", err)
+				}
+				defer unlock()
+			}
+			sel, err := selectRecords(dir, filter)
+			if err != nil {
+				fatalf("Error reading dead letter files: %+v.", err)
+			}
+			if len(sel.records) == 0 {
+				fmt.Println("No events to replay.")
+				return
+			}
+
+			outputName := b.Config.Output.Name()
+			outputConfig, err := replayOutputConfig(outputName, b.Config.Output.Config(), dir)
+			if err != nil {
+				fatalf("Error configuring output: %+v.", err)
+			}
+			observer := &replayObserver{Observer: outputs.NewNilObserver()}
+			im, err := idxmgmt.DefaultSupport(nil, b.Info, nil)
+			if err != nil {
+				fatalf("Error initializing index management: %+v.", err)
+			}
+			group, err := outputs.Load(im, b.Info, observer, outputName, outputConfig)
+			if err != nil {
+				fatalf("Error initializing output: %+v.", err)
+			}
+
+			ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+
+			r := newReplayer(group, maxRetries)
+			published, failed := r.replay(ctx, sel.records)
+			r.close()
+			if err := restoreReplayed(dir); err != nil {
+				fatalf("Error moving the events rejected during replay to %v: %+v.", dir, err)
+			}
+
+			// Events rejected by Elasticsearch are acknowledged by the output
+			// and reported as permanent errors.
+			rejected := int(observer.permanentErrors.Load())
+			published -= rejected
+			fmt.Printf("Replayed %d events: %d published, %d rejected, %d failed.\n",
+				len(sel.records), published, rejected, failed)
+
+			if !remove {
+				return
+			}
+			if failed > 0 || ctx.Err() != nil {
+				fatalf("Not removing the replayed events from %v, as not all events could be published.", dir)
+			}
+			if rejected > 0 && outputName != esOutputName {
+				fatalf("Not removing the replayed events from %v, as %d events were rejected by the output.", dir, rejected)
+			}
+			if err := removeSelected(sel); err != nil {
+				fatalf("Error removing replayed events: %+v.", err)
+			}
+		},
+	}
+
+	opts.addFlags(cmd)
+	cmd.Flags().BoolVar(&remove, "remove", false, "Remove the replayed events from the dead letter files if all of them have been published")
+	cmd.Flags().IntVar(&maxRetries, "max-retries", 3, "Number of times publishing a batch is retried")
+	return cmd
+}
+
+// replayDirName is the sub-directory of the dead letter directory events
+// rejected during a replay are written to. The files being replayed are never
+// touched by the output this way. Once the output is closed, the new files are
+// moved into the dead letter directory by restoreReplayed.
+const replayDirName = "replay"
+
+// replayOutputConfig returns the output settings used for replaying events.
+// Events rejected by Elasticsearch are written to new dead letter files in the
+// replay directory of dir. No files are purged.
+func replayOutputConfig(name string, cfg *config.C, dir string) (*config.C, error) {
+	if name != esOutputName {
+		return cfg, nil
+	}
+
+	out := config.NewConfig()
+	if err := out.Merge(cfg); err != nil {
+		return nil, err
+	}
+	err := out.Merge(mapstr.M{
+		"dead_letter_file": mapstr.M{
+			"enabled":   true,
+			"path":      filepath.Join(dir, replayDirName),
+			"max_files": 1024,
+		},
+	})
+	return out, err
+}
+
+// restoreReplayed moves the dead letter files written during a replay into
+// dir and removes the replay directory.
+func restoreReplayed(dir string) error {
+	replayDir := filepath.Join(dir, replayDirName)
+	files, err := deadletter.Files(replayDir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if _, err := deadletter.MoveFile(f, dir); err != nil {
+			return err
+		}
+	}
+	if err := os.Remove(filepath.Join(replayDir, deadletter.LockFileName)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(replayDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// recordEvent restores the event a dead letter record was created from. The
+// event is sent to the index it was originally meant for.
+func recordEvent(r deadletter.Record) (beat.Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(r.Event))
+	dec.UseNumber()
+	var doc map[string]interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return beat.Event{}, fmt.Errorf("invalid event: %w", err)
+	}
+	fields, _ := normalize(doc).(mapstr.M)
+
+	event := beat.Event{
+		Timestamp: r.Timestamp,
+		Fields:    fields,
+		Meta:      mapstr.M{events.FieldMetaRawIndex: r.Index},
+	}
+	if ts, ok := fields["@timestamp"]; ok {
+		s, _ := ts.(string)
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return beat.Event{}, fmt.Errorf("invalid @timestamp '%v': %w", ts, err)
+		}
+		event.Timestamp = t
+		delete(fields, "@timestamp")
+	}
+	if r.Pipeline != "" {
+		event.Meta[events.FieldMetaPipeline] = r.Pipeline
+	}
+	if r.ID != "" {
+		event.Meta[events.FieldMetaID] = r.ID
+	}
+	if r.OpType != "" {
+		event.Meta[events.FieldMetaOpType] = r.OpType
+	}
+	return event, nil
+}
+
+// normalize converts decoded JSON values to the types used in events.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(mapstr.M, len(v))
+		for k, val := range v {
+			m[k] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+		return v
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
+
+// replayer publishes events with the clients of an output. On errors the
+// next client is used.
+type replayer struct {
+	log       *logp.Logger
+	clients   []outputs.Client
+	encoder   queue.Encoder
+	batchSize int
+	retries   int
+	backoff   time.Duration
+
+	current   int
+	connected bool
+}
+
+func newReplayer(group outputs.Group, retries int) *replayer {
+	r := &replayer{
+		log:       logp.NewLogger("dlq"),
+		clients:   group.Clients,
+		batchSize: group.BatchSize,
+		retries:   retries,
+		backoff:   time.Second,
+	}
+	if r.batchSize <= 0 {
+		r.batchSize = defaultBatchSize
+	}
+	if group.EncoderFactory != nil {
+		r.encoder = group.EncoderFactory()
+	}
+	return r
+}
+
+// replay publishes the events of the records. It returns the number of
+// events acknowledged by the output and the number of events that could not
+// be published.
+func (r *replayer) replay(ctx context.Context, records []deadletter.Record) (published, failed int) {
+	for start := 0; start < len(records); start += r.batchSize {
+		end := start + r.batchSize
+		if end > len(records) {
+			end = len(records)
+		}
+
+		batch := make([]publisher.Event, 0, end-start)
+		for _, record := range records[start:end] {
+			event, err := recordEvent(record)
+			if err != nil {
+				r.log.Errorf("Cannot replay event for index %v: %v", record.Index, err)
+				failed++
+				continue
+			}
+			batch = append(batch, r.encode(event))
+		}
+
+		p, f := r.publish(ctx, batch)
+		published += p
+		failed += f
+		if ctx.Err() != nil {
+			failed += len(records) - end
+			break
+		}
+	}
+	return published, failed
+}
+
+func (r *replayer) encode(event beat.Event) publisher.Event {
+	e := publisher.Event{Content: event}
+	if r.encoder != nil {
+		encoded, _ := r.encoder.EncodeEntry(e)
+		e, _ = encoded.(publisher.Event)
+	}
+	return e
+}
+
+// publish sends the events, retrying events that have not been acknowledged.
+func (r *replayer) publish(ctx context.Context, events []publisher.Event) (published, failed int) {
+	for attempt := 0; len(events) > 0; attempt++ {
+		if attempt > r.retries {
+			r.log.Errorf("Failed to publish %d events after %d retries", len(events), r.retries)
+			return published, failed + len(events)
+		}
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return published, failed + len(events)
+			case <-time.After(r.backoff):
+			}
+		}
+
+		client, err := r.client(ctx)
+		if err != nil {
+			r.log.Errorf("Failed to connect to the output: %v", err)
+			continue
+		}
+
+		batch := newReplayBatch(events)
+		if err := client.Publish(ctx, batch); err != nil {
+			r.log.Errorf("Failed to publish events: %v", err)
+			r.disconnect()
+			if !batch.isDone() {
+				batch.Retry()
+			}
+		}
+		if err := batch.wait(ctx); err != nil {
+			return published, failed + len(events)
+		}
+
+		published += batch.acked
+		failed += batch.dropped
+		events = batch.retry
+	}
+	return published, failed
+}
+
+// client returns the connected client to publish events with.
+func (r *replayer) client(ctx context.Context) (outputs.Client, error) {
+	client := r.clients[r.current]
+	if r.connected {
+		return client, nil
+	}
+	if c, ok := client.(outputs.Connectable); ok {
+		if err := c.Connect(ctx); err != nil {
+			r.current = (r.current + 1) % len(r.clients)
+			return nil, err
+		}
+	}
+	r.connected = true
+	return client, nil
+}
+
+// disconnect closes the active client and switches to the next one.
+func (r *replayer) disconnect() {
+	if _, ok := r.clients[r.current].(outputs.Connectable); ok {
+		r.clients[r.current].Close()
+	}
+	r.current = (r.current + 1) % len(r.clients)
+	r.connected = false
+}
+
+func (r *replayer) close() {
+	for _, client := range r.clients {
+		client.Close()
+	}
+}
+
+// replayBatch is a publisher.Batch that records how the output handled the
+// events.
+type replayBatch struct {
+	events []publisher.Event
+
+	once sync.Once
+	done chan struct{}
+
+	acked   int
+	dropped int
+	retry   []publisher.Event
+}
+
+func newReplayBatch(events []publisher.Event) *replayBatch {
+	return &replayBatch{events: events, done: make(chan struct{})}
+}
+
+func (b *replayBatch) Events() []publisher.Event {
+	return b.events
+}
+
+func (b *replayBatch) ACK() {
+	b.finish(len(b.events), 0, nil)
+}
+
+func (b *replayBatch) Drop() {
+	b.finish(0, len(b.events), nil)
+}
+
+func (b *replayBatch) Retry() {
+	b.finish(0, 0, b.events)
+}
+
+func (b *replayBatch) RetryEvents(events []publisher.Event) {
+	b.finish(len(b.events)-len(events), 0, events)
+}
+
+// SplitRetry is not supported. Outputs drop batches that are too large, so
+// the events are reported as failed.
+func (b *replayBatch) SplitRetry() bool {
+	return false
+}
+
+func (b *replayBatch) Cancelled() {
+	b.finish(0, 0, b.events)
+}
+
+func (b *replayBatch) finish(acked, dropped int, retry []publisher.Event) {
+	b.once.Do(func() {
+		b.acked = acked
+		b.dropped = dropped
+		b.retry = retry
+		close(b.done)
+	})
+}
+
+func (b *replayBatch) isDone() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *replayBatch) wait(ctx context.Context) error {
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return errors.New("replay cancelled")
+	}
+}
+
+// replayObserver counts the events rejected by the output.
+type replayObserver struct {
+	outputs.Observer
+	permanentErrors atomic.Int64
+}
+
+func (o *replayObserver) PermanentErrors(n int) {
+	o.permanentErrors.Add(int64(n))
+}
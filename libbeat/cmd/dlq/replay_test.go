@@ -0,0 +1,278 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat/events"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestRecordEvent(t *testing.T) {
+	record := deadletter.Record{
+		Timestamp: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		Index:     "logs-app-default",
+		Pipeline:  "app",
+		ID:        "abc",
+		OpType:    "create",
+		Event: json.RawMessage(`{
+			"@timestamp": "2024-05-01T09:59:58.123Z",
+			"message": "hello",
+			"http": {"status": 200, "duration": 1.5, "bytes": 9007199254740993},
+			"tags": ["a", 1]
+		}`),
+	}
+
+	event, err := recordEvent(record)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 5, 1, 9, 59, 58, 123000000, time.UTC), event.Timestamp)
+	assert.Equal(t, mapstr.M{
+		"message": "hello",
+		"http": mapstr.M{
+			"status":   int64(200),
+			"duration": 1.5,
+			"bytes":    int64(9007199254740993),
+		},
+		"tags": []interface{}{"a", int64(1)},
+	}, event.Fields)
+	assert.Equal(t, mapstr.M{
+		events.FieldMetaRawIndex: "logs-app-default",
+		events.FieldMetaPipeline: "app",
+		events.FieldMetaID:       "abc",
+		events.FieldMetaOpType:   "create",
+	}, event.Meta)
+
+	record.Event = json.RawMessage(`{"@timestamp": "yesterday"}`)
+	_, err = recordEvent(record)
+	assert.Error(t, err)
+
+	record.Event = json.RawMessage(`not json`)
+	_, err = recordEvent(record)
+	assert.Error(t, err)
+}
+
+func TestReplay(t *testing.T) {
+	records := make([]deadletter.Record, 5)
+	for i := range records {
+		records[i] = deadletter.Record{
+			Index: "logs",
+			Event: json.RawMessage(`{"message": "test"}`),
+		}
+	}
+
+	t.Run("retry events", func(t *testing.T) {
+		// The first attempt to publish a batch retries the last event.
+		client := &mockClient{
+			publish: func(attempt int, batch publisher.Batch) error {
+				events := batch.Events()
+				if attempt%2 == 0 {
+					batch.RetryEvents(events[len(events)-1:])
+				} else {
+					batch.ACK()
+				}
+				return nil
+			},
+		}
+		r := newTestReplayer(client)
+		published, failed := r.replay(context.Background(), records)
+		assert.Equal(t, 5, published)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, 1, client.connects)
+	})
+
+	t.Run("reconnect on error", func(t *testing.T) {
+		client := &mockClient{
+			publish: func(attempt int, batch publisher.Batch) error {
+				if attempt == 0 {
+					return errors.New("connection reset")
+				}
+				batch.ACK()
+				return nil
+			},
+		}
+		r := newTestReplayer(client)
+		published, failed := r.replay(context.Background(), records)
+		assert.Equal(t, 5, published)
+		assert.Equal(t, 0, failed)
+		assert.Equal(t, 2, client.connects)
+	})
+
+	t.Run("dropped and retries exhausted", func(t *testing.T) {
+		client := &mockClient{
+			publish: func(attempt int, batch publisher.Batch) error {
+				if attempt == 0 {
+					batch.Drop()
+				} else {
+					batch.Retry()
+				}
+				return nil
+			},
+		}
+		r := newTestReplayer(client)
+		published, failed := r.replay(context.Background(), records)
+		assert.Equal(t, 0, published)
+		assert.Equal(t, 5, failed)
+	})
+
+	t.Run("invalid events", func(t *testing.T) {
+		client := &mockClient{
+			publish: func(attempt int, batch publisher.Batch) error {
+				batch.ACK()
+				return nil
+			},
+		}
+		invalid := append([]deadletter.Record{{Event: json.RawMessage(`{`)}}, records...)
+		r := newTestReplayer(client)
+		published, failed := r.replay(context.Background(), invalid)
+		assert.Equal(t, 5, published)
+		assert.Equal(t, 1, failed)
+	})
+}
+
+func TestReplayOutputConfig(t *testing.T) {
+	cfg := mustConfig(t, mapstr.M{
+		"hosts":            []string{"localhost:9200"},
+		"dead_letter_file": mapstr.M{"max_files": 3},
+	})
+
+	out, err := replayOutputConfig(esOutputName, cfg, "/tmp/dlq")
+	require.NoError(t, err)
+
+	var settings struct {
+		Hosts          []string          `config:"hosts"`
+		DeadLetterFile deadletter.Config `config:"dead_letter_file"`
+	}
+	settings.DeadLetterFile = deadletter.DefaultConfig()
+	require.NoError(t, out.Unpack(&settings))
+	assert.Equal(t, []string{"localhost:9200"}, settings.Hosts)
+	assert.True(t, settings.DeadLetterFile.Enabled)
+	assert.Equal(t, filepath.Join("/tmp/dlq", replayDirName), settings.DeadLetterFile.Path)
+	assert.EqualValues(t, 1024, settings.DeadLetterFile.MaxFiles)
+
+	// The settings of the running output are not modified.
+	maxFiles, err := cfg.Int("dead_letter_file.max_files", -1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, maxFiles)
+	assert.False(t, cfg.HasField("dead_letter_file.enabled"))
+}
+
+func TestReplayRejected(t *testing.T) {
+	// The files rejected during the replay are started on the same day as
+	// the file being replayed.
+	dir := t.TempDir()
+	today := time.Now().Format("20060102")
+	writeRecords(t, filepath.Join(dir, "rejected-"+today+".ndjson"), "logs-a", "logs-a")
+	writeRecords(t, filepath.Join(dir, "rejected-20240502.ndjson"), "logs-a", "logs-b")
+
+	sel, err := selectRecords(dir, deadletter.Filter{Index: "logs-a"})
+	require.NoError(t, err)
+	require.Len(t, sel.records, 3)
+
+	// The output rejects every event again, writing it to the dead letter
+	// directory configured by replayOutputConfig, like the Elasticsearch
+	// output does.
+	cfg, err := replayOutputConfig(esOutputName, mustConfig(t, mapstr.M{}), dir)
+	require.NoError(t, err)
+	dlConfig := deadletter.DefaultConfig()
+	sub, err := cfg.Child("dead_letter_file", -1)
+	require.NoError(t, err)
+	require.NoError(t, sub.Unpack(&dlConfig))
+	writer := deadletter.NewWriter(dlConfig)
+
+	client := &mockClient{
+		publish: func(_ int, batch publisher.Batch) error {
+			for _, e := range batch.Events() {
+				index, _ := e.Content.Meta.GetValue(events.FieldMetaRawIndex)
+				require.NoError(t, writer.Write(deadletter.Record{
+					Index:  index.(string),
+					Status: 400,
+					Event:  json.RawMessage(`{"message": "test"}`),
+				}))
+			}
+			batch.ACK()
+			return nil
+		},
+		close: writer.Close,
+	}
+	r := newTestReplayer(client)
+	published, failed := r.replay(context.Background(), sel.records)
+	assert.Equal(t, 3, published)
+	assert.Equal(t, 0, failed)
+	r.close()
+
+	require.NoError(t, restoreReplayed(dir))
+	require.NoError(t, removeSelected(sel))
+	assert.NoDirExists(t, filepath.Join(dir, replayDirName))
+
+	// The rejected events are kept, together with the events that have
+	// not been replayed.
+	sel, err = selectRecords(dir, deadletter.Filter{})
+	require.NoError(t, err)
+	var indices []string
+	for _, r := range sel.records {
+		indices = append(indices, r.Index)
+	}
+	assert.ElementsMatch(t, []string{"logs-b", "logs-a", "logs-a", "logs-a"}, indices)
+}
+
+func newTestReplayer(client outputs.Client) *replayer {
+	r := newReplayer(outputs.Group{Clients: []outputs.Client{client}, BatchSize: 2}, 2)
+	r.backoff = 0
+	return r
+}
+
+type mockClient struct {
+	publish  func(attempt int, batch publisher.Batch) error
+	close    func() error
+	attempt  int
+	connects int
+}
+
+func (c *mockClient) Connect(context.Context) error {
+	c.connects++
+	return nil
+}
+
+func (c *mockClient) Close() error {
+	if c.close != nil {
+		return c.close()
+	}
+	return nil
+}
+
+func (c *mockClient) Publish(_ context.Context, batch publisher.Batch) error {
+	err := c.publish(c.attempt, batch)
+	c.attempt++
+	return err
+}
+
+func (c *mockClient) String() string { return "mock" }
@@ -0,0 +1,265 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// encoding helpers to build test data.
+
+func long(l int64) []byte { return binary.AppendVarint(nil, l) }
+
+func str(s string) []byte { return append(long(int64(len(s))), s...) }
+
+func float(f float32) []byte { return binary.LittleEndian.AppendUint32(nil, math.Float32bits(f)) }
+
+func double(f float64) []byte { return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)) }
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		schema string
+		data   []byte
+		want   interface{}
+	}{
+		"null": {
+			schema: `"null"`,
+			data:   nil,
+			want:   nil,
+		},
+		"primitives": {
+			schema: `{"type": "record", "name": "Primitives", "fields": [
+				{"name": "b", "type": "boolean"},
+				{"name": "i", "type": "int"},
+				{"name": "l", "type": "long"},
+				{"name": "f", "type": "float"},
+				{"name": "d", "type": "double"},
+				{"name": "by", "type": "bytes"},
+				{"name": "s", "type": {"type": "string"}}
+			]}`,
+			data: concat([]byte{1}, long(-3), long(1<<40), float(1.5), double(-2.25), str("\x00\x01"), str("hello")),
+			want: mapstr.M{
+				"b":  true,
+				"i":  int32(-3),
+				"l":  int64(1 << 40),
+				"f":  float32(1.5),
+				"d":  -2.25,
+				"by": []byte{0, 1},
+				"s":  "hello",
+			},
+		},
+		"nested records with namespaces and references": {
+			schema: `{"type": "record", "name": "Event", "namespace": "com.example", "fields": [
+				{"name": "source", "type": {"type": "record", "name": "Endpoint", "fields": [
+					{"name": "ip", "type": "string"}
+				]}},
+				{"name": "destination", "type": "com.example.Endpoint"},
+				{"name": "proxy", "type": "Endpoint"}
+			]}`,
+			data: concat(str("10.0.0.1"), str("10.0.0.2"), str("10.0.0.3")),
+			want: mapstr.M{
+				"source":      mapstr.M{"ip": "10.0.0.1"},
+				"destination": mapstr.M{"ip": "10.0.0.2"},
+				"proxy":       mapstr.M{"ip": "10.0.0.3"},
+			},
+		},
+		"enum, fixed and unions": {
+			schema: `{"type": "record", "name": "R", "fields": [
+				{"name": "level", "type": {"type": "enum", "name": "Level", "symbols": ["DEBUG", "INFO", "ERROR"]}},
+				{"name": "hash", "type": {"type": "fixed", "name": "MD5", "size": 4}},
+				{"name": "user", "type": ["null", "string"]},
+				{"name": "missing", "type": ["null", "string"]}
+			]}`,
+			data: concat(long(2), []byte{1, 2, 3, 4}, long(1), str("alice"), long(0)),
+			want: mapstr.M{
+				"level":   "ERROR",
+				"hash":    []byte{1, 2, 3, 4},
+				"user":    "alice",
+				"missing": nil,
+			},
+		},
+		"arrays and maps in several blocks": {
+			schema: `{"type": "record", "name": "R", "fields": [
+				{"name": "tags", "type": {"type": "array", "items": "string"}},
+				{"name": "labels", "type": {"type": "map", "values": "long"}}
+			]}`,
+			data: concat(
+				long(2), str("a"), str("b"), long(-1), long(2), str("c"), long(0),
+				long(1), str("env.name"), long(7), long(0),
+			),
+			want: mapstr.M{
+				"tags":   []interface{}{"a", "b", "c"},
+				"labels": mapstr.M{"env.name": int64(7)},
+			},
+		},
+		"recursive record": {
+			schema: `{"type": "record", "name": "Node", "fields": [
+				{"name": "value", "type": "int"},
+				{"name": "next", "type": ["null", "Node"]}
+			]}`,
+			data: concat(long(1), long(1), long(2), long(0)),
+			want: mapstr.M{
+				"value": int32(1),
+				"next":  mapstr.M{"value": int32(2), "next": nil},
+			},
+		},
+		"logical types": {
+			schema: `{"type": "record", "name": "R", "fields": [
+				{"name": "day", "type": {"type": "int", "logicalType": "date"}},
+				{"name": "ts", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+				{"name": "tsu", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+				{"name": "price", "type": {"type": "bytes", "logicalType": "decimal", "precision": 6, "scale": 2}},
+				{"name": "small", "type": {"type": "fixed", "name": "D", "size": 2, "logicalType": "decimal", "precision": 4, "scale": 3}},
+				{"name": "id", "type": {"type": "string", "logicalType": "uuid"}},
+				{"name": "t", "type": {"type": "int", "logicalType": "time-millis"}}
+			]}`,
+			data: concat(
+				long(19000),
+				long(1700000000123),
+				long(1700000000123456),
+				str("\x30\x39"),    // 12345
+				[]byte{0xff, 0xf6}, // -10
+				str("3e6f1b2c-0b5e-4bb5-9d4c-1b2a3c4d5e6f"),
+				long(1000),
+			),
+			want: mapstr.M{
+				"day":   time.Date(2022, 1, 8, 0, 0, 0, 0, time.UTC),
+				"ts":    time.UnixMilli(1700000000123).UTC(),
+				"tsu":   time.UnixMicro(1700000000123456).UTC(),
+				"price": "123.45",
+				"small": "-0.010",
+				"id":    "3e6f1b2c-0b5e-4bb5-9d4c-1b2a3c4d5e6f",
+				"t":     int32(1000),
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse(tc.schema)
+			require.NoError(t, err)
+			v, err := s.Decode(tc.data)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, v)
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := map[string]struct {
+		schema string
+		data   []byte
+		err    string
+	}{
+		"short string": {
+			schema: `"string"`,
+			data:   concat(long(10), []byte("abc")),
+			err:    ErrShortBuffer.Error(),
+		},
+		"short double": {
+			schema: `"double"`,
+			data:   []byte{1, 2},
+			err:    ErrShortBuffer.Error(),
+		},
+		"int overflow": {
+			schema: `"int"`,
+			data:   long(1 << 40),
+			err:    "overflows 32 bits",
+		},
+		"invalid enum index": {
+			schema: `{"type": "enum", "name": "E", "symbols": ["A"]}`,
+			data:   long(3),
+			err:    `invalid index 3 of enum "E"`,
+		},
+		"invalid union branch": {
+			schema: `["null", "string"]`,
+			data:   long(2),
+			err:    "invalid union branch 2",
+		},
+		"trailing data": {
+			schema: `"long"`,
+			data:   concat(long(1), long(2)),
+			err:    "1 bytes left after the value",
+		},
+		"error in field": {
+			schema: `{"type": "record", "name": "R", "fields": [{"name": "s", "type": "string"}]}`,
+			data:   long(5),
+			err:    `in field "s" of record "R"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			s, err := Parse(tc.schema)
+			require.NoError(t, err)
+			_, err = s.Decode(tc.data)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]struct {
+		schema string
+		err    string
+	}{
+		"invalid json": {
+			schema: `{"type": `,
+			err:    "invalid Avro schema",
+		},
+		"unknown type": {
+			schema: `{"type": "record", "name": "R", "fields": [{"name": "a", "type": "Unknown"}]}`,
+			err:    `unknown type "Unknown"`,
+		},
+		"duplicate name": {
+			schema: `["null", {"type": "fixed", "name": "F", "size": 1}, {"type": "enum", "name": "F", "symbols": []}]`,
+			err:    `type "F" is defined more than once`,
+		},
+		"nested unions": {
+			schema: `["null", ["string"]]`,
+			err:    "unions cannot contain unions",
+		},
+		"missing fields": {
+			schema: `{"type": "record", "name": "R"}`,
+			err:    `missing fields of record "R"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tc.schema)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
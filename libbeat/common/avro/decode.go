@@ -0,0 +1,287 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package avro
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// ErrShortBuffer is returned when the data ends before the value.
+var ErrShortBuffer = errors.New("avro: data is too short")
+
+// maxCollectionItems limits the number of items allocated for a block of an
+// array or map, so corrupted data cannot exhaust memory.
+const maxCollectionItems = 1 << 20
+
+// Decode decodes a value encoded with the schema. Records and maps are
+// returned as mapstr.M, arrays as []interface{}, bytes and fixed as []byte,
+// enums as strings and unions as the value of their branch. The date and
+// timestamp logical types are returned as time.Time and decimals as
+// strings. Decode returns an error if data is not completely consumed.
+func (s *Schema) Decode(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.decode(s.root)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.data) > 0 {
+		return nil, fmt.Errorf("avro: %d bytes left after the value", len(d.data))
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+}
+
+func (d *decoder) decode(n *node) (interface{}, error) {
+	switch n.kind {
+	case kindNull:
+		return nil, nil
+	case kindBoolean:
+		b, err := d.read(1)
+		if err != nil {
+			return nil, err
+		}
+		return b[0] != 0, nil
+	case kindInt:
+		i, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		return intLogical(n, i), nil
+	case kindLong:
+		l, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		return longLogical(n, l), nil
+	case kindFloat:
+		b, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float32frombits(binary.LittleEndian.Uint32(b)), nil
+	case kindDouble:
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(b)), nil
+	case kindBytes:
+		b, err := d.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return bytesLogical(n, b), nil
+	case kindString:
+		b, err := d.readBytes()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	case kindFixed:
+		b, err := d.read(n.size)
+		if err != nil {
+			return nil, err
+		}
+		return bytesLogical(n, append([]byte(nil), b...)), nil
+	case kindEnum:
+		i, err := d.readInt()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || int(i) >= len(n.symbols) {
+			return nil, fmt.Errorf("avro: invalid index %d of enum %q", i, n.name)
+		}
+		return n.symbols[i], nil
+	case kindUnion:
+		i, err := d.readLong()
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(n.branches)) {
+			return nil, fmt.Errorf("avro: invalid union branch %d", i)
+		}
+		return d.decode(n.branches[i])
+	case kindRecord:
+		m := make(mapstr.M, len(n.fields))
+		for _, f := range n.fields {
+			v, err := d.decode(f.node)
+			if err != nil {
+				return nil, fmt.Errorf("%w in field %q of record %q", err, f.name, n.name)
+			}
+			m[f.name] = v
+		}
+		return m, nil
+	case kindArray:
+		items := []interface{}{}
+		err := d.readBlocks(func() error {
+			v, err := d.decode(n.items)
+			if err != nil {
+				return err
+			}
+			items = append(items, v)
+			return nil
+		})
+		return items, err
+	case kindMap:
+		m := mapstr.M{}
+		err := d.readBlocks(func() error {
+			k, err := d.readBytes()
+			if err != nil {
+				return err
+			}
+			v, err := d.decode(n.values)
+			if err != nil {
+				return err
+			}
+			m[string(k)] = v
+			return nil
+		})
+		return m, err
+	default:
+		return nil, fmt.Errorf("avro: unsupported type %d", n.kind)
+	}
+}
+
+func (d *decoder) read(n int) ([]byte, error) {
+	if n > len(d.data) {
+		return nil, ErrShortBuffer
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+func (d *decoder) readLong() (int64, error) {
+	// Avro longs use the same zig-zag variable-length encoding as Go.
+	l, n := binary.Varint(d.data)
+	if n == 0 {
+		return 0, ErrShortBuffer
+	}
+	if n < 0 {
+		return 0, errors.New("avro: long overflows 64 bits")
+	}
+	d.data = d.data[n:]
+	return l, nil
+}
+
+func (d *decoder) readInt() (int32, error) {
+	l, err := d.readLong()
+	if err != nil {
+		return 0, err
+	}
+	if l < math.MinInt32 || l > math.MaxInt32 {
+		return 0, fmt.Errorf("avro: int %d overflows 32 bits", l)
+	}
+	return int32(l), nil
+}
+
+func (d *decoder) readBytes() ([]byte, error) {
+	l, err := d.readLong()
+	if err != nil {
+		return nil, err
+	}
+	if l < 0 || l > int64(len(d.data)) {
+		return nil, ErrShortBuffer
+	}
+	return d.read(int(l))
+}
+
+// readBlocks reads the blocks of an array or map, calling item for every
+// item.
+func (d *decoder) readBlocks(item func() error) error {
+	for {
+		count, err := d.readLong()
+		if err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		if count < 0 {
+			// A negative count is followed by the size of the block in bytes.
+			count = -count
+			if _, err := d.readLong(); err != nil {
+				return err
+			}
+		}
+		if count > maxCollectionItems {
+			return fmt.Errorf("avro: block of %d items is too large", count)
+		}
+		for i := int64(0); i < count; i++ {
+			if err := item(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func intLogical(n *node, i int32) interface{} {
+	if n.logicalType == "date" {
+		return time.Unix(int64(i)*86400, 0).UTC()
+	}
+	return i
+}
+
+func longLogical(n *node, l int64) interface{} {
+	switch n.logicalType {
+	case "timestamp-millis", "local-timestamp-millis":
+		return time.UnixMilli(l).UTC()
+	case "timestamp-micros", "local-timestamp-micros":
+		return time.UnixMicro(l).UTC()
+	case "timestamp-nanos", "local-timestamp-nanos":
+		return time.Unix(0, l).UTC()
+	}
+	return l
+}
+
+func bytesLogical(n *node, b []byte) interface{} {
+	if n.logicalType != "decimal" {
+		return b
+	}
+	// The unscaled value is a big-endian two's-complement integer.
+	unscaled := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(8*len(b))))
+	}
+	return formatDecimal(unscaled, n.scale)
+}
+
+func formatDecimal(unscaled *big.Int, scale int) string {
+	s := new(big.Int).Abs(unscaled).String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale+1-len(s)) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if unscaled.Sign() < 0 {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,291 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package avro decodes data encoded with the Apache Avro binary encoding.
+package avro
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Schema is a parsed Avro schema.
+type Schema struct {
+	root *node
+}
+
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBoolean
+	kindInt
+	kindLong
+	kindFloat
+	kindDouble
+	kindBytes
+	kindString
+	kindRecord
+	kindEnum
+	kindArray
+	kindMap
+	kindUnion
+	kindFixed
+)
+
+var primitives = map[string]kind{
+	"null":    kindNull,
+	"boolean": kindBoolean,
+	"int":     kindInt,
+	"long":    kindLong,
+	"float":   kindFloat,
+	"double":  kindDouble,
+	"bytes":   kindBytes,
+	"string":  kindString,
+}
+
+type node struct {
+	kind        kind
+	name        string
+	logicalType string
+	// precision and scale of decimal logical types.
+	precision int
+	scale     int
+
+	fields   []field // record
+	symbols  []string
+	items    *node   // array
+	values   *node   // map
+	branches []*node // union
+	size     int     // fixed
+}
+
+type field struct {
+	name string
+	node *node
+}
+
+// Parse parses a schema in its JSON form. Named types can be referenced by
+// their full name after their definition, which allows recursive records.
+func Parse(schema string) (*Schema, error) {
+	var v interface{}
+	if err := json.Unmarshal([]byte(schema), &v); err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	p := parser{named: map[string]*node{}}
+	root, err := p.parse(v, "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid Avro schema: %w", err)
+	}
+	return &Schema{root: root}, nil
+}
+
+type parser struct {
+	named map[string]*node
+}
+
+func (p *parser) parse(v interface{}, namespace string) (*node, error) {
+	switch v := v.(type) {
+	case string:
+		return p.reference(v, namespace)
+	case []interface{}:
+		return p.parseUnion(v, namespace)
+	case map[string]interface{}:
+		return p.parseComplex(v, namespace)
+	default:
+		return nil, fmt.Errorf("unexpected schema element %v", v)
+	}
+}
+
+func (p *parser) reference(name, namespace string) (*node, error) {
+	if k, ok := primitives[name]; ok {
+		return &node{kind: k}, nil
+	}
+	if n, ok := p.named[fullName(name, namespace)]; ok {
+		return n, nil
+	}
+	if n, ok := p.named[name]; ok {
+		return n, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", name)
+}
+
+func (p *parser) parseUnion(types []interface{}, namespace string) (*node, error) {
+	n := &node{kind: kindUnion}
+	for _, t := range types {
+		branch, err := p.parse(t, namespace)
+		if err != nil {
+			return nil, err
+		}
+		if branch.kind == kindUnion {
+			return nil, errors.New("unions cannot contain unions")
+		}
+		n.branches = append(n.branches, branch)
+	}
+	return n, nil
+}
+
+func (p *parser) parseComplex(m map[string]interface{}, namespace string) (*node, error) {
+	typ, ok := m["type"]
+	if !ok {
+		return nil, errors.New("missing type")
+	}
+	name, ok := typ.(string)
+	if !ok {
+		// A nested type definition, such as {"type": {"type": "array", ...}}.
+		return p.parse(typ, namespace)
+	}
+
+	logicalType, _ := m["logicalType"].(string)
+
+	switch name {
+	case "record", "error":
+		return p.parseRecord(m, namespace)
+	case "enum":
+		n, err := p.define(m, namespace, kindEnum)
+		if err != nil {
+			return nil, err
+		}
+		symbols, _ := m["symbols"].([]interface{})
+		for _, s := range symbols {
+			str, ok := s.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid symbol %v of enum %q", s, n.name)
+			}
+			n.symbols = append(n.symbols, str)
+		}
+		return n, nil
+	case "fixed":
+		n, err := p.define(m, namespace, kindFixed)
+		if err != nil {
+			return nil, err
+		}
+		size, ok := m["size"].(float64)
+		if !ok || size < 0 {
+			return nil, fmt.Errorf("invalid size of fixed %q", n.name)
+		}
+		n.size = int(size)
+		return n, p.setLogicalType(n, logicalType, m)
+	case "array":
+		items, err := p.parse(m["items"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid array items: %w", err)
+		}
+		return &node{kind: kindArray, items: items}, nil
+	case "map":
+		values, err := p.parse(m["values"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid map values: %w", err)
+		}
+		return &node{kind: kindMap, values: values}, nil
+	}
+
+	n, err := p.reference(name, namespace)
+	if err != nil {
+		return nil, err
+	}
+	if logicalType == "" {
+		return n, nil
+	}
+	if n.name != "" {
+		// Do not modify named types, they may be used without the
+		// logical type elsewhere.
+		c := *n
+		n = &c
+	}
+	return n, p.setLogicalType(n, logicalType, m)
+}
+
+func (p *parser) parseRecord(m map[string]interface{}, namespace string) (*node, error) {
+	n, err := p.define(m, namespace, kindRecord)
+	if err != nil {
+		return nil, err
+	}
+	namespace = namespaceOf(n.name)
+
+	fields, ok := m["fields"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing fields of record %q", n.name)
+	}
+	for _, f := range fields {
+		fm, ok := f.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid field %v of record %q", f, n.name)
+		}
+		name, _ := fm["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("missing field name in record %q", n.name)
+		}
+		fn, err := p.parse(fm["type"], namespace)
+		if err != nil {
+			return nil, fmt.Errorf("invalid field %q of record %q: %w", name, n.name, err)
+		}
+		n.fields = append(n.fields, field{name: name, node: fn})
+	}
+	return n, nil
+}
+
+// define registers a named type.
+func (p *parser) define(m map[string]interface{}, namespace string, k kind) (*node, error) {
+	name, _ := m["name"].(string)
+	if name == "" {
+		return nil, errors.New("missing name of named type")
+	}
+	if ns, ok := m["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	name = fullName(name, namespace)
+	if _, exists := p.named[name]; exists {
+		return nil, fmt.Errorf("type %q is defined more than once", name)
+	}
+	n := &node{kind: k, name: name}
+	p.named[name] = n
+	return n, nil
+}
+
+func (p *parser) setLogicalType(n *node, logicalType string, m map[string]interface{}) error {
+	if logicalType == "decimal" {
+		if n.kind != kindBytes && n.kind != kindFixed {
+			// Invalid logical types must be ignored.
+			return nil
+		}
+		precision, _ := m["precision"].(float64)
+		scale, _ := m["scale"].(float64)
+		if precision <= 0 || scale < 0 || scale > precision {
+			return nil
+		}
+		n.precision, n.scale = int(precision), int(scale)
+	}
+	n.logicalType = logicalType
+	return nil
+}
+
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+func namespaceOf(name string) string {
+	if i := strings.LastIndexByte(name, '.'); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
@@ -37,6 +37,7 @@ type Config struct {
 	OR        []Config               `config:"or"`
 	AND       []Config               `config:"and"`
 	NOT       *Config                `config:"not"`
+	Expr      string                 `config:"expr"`
 }
 
 // Condition is the interface for all defined conditions
@@ -88,6 +89,8 @@ func NewCondition(config *Config) (Condition, error) {
 		if err == nil {
 			condition, err = NewNotCondition(inner)
 		}
+	case config.Expr != "":
+		condition, err = NewExprCondition(config.Expr)
 	default:
 		err = errors.New("missing or invalid condition")
 	}
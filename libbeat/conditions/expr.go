@@ -0,0 +1,192 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/ast"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+	"github.com/google/cel-go/interpreter"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Expr is a Condition that evaluates a CEL expression against the event.
+// Identifiers in the expression are resolved as event fields, so
+// `http.response.status_code >= 500` reads the field of the same name.
+// The expression is compiled once when the condition is created.
+type Expr struct {
+	expr string
+	prg  cel.Program
+	log  *logp.Logger
+}
+
+// NewExprCondition compiles the given CEL expression into a condition.
+func NewExprCondition(expr string) (*Expr, error) {
+	opts := []cel.EnvOption{
+		cel.CustomTypeAdapter(eventAdapter{}),
+		ext.Strings(),
+		ext.Math(),
+		ext.Lists(),
+		ext.Sets(),
+	}
+	env, err := cel.NewEnv(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create expr environment: %w", err)
+	}
+
+	// The fields of an event are not known in advance, so every top-level
+	// identifier of the expression is declared as a dynamically typed
+	// variable. This way the expression can be type checked, reporting
+	// unknown functions and mismatched operands when the condition is
+	// created.
+	parsed, iss := env.Parse(expr)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("failed to compile expr condition '%s': %w", expr, iss.Err())
+	}
+	for _, name := range declareFields(env, parsed.NativeRep().Expr()) {
+		opts = append(opts, cel.Variable(name, cel.DynType))
+	}
+	env, err = cel.NewEnv(opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create expr environment: %w", err)
+	}
+
+	checked, iss := env.Check(parsed)
+	if iss.Err() != nil {
+		return nil, fmt.Errorf("failed to compile expr condition '%s': %w", expr, iss.Err())
+	}
+	prg, err := env.Program(checked, cel.EvalOptions(cel.OptOptimize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile expr condition '%s': %w", expr, err)
+	}
+
+	return &Expr{
+		expr: expr,
+		prg:  prg,
+		log:  logp.L().Named(logName),
+	}, nil
+}
+
+// fieldPrefix marks identifiers that are renamed because they clash with a
+// CEL type name like `type` or `string`. Fields take precedence, so such
+// identifiers still refer to the event field of the same name.
+const fieldPrefix = "@"
+
+// declareFields returns the names of all identifiers in expr that are not
+// bound by a comprehension. Identifiers clashing with CEL type names are
+// renamed in expr.
+func declareFields(env *cel.Env, expr ast.Expr) []string {
+	bound := map[string]bool{}
+	ast.PostOrderVisit(expr, ast.NewExprVisitor(func(e ast.Expr) {
+		if e.Kind() == ast.ComprehensionKind {
+			bound[e.AsComprehension().IterVar()] = true
+			bound[e.AsComprehension().AccuVar()] = true
+		}
+	}))
+
+	fac := ast.NewExprFactory()
+	seen := map[string]bool{}
+	var names []string
+	ast.PostOrderVisit(expr, ast.NewExprVisitor(func(e ast.Expr) {
+		if e.Kind() != ast.IdentKind || bound[e.AsIdent()] {
+			return
+		}
+		name := e.AsIdent()
+		if _, ok := env.CELTypeProvider().FindIdent(name); ok {
+			name = fieldPrefix + name
+			e.SetKindCase(fac.NewIdent(e.ID(), name))
+		}
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}))
+	return names
+}
+
+// Check determines whether the given event matches this condition. An
+// expression that fails to evaluate, for example because it references a
+// missing field, or that does not return a bool does not match.
+func (c *Expr) Check(event ValuesMap) bool {
+	out, _, err := c.prg.Eval(fieldsActivation{event})
+	if err != nil {
+		c.log.Debugf("expr condition '%s' failed: %v", c.expr, err)
+		return false
+	}
+	if out.Type() != types.BoolType {
+		c.log.Debugf("expr condition '%s' returned %v instead of bool", c.expr, out.Type())
+		return false
+	}
+	return out == types.True
+}
+
+func (c *Expr) String() string {
+	return fmt.Sprintf("expr: %v", c.expr)
+}
+
+// fieldsActivation resolves the identifiers of an expression as event
+// fields.
+type fieldsActivation struct {
+	event ValuesMap
+}
+
+func (a fieldsActivation) ResolveName(name string) (interface{}, bool) {
+	v, err := a.event.GetValue(strings.TrimPrefix(name, fieldPrefix))
+	if err != nil {
+		return nil, false
+	}
+	return eventAdapter{}.NativeToValue(v), true
+}
+
+func (a fieldsActivation) Parent() interpreter.Activation {
+	return nil
+}
+
+// eventAdapter converts event values into CEL values. Maps and lists are
+// wrapped instead of copied, so only the values an expression accesses are
+// converted.
+type eventAdapter struct{}
+
+func (a eventAdapter) NativeToValue(value interface{}) ref.Val {
+	switch v := value.(type) {
+	case mapstr.M:
+		return types.NewStringInterfaceMap(a, v)
+	case map[string]interface{}:
+		return types.NewStringInterfaceMap(a, v)
+	case []mapstr.M, []interface{}:
+		return types.NewDynamicList(a, v)
+	case common.Time:
+		return types.Timestamp{Time: time.Time(v)}
+	case *common.Time:
+		if v == nil {
+			return types.NullValue
+		}
+		return types.Timestamp{Time: time.Time(*v)}
+	default:
+		return types.DefaultTypeAdapter.NativeToValue(value)
+	}
+}
@@ -0,0 +1,129 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package conditions
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestExprCondition(t *testing.T) {
+	tests := []struct {
+		expr     string
+		event    *beat.Event
+		expected bool
+	}{
+		{`type == "process"`, secdTestEvent, true},
+		{`proc.cpu.total > 6000 && proc.cpu.total_p < 0.1`, secdTestEvent, true},
+		{`proc.cpu.user + proc.cpu.system == proc.cpu.total`, secdTestEvent, true},
+		{`proc.cpu.user > proc.cpu.system * 3`, secdTestEvent, false},
+		{`proc.cmdline.startsWith("/usr/") && proc.name.upperAscii() == "SECD"`, secdTestEvent, true},
+		{`"prod" in tags`, secdTestEvent, true},
+		{`proc.keywords.exists(k, k.matches("^b"))`, secdTestEvent, true},
+		{`sets.contains(tags, ["security", "auditbeat"])`, secdTestEvent, true},
+		{`!final`, secdTestEvent, true},
+		{`http.code >= 200 && http.code < 300 && status == "OK"`, httpResponseTestEvent, true},
+		{`bytes_out / bytes_in > 100`, httpResponseTestEvent, true},
+		{`path.endsWith(".js") && method in ["GET", "HEAD"]`, httpResponseTestEvent, true},
+		{`has(http.phrase)`, httpResponseTestEvent, true},
+		{`has(http.missing)`, httpResponseTestEvent, false},
+
+		// missing fields and non bool results never match
+		{`missing == "x"`, secdTestEvent, false},
+		{`!(missing == "x")`, secdTestEvent, false},
+		{`type`, secdTestEvent, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			testConfig(t, test.expected, test.event, &Config{Expr: test.expr})
+		})
+	}
+}
+
+func TestExprConditionValues(t *testing.T) {
+	ts := common.MustParseTime("2024-05-01T10:00:00.000Z")
+	event := mapstr.M{
+		"created": ts,
+		"hosts":   []mapstr.M{{"name": "a"}, {"name": "b"}},
+		"labels":  map[string]interface{}{"env": "prod"},
+	}
+
+	for expr, expected := range map[string]bool{
+		`created > timestamp("2024-01-01T00:00:00Z")`: true,
+		`created.getFullYear() == 2023`:               false,
+		`hosts.exists(h, h.name == "b")`:              true,
+		`labels.env == "prod"`:                        true,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			cond, err := NewExprCondition(expr)
+			require.NoError(t, err)
+			assert.Equal(t, expected, cond.Check(event))
+		})
+	}
+}
+
+func TestExprConditionConfig(t *testing.T) {
+	var config Config
+	require.NoError(t, conf.MustNewConfigFrom(`expr: 'size(message) > 3'`).Unpack(&config))
+
+	cond, err := NewCondition(&config)
+	require.NoError(t, err)
+	assert.Equal(t, "expr: size(message) > 3", cond.String())
+	assert.True(t, cond.Check(mapstr.M{"message": "hello"}))
+	assert.False(t, cond.Check(mapstr.M{"message": "hi"}))
+}
+
+func TestExprConditionInvalid(t *testing.T) {
+	for _, expr := range []string{
+		`type ==`,
+		`unknown_function(message)`,
+		`message.startsWith()`,
+		`1 + "a" == 2`,
+		`size(message) > "3"`,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			_, err := NewCondition(&Config{Expr: expr})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestExprConditionTypeNames(t *testing.T) {
+	// Fields named like CEL types take precedence over the type.
+	event := mapstr.M{"type": "process", "int": 5, "string": mapstr.M{"value": "x"}}
+	for expr, expected := range map[string]bool{
+		`type == "process"`:                        true,
+		`int > 3 && string.value == "x"`:           true,
+		`[1, 2].exists(type, type == 2)`:           true,
+		`type.startsWith("proc") && int(int) == 5`: true,
+	} {
+		t.Run(expr, func(t *testing.T) {
+			cond, err := NewExprCondition(expr)
+			require.NoError(t, err)
+			assert.Equal(t, expected, cond.Check(event))
+		})
+	}
+}
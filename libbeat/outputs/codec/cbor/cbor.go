@@ -0,0 +1,75 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package cbor
+
+import (
+	"github.com/ugorji/go/codec"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	outcodec "github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+// Encoder serializes a beat.Event to CBOR. Types are preserved, the event
+// timestamp and any time.Time values are written as RFC 3339 strings with
+// nanosecond precision, tagged as date/time strings (tag 0).
+type Encoder struct {
+	version string
+	handle  codec.CborHandle
+	enc     *codec.Encoder
+}
+
+// Config is used to pass encoding parameters to New.
+type Config struct {
+	// Canonical sorts map keys, so equal events are always encoded to the
+	// same bytes.
+	Canonical bool `config:"canonical"`
+}
+
+func init() {
+	outcodec.RegisterType("cbor", func(info beat.Info, cfg *config.C) (outcodec.Codec, error) {
+		config := Config{}
+		if cfg != nil {
+			if err := cfg.Unpack(&config); err != nil {
+				return nil, err
+			}
+		}
+
+		return New(info.Version, config), nil
+	})
+}
+
+// New creates a new CBOR Encoder.
+func New(version string, config Config) *Encoder {
+	e := &Encoder{version: version}
+	e.handle.TimeRFC3339 = true
+	e.handle.Canonical = config.Canonical
+	e.enc = codec.NewEncoderBytes(new([]byte), &e.handle)
+	return e
+}
+
+// Encode serializes a beat event to CBOR. It adds additional metadata
+// in the `@metadata` namespace.
+func (e *Encoder) Encode(index string, event *beat.Event) ([]byte, error) {
+	var buf []byte
+	e.enc.ResetBytes(&buf)
+	if err := e.enc.Encode(outcodec.MakeEventMap(index, e.version, event)); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
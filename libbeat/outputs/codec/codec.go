@@ -22,3 +22,14 @@ import "github.com/elastic/beats/v7/libbeat/beat"
 type Codec interface {
 	Encode(index string, event *beat.Event) ([]byte, error)
 }
+
+// HeaderCodec is implemented by codecs that start every output file with a
+// header line. The file output writes the header whenever it opens a new
+// file, outputs that do not write files ignore it.
+type HeaderCodec interface {
+	Codec
+
+	// Header returns the header without a trailing newline, or nil if no
+	// header is configured.
+	Header() ([]byte, error)
+}
@@ -0,0 +1,185 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// Encoder serializes a beat.Event to a single CSV record with the configured
+// columns.
+type Encoder struct {
+	buf    bytes.Buffer
+	writer *csv.Writer
+	record []string
+
+	config Config
+}
+
+// Config is used to pass encoding parameters to New.
+type Config struct {
+	// Fields lists the columns of the records. `@timestamp` is the event
+	// timestamp, `@metadata.*` fields are read from the event metadata.
+	Fields []string `config:"fields" validate:"required"`
+
+	// Header writes a header line with the field names at the start of
+	// every file written by the file output.
+	Header bool `config:"header"`
+
+	// Delimiter separates the fields of a record.
+	Delimiter string `config:"delimiter"`
+}
+
+var defaultConfig = Config{
+	Delimiter: ",",
+}
+
+func (c *Config) Validate() error {
+	if utf8.RuneCountInString(c.Delimiter) != 1 {
+		return fmt.Errorf("csv delimiter must be a single character, got '%v'", c.Delimiter)
+	}
+	r, _ := utf8.DecodeRuneInString(c.Delimiter)
+	if r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+		return fmt.Errorf("invalid csv delimiter '%v'", c.Delimiter)
+	}
+	return nil
+}
+
+func init() {
+	codec.RegisterType("csv", func(_ beat.Info, cfg *config.C) (codec.Codec, error) {
+		config := defaultConfig
+		if cfg == nil {
+			return nil, errors.New("empty csv codec configuration")
+		}
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		return New(config), nil
+	})
+}
+
+// New creates a new CSV Encoder.
+func New(config Config) *Encoder {
+	e := &Encoder{
+		config: config,
+		record: make([]string, len(config.Fields)),
+	}
+	e.writer = csv.NewWriter(&e.buf)
+	if config.Delimiter != "" {
+		e.writer.Comma, _ = utf8.DecodeRuneInString(config.Delimiter)
+	}
+	return e
+}
+
+// Header returns the field names as CSV record if the header is enabled.
+// Only the file output writes the header, once at the start of every file.
+func (e *Encoder) Header() ([]byte, error) {
+	if !e.config.Header {
+		return nil, nil
+	}
+	out, err := e.encodeRecord(e.config.Fields)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(out), nil
+}
+
+// Encode serializes the configured fields of an event to a CSV record. The
+// record is returned without a trailing newline.
+func (e *Encoder) Encode(_ string, event *beat.Event) ([]byte, error) {
+	for i, field := range e.config.Fields {
+		value, err := fieldValue(event, field)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode field '%v': %w", field, err)
+		}
+		e.record[i] = value
+	}
+	return e.encodeRecord(e.record)
+}
+
+func (e *Encoder) encodeRecord(record []string) ([]byte, error) {
+	e.buf.Reset()
+	if err := e.writer.Write(record); err != nil {
+		return nil, err
+	}
+	e.writer.Flush()
+	if err := e.writer.Error(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(e.buf.Bytes(), []byte("\n")), nil
+}
+
+func fieldValue(event *beat.Event, field string) (string, error) {
+	var value interface{}
+	switch {
+	case field == "@timestamp":
+		value = event.Timestamp
+	case strings.HasPrefix(field, "@metadata."):
+		v, err := event.Meta.GetValue(strings.TrimPrefix(field, "@metadata."))
+		if err != nil {
+			return "", nil //nolint:nilerr // missing fields are empty columns
+		}
+		value = v
+	default:
+		v, err := event.Fields.GetValue(field)
+		if err != nil {
+			return "", nil //nolint:nilerr // missing fields are empty columns
+		}
+		value = v
+	}
+	return formatValue(value)
+}
+
+func formatValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	case time.Time:
+		return common.Time(v).String(), nil
+	case common.Time:
+		return v.String(), nil
+	case mapstr.M, map[string]interface{}, []interface{}, []string, []mapstr.M:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(b), nil
+	case fmt.Stringer:
+		return v.String(), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
@@ -0,0 +1,116 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package csv
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestEncode(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 30, 45, 123000000, time.UTC)
+	event := &beat.Event{
+		Timestamp: ts,
+		Meta:      mapstr.M{"pipeline": "p1"},
+		Fields: mapstr.M{
+			"message": `say "hi", then leave`,
+			"host":    mapstr.M{"name": "web-1"},
+			"http":    mapstr.M{"response": mapstr.M{"status_code": 200}},
+			"tags":    []string{"a", "b"},
+		},
+	}
+
+	tests := map[string]struct {
+		config Config
+		want   []string
+	}{
+		"columns": {
+			config: Config{Fields: []string{"@timestamp", "host.name", "http.response.status_code", "message", "missing"}},
+			want:   []string{`2024-05-01T12:30:45.123Z,web-1,200,"say ""hi"", then leave",`},
+		},
+		"header is not part of the records": {
+			config: Config{Fields: []string{"host.name", "@metadata.pipeline"}, Header: true},
+			want:   []string{"web-1,p1", "web-1,p1"},
+		},
+		"delimiter and nested values": {
+			config: Config{Fields: []string{"host", "tags"}, Delimiter: "\t"},
+			want:   []string{"\"{\"\"name\"\":\"\"web-1\"\"}\"\t\"[\"\"a\"\",\"\"b\"\"]\""},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			enc := New(test.config)
+			for _, want := range test.want {
+				out, err := enc.Encode("testbeat", event)
+				require.NoError(t, err)
+				assert.Equal(t, want, string(out))
+			}
+		})
+	}
+}
+
+func TestHeader(t *testing.T) {
+	enc := New(Config{Fields: []string{"host.name", "@metadata.pipeline"}, Header: true, Delimiter: ";"})
+	header, err := enc.Header()
+	require.NoError(t, err)
+	assert.Equal(t, "host.name;@metadata.pipeline", string(header))
+
+	// The header must not be overwritten by encoding records.
+	_, err = enc.Encode("testbeat", &beat.Event{Fields: mapstr.M{"host": mapstr.M{"name": "web-1"}}})
+	require.NoError(t, err)
+	assert.Equal(t, "host.name;@metadata.pipeline", string(header))
+
+	header, err = New(Config{Fields: []string{"message"}}).Header()
+	require.NoError(t, err)
+	assert.Nil(t, header)
+}
+
+func TestConfig(t *testing.T) {
+	tests := map[string]struct {
+		cfg   mapstr.M
+		valid bool
+	}{
+		"fields":             {cfg: mapstr.M{"fields": []string{"message"}}, valid: true},
+		"missing fields":     {cfg: mapstr.M{}, valid: false},
+		"semicolon":          {cfg: mapstr.M{"fields": []string{"message"}, "delimiter": ";"}, valid: true},
+		"multi char":         {cfg: mapstr.M{"fields": []string{"message"}, "delimiter": "||"}, valid: false},
+		"quote as delimiter": {cfg: mapstr.M{"fields": []string{"message"}, "delimiter": `"`}, valid: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig
+			err := config.MustNewConfigFrom(test.cfg).Unpack(&c)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
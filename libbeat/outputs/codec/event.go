@@ -0,0 +1,88 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package codec
+
+import (
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// MakeEventMap returns the event in the same layout the json codec uses, with
+// the timestamp in `@timestamp` and the metadata in `@metadata`. Nested maps
+// and slices are copied and normalized to plain Go types so the result can be
+// serialized by generic encoders: mapstr.M becomes map[string]interface{} and
+// common.Time becomes time.Time. All other values are kept as is.
+func MakeEventMap(index, version string, event *beat.Event) map[string]interface{} {
+	out := make(map[string]interface{}, len(event.Fields)+2)
+	for k, v := range event.Fields {
+		out[k] = normalizeValue(v)
+	}
+
+	meta := make(map[string]interface{}, len(event.Meta)+3)
+	for k, v := range event.Meta {
+		meta[k] = normalizeValue(v)
+	}
+	meta["beat"] = index
+	meta["type"] = "_doc"
+	meta["version"] = version
+
+	out["@timestamp"] = event.Timestamp
+	out["@metadata"] = meta
+	return out
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case mapstr.M:
+		return normalizeMap(v)
+	case map[string]interface{}:
+		return normalizeMap(v)
+	case []mapstr.M:
+		out := make([]interface{}, len(v))
+		for i, m := range v {
+			out[i] = normalizeMap(m)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = normalizeValue(e)
+		}
+		return out
+	case common.Time:
+		return time.Time(v)
+	case *common.Time:
+		if v == nil {
+			return nil
+		}
+		return time.Time(*v)
+	default:
+		return v
+	}
+}
+
+func normalizeMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = normalizeValue(v)
+	}
+	return out
+}
@@ -0,0 +1,94 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package msgpack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func decode(t *testing.T, data []byte) map[string]interface{} {
+	t.Helper()
+	h := &codec.MsgpackHandle{}
+	h.MapType = reflect.TypeOf(map[string]interface{}(nil))
+	h.WriteExt = true
+	var out map[string]interface{}
+	require.NoError(t, codec.NewDecoderBytes(data, h).Decode(&out))
+	return out
+}
+
+func TestEncode(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.UTC)
+	enc := New("9.0.0", Config{})
+
+	data, err := enc.Encode("testbeat", &beat.Event{
+		Timestamp: ts,
+		Meta:      mapstr.M{"pipeline": "p1"},
+		Fields: mapstr.M{
+			"message": "hello",
+			"count":   int64(-42),
+			"ratio":   0.25,
+			"ok":      true,
+			"tags":    []interface{}{"a", "b"},
+			"event":   mapstr.M{"created": common.Time(ts)},
+			"raw":     []byte{0x00, 0xff},
+		},
+	})
+	require.NoError(t, err)
+
+	out := decode(t, data)
+	assert.True(t, ts.Equal(out["@timestamp"].(time.Time)), out["@timestamp"])
+	assert.Equal(t, map[string]interface{}{
+		"beat":     "testbeat",
+		"type":     "_doc",
+		"version":  "9.0.0",
+		"pipeline": "p1",
+	}, out["@metadata"])
+	assert.Equal(t, "hello", out["message"])
+	assert.EqualValues(t, -42, out["count"])
+	assert.Equal(t, 0.25, out["ratio"])
+	assert.Equal(t, true, out["ok"])
+	assert.Equal(t, []interface{}{"a", "b"}, out["tags"])
+	assert.Equal(t, []byte{0x00, 0xff}, out["raw"])
+	created := out["event"].(map[string]interface{})["created"].(time.Time)
+	assert.True(t, ts.Equal(created))
+}
+
+func TestEncodeCanonical(t *testing.T) {
+	enc := New("9.0.0", Config{Canonical: true})
+	event := &beat.Event{Fields: mapstr.M{"b": 1, "a": 2, "c": mapstr.M{"z": 1, "y": 2}}}
+
+	first, err := enc.Encode("testbeat", event)
+	require.NoError(t, err)
+	for i := 0; i < 10; i++ {
+		data, err := enc.Encode("testbeat", event)
+		require.NoError(t, err)
+		assert.Equal(t, first, data)
+	}
+}
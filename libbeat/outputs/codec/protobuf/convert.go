@@ -0,0 +1,326 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protobuf
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+)
+
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// setMessage sets the fields of msg from values. Keys are matched to the
+// field names first and to their JSON names second, a leading `@` is
+// ignored. Unknown keys and nil values are skipped.
+func setMessage(msg protoreflect.Message, values map[string]interface{}) error {
+	fields := msg.Descriptor().Fields()
+	for key, v := range values {
+		if v == nil {
+			continue
+		}
+		name := strings.TrimPrefix(key, "@")
+		fd := fields.ByName(protoreflect.Name(name))
+		if fd == nil {
+			fd = fields.ByJSONName(name)
+		}
+		if fd == nil {
+			continue
+		}
+		if err := setField(msg, fd, v); err != nil {
+			return fmt.Errorf("failed to set field '%v': %w", fd.FullName(), err)
+		}
+	}
+	return nil
+}
+
+func setField(msg protoreflect.Message, fd protoreflect.FieldDescriptor, v interface{}) error {
+	switch {
+	case fd.IsMap():
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", v)
+		}
+		dst := msg.Mutable(fd).Map()
+		for k, ev := range m {
+			if ev == nil {
+				continue
+			}
+			key, err := scalarValue(fd.MapKey(), k)
+			if err != nil {
+				return fmt.Errorf("key '%v': %w", k, err)
+			}
+			val, err := newValue(fd.MapValue(), ev, dst.NewValue)
+			if err != nil {
+				return fmt.Errorf("key '%v': %w", k, err)
+			}
+			dst.Set(key.MapKey(), val)
+		}
+		return nil
+
+	case fd.IsList():
+		rv := reflect.ValueOf(v)
+		if _, isBytes := v.([]byte); isBytes || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			v = []interface{}{v}
+			rv = reflect.ValueOf(v)
+		}
+		dst := msg.Mutable(fd).List()
+		for i := 0; i < rv.Len(); i++ {
+			ev := rv.Index(i).Interface()
+			if ev == nil {
+				continue
+			}
+			val, err := newValue(fd, ev, dst.NewElement)
+			if err != nil {
+				return fmt.Errorf("index %d: %w", i, err)
+			}
+			dst.Append(val)
+		}
+		return nil
+
+	case fd.Kind() == protoreflect.MessageKind || fd.Kind() == protoreflect.GroupKind:
+		return setMessageValue(msg.Mutable(fd).Message(), v)
+
+	default:
+		val, err := scalarValue(fd, v)
+		if err != nil {
+			return err
+		}
+		msg.Set(fd, val)
+		return nil
+	}
+}
+
+// newValue converts v to a single value of fd, allocating messages with
+// alloc.
+func newValue(fd protoreflect.FieldDescriptor, v interface{}, alloc func() protoreflect.Value) (protoreflect.Value, error) {
+	if fd.Kind() != protoreflect.MessageKind && fd.Kind() != protoreflect.GroupKind {
+		return scalarValue(fd, v)
+	}
+	val := alloc()
+	if err := setMessageValue(val.Message(), v); err != nil {
+		return protoreflect.Value{}, err
+	}
+	return val, nil
+}
+
+func setMessageValue(msg protoreflect.Message, v interface{}) error {
+	if msg.Descriptor().FullName() == timestampName {
+		return setTimestamp(msg, v)
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected object, got %T", v)
+	}
+	return setMessage(msg, m)
+}
+
+func setTimestamp(msg protoreflect.Message, v interface{}) error {
+	var ts time.Time
+	switch v := v.(type) {
+	case time.Time:
+		ts = v
+	case common.Time:
+		ts = time.Time(v)
+	case string:
+		var err error
+		if ts, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("expected timestamp, got %T", v)
+	}
+	fields := msg.Descriptor().Fields()
+	msg.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(ts.Unix()))
+	msg.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(ts.Nanosecond())))
+	return nil
+}
+
+func scalarValue(fd protoreflect.FieldDescriptor, v interface{}) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		switch b := v.(type) {
+		case bool:
+			return protoreflect.ValueOfBool(b), nil
+		case string:
+			parsed, err := strconv.ParseBool(b)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfBool(parsed), nil
+		}
+
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		i, err := toInt64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return protoreflect.Value{}, fmt.Errorf("value %v overflows int32", i)
+		}
+		return protoreflect.ValueOfInt32(int32(i)), nil
+
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		i, err := toInt64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfInt64(i), nil
+
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		u, err := toUint64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if u > math.MaxUint32 {
+			return protoreflect.Value{}, fmt.Errorf("value %v overflows uint32", u)
+		}
+		return protoreflect.ValueOfUint32(uint32(u)), nil
+
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		u, err := toUint64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfUint64(u), nil
+
+	case protoreflect.FloatKind:
+		f, err := toFloat64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat32(float32(f)), nil
+
+	case protoreflect.DoubleKind:
+		f, err := toFloat64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+
+	case protoreflect.StringKind:
+		switch s := v.(type) {
+		case string:
+			return protoreflect.ValueOfString(s), nil
+		case []byte:
+			return protoreflect.ValueOfString(string(s)), nil
+		case time.Time:
+			return protoreflect.ValueOfString(s.UTC().Format(time.RFC3339Nano)), nil
+		case map[string]interface{}, []interface{}:
+			b, err := json.Marshal(s)
+			if err != nil {
+				return protoreflect.Value{}, err
+			}
+			return protoreflect.ValueOfString(string(b)), nil
+		default:
+			return protoreflect.ValueOfString(fmt.Sprint(s)), nil
+		}
+
+	case protoreflect.BytesKind:
+		switch b := v.(type) {
+		case []byte:
+			return protoreflect.ValueOfBytes(b), nil
+		case string:
+			return protoreflect.ValueOfBytes([]byte(b)), nil
+		}
+
+	case protoreflect.EnumKind:
+		if s, ok := v.(string); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(s))
+			if ev == nil {
+				return protoreflect.Value{}, fmt.Errorf("unknown value '%v' for enum %v", s, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		i, err := toInt64(v)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		if i < math.MinInt32 || i > math.MaxInt32 {
+			return protoreflect.Value{}, fmt.Errorf("value %v overflows enum %v", i, fd.Enum().FullName())
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(i)), nil
+	}
+
+	return protoreflect.Value{}, fmt.Errorf("cannot convert %T to %v", v, fd.Kind())
+}
+
+func toInt64(v interface{}) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u := rv.Uint()
+		if u > math.MaxInt64 {
+			return 0, fmt.Errorf("value %v overflows int64", u)
+		}
+		return int64(u), nil
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return 0, fmt.Errorf("value %v is not an integer", f)
+		}
+		return int64(f), nil
+	case reflect.String:
+		return strconv.ParseInt(rv.String(), 10, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to integer", v)
+}
+
+func toUint64(v interface{}) (uint64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.String:
+		return strconv.ParseUint(rv.String(), 10, 64)
+	}
+	i, err := toInt64(v)
+	if err != nil {
+		return 0, err
+	}
+	if i < 0 {
+		return 0, fmt.Errorf("value %v is negative", i)
+	}
+	return uint64(i), nil
+}
+
+func toFloat64(v interface{}) (float64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint()), nil
+	case reflect.String:
+		return strconv.ParseFloat(rv.String(), 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to float", v)
+}
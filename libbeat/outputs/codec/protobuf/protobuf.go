@@ -0,0 +1,181 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package protobuf
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+// Encoder serializes a beat.Event to a Protobuf message described by a
+// schema loaded at runtime.
+type Encoder struct {
+	version string
+	desc    protoreflect.MessageDescriptor
+}
+
+// Config is used to pass encoding parameters to New.
+type Config struct {
+	// DescriptorSet is the path to a serialized FileDescriptorSet, as written
+	// by `protoc --include_imports --descriptor_set_out`.
+	DescriptorSet string `config:"descriptor_set" validate:"required"`
+
+	// Message is the fully qualified name of the message events are encoded
+	// to.
+	Message string `config:"message" validate:"required"`
+}
+
+func init() {
+	codec.RegisterType("protobuf", func(info beat.Info, cfg *config.C) (codec.Codec, error) {
+		config := Config{}
+		if cfg == nil {
+			return nil, errors.New("empty protobuf codec configuration")
+		}
+		if err := cfg.Unpack(&config); err != nil {
+			return nil, err
+		}
+
+		desc, err := LoadMessageDescriptor(config.DescriptorSet, config.Message)
+		if err != nil {
+			return nil, err
+		}
+		return New(info.Version, desc), nil
+	})
+}
+
+// New creates a new Protobuf Encoder for the given message type.
+func New(version string, desc protoreflect.MessageDescriptor) *Encoder {
+	return &Encoder{version: version, desc: desc}
+}
+
+// Encode serializes a beat event to the configured Protobuf message. Event
+// fields are matched by name to the message fields, `@timestamp` and
+// `@metadata` match fields named `timestamp` and `metadata`. Fields that are
+// not part of the message are ignored.
+func (e *Encoder) Encode(index string, event *beat.Event) ([]byte, error) {
+	msg := dynamicpb.NewMessage(e.desc)
+	if err := setMessage(msg, codec.MakeEventMap(index, e.version, event)); err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+// LoadMessageDescriptor reads a FileDescriptorSet from path and returns the
+// descriptor of the named message. Imports missing from the set are resolved
+// from the well-known types linked into the binary.
+func LoadMessageDescriptor(path, name string) (protoreflect.MessageDescriptor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read protobuf descriptor set: %w", err)
+	}
+
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse protobuf descriptor set '%v': %w", path, err)
+	}
+
+	files, err := RegisterFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf descriptor set '%v': %w", path, err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(name))
+	if err != nil {
+		return nil, fmt.Errorf("message '%v' not found in protobuf descriptor set '%v': %w", name, path, err)
+	}
+	desc, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("'%v' in protobuf descriptor set '%v' is not a message", name, path)
+	}
+	return desc, nil
+}
+
+// RegisterFiles builds the file descriptors of set in dependency order.
+// Imports missing from the set are resolved from the well-known types linked
+// into the binary.
+func RegisterFiles(set *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error) {
+	pending := make(map[string]*descriptorpb.FileDescriptorProto, len(set.File))
+	for _, fd := range set.File {
+		pending[fd.GetName()] = fd
+	}
+
+	files := &protoregistry.Files{}
+	r := resolver{files}
+	var register func(name string, seen map[string]bool) error
+	register = func(name string, seen map[string]bool) error {
+		fd, ok := pending[name]
+		if !ok {
+			return nil
+		}
+		if seen[name] {
+			return fmt.Errorf("import cycle in '%v'", name)
+		}
+		seen[name] = true
+		for _, dep := range fd.GetDependency() {
+			if err := register(dep, seen); err != nil {
+				return err
+			}
+		}
+
+		file, err := protodesc.NewFile(fd, r)
+		if err != nil {
+			return err
+		}
+		delete(pending, name)
+		return files.RegisterFile(file)
+	}
+
+	for _, fd := range set.File {
+		if err := register(fd.GetName(), map[string]bool{}); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// resolver looks up descriptors in the loaded files first and falls back to
+// the global registry for well-known types.
+type resolver struct {
+	files *protoregistry.Files
+}
+
+func (r resolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	return protoregistry.GlobalFiles.FindFileByPath(path)
+}
+
+func (r resolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if d, err := r.files.FindDescriptorByName(name); err == nil {
+		return d, nil
+	}
+	return protoregistry.GlobalFiles.FindDescriptorByName(name)
+}
@@ -0,0 +1,238 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package protobuf
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(number),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// writeDescriptorSet writes the descriptor set of the test schema:
+//
+//	syntax = "proto3";
+//	package test;
+//	import "google/protobuf/timestamp.proto";
+//	message Event {
+//	  enum Level { UNKNOWN = 0; INFO = 1; ERROR = 2; }
+//	  message Metadata { string beat = 1; string version = 2; }
+//	  message Host { string name = 1; int32 port = 2; }
+//	  google.protobuf.Timestamp timestamp = 1;
+//	  Metadata metadata = 2;
+//	  string message = 3;
+//	  int64 count = 4;
+//	  double ratio = 5;
+//	  repeated string tags = 6;
+//	  map<string, string> labels = 7;
+//	  Host host = 8;
+//	  Level level = 9;
+//	  repeated Host peers = 10;
+//	  bytes raw = 11;
+//	}
+func writeDescriptorSet(t *testing.T) string {
+	t.Helper()
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("test/event.proto"),
+		Package:    proto.String("test"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Event"),
+			EnumType: []*descriptorpb.EnumDescriptorProto{{
+				Name: proto.String("Level"),
+				Value: []*descriptorpb.EnumValueDescriptorProto{
+					{Name: proto.String("UNKNOWN"), Number: proto.Int32(0)},
+					{Name: proto.String("INFO"), Number: proto.Int32(1)},
+					{Name: proto.String("ERROR"), Number: proto.Int32(2)},
+				},
+			}},
+			NestedType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("Metadata"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("beat", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+						field("version", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+					},
+				},
+				{
+					Name: proto.String("Host"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+						field("port", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false),
+					},
+				},
+				{
+					Name:    proto.String("LabelsEntry"),
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+						field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+					},
+				},
+			},
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("timestamp", 1, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp", false),
+				field("metadata", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Event.Metadata", false),
+				field("message", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+				field("count", 4, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false),
+				field("ratio", 5, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+				field("tags", 6, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", true),
+				field("labels", 7, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Event.LabelsEntry", true),
+				field("host", 8, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Event.Host", false),
+				field("level", 9, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".test.Event.Level", false),
+				field("peers", 10, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".test.Event.Host", true),
+				field("raw", 11, descriptorpb.FieldDescriptorProto_TYPE_BYTES, "", false),
+			},
+		}},
+	}
+
+	data, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{file}})
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "event.desc")
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestEncode(t *testing.T) {
+	desc, err := LoadMessageDescriptor(writeDescriptorSet(t), "test.Event")
+	require.NoError(t, err)
+	enc := New("9.0.0", desc)
+
+	data, err := enc.Encode("testbeat", &beat.Event{
+		Timestamp: time.Date(2024, 5, 1, 12, 30, 45, 123456789, time.UTC),
+		Fields: mapstr.M{
+			"message": "hello",
+			"count":   "42",
+			"ratio":   1,
+			"tags":    []string{"a", "b"},
+			"labels":  mapstr.M{"env": "prod"},
+			"host":    mapstr.M{"name": "web-1", "port": uint16(8080), "ip": "10.0.0.1"},
+			"level":   "ERROR",
+			"peers":   []mapstr.M{{"name": "web-2"}, {"name": "web-3"}},
+			"raw":     []byte{0x01, 0x02},
+			"unknown": "ignored",
+		},
+	})
+	require.NoError(t, err)
+
+	msg := dynamicpb.NewMessage(desc)
+	require.NoError(t, proto.Unmarshal(data, msg))
+	out, err := protojson.Marshal(msg)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"timestamp": "2024-05-01T12:30:45.123456789Z",
+		"metadata": {"beat": "testbeat", "version": "9.0.0"},
+		"message": "hello",
+		"count": "42",
+		"ratio": 1,
+		"tags": ["a", "b"],
+		"labels": {"env": "prod"},
+		"host": {"name": "web-1", "port": 8080},
+		"level": "ERROR",
+		"peers": [{"name": "web-2"}, {"name": "web-3"}],
+		"raw": "AQI="
+	}`, string(out))
+}
+
+func TestEncodeErrors(t *testing.T) {
+	desc, err := LoadMessageDescriptor(writeDescriptorSet(t), "test.Event")
+	require.NoError(t, err)
+	enc := New("9.0.0", desc)
+
+	tests := map[string]mapstr.M{
+		"not an integer":   {"count": "many"},
+		"int32 overflow":   {"host": mapstr.M{"port": int64(1) << 40}},
+		"unknown enum":     {"level": "DEBUG"},
+		"object expected":  {"host": "web-1"},
+		"float to integer": {"count": 1.5},
+	}
+	for name, fields := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := enc.Encode("testbeat", &beat.Event{Timestamp: time.Now(), Fields: fields})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadMessageDescriptor(t *testing.T) {
+	path := writeDescriptorSet(t)
+
+	_, err := LoadMessageDescriptor(path, "test.Missing")
+	assert.Error(t, err)
+
+	_, err = LoadMessageDescriptor(path, "test.Event.Level")
+	assert.Error(t, err)
+
+	_, err = LoadMessageDescriptor(filepath.Join(t.TempDir(), "missing.desc"), "test.Event")
+	assert.Error(t, err)
+}
+
+func TestConfig(t *testing.T) {
+	path := writeDescriptorSet(t)
+
+	for name, test := range map[string]struct {
+		cfg   mapstr.M
+		valid bool
+	}{
+		"complete":        {cfg: mapstr.M{"descriptor_set": path, "message": "test.Event"}, valid: true},
+		"missing message": {cfg: mapstr.M{"descriptor_set": path}, valid: false},
+		"missing set":     {cfg: mapstr.M{"message": "test.Event"}, valid: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var c Config
+			err := config.MustNewConfigFrom(test.cfg).Unpack(&c)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -31,6 +31,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/beat/events"
 	"github.com/elastic/beats/v7/libbeat/esleg/eslegclient"
 	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/elastic-agent-libs/logp"
@@ -59,6 +60,10 @@ type Client struct {
 	// forwarded to this index. Otherwise, they will be dropped.
 	deadLetterIndex string
 
+	// If deadLetterFile is set, events that would be dropped due to
+	// bulk-ingest errors are written to local files instead.
+	deadLetterFile *deadletter.Writer
+
 	log                    *logp.Logger
 	pLogIndex              *periodic.Doer
 	pLogIndexTryDeadLetter *periodic.Doer
@@ -79,6 +84,10 @@ type clientSettings struct {
 	// If deadLetterIndex is set, events with bulk-ingest errors will be
 	// forwarded to this index. Otherwise, they will be dropped.
 	deadLetterIndex string
+
+	// If deadLetterFile is set, events that would be dropped due to
+	// bulk-ingest errors are written to local files instead.
+	deadLetterFile *deadletter.Writer
 }
 
 type bulkResultStats struct {
@@ -191,6 +200,7 @@ func NewClient(
 		pipelineSelector: pipeline,
 		observer:         observer,
 		deadLetterIndex:  s.deadLetterIndex,
+		deadLetterFile:   s.deadLetterFile,
 
 		log:                    log,
 		pLogDeadLetter:         pLogDeadLetter,
@@ -234,6 +244,7 @@ func (client *Client) Clone() *Client {
 			indexSelector:    client.indexSelector,
 			pipelineSelector: client.pipelineSelector,
 			deadLetterIndex:  client.deadLetterIndex,
+			deadLetterFile:   client.deadLetterFile,
 		},
 		nil, // XXX: do not pass connection callback?
 	)
@@ -506,6 +517,11 @@ func (client *Client) applyItemStatus(
 		if encodedEvent.deadLetter {
 			// Fatal error while sending an already-failed event to the dead letter
 			// index, drop.
+			if client.writeDeadLetterFile(encodedEvent, itemStatus, itemMessage) {
+				client.log.Warnw(fmt.Sprintf("Can't deliver to dead letter index event '%s' (status=%v): %s, written to dead letter file", encodedEvent, itemStatus, itemMessage), logp.TypeKey, logp.EventType)
+				stats.nonIndexable++
+				return false
+			}
 			client.pLogDeadLetter.Add()
 			client.log.Errorw(fmt.Sprintf("Can't deliver to dead letter index event '%s' (status=%v): %s", encodedEvent, itemStatus, itemMessage), logp.TypeKey, logp.EventType)
 			stats.nonIndexable++
@@ -513,6 +529,11 @@ func (client *Client) applyItemStatus(
 		}
 		if client.deadLetterIndex == "" {
 			// Fatal error and no dead letter index, drop.
+			if client.writeDeadLetterFile(encodedEvent, itemStatus, itemMessage) {
+				client.log.Warnw(fmt.Sprintf("Cannot index event '%s' (status=%v): %s, written to dead letter file", encodedEvent, itemStatus, itemMessage), logp.TypeKey, logp.EventType)
+				stats.nonIndexable++
+				return false
+			}
 			client.pLogIndex.Add()
 			client.log.Warnw(fmt.Sprintf("Cannot index event '%s' (status=%v): %s, dropping event!", encodedEvent, itemStatus, itemMessage), logp.TypeKey, logp.EventType)
 			stats.nonIndexable++
@@ -532,12 +553,49 @@ func (client *Client) applyItemStatus(
 	return true
 }
 
+// writeDeadLetterFile writes a rejected event to the dead letter file, if one
+// is configured. Returns true if the event has been written.
+func (client *Client) writeDeadLetterFile(event *encodedEvent, itemStatus int, itemMessage []byte) bool {
+	if client.deadLetterFile == nil {
+		return false
+	}
+
+	record := deadletter.Record{
+		Timestamp: time.Now().UTC(),
+		Index:     event.index,
+		Pipeline:  event.pipeline,
+		ID:        event.id,
+		OpType:    event.opType.String(),
+		Status:    itemStatus,
+		Error:     deadletter.ErrorMessage(itemMessage),
+		Event:     event.encoding,
+	}
+	if r := event.rejected; r != nil {
+		record.Index = r.index
+		record.Event = r.encoding
+		record.Status = r.status
+		record.Error = deadletter.ErrorMessage([]byte(r.message))
+		record.DeadLetterStatus = itemStatus
+		record.DeadLetterError = deadletter.ErrorMessage(itemMessage)
+	}
+
+	if err := client.deadLetterFile.Write(record); err != nil {
+		client.log.Errorf("Failed to write event to dead letter file: %v", err)
+		return false
+	}
+	return true
+}
+
 func (client *Client) Connect(ctx context.Context) error {
 	return client.conn.Connect(ctx)
 }
 
 func (client *Client) Close() error {
-	return client.conn.Close()
+	err := client.conn.Close()
+	if client.deadLetterFile != nil {
+		err = errors.Join(err, client.deadLetterFile.Close())
+	}
+	return err
 }
 
 func (client *Client) String() string {
@@ -42,6 +42,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/idxmgmt"
 	"github.com/elastic/beats/v7/libbeat/internal/testutil"
 	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
 	"github.com/elastic/beats/v7/libbeat/outputs/outest"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/beats/v7/libbeat/publisher"
@@ -534,6 +535,115 @@ func TestCollectPublishFailDrop(t *testing.T) {
 	assert.Equal(t, bulkResultStats{acked: 2, fails: 0, nonIndexable: 1}, stats)
 }
 
+func TestCollectPublishFailDeadLetterFile(t *testing.T) {
+	dir := t.TempDir()
+	dlConfig := deadletter.DefaultConfig()
+	dlConfig.Path = dir
+	writer := deadletter.NewWriter(dlConfig)
+	defer writer.Close()
+
+	client, err := NewClient(
+		clientSettings{
+			observer:       outputs.NewNilObserver(),
+			deadLetterFile: writer,
+		},
+		nil,
+	)
+	require.NoError(t, err)
+
+	response := []byte(`
+    { "items": [
+      {"create": {"status": 200}},
+      {"create": {
+		  "error" : {"type" : "mapper_parsing_exception", "reason" : "failed to parse field [bar]"},
+		  "status" : 400
+		}
+      }
+    ]}
+  `)
+
+	event := publisher.Event{Content: beat.Event{Fields: mapstr.M{"bar": 1}}}
+	eventFail := publisher.Event{Content: beat.Event{Fields: mapstr.M{"bar": "bar1"}}}
+	events := encodeEvents(client, []publisher.Event{event, eventFail})
+
+	res, stats := client.bulkCollectPublishFails(bulkResult{
+		events:   events,
+		status:   200,
+		response: response,
+	})
+	assert.Equal(t, 0, len(res))
+	assert.Equal(t, bulkResultStats{acked: 1, nonIndexable: 1}, stats)
+	// Closing the client closes the dead letter file.
+	require.NoError(t, client.Close())
+
+	records := readDeadLetterRecords(t, dir)
+	require.Len(t, records, 1)
+	assert.Equal(t, 400, records[0].Status)
+	assert.Equal(t, "mapper_parsing_exception: failed to parse field [bar]", records[0].Reason())
+	assert.Equal(t, events[1].EncodedEvent.(*encodedEvent).index, records[0].Index)
+	assert.JSONEq(t, string(events[1].EncodedEvent.(*encodedEvent).encoding), string(records[0].Event))
+}
+
+func TestCollectPublishFailDeadLetterIndexAndFile(t *testing.T) {
+	// An event rejected by the dead letter index is written to the dead
+	// letter file with its original index and document.
+	const deadLetterIndex = "test_index"
+	dir := t.TempDir()
+	dlConfig := deadletter.DefaultConfig()
+	dlConfig.Path = dir
+	writer := deadletter.NewWriter(dlConfig)
+	defer writer.Close()
+
+	client, err := NewClient(
+		clientSettings{
+			observer:        outputs.NewNilObserver(),
+			deadLetterIndex: deadLetterIndex,
+			deadLetterFile:  writer,
+		},
+		nil,
+	)
+	require.NoError(t, err)
+
+	event := encodeEvent(client, publisher.Event{Content: beat.Event{Fields: mapstr.M{"bar": 1}}})
+	encoded := event.EncodedEvent.(*encodedEvent)
+	originalIndex, originalEncoding := encoded.index, encoded.encoding
+	encoded.setDeadLetter(deadLetterIndex, 400, "original error")
+
+	res, stats := client.bulkCollectPublishFails(bulkResult{
+		events:   []publisher.Event{event},
+		status:   200,
+		response: []byte(`{"items": [{"create": {"status": 403, "error": "index closed"}}]}`),
+	})
+	assert.Equal(t, 0, len(res))
+	assert.Equal(t, bulkResultStats{nonIndexable: 1}, stats)
+	require.NoError(t, writer.Close())
+
+	records := readDeadLetterRecords(t, dir)
+	require.Len(t, records, 1)
+	assert.Equal(t, originalIndex, records[0].Index)
+	assert.JSONEq(t, string(originalEncoding), string(records[0].Event))
+	assert.Equal(t, 400, records[0].Status)
+	assert.Equal(t, "original error", records[0].Reason())
+	assert.Equal(t, 403, records[0].DeadLetterStatus)
+	assert.JSONEq(t, `"index closed"`, string(records[0].DeadLetterError))
+}
+
+func readDeadLetterRecords(t *testing.T, dir string) []deadletter.Record {
+	t.Helper()
+	files, err := deadletter.Files(dir)
+	require.NoError(t, err)
+
+	var records []deadletter.Record
+	for _, f := range files {
+		err := deadletter.ReadFile(f, func(r deadletter.Record) error {
+			records = append(records, r)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	return records
+}
+
 func TestCollectPublishFailAll(t *testing.T) {
 	client, err := NewClient(
 		clientSettings{
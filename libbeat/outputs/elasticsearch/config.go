@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/elastic/beats/v7/libbeat/common/transport/kerberos"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
 )
@@ -42,6 +43,7 @@ type elasticsearchConfig struct {
 	MaxRetries         int               `config:"max_retries"`
 	Backoff            Backoff           `config:"backoff"`
 	NonIndexablePolicy *config.Namespace `config:"non_indexable_policy"`
+	DeadLetterFile     deadletter.Config `config:"dead_letter_file"`
 	AllowOlderVersion  bool              `config:"allow_older_versions"`
 	Queue              config.Namespace  `config:"queue"`
 
@@ -74,7 +76,8 @@ var (
 			Init: 1 * time.Second,
 			Max:  60 * time.Second,
 		},
-		Transport: esDefaultTransportSettings(),
+		Transport:      esDefaultTransportSettings(),
+		DeadLetterFile: deadletter.DefaultConfig(),
 	}
 )
 
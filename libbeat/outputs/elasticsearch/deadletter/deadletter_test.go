@@ -0,0 +1,278 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRead(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.Path = dir
+	w := NewWriter(config)
+
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	records := []Record{
+		{
+			Timestamp: ts,
+			Index:     "logs-a",
+			Status:    400,
+			Error:     ErrorMessage([]byte(`{"type":"mapper_parsing_exception","reason":"failed to parse"}`)),
+			Event:     json.RawMessage(`{"message":"a"}`),
+		},
+		{
+			Timestamp: ts.Add(time.Minute),
+			Index:     "logs-b",
+			Pipeline:  "pipeline",
+			ID:        "id",
+			OpType:    "create",
+			Status:    403,
+			Error:     ErrorMessage([]byte("index closed")),
+			Event:     json.RawMessage(`{"message":"b"}`),
+		},
+	}
+	for _, r := range records {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	// The writer appends to the same file after Close.
+	require.NoError(t, w.Write(records[0]))
+	require.NoError(t, w.Close())
+
+	files, err := Files(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	// A new writer starts a new file.
+	w = NewWriter(config)
+	require.NoError(t, w.Write(records[1]))
+	require.NoError(t, w.Close())
+	files, err = Files(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	var read []Record
+	for _, f := range files {
+		err := ReadFile(f, func(r Record) error {
+			read = append(read, r)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	require.Len(t, read, 4)
+	for i, want := range append(records, records[0], records[1]) {
+		assert.True(t, want.Timestamp.Equal(read[i].Timestamp))
+		assert.Equal(t, want.Index, read[i].Index)
+		assert.Equal(t, want.Pipeline, read[i].Pipeline)
+		assert.Equal(t, want.ID, read[i].ID)
+		assert.Equal(t, want.OpType, read[i].OpType)
+		assert.Equal(t, want.Status, read[i].Status)
+		assert.JSONEq(t, string(want.Error), string(read[i].Error))
+		assert.JSONEq(t, string(want.Event), string(read[i].Event))
+	}
+}
+
+func TestReadFileStopsOnError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rejected-20240501.ndjson")
+	require.NoError(t, os.WriteFile(path, []byte(`{"index":"a"}`+"\n"+`{"index":"b"}`+"\n"), 0600))
+
+	errStop := errors.New("stop")
+	var indices []string
+	err := ReadFile(path, func(r Record) error {
+		indices = append(indices, r.Index)
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, []string{"a"}, indices)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json\n"), 0600))
+	assert.Error(t, ReadFile(path, func(Record) error { return nil }))
+}
+
+func TestFilesOrder(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	names := []string{"rejected-20240502.ndjson", "rejected-20240501.ndjson", "rejected-20240501-1.ndjson", "rejected-20240501-2.ndjson", "rejected-20240501-10.ndjson"}
+	for i, name := range names {
+		// Files with the same modification time are ordered by their name.
+		if i > 2 {
+			i = 2
+		}
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, nil, 0600))
+		modTime := now.Add(time.Duration(i) * time.Minute)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "other.ndjson"), nil, 0600))
+
+	files, err := Files(dir)
+	require.NoError(t, err)
+	want := make([]string, len(names))
+	for i, name := range names {
+		want[i] = filepath.Join(dir, name)
+	}
+	assert.Equal(t, want, files)
+}
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src := t.TempDir()
+	for _, name := range []string{"rejected-20240501.ndjson", "rejected-20240501-3.ndjson", "rejected-20240502.ndjson"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	tests := map[string]string{
+		"rejected-20240501.ndjson":   "rejected-20240501-4.ndjson",
+		"rejected-20240502-7.ndjson": "rejected-20240502-1.ndjson",
+		"rejected-20240503-2.ndjson": "rejected-20240503.ndjson",
+	}
+	for name, want := range tests {
+		path := filepath.Join(src, name)
+		require.NoError(t, os.WriteFile(path, []byte(name), 0600))
+
+		target, err := MoveFile(path, dir)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, want), target)
+		data, err := os.ReadFile(target)
+		require.NoError(t, err)
+		assert.Equal(t, name, string(data))
+		assert.NoFileExists(t, path)
+	}
+}
+
+func TestReason(t *testing.T) {
+	cases := map[string]struct {
+		msg  string
+		want string
+	}{
+		"type and reason": {
+			msg:  `{"type":"mapper_parsing_exception","reason":"failed to parse"}`,
+			want: "mapper_parsing_exception: failed to parse",
+		},
+		"type only": {
+			msg:  `{"type":"version_conflict_engine_exception"}`,
+			want: "version_conflict_engine_exception",
+		},
+		"plain string": {
+			msg:  `index closed`,
+			want: "index closed",
+		},
+		"empty": {},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := Record{Error: ErrorMessage([]byte(tc.msg))}
+			assert.Equal(t, tc.want, r.Reason())
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	record := Record{
+		Timestamp: ts,
+		Index:     "logs-app-default",
+		Status:    400,
+		Error:     ErrorMessage([]byte(`{"type":"mapper_parsing_exception","reason":"failed to parse field [bar]"}`)),
+	}
+
+	cases := map[string]struct {
+		filter Filter
+		want   bool
+	}{
+		"empty":            {Filter{}, true},
+		"index match":      {Filter{Index: "logs-*"}, true},
+		"index mismatch":   {Filter{Index: "metrics-*"}, false},
+		"status match":     {Filter{Status: 400}, true},
+		"status mismatch":  {Filter{Status: 403}, false},
+		"reason match":     {Filter{Reason: "MAPPER_PARSING"}, true},
+		"reason mismatch":  {Filter{Reason: "version_conflict"}, false},
+		"since inclusive":  {Filter{Since: ts}, true},
+		"since after":      {Filter{Since: ts.Add(time.Second)}, false},
+		"until exclusive":  {Filter{Until: ts}, false},
+		"until after":      {Filter{Until: ts.Add(time.Second)}, true},
+		"all settings set": {Filter{Index: "logs-*", Status: 400, Reason: "field [bar]", Since: ts, Until: ts.Add(time.Hour)}, true},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.filter.Match(&record))
+		})
+	}
+
+	invalid := Filter{Index: "logs-["}
+	assert.Error(t, invalid.Validate())
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := DefaultConfig()
+	config.Enabled = true
+	assert.NoError(t, config.Validate())
+
+	config.MaxFiles = 0
+	assert.Error(t, config.Validate())
+
+	config = DefaultConfig()
+	config.Enabled = true
+	config.MaxSize = 0
+	assert.Error(t, config.Validate())
+
+	// Disabled settings are not checked.
+	assert.NoError(t, (&Config{}).Validate())
+}
+
+func TestLock(t *testing.T) {
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.Path = dir
+	w := NewWriter(config)
+	record := Record{Index: "logs-a", Status: 400, Event: json.RawMessage(`{}`)}
+
+	require.NoError(t, w.Write(record))
+	_, err := Lock(dir)
+	assert.ErrorIs(t, err, ErrLocked, "the directory is locked by the open writer")
+
+	require.NoError(t, w.Close())
+	unlock, err := Lock(dir)
+	require.NoError(t, err)
+
+	// The writer starts a new file instead of appending to the last one
+	// while the directory is locked.
+	require.NoError(t, w.Write(record))
+	files, err := Files(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+
+	require.NoError(t, unlock())
+	require.NoError(t, w.Write(record))
+	_, err = Lock(dir)
+	assert.ErrorIs(t, err, ErrLocked, "the writer takes the lock once it is released")
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,178 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Files returns the dead letter files in dir, oldest first.
+func Files(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, filePrefix+"-*.ndjson"))
+	if err != nil {
+		return nil, err
+	}
+
+	modTimes := make(map[string]time.Time, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[f] = info.ModTime()
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		ti, tj := modTimes[files[i]], modTimes[files[j]]
+		if ti.Equal(tj) {
+			di, ni := fileOrder(files[i])
+			dj, nj := fileOrder(files[j])
+			if di != dj {
+				return di < dj
+			}
+			return ni < nj
+		}
+		return ti.Before(tj)
+	})
+	return files, nil
+}
+
+// MoveFile moves the dead letter file src into dir. The file keeps its date
+// but gets the next free counter of that date in dir, so existing files are
+// never replaced. The new path is returned.
+func MoveFile(src, dir string) (string, error) {
+	date, _ := fileOrder(src)
+	existing, err := filepath.Glob(filepath.Join(dir, filePrefix+"-"+date+"*.ndjson"))
+	if err != nil {
+		return "", err
+	}
+
+	next := 0
+	for _, f := range existing {
+		if d, n := fileOrder(f); d == date && n >= next {
+			next = n + 1
+		}
+	}
+	name := filePrefix + "-" + date + ".ndjson"
+	if next > 0 {
+		name = filePrefix + "-" + date + "-" + strconv.Itoa(next) + ".ndjson"
+	}
+
+	target := filepath.Join(dir, name)
+	if err := os.Rename(src, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// fileOrder returns the date and the counter of a dead letter file name of
+// the form rejected-YYYYMMDD[-N].ndjson.
+func fileOrder(path string) (date string, n int) {
+	name := strings.TrimSuffix(filepath.Base(path), ".ndjson")
+	name = strings.TrimPrefix(name, filePrefix+"-")
+	date, counter, found := strings.Cut(name, "-")
+	if found {
+		n, _ = strconv.Atoi(counter)
+	}
+	return date, n
+}
+
+// ReadFile calls fn for every record in the file. Reading stops at the first
+// error returned by fn.
+func ReadFile(path string, fn func(Record) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for lineNo := 1; ; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 && len(strings.TrimSpace(string(line))) > 0 {
+			var record Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				return fmt.Errorf("invalid record in %v line %d: %w", path, lineNo, err)
+			}
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// Filter selects records.
+type Filter struct {
+	// Index is a glob pattern matched against the target index.
+	Index string
+	// Status is the status code the event was rejected with.
+	Status int
+	// Reason is a substring of the rejection reason, matched case
+	// insensitively.
+	Reason string
+	// Since and Until limit the time the event was rejected.
+	Since time.Time
+	Until time.Time
+}
+
+// Validate checks the filter settings.
+func (f *Filter) Validate() error {
+	if f.Index != "" {
+		if _, err := filepath.Match(f.Index, ""); err != nil {
+			return fmt.Errorf("invalid index pattern '%v': %w", f.Index, err)
+		}
+	}
+	return nil
+}
+
+// Match reports whether the record matches all filter settings.
+func (f *Filter) Match(r *Record) bool {
+	if f.Index != "" {
+		if ok, _ := filepath.Match(f.Index, r.Index); !ok {
+			return false
+		}
+	}
+	if f.Status != 0 && f.Status != r.Status {
+		return false
+	}
+	if f.Reason != "" && !strings.Contains(strings.ToLower(r.Reason()), strings.ToLower(f.Reason)) {
+		return false
+	}
+	if !f.Since.IsZero() && r.Timestamp.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !r.Timestamp.Before(f.Until) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,263 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package deadletter writes events rejected by Elasticsearch to local files
+// and reads them back, so they can be inspected and replayed later.
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gofrs/flock"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
+	"github.com/elastic/elastic-agent-libs/file"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/paths"
+)
+
+// filePrefix is the prefix of all dead letter files. The rotator appends the
+// date, an optional counter and the .ndjson extension.
+const filePrefix = "rejected"
+
+// LockFileName is the lock file of a dead letter directory. Writers hold a
+// shared lock on it while their file is open, Lock takes an exclusive lock.
+const LockFileName = ".lock"
+
+// ErrLocked is returned by Lock if a writer has a dead letter file open.
+var ErrLocked = errors.New("dead letter files are being written to")
+
+// Config configures the dead letter files.
+type Config struct {
+	Enabled     bool             `config:"enabled"`
+	Path        string           `config:"path"`
+	MaxSize     cfgtype.ByteSize `config:"max_size"`
+	MaxFiles    uint             `config:"max_files"`
+	Permissions uint32           `config:"permissions"`
+}
+
+// DefaultConfig returns the default dead letter file settings.
+func DefaultConfig() Config {
+	return Config{
+		Enabled:     false,
+		MaxSize:     100 * 1024 * 1024,
+		MaxFiles:    7,
+		Permissions: 0600,
+	}
+}
+
+// Validate checks the settings of enabled dead letter files.
+func (c *Config) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxSize == 0 {
+		return errors.New("dead letter file max_size must be greater than 0")
+	}
+	if c.MaxFiles < 1 || c.MaxFiles > 1024 {
+		return fmt.Errorf("dead letter file max_files must be between 1 and 1024, got %v", c.MaxFiles)
+	}
+	return nil
+}
+
+// Dir returns the directory of the dead letter files.
+func (c *Config) Dir() string {
+	if c.Path == "" {
+		return paths.Resolve(paths.Data, "dead_letter")
+	}
+	return c.Path
+}
+
+// Record is a rejected event as written to a dead letter file.
+type Record struct {
+	// Timestamp is the time the event was rejected.
+	Timestamp time.Time `json:"@timestamp"`
+
+	Index    string `json:"index"`
+	Pipeline string `json:"pipeline,omitempty"`
+	ID       string `json:"id,omitempty"`
+	OpType   string `json:"op_type,omitempty"`
+
+	// Status and Error are the status code and the error of the bulk item
+	// that rejected the event.
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error,omitempty"`
+
+	// DeadLetterStatus and DeadLetterError are set if the event was also
+	// rejected by the dead letter index.
+	DeadLetterStatus int             `json:"dead_letter_status,omitempty"`
+	DeadLetterError  json.RawMessage `json:"dead_letter_error,omitempty"`
+
+	// Event is the document as sent to Elasticsearch.
+	Event json.RawMessage `json:"event"`
+}
+
+// ErrorMessage converts an error message of a bulk response item into a
+// value that can be stored in a Record.
+func ErrorMessage(msg []byte) json.RawMessage {
+	if len(msg) == 0 {
+		return nil
+	}
+	if json.Valid(msg) {
+		return json.RawMessage(msg)
+	}
+	quoted, _ := json.Marshal(string(msg))
+	return quoted
+}
+
+// Reason returns a short description of why the event was rejected.
+func (r *Record) Reason() string {
+	var details struct {
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(r.Error, &details); err == nil {
+		switch {
+		case details.Type != "" && details.Reason != "":
+			return details.Type + ": " + details.Reason
+		case details.Reason != "":
+			return details.Reason
+		}
+		return details.Type
+	}
+	var msg string
+	if err := json.Unmarshal(r.Error, &msg); err == nil {
+		return msg
+	}
+	return string(r.Error)
+}
+
+// Writer appends records to rotating dead letter files. A new file is started
+// on the first write, so files that existed before are never appended to and
+// can be replayed and removed safely. Writes after Close reopen the last file,
+// unless the directory is locked by Lock.
+type Writer struct {
+	config Config
+	log    *logp.Logger
+
+	mu      sync.Mutex
+	rotator *file.Rotator
+	opened  bool
+	lock    *flock.Flock
+	locked  bool
+}
+
+// NewWriter creates a writer for the dead letter files.
+func NewWriter(config Config) *Writer {
+	return &Writer{
+		config: config,
+		log:    logp.NewLogger("dead_letter"),
+	}
+}
+
+// Write appends the record to the active dead letter file.
+func (w *Writer) Write(r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter record: %w", err)
+	}
+	data = append(data, '\n')
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.rotator == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	} else if !w.locked {
+		w.tryLock()
+	}
+	if _, err := w.rotator.Write(data); err != nil {
+		return fmt.Errorf("failed to write dead letter record: %w", err)
+	}
+	return nil
+}
+
+func (w *Writer) open() error {
+	dir := w.config.Dir()
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	if w.lock == nil {
+		w.lock = flock.New(filepath.Join(dir, LockFileName))
+	}
+	// The last file may be removed while the directory is locked, so it is
+	// only appended to if the lock could be taken.
+	w.tryLock()
+	rotator, err := file.NewFileRotator(
+		filepath.Join(dir, filePrefix),
+		file.MaxSizeBytes(uint(w.config.MaxSize)),
+		file.MaxBackups(w.config.MaxFiles),
+		file.Permissions(os.FileMode(w.config.Permissions)),
+		file.RotateOnStartup(!w.opened || !w.locked),
+		file.WithLogger(w.log.With(logp.Namespace("rotator"))),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter file: %w", err)
+	}
+	w.rotator = rotator
+	w.opened = true
+	return nil
+}
+
+// Close closes the active dead letter file. The Writer can be used again
+// afterwards, so it can be shared by clients that are closed and reconnected
+// independently.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.rotator == nil {
+		return nil
+	}
+	err := w.rotator.Close()
+	w.rotator = nil
+	if w.locked {
+		err = errors.Join(err, w.lock.Unlock())
+		w.locked = false
+	}
+	return err
+}
+
+// tryLock takes a shared lock on the directory. Callers must hold w.mu.
+func (w *Writer) tryLock() {
+	locked, err := w.lock.TryRLock()
+	if err != nil {
+		w.log.Warnf("Failed to lock dead letter directory: %v", err)
+	}
+	w.locked = locked
+}
+
+// Lock takes an exclusive lock on the dead letter files in dir. While it is
+// held, writers only append to new files, so existing files can be removed
+// safely. ErrLocked is returned if a writer has a file open.
+func Lock(dir string) (unlock func() error, err error) {
+	lock := flock.New(filepath.Join(dir, LockFileName))
+	locked, err := lock.TryLock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock dead letter directory %v: %w", dir, err)
+	}
+	if !locked {
+		return nil, ErrLocked
+	}
+	return lock.Unlock, nil
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/esleg/eslegclient"
 	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/elasticsearch/deadletter"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/logp"
@@ -79,6 +80,11 @@ func makeES(
 		return outputs.Fail(err)
 	}
 
+	var deadLetterFile *deadletter.Writer
+	if esConfig.DeadLetterFile.Enabled {
+		deadLetterFile = deadletter.NewWriter(esConfig.DeadLetterFile)
+	}
+
 	hosts, err := outputs.ReadHostList(cfg)
 	if err != nil {
 		return outputs.Fail(err)
@@ -127,6 +133,7 @@ func makeES(
 			pipelineSelector: pipelineSelector,
 			observer:         observer,
 			deadLetterIndex:  deadLetterIndex,
+			deadLetterFile:   deadLetterFile,
 		}, &connectCallbackRegistry)
 		if err != nil {
 			return outputs.Fail(err)
@@ -49,6 +49,11 @@ type encodedEvent struct {
 	// contents included as a raw string in the "message" field.
 	deadLetter bool
 
+	// rejected holds the original target and encoding of an event that is
+	// retried on the dead letter index, so it can be written to the dead
+	// letter file if that fails too.
+	rejected *rejection
+
 	// timestamp is the timestamp from the source beat.Event. It's only used
 	// when reencoding for the dead letter index, so it isn't strictly needed
 	// but it avoids deserializing the encoded event to recover one field if
@@ -138,9 +143,23 @@ func (pe *eventEncoder) encodeRawEvent(e *beat.Event) *encodedEvent {
 	}
 }
 
+// rejection is the first rejection of an event.
+type rejection struct {
+	index    string
+	encoding []byte
+	status   int
+	message  string
+}
+
 func (e *encodedEvent) setDeadLetter(
 	deadLetterIndex string, errType int, errMsg string,
 ) {
+	e.rejected = &rejection{
+		index:    e.index,
+		encoding: e.encoding,
+		status:   errType,
+		message:  errMsg,
+	}
 	e.deadLetter = true
 	e.index = deadLetterIndex
 	deadLetterReencoding := mapstr.M{
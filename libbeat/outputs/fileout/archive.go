@@ -0,0 +1,548 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package fileout
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+var errArchiveClosed = errors.New("file output is closed")
+
+const (
+	compressionNone = ""
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+)
+
+var compressionExtensions = map[string]string{
+	compressionGzip: ".gz",
+	compressionZstd: ".zst",
+}
+
+// codecExtensions maps codec names to the extension of the files written in
+// archive mode. Codecs not listed here use their name as extension.
+var codecExtensions = map[string]string{
+	"json":   ".ndjson",
+	"format": ".log",
+	"csv":    ".csv",
+}
+
+// archiveExtension returns the file extension used for files encoded with the
+// named codec. An empty name selects the default json codec.
+func archiveExtension(codecName string) string {
+	if codecName == "" {
+		codecName = "json"
+	}
+	if ext, ok := codecExtensions[codecName]; ok {
+		return ext
+	}
+	return "." + codecName
+}
+
+// archiveSettings configures an archiveWriter.
+type archiveSettings struct {
+	path            *PathFormatString
+	filename        *PathFormatString
+	extension       string
+	header          []byte
+	maxSizeBytes    uint
+	interval        time.Duration
+	maxFiles        uint
+	maxAge          time.Duration
+	compression     string
+	permissions     os.FileMode
+	rotateOnStartup bool
+}
+
+// archiveWriter writes events to files that are rotated on size and on
+// interval boundaries. Files are closed at the end of their interval even if
+// no more events are written, so they are compressed and purged in time. The
+// path and filename format strings are expanded with
+// the start of the current interval every time a new file is opened, so date
+// tokens in the templates move files into new names or directories. Rotated
+// files are optionally compressed in the background and purged by count and
+// by age.
+type archiveWriter struct {
+	log      *logp.Logger
+	settings archiveSettings
+	now      func() time.Time
+
+	mu         sync.Mutex
+	file       *os.File
+	activePath atomic.Value // string
+	size       uint
+	bucket     time.Time
+	rotateAt   *time.Timer // closes the active file at the end of its bucket
+	started    bool
+	closed     bool
+
+	// Rotated files are queued without bounds so Write never waits for
+	// compression to catch up.
+	queueMu   sync.Mutex
+	queue     []string
+	signal    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	rotatedRegexp *regexp.Regexp
+}
+
+func newArchiveWriter(log *logp.Logger, settings archiveSettings) (*archiveWriter, error) {
+	if settings.maxSizeBytes == 0 {
+		return nil, errors.New("file rotator max file size must be greater than 0")
+	}
+	if settings.interval != 0 && settings.interval < time.Second {
+		return nil, errors.New("the minimum time interval for file rotation is 1 second")
+	}
+	if _, ok := compressionExtensions[settings.compression]; !ok && settings.compression != compressionNone {
+		return nil, fmt.Errorf("unsupported compression '%v'", settings.compression)
+	}
+	if settings.extension == "" {
+		settings.extension = archiveExtension("")
+	}
+
+	w := &archiveWriter{
+		log:      log,
+		settings: settings,
+		now:      time.Now,
+		signal:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		rotatedRegexp: regexp.MustCompile(`-\d{8}(-\d{6})?(-\d+)?` +
+			regexp.QuoteMeta(settings.extension) + `(\.gz|\.zst)?$`),
+	}
+	w.activePath.Store("")
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Write writes data to the active file, rotating it first if data would
+// exceed the maximum size or a new interval has started.
+func (w *archiveWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		return 0, errArchiveClosed
+	}
+
+	dataLen := uint(len(data))
+	if dataLen > w.settings.maxSizeBytes {
+		return 0, fmt.Errorf("data size (%d bytes) is greater than "+
+			"the max file size (%d bytes)", dataLen, w.settings.maxSizeBytes)
+	}
+
+	bucket := w.bucketOf(w.now())
+	if w.file != nil && (!bucket.Equal(w.bucket) || w.size+dataLen > w.settings.maxSizeBytes) {
+		if err := w.closeActive(); err != nil {
+			return 0, err
+		}
+	}
+	if w.file == nil {
+		if err := w.openNew(bucket); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(data)
+	w.size += uint(n)
+	if err != nil {
+		return n, fmt.Errorf("failed to write to file: %w", err)
+	}
+	return n, nil
+}
+
+// Close closes the active file and waits for pending compressions.
+func (w *archiveWriter) Close() error {
+	w.mu.Lock()
+	w.closed = true
+	err := w.closeFile()
+	w.mu.Unlock()
+
+	w.closeOnce.Do(func() { close(w.done) })
+	w.wg.Wait()
+	return err
+}
+
+// enqueue hands a rotated file over to the background worker.
+func (w *archiveWriter) enqueue(path string) {
+	w.queueMu.Lock()
+	w.queue = append(w.queue, path)
+	w.queueMu.Unlock()
+
+	select {
+	case w.signal <- struct{}{}:
+	default:
+	}
+}
+
+// dequeue returns all queued files and resets the queue.
+func (w *archiveWriter) dequeue() []string {
+	w.queueMu.Lock()
+	defer w.queueMu.Unlock()
+	paths := w.queue
+	w.queue = nil
+	return paths
+}
+
+func (w *archiveWriter) bucketOf(t time.Time) time.Time {
+	t = t.UTC()
+	if w.settings.interval <= 0 {
+		return time.Time{}
+	}
+	return t.Truncate(w.settings.interval)
+}
+
+// basePath expands the path and filename templates for the given time.
+func (w *archiveWriter) basePath(t time.Time) (string, error) {
+	dir, err := w.settings.path.Run(t)
+	if err != nil {
+		return "", err
+	}
+	name, err := w.settings.filename.Run(t)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, name), nil
+}
+
+func (w *archiveWriter) stampLayout() string {
+	if w.settings.interval > 0 && w.settings.interval%(24*time.Hour) != 0 {
+		return "20060102-150405"
+	}
+	return "20060102"
+}
+
+func (w *archiveWriter) openNew(bucket time.Time) error {
+	ts := bucket
+	if ts.IsZero() {
+		ts = w.now().UTC()
+	}
+	base, err := w.basePath(ts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(base), dirMode(w.settings.permissions)); err != nil {
+		return fmt.Errorf("failed to make directories for new file: %w", err)
+	}
+
+	prefix := base + "-" + ts.Format(w.stampLayout())
+	path := prefix + w.settings.extension
+
+	// On startup files left behind by a previous run are either appended to
+	// or, if rotate_on_startup is set, handed over to compression and
+	// retention. Afterwards a rotation always starts a new file.
+	startup := !w.started
+	w.started = true
+	for i := 1; ; i++ {
+		exists := fileExists(path)
+		if exists && startup && !w.settings.rotateOnStartup {
+			break
+		}
+		if !exists && !w.hasCompressedVariant(path) {
+			break
+		}
+		if exists && startup {
+			w.enqueue(path)
+		}
+		path = prefix + "-" + strconv.Itoa(i) + w.settings.extension
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.settings.permissions)
+	if err != nil {
+		return fmt.Errorf("failed to open new file '%s': %w", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	size := uint(info.Size())
+	if size == 0 && len(w.settings.header) > 0 {
+		n, err := f.Write(append(append([]byte{}, w.settings.header...), '\n'))
+		size += uint(n)
+		if err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write header to file '%s': %w", path, err)
+		}
+	}
+
+	w.file = f
+	w.activePath.Store(path)
+	w.size = size
+	w.bucket = bucket
+	if !bucket.IsZero() {
+		w.rotateAt = time.AfterFunc(bucket.Add(w.settings.interval).Sub(w.now()), w.rotateExpired)
+	}
+	w.log.Debugw("Opened file", "filename", path)
+	return nil
+}
+
+func (w *archiveWriter) hasCompressedVariant(path string) bool {
+	ext, ok := compressionExtensions[w.settings.compression]
+	return ok && fileExists(path+ext)
+}
+
+func (w *archiveWriter) closeActive() error {
+	path := w.active()
+	if err := w.closeFile(); err != nil {
+		return err
+	}
+	w.activePath.Store("")
+	w.log.Debugw("Rotated file", "filename", path)
+	w.enqueue(path)
+	return nil
+}
+
+// rotateExpired closes the active file once its interval has ended.
+func (w *archiveWriter) rotateExpired() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed || w.file == nil {
+		return
+	}
+	if d := w.bucket.Add(w.settings.interval).Sub(w.now()); d > 0 {
+		w.rotateAt.Reset(d)
+		return
+	}
+	if err := w.closeActive(); err != nil {
+		w.log.Errorf("Failed to rotate file %v: %v", w.active(), err)
+	}
+}
+
+func (w *archiveWriter) closeFile() error {
+	if w.file == nil {
+		return nil
+	}
+	if w.rotateAt != nil {
+		w.rotateAt.Stop()
+		w.rotateAt = nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	w.size = 0
+	if err != nil {
+		return fmt.Errorf("failed to close active file: %w", err)
+	}
+	return nil
+}
+
+func (w *archiveWriter) active() string {
+	return w.activePath.Load().(string) //nolint:errcheck // only strings are stored
+}
+
+// run compresses rotated files and applies the retention settings. Files
+// still queued on Close are processed before run returns.
+func (w *archiveWriter) run() {
+	defer w.wg.Done()
+	for {
+		var closing bool
+		select {
+		case <-w.signal:
+		case <-w.done:
+			closing = true
+		}
+
+		for _, path := range w.dequeue() {
+			w.process(path)
+		}
+		if closing {
+			return
+		}
+	}
+}
+
+func (w *archiveWriter) process(path string) {
+	if w.settings.compression != compressionNone {
+		if err := compressFile(path, w.settings.compression, w.settings.permissions); err != nil {
+			w.log.Errorf("Failed to compress rotated file %v: %v", path, err)
+		}
+	}
+	if err := w.purge(); err != nil {
+		w.log.Errorf("Failed to purge rotated files: %v", err)
+	}
+}
+
+// rotatedFiles returns all rotated files matching the path and filename
+// templates, sorted by modification time with the oldest first.
+func (w *archiveWriter) rotatedFiles() ([]os.FileInfo, []string, error) {
+	pattern := filepath.Join(w.settings.path.Pattern(), w.settings.filename.Pattern()) + "-*"
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	active := w.active()
+	var infos []os.FileInfo
+	var paths []string
+	for _, m := range matches {
+		if m == active || !w.rotatedRegexp.MatchString(m) {
+			continue
+		}
+		info, err := os.Stat(m)
+		if err != nil {
+			continue
+		}
+		infos = append(infos, info)
+		paths = append(paths, m)
+	}
+	sort.Sort(byModTime{infos, paths})
+	return infos, paths, nil
+}
+
+func (w *archiveWriter) purge() error {
+	infos, paths, err := w.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	cutoff := w.now().Add(-w.settings.maxAge)
+	keepFrom := 0
+	if w.settings.maxFiles > 0 && uint(len(paths)) > w.settings.maxFiles {
+		keepFrom = len(paths) - int(w.settings.maxFiles)
+	}
+	for i, path := range paths {
+		expired := w.settings.maxAge > 0 && infos[i].ModTime().Before(cutoff)
+		if i >= keepFrom && !expired {
+			continue
+		}
+		w.log.Debugw("Removing rotated file", "filename", path)
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compressFile compresses path into path plus the compression extension and
+// removes the original. The modification time of the original is kept so
+// retention by age and ordering are not affected.
+func compressFile(path, compression string, perm os.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	target := path + compressionExtensions[compression]
+	tmp := target + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	var enc io.WriteCloser
+	switch compression {
+	case compressionGzip:
+		enc = gzip.NewWriter(dst)
+	case compressionZstd:
+		enc, err = zstd.NewWriter(dst)
+		if err != nil {
+			dst.Close()
+			os.Remove(tmp)
+			return err
+		}
+	}
+
+	if _, err = io.Copy(enc, src); err == nil {
+		err = enc.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	_ = os.Chtimes(target, info.ModTime(), info.ModTime())
+	src.Close()
+	return os.Remove(path)
+}
+
+type byModTime struct {
+	infos []os.FileInfo
+	paths []string
+}
+
+func (b byModTime) Len() int { return len(b.infos) }
+func (b byModTime) Less(i, j int) bool {
+	mi, mj := b.infos[i].ModTime(), b.infos[j].ModTime()
+	if mi.Equal(mj) {
+		return b.paths[i] < b.paths[j]
+	}
+	return mi.Before(mj)
+}
+func (b byModTime) Swap(i, j int) {
+	b.infos[i], b.infos[j] = b.infos[j], b.infos[i]
+	b.paths[i], b.paths[j] = b.paths[j], b.paths[i]
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func dirMode(perm os.FileMode) os.FileMode {
+	mode := os.FileMode(0700)
+	if perm&0070 > 0 {
+		mode |= 0050
+	}
+	if perm&0007 > 0 {
+		mode |= 0005
+	}
+	return mode
+}
+
+// formatExprRegexp matches the format expressions of a format string.
+var formatExprRegexp = regexp.MustCompile(`%\{[^}]*\}`)
+
+func globPattern(raw string) string {
+	return formatExprRegexp.ReplaceAllString(raw, "*")
+}
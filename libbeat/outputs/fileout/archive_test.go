@@ -0,0 +1,417 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package fileout
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func mustFormat(t *testing.T, s string) *PathFormatString {
+	t.Helper()
+	fs := &PathFormatString{}
+	require.NoError(t, fs.Unpack(s))
+	return fs
+}
+
+func newTestArchiveWriter(t *testing.T, clock *fakeClock, settings archiveSettings) *archiveWriter {
+	t.Helper()
+	if settings.maxSizeBytes == 0 {
+		settings.maxSizeBytes = 1024 * 1024
+	}
+	if settings.permissions == 0 {
+		settings.permissions = 0600
+	}
+	w, err := newArchiveWriter(logp.NewLogger("test"), settings)
+	require.NoError(t, err)
+	w.now = clock.Now
+	return w
+}
+
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(files)
+	return files
+}
+
+func TestArchiveRotateInterval(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 15, 0, 0, time.UTC)}
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:     mustFormat(t, dir),
+		filename: mustFormat(t, "events"),
+		interval: time.Hour,
+		maxFiles: 10,
+	})
+
+	_, err := w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("second\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{
+		"events-20240501-100000.ndjson",
+		"events-20240501-110000.ndjson",
+	}, listFiles(t, dir))
+
+	data, err := os.ReadFile(filepath.Join(dir, "events-20240501-110000.ndjson"))
+	require.NoError(t, err)
+	assert.Equal(t, "second\n", string(data))
+}
+
+func TestArchiveRotateIntervalWithoutWrites(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 59, 59, 950_000_000, time.UTC)}
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:        mustFormat(t, dir),
+		filename:    mustFormat(t, "events"),
+		interval:    time.Hour,
+		maxFiles:    10,
+		compression: compressionGzip,
+	})
+	defer w.Close()
+
+	_, err := w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Second)
+
+	// the file is rotated and compressed at the end of the interval, not on
+	// the next write
+	assert.Eventually(t, func() bool {
+		files := listFiles(t, dir)
+		return len(files) == 1 && files[0] == "events-20240501-100000.ndjson.gz"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestArchiveDateTemplate(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)}
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:     mustFormat(t, filepath.Join(dir, "%{+yyyy-MM}")),
+		filename: mustFormat(t, "events-%{+dd}"),
+		interval: 24 * time.Hour,
+		maxFiles: 10,
+	})
+
+	_, err := w.Write([]byte("a\n"))
+	require.NoError(t, err)
+	clock.Advance(2 * time.Minute)
+	_, err = w.Write([]byte("b\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{
+		"2024-05/events-01-20240501.ndjson",
+		"2024-05/events-02-20240502.ndjson",
+	}, listFiles(t, dir))
+}
+
+func TestArchiveCompression(t *testing.T) {
+	for compression, decompress := range map[string]func(io.Reader) (io.Reader, error){
+		compressionGzip: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		compressionZstd: func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	} {
+		t.Run(compression, func(t *testing.T) {
+			dir := t.TempDir()
+			clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+			w := newTestArchiveWriter(t, clock, archiveSettings{
+				path:         mustFormat(t, dir),
+				filename:     mustFormat(t, "events"),
+				maxSizeBytes: 12,
+				maxFiles:     10,
+				compression:  compression,
+			})
+
+			for _, line := range []string{"line-one\n", "line-two\n", "line-three\n"} {
+				_, err := w.Write([]byte(line))
+				require.NoError(t, err)
+			}
+			require.NoError(t, w.Close())
+
+			ext := compressionExtensions[compression]
+			assert.Equal(t, []string{
+				"events-20240501-1.ndjson" + ext,
+				"events-20240501-2.ndjson",
+				"events-20240501.ndjson" + ext,
+			}, listFiles(t, dir))
+
+			f, err := os.Open(filepath.Join(dir, "events-20240501.ndjson"+ext))
+			require.NoError(t, err)
+			defer f.Close()
+			r, err := decompress(f)
+			require.NoError(t, err)
+			data, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, "line-one\n", string(data))
+		})
+	}
+}
+
+func TestArchiveRetention(t *testing.T) {
+	t.Run("by count", func(t *testing.T) {
+		dir := t.TempDir()
+		clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+		w := newTestArchiveWriter(t, clock, archiveSettings{
+			path:     mustFormat(t, dir),
+			filename: mustFormat(t, "events"),
+			interval: time.Hour,
+			maxFiles: 2,
+		})
+
+		for i := 0; i < 5; i++ {
+			_, err := w.Write([]byte("x\n"))
+			require.NoError(t, err)
+			// keep modification times ordered
+			require.NoError(t, os.Chtimes(w.active(), clock.Now(), clock.Now()))
+			clock.Advance(time.Hour)
+		}
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, []string{
+			"events-20240501-020000.ndjson",
+			"events-20240501-030000.ndjson",
+			"events-20240501-040000.ndjson",
+		}, listFiles(t, dir))
+	})
+
+	t.Run("by age", func(t *testing.T) {
+		dir := t.TempDir()
+		now := time.Now().UTC().Truncate(time.Hour)
+		clock := &fakeClock{now: now}
+
+		old := filepath.Join(dir, "events-20000101.ndjson.gz")
+		require.NoError(t, os.WriteFile(old, []byte("old"), 0600))
+		require.NoError(t, os.Chtimes(old, now.Add(-48*time.Hour), now.Add(-48*time.Hour)))
+		unrelated := filepath.Join(dir, "other-20000101.ndjson")
+		require.NoError(t, os.WriteFile(unrelated, []byte("keep"), 0600))
+		require.NoError(t, os.Chtimes(unrelated, now.Add(-48*time.Hour), now.Add(-48*time.Hour)))
+
+		w := newTestArchiveWriter(t, clock, archiveSettings{
+			path:     mustFormat(t, dir),
+			filename: mustFormat(t, "events"),
+			interval: time.Hour,
+			maxFiles: 100,
+			maxAge:   24 * time.Hour,
+		})
+		_, err := w.Write([]byte("a\n"))
+		require.NoError(t, err)
+		clock.Advance(time.Hour)
+		_, err = w.Write([]byte("b\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		files := listFiles(t, dir)
+		assert.NotContains(t, files, "events-20000101.ndjson.gz")
+		assert.Contains(t, files, "other-20000101.ndjson")
+		assert.Len(t, files, 3)
+	})
+}
+
+func TestArchiveRotateOnStartup(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	existing := filepath.Join(dir, "events-20240501.ndjson")
+	require.NoError(t, os.WriteFile(existing, []byte("previous\n"), 0600))
+
+	t.Run("append", func(t *testing.T) {
+		w := newTestArchiveWriter(t, clock, archiveSettings{
+			path:     mustFormat(t, dir),
+			filename: mustFormat(t, "events"),
+			maxFiles: 10,
+		})
+		_, err := w.Write([]byte("next\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		data, err := os.ReadFile(existing)
+		require.NoError(t, err)
+		assert.Equal(t, "previous\nnext\n", string(data))
+	})
+
+	t.Run("rotate", func(t *testing.T) {
+		w := newTestArchiveWriter(t, clock, archiveSettings{
+			path:            mustFormat(t, dir),
+			filename:        mustFormat(t, "events"),
+			maxFiles:        10,
+			compression:     compressionGzip,
+			rotateOnStartup: true,
+		})
+		_, err := w.Write([]byte("new\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, []string{
+			"events-20240501-1.ndjson",
+			"events-20240501.ndjson.gz",
+		}, listFiles(t, dir))
+	})
+}
+
+func TestArchiveExtension(t *testing.T) {
+	assert.Equal(t, ".ndjson", archiveExtension(""))
+	assert.Equal(t, ".ndjson", archiveExtension("json"))
+	assert.Equal(t, ".csv", archiveExtension("csv"))
+	assert.Equal(t, ".log", archiveExtension("format"))
+	assert.Equal(t, ".msgpack", archiveExtension("msgpack"))
+
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:      mustFormat(t, dir),
+		filename:  mustFormat(t, "events"),
+		extension: archiveExtension("csv"),
+		interval:  24 * time.Hour,
+		maxFiles:  2,
+	})
+	for i := 0; i < 4; i++ {
+		_, err := w.Write([]byte("a,b\n"))
+		require.NoError(t, err)
+		clock.Advance(24 * time.Hour)
+	}
+	require.NoError(t, w.Close())
+
+	assert.Equal(t, []string{
+		"events-20240502.csv",
+		"events-20240503.csv",
+		"events-20240504.csv",
+	}, listFiles(t, dir))
+}
+
+func TestArchiveRotationsDoNotBlockWrite(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:         mustFormat(t, dir),
+		filename:     mustFormat(t, "events"),
+		maxSizeBytes: 4,
+		maxFiles:     1000,
+		compression:  compressionGzip,
+	})
+
+	// Every write rotates, queueing far more files than the worker can
+	// compress in the meantime.
+	const writes = 200
+	for i := 0; i < writes; i++ {
+		_, err := w.Write([]byte("abc\n"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	files := listFiles(t, dir)
+	assert.Len(t, files, writes)
+	compressed := 0
+	for _, f := range files {
+		if filepath.Ext(f) == ".gz" {
+			compressed++
+		}
+	}
+	assert.Equal(t, writes-1, compressed)
+}
+
+func TestArchiveWriteAfterClose(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)}
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:     mustFormat(t, dir),
+		filename: mustFormat(t, "events"),
+		maxFiles: 10,
+	})
+	_, err := w.Write([]byte("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	_, err = w.Write([]byte("second\n"))
+	assert.ErrorIs(t, err, errArchiveClosed)
+	require.NoError(t, w.Close())
+}
+
+func TestArchiveHeader(t *testing.T) {
+	dir := t.TempDir()
+	clock := &fakeClock{now: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	existing := filepath.Join(dir, "events-20240501-100000.csv")
+	require.NoError(t, os.WriteFile(existing, []byte("a,b\n1,2\n"), 0600))
+
+	w := newTestArchiveWriter(t, clock, archiveSettings{
+		path:      mustFormat(t, dir),
+		filename:  mustFormat(t, "events"),
+		extension: ".csv",
+		header:    []byte("a,b"),
+		interval:  time.Hour,
+		maxFiles:  10,
+	})
+	_, err := w.Write([]byte("3,4\n"))
+	require.NoError(t, err)
+	clock.Advance(time.Hour)
+	_, err = w.Write([]byte("5,6\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	// Files appended to on startup already start with the header.
+	data, err := os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n1,2\n3,4\n", string(data))
+
+	data, err = os.ReadFile(filepath.Join(dir, "events-20240501-110000.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n5,6\n", string(data))
+}
@@ -19,6 +19,7 @@ package fileout
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 	"github.com/elastic/elastic-agent-libs/config"
@@ -33,6 +34,9 @@ type fileOutConfig struct {
 	Codec           codec.Config      `config:"codec"`
 	Permissions     uint32            `config:"permissions"`
 	RotateOnStartup bool              `config:"rotate_on_startup"`
+	RotateInterval  time.Duration     `config:"rotate_interval"`
+	Compression     string            `config:"compression"`
+	MaxAge          time.Duration     `config:"max_age"`
 	Queue           config.Namespace  `config:"queue"`
 }
 
@@ -64,5 +68,27 @@ func (c *fileOutConfig) Validate() error {
 			file.MaxBackupsLimit)
 	}
 
+	if c.RotateInterval != 0 && c.RotateInterval < time.Second {
+		return fmt.Errorf("the rotate_interval must be at least 1s")
+	}
+
+	switch c.Compression {
+	case compressionNone, "none", compressionGzip, compressionZstd:
+	default:
+		return fmt.Errorf("unsupported compression '%v', expected one of none, %v or %v",
+			c.Compression, compressionGzip, compressionZstd)
+	}
+
+	if c.MaxAge < 0 {
+		return fmt.Errorf("the max_age must not be negative")
+	}
+
 	return nil
 }
+
+// archiveMode reports if the output is configured for archiving, which
+// requires the time based rotation, compression and retention support of
+// the archiveWriter.
+func (c *fileOutConfig) archiveMode() bool {
+	return c.RotateInterval > 0 || c.MaxAge > 0 || (c.Compression != compressionNone && c.Compression != "none")
+}
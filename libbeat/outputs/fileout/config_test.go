@@ -91,6 +91,38 @@ func TestConfig(t *testing.T) {
 				assert.Nil(t, err)
 			},
 		},
+		"config given with archive settings": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"path":            "/var/archive/%{+yyyy-MM}",
+				"filename":        "events-%{+dd}",
+				"rotate_interval": "1h",
+				"compression":     "zstd",
+				"max_age":         "720h",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.Nil(t, err)
+				assert.Equal(t, time.Hour, actual.RotateInterval)
+				assert.Equal(t, "zstd", actual.Compression)
+				assert.Equal(t, 720*time.Hour, actual.MaxAge)
+				assert.True(t, actual.archiveMode())
+			},
+		},
+		"config given with unsupported compression": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"compression": "lz4",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.Error(t, err)
+			},
+		},
+		"config given with too short rotate interval": {
+			config: config.MustNewConfigFrom(mapstr.M{
+				"rotate_interval": "10ms",
+			}),
+			assertion: func(t *testing.T, actual *fileOutConfig, err error) {
+				assert.Error(t, err)
+			},
+		},
 	} {
 		t.Run(name, func(t *testing.T) {
 			isWindowsPath = test.useWindowsPath
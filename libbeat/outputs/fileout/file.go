@@ -20,6 +20,7 @@ package fileout
 import (
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"time"
@@ -42,7 +43,7 @@ type fileOutput struct {
 	filePath string
 	beat     beat.Info
 	observer outputs.Observer
-	rotator  *file.Rotator
+	rotator  io.WriteCloser
 	codec    codec.Codec
 }
 
@@ -71,16 +72,38 @@ func makeFileout(
 }
 
 func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
-	var path string
+	filename := c.Filename
+	if filename == "" {
+		filename = out.beat.Beat
+	}
+
+	var err error
+	out.codec, err = codec.CreateEncoder(beat, c.Codec)
+	if err != nil {
+		return err
+	}
+
+	// Headers are written at the start of every file, which only the
+	// archiveWriter knows about.
+	var header []byte
+	if hc, ok := out.codec.(codec.HeaderCodec); ok {
+		if header, err = hc.Header(); err != nil {
+			return err
+		}
+	}
+
+	if c.archiveMode() || header != nil {
+		return out.initArchive(c, filename, header)
+	}
+	return out.initRotator(c, filename)
+}
+
+func (out *fileOutput) initRotator(c fileOutConfig, filename string) error {
 	configPath, runErr := c.Path.Run(time.Now().UTC())
 	if runErr != nil {
 		return runErr
 	}
-	if c.Filename != "" {
-		path = filepath.Join(configPath, c.Filename)
-	} else {
-		path = filepath.Join(configPath, out.beat.Beat)
-	}
+	path := filepath.Join(configPath, filename)
 
 	out.filePath = path
 
@@ -97,14 +120,55 @@ func (out *fileOutput) init(beat beat.Info, c fileOutConfig) error {
 		return err
 	}
 
-	out.codec, err = codec.CreateEncoder(beat, c.Codec)
+	out.log.Infof("Initialized file output. "+
+		"path=%v max_size_bytes=%v max_backups=%v permissions=%v",
+		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions))
+
+	return nil
+}
+
+// initArchive sets up the archiveWriter. Unlike the default rotator the
+// filename is a format string as well, so it can contain date tokens.
+func (out *fileOutput) initArchive(c fileOutConfig, filename string, header []byte) error {
+	filenameFmt := &PathFormatString{}
+	if err := filenameFmt.Unpack(filename); err != nil {
+		return err
+	}
+	if _, err := c.Path.Run(time.Now().UTC()); err != nil {
+		return err
+	}
+
+	compression := c.Compression
+	if compression == "none" {
+		compression = compressionNone
+	}
+
+	out.filePath = filepath.Join(c.Path.raw, filenameFmt.raw)
+
+	var err error
+	out.rotator, err = newArchiveWriter(
+		logp.NewLogger("rotator").With(logp.Namespace("rotator")),
+		archiveSettings{
+			path:            c.Path,
+			filename:        filenameFmt,
+			extension:       archiveExtension(c.Codec.Namespace.Name()),
+			header:          header,
+			maxSizeBytes:    c.RotateEveryKb * 1024,
+			interval:        c.RotateInterval,
+			maxFiles:        c.NumberOfFiles,
+			maxAge:          c.MaxAge,
+			compression:     compression,
+			permissions:     os.FileMode(c.Permissions),
+			rotateOnStartup: c.RotateOnStartup,
+		},
+	)
 	if err != nil {
 		return err
 	}
 
-	out.log.Infof("Initialized file output. "+
-		"path=%v max_size_bytes=%v max_backups=%v permissions=%v",
-		path, c.RotateEveryKb*1024, c.NumberOfFiles, os.FileMode(c.Permissions))
+	out.log.Infof("Initialized file output in archive mode. "+
+		"path=%v max_size_bytes=%v rotate_interval=%v max_backups=%v max_age=%v compression=%v permissions=%v",
+		out.filePath, c.RotateEveryKb*1024, c.RotateInterval, c.NumberOfFiles, c.MaxAge, c.Compression, os.FileMode(c.Permissions))
 
 	return nil
 }
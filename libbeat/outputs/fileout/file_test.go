@@ -18,3 +18,86 @@
 //go:build !integration
 
 package fileout
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/csv"
+	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+)
+
+func TestDefaultRotatorKeepsFilename(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.MustNewConfigFrom(map[string]interface{}{
+		"path":     dir,
+		"filename": "out-%{+yyyy}",
+	})
+	foConfig, err := readConfig(cfg)
+	require.NoError(t, err)
+	require.False(t, foConfig.archiveMode())
+
+	out := &fileOutput{log: logp.NewLogger("test"), beat: beat.Info{Beat: "test"}}
+	require.NoError(t, out.init(out.beat, *foConfig))
+	_, err = out.rotator.Write([]byte("event\n"))
+	require.NoError(t, err)
+	require.NoError(t, out.Close())
+
+	path := filepath.Join(dir, "out-%{+yyyy}")
+	assert.Equal(t, path, out.filePath)
+	matches, err := filepath.Glob(filepath.Join(dir, "out-%{+yyyy}-*"))
+	require.NoError(t, err)
+	assert.Len(t, matches, 1)
+}
+
+func TestCSVHeaderPerFile(t *testing.T) {
+	dir := t.TempDir()
+	cfg := config.MustNewConfigFrom(map[string]interface{}{
+		"path":            dir,
+		"filename":        "events",
+		"rotate_every_kb": 1,
+		"codec.csv": map[string]interface{}{
+			"fields": []string{"message"},
+			"header": true,
+		},
+	})
+	foConfig, err := readConfig(cfg)
+	require.NoError(t, err)
+
+	out := &fileOutput{
+		log:      logp.NewLogger("test"),
+		beat:     beat.Info{Beat: "test"},
+		observer: outputs.NewStats(monitoring.NewRegistry()),
+	}
+	require.NoError(t, out.init(out.beat, *foConfig))
+
+	// Enough events for several files of 1KB each.
+	events := make([]beat.Event, 200)
+	for i := range events {
+		events[i] = beat.Event{Timestamp: time.Now(), Fields: mapstr.M{"message": "0123456789"}}
+	}
+	require.NoError(t, out.Publish(context.Background(), outest.NewBatch(events...)))
+	require.NoError(t, out.Close())
+
+	matches, err := filepath.Glob(filepath.Join(dir, "events-*.csv"))
+	require.NoError(t, err)
+	require.Greater(t, len(matches), 1)
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		require.NoError(t, err)
+		assert.Regexp(t, "^message\n(0123456789\n)+$", string(data), m)
+	}
+}
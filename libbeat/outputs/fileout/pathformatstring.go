@@ -37,6 +37,7 @@ var isWindowsPath = os.PathSeparator == '\\'
 // the path separator so it is properly interpreted by the fmtstr processor
 type PathFormatString struct {
 	efs *fmtstr.EventFormatString
+	raw string
 }
 
 // Run executes the format string returning a new expanded string or an error
@@ -61,6 +62,7 @@ func (fs *PathFormatString) Unpack(v interface{}) error {
 		return nil
 	}
 
+	fs.raw = path
 	if isWindowsPath {
 		path = strings.ReplaceAll(path, "\\", "\\\\")
 	}
@@ -68,3 +70,9 @@ func (fs *PathFormatString) Unpack(v interface{}) error {
 	fs.efs = &fmtstr.EventFormatString{}
 	return fs.efs.Unpack(path)
 }
+
+// Pattern returns a glob pattern matching all paths the format string can
+// expand to, by replacing every format expression with a wildcard.
+func (fs *PathFormatString) Pattern() string {
+	return globPattern(fs.raw)
+}
@@ -0,0 +1,301 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+// maxErrorBodySize limits how much of a failed response is kept for logging.
+const maxErrorBodySize = 1024
+
+var errPayloadTooLarge = errors.New("the bulk payload is too large for the server. Consider to adjust `bulk_max_size` parameter")
+
+type clientSettings struct {
+	url              string
+	method           string
+	params           map[string]string
+	headers          map[string]string
+	username         string
+	password         string
+	bearerToken      string
+	bodyFormat       string
+	compressionLevel int
+	transport        httpcommon.HTTPTransportSettings
+	userAgent        string
+	index            string
+	codec            codec.Codec
+	observer         outputs.Observer
+}
+
+// client publishes batches of events to a single HTTP endpoint. Each batch is
+// sent as one request; the response status decides if the batch is ACKed,
+// retried, split or dropped, mirroring the elasticsearch output semantics.
+type client struct {
+	log      *logp.Logger
+	settings clientSettings
+	observer outputs.Observer
+	http     *http.Client
+	buf      bytes.Buffer
+}
+
+func newClient(s clientSettings) (*client, error) {
+	if _, err := url.Parse(s.url); err != nil {
+		return nil, fmt.Errorf("invalid url %v: %w", s.url, err)
+	}
+
+	observer := s.observer
+	if observer == nil {
+		observer = outputs.NewNilObserver()
+	}
+
+	if len(s.params) > 0 {
+		u, _ := url.Parse(s.url)
+		q := u.Query()
+		for k, v := range s.params {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+		s.url = u.String()
+	}
+
+	return &client{
+		log:      logp.NewLogger(logSelector),
+		settings: s,
+		observer: observer,
+	}, nil
+}
+
+func (c *client) Connect(_ context.Context) error {
+	if c.http != nil {
+		return nil
+	}
+
+	httpClient, err := c.settings.transport.Client(
+		httpcommon.WithLogger(c.log),
+		httpcommon.WithIOStats(c.observer),
+		httpcommon.WithKeepaliveSettings{IdleConnTimeout: c.settings.transport.IdleConnTimeout},
+	)
+	if err != nil {
+		return err
+	}
+	c.http = httpClient
+	return nil
+}
+
+func (c *client) Close() error {
+	if c.http != nil {
+		c.http.CloseIdleConnections()
+		c.http = nil
+	}
+	return nil
+}
+
+func (c *client) String() string {
+	return "http(" + c.settings.url + ")"
+}
+
+func (c *client) Publish(ctx context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	okEvents, body, err := c.encodeBody(events)
+	c.observer.PermanentErrors(len(events) - len(okEvents))
+	if err != nil {
+		c.log.Errorf("Failed to build request body: %v", err)
+		c.observer.PermanentErrors(len(okEvents))
+		batch.Drop()
+		return nil
+	}
+	if len(okEvents) == 0 {
+		batch.ACK()
+		return nil
+	}
+
+	begin := time.Now()
+	status, respBody, err := c.send(ctx, body)
+	if err != nil {
+		c.log.Errorf("Failed to publish events: %v", err)
+		c.observer.RetryableErrors(len(okEvents))
+		batch.RetryEvents(okEvents)
+		return err
+	}
+	c.observer.ReportLatency(time.Since(begin))
+
+	switch {
+	case status < 300:
+		c.log.Debugf("%d events have been sent to %v in %v.", len(okEvents), c.settings.url, time.Since(begin))
+		c.observer.AckedEvents(len(okEvents))
+		batch.ACK()
+		return nil
+
+	case status == http.StatusRequestEntityTooLarge:
+		if batch.SplitRetry() {
+			c.observer.BatchSplit()
+			c.observer.RetryableErrors(len(okEvents))
+		} else {
+			batch.Drop()
+			c.observer.PermanentErrors(len(okEvents))
+			c.log.Error(errPayloadTooLarge)
+		}
+		return nil
+
+	case status == http.StatusTooManyRequests:
+		c.observer.ErrTooMany(len(okEvents))
+		c.observer.RetryableErrors(len(okEvents))
+		batch.RetryEvents(okEvents)
+		return fmt.Errorf("%v responded with %d %s", c.settings.url, status, http.StatusText(status))
+
+	case status >= 500:
+		c.observer.RetryableErrors(len(okEvents))
+		batch.RetryEvents(okEvents)
+		return fmt.Errorf("%v responded with %d %s: %s", c.settings.url, status, http.StatusText(status), respBody)
+
+	default:
+		// Any other client error will not succeed on retry, drop the events.
+		c.log.Warnw(fmt.Sprintf("Cannot publish %d events (status=%v): %s, dropping events!", len(okEvents), status, respBody), logp.TypeKey, logp.EventType)
+		c.observer.PermanentErrors(len(okEvents))
+		batch.ACK()
+		return nil
+	}
+}
+
+// encodeBody encodes all events into the request body. Events that cannot
+// be encoded are dropped and excluded from the returned slice.
+func (c *client) encodeBody(events []publisher.Event) ([]publisher.Event, []byte, error) {
+	c.buf.Reset()
+
+	okEvents := make([]publisher.Event, 0, len(events))
+	if c.settings.bodyFormat == bodyFormatJSONArray {
+		c.buf.WriteByte('[')
+	}
+	for i := range events {
+		event := &events[i]
+		serialized, err := c.settings.codec.Encode(c.settings.index, &event.Content)
+		if err != nil {
+			c.log.Errorf("Failed to serialize the event: %+v", err)
+			c.log.Errorw(fmt.Sprintf("Failed event: %v", event.Content), logp.TypeKey, logp.EventType)
+			continue
+		}
+		serialized = bytes.TrimRight(serialized, "\n")
+
+		switch c.settings.bodyFormat {
+		case bodyFormatJSONArray:
+			if len(okEvents) > 0 {
+				c.buf.WriteByte(',')
+			}
+			c.buf.Write(serialized)
+		default:
+			c.buf.Write(serialized)
+			c.buf.WriteByte('\n')
+		}
+		okEvents = append(okEvents, *event)
+	}
+	if c.settings.bodyFormat == bodyFormatJSONArray {
+		c.buf.WriteByte(']')
+	}
+
+	if c.settings.compressionLevel == 0 {
+		return okEvents, c.buf.Bytes(), nil
+	}
+
+	var compressed bytes.Buffer
+	w, err := gzip.NewWriterLevel(&compressed, c.settings.compressionLevel)
+	if err != nil {
+		return okEvents, nil, err
+	}
+	if _, err := w.Write(c.buf.Bytes()); err != nil {
+		return okEvents, nil, err
+	}
+	if err := w.Close(); err != nil {
+		return okEvents, nil, err
+	}
+	return okEvents, compressed.Bytes(), nil
+}
+
+func (c *client) send(ctx context.Context, body []byte) (int, []byte, error) {
+	if c.http == nil {
+		return 0, nil, errors.New("http client is not connected")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, c.settings.method, c.settings.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	c.setHeaders(req)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	var respBody []byte
+	if resp.StatusCode >= 300 {
+		respBody, _ = io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	}
+	// Drain the rest of the body so the connection can be reused.
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, respBody, nil
+}
+
+func (c *client) setHeaders(req *http.Request) {
+	switch c.settings.bodyFormat {
+	case bodyFormatJSONArray:
+		req.Header.Set("Content-Type", "application/json")
+	default:
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if c.settings.compressionLevel > 0 {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if c.settings.userAgent != "" {
+		req.Header.Set("User-Agent", c.settings.userAgent)
+	}
+
+	switch {
+	case c.settings.bearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.settings.bearerToken)
+	case c.settings.username != "" || c.settings.password != "":
+		req.SetBasicAuth(c.settings.username, c.settings.password)
+	}
+
+	// User configured headers take precedence over the defaults.
+	for k, v := range c.settings.headers {
+		if http.CanonicalHeaderKey(k) == "Host" {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+}
@@ -0,0 +1,300 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package httpout
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	jsoncodec "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/monitoring"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+type request struct {
+	host   string
+	header http.Header
+	body   []byte
+}
+
+func newTestServer(t *testing.T, status int) (*httptest.Server, chan request) {
+	t.Helper()
+	requests := make(chan request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reader io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			gz, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			reader = gz
+		}
+		body, _ := io.ReadAll(reader)
+		requests <- request{host: r.Host, header: r.Header.Clone(), body: body}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+func newTestClient(t *testing.T, settings clientSettings) (*client, *monitoring.Registry) {
+	t.Helper()
+	reg := monitoring.NewRegistry()
+	if settings.method == "" {
+		settings.method = http.MethodPost
+	}
+	if settings.bodyFormat == "" {
+		settings.bodyFormat = bodyFormatNDJSON
+	}
+	settings.transport = httpcommon.DefaultHTTPTransportSettings()
+	settings.index = "test"
+	settings.codec = jsoncodec.New("1.2.3", jsoncodec.Config{})
+	settings.observer = outputs.NewStats(reg)
+
+	c, err := newClient(settings)
+	require.NoError(t, err)
+	require.NoError(t, c.Connect(context.Background()))
+	t.Cleanup(func() { c.Close() })
+	return c, reg
+}
+
+func testEvents(n int) []beat.Event {
+	events := make([]beat.Event, n)
+	for i := range events {
+		events[i] = beat.Event{
+			Timestamp: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			Fields:    mapstr.M{"message": "hello", "n": i},
+		}
+	}
+	return events
+}
+
+func TestPublishNDJSON(t *testing.T) {
+	srv, requests := newTestServer(t, http.StatusOK)
+	c, reg := newTestClient(t, clientSettings{
+		url:     srv.URL,
+		headers: map[string]string{"X-Test": "value"},
+	})
+
+	batch := outest.NewBatch(testEvents(3)...)
+	require.NoError(t, c.Publish(context.Background(), batch))
+
+	req := <-requests
+	assert.Equal(t, "application/x-ndjson", req.header.Get("Content-Type"))
+	assert.Equal(t, "value", req.header.Get("X-Test"))
+
+	scanner := bufio.NewScanner(bytes.NewReader(req.body))
+	lines := 0
+	for scanner.Scan() {
+		var doc map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &doc))
+		assert.Equal(t, "hello", doc["message"])
+		lines++
+	}
+	assert.Equal(t, 3, lines)
+
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+	assertRegistryUint(t, reg, "events.acked", 3)
+}
+
+func TestPublishJSONArrayGzip(t *testing.T) {
+	srv, requests := newTestServer(t, http.StatusAccepted)
+	c, _ := newTestClient(t, clientSettings{
+		url:              srv.URL,
+		bodyFormat:       bodyFormatJSONArray,
+		compressionLevel: 5,
+		bearerToken:      "secret",
+	})
+
+	batch := outest.NewBatch(testEvents(2)...)
+	require.NoError(t, c.Publish(context.Background(), batch))
+
+	req := <-requests
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, "gzip", req.header.Get("Content-Encoding"))
+	assert.Equal(t, "Bearer secret", req.header.Get("Authorization"))
+
+	var docs []map[string]interface{}
+	require.NoError(t, json.Unmarshal(req.body, &docs))
+	assert.Len(t, docs, 2)
+}
+
+func TestPublishBasicAuth(t *testing.T) {
+	srv, requests := newTestServer(t, http.StatusOK)
+	c, _ := newTestClient(t, clientSettings{
+		url:      srv.URL,
+		username: "beat",
+		password: "changeme",
+	})
+
+	require.NoError(t, c.Publish(context.Background(), outest.NewBatch(testEvents(1)...)))
+	req := <-requests
+	assert.Equal(t, "Basic YmVhdDpjaGFuZ2VtZQ==", req.header.Get("Authorization"))
+}
+
+func TestPublishHostHeader(t *testing.T) {
+	for _, key := range []string{"Host", "host", "HOST"} {
+		t.Run(key, func(t *testing.T) {
+			srv, requests := newTestServer(t, http.StatusOK)
+			c, _ := newTestClient(t, clientSettings{
+				url:     srv.URL,
+				headers: map[string]string{key: "logs.example.com"},
+			})
+
+			require.NoError(t, c.Publish(context.Background(), outest.NewBatch(testEvents(1)...)))
+			req := <-requests
+			assert.Equal(t, "logs.example.com", req.host)
+		})
+	}
+}
+
+func TestPublishStatusHandling(t *testing.T) {
+	tests := map[string]struct {
+		status      int
+		wantErr     bool
+		wantSignal  outest.BatchSignalTag
+		wantRetried bool
+		wantDropped uint64
+		wantTooMany uint64
+	}{
+		"too many requests is retried": {
+			status:      http.StatusTooManyRequests,
+			wantErr:     true,
+			wantSignal:  outest.BatchRetryEvents,
+			wantRetried: true,
+			wantTooMany: 2,
+		},
+		"server errors are retried": {
+			status:      http.StatusServiceUnavailable,
+			wantErr:     true,
+			wantSignal:  outest.BatchRetryEvents,
+			wantRetried: true,
+		},
+		"client errors are dropped": {
+			status:      http.StatusBadRequest,
+			wantSignal:  outest.BatchACK,
+			wantDropped: 2,
+		},
+		"payload too large splits the batch": {
+			status:     http.StatusRequestEntityTooLarge,
+			wantSignal: outest.BatchSplitRetry,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			srv, _ := newTestServer(t, test.status)
+			c, reg := newTestClient(t, clientSettings{url: srv.URL})
+
+			batch := outest.NewBatch(testEvents(2)...)
+			err := c.Publish(context.Background(), batch)
+			if test.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			require.NotEmpty(t, batch.Signals)
+			assert.Equal(t, test.wantSignal, batch.Signals[0].Tag)
+			if test.wantRetried {
+				assert.Len(t, batch.Signals[0].Events, 2)
+			}
+			assertRegistryUint(t, reg, "events.dropped", test.wantDropped)
+			assertRegistryUint(t, reg, "events.toomany", test.wantTooMany)
+		})
+	}
+}
+
+func assertRegistryUint(t *testing.T, reg *monitoring.Registry, key string, expected uint64) {
+	t.Helper()
+	value := reg.Get(key).(*monitoring.Uint)
+	require.NotNil(t, value, "expected registry entry for key '%v'", key)
+	assert.Equal(t, expected, value.Get(), key)
+}
+
+func TestConnectionErrorIsRetried(t *testing.T) {
+	srv, _ := newTestServer(t, http.StatusOK)
+	c, _ := newTestClient(t, clientSettings{url: srv.URL})
+	srv.Close()
+
+	batch := outest.NewBatch(testEvents(2)...)
+	assert.Error(t, c.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	assert.Len(t, batch.Signals[0].Events, 2)
+}
+
+func TestMakeHTTPLoadBalance(t *testing.T) {
+	cfg := config.MustNewConfigFrom(mapstr.M{
+		"hosts":       []string{"localhost:8080", "localhost:8081"},
+		"path":        "/ingest",
+		"loadbalance": true,
+	})
+
+	group, err := makeHTTP(nil, beat.Info{Beat: "libbeat"}, outputs.NewNilObserver(), cfg)
+	require.NoError(t, err)
+	assert.Len(t, group.Clients, 2)
+	assert.Equal(t, defaultConfig.BulkMaxSize, group.BatchSize)
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := map[string]struct {
+		cfg   mapstr.M
+		valid bool
+	}{
+		"defaults":             {cfg: mapstr.M{}, valid: true},
+		"json array":           {cfg: mapstr.M{"body_format": "json_array"}, valid: true},
+		"unknown body format":  {cfg: mapstr.M{"body_format": "xml"}, valid: false},
+		"unsupported method":   {cfg: mapstr.M{"method": "GET"}, valid: false},
+		"bearer and basic":     {cfg: mapstr.M{"bearer_token": "t", "username": "u"}, valid: false},
+		"bad compression":      {cfg: mapstr.M{"compression_level": 10}, valid: false},
+		"put with compression": {cfg: mapstr.M{"method": "put", "compression_level": 9}, valid: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig
+			err := config.MustNewConfigFrom(test.cfg).Unpack(&c)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/httpcommon"
+)
+
+type httpConfig struct {
+	Protocol         string            `config:"protocol"`
+	Path             string            `config:"path"`
+	Method           string            `config:"method"`
+	Params           map[string]string `config:"parameters"`
+	Headers          map[string]string `config:"headers"`
+	Username         string            `config:"username"`
+	Password         string            `config:"password"`
+	BearerToken      string            `config:"bearer_token"`
+	BodyFormat       string            `config:"body_format"`
+	CompressionLevel int               `config:"compression_level" validate:"min=0, max=9"`
+	LoadBalance      bool              `config:"loadbalance"`
+	BulkMaxSize      int               `config:"bulk_max_size"`
+	MaxRetries       int               `config:"max_retries"`
+	Backoff          Backoff           `config:"backoff"`
+	Codec            codec.Config      `config:"codec"`
+	Queue            config.Namespace  `config:"queue"`
+
+	Transport httpcommon.HTTPTransportSettings `config:",inline"`
+}
+
+type Backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+const (
+	bodyFormatNDJSON    = "ndjson"
+	bodyFormatJSONArray = "json_array"
+)
+
+var defaultConfig = httpConfig{
+	Method:           http.MethodPost,
+	BodyFormat:       bodyFormatNDJSON,
+	CompressionLevel: 0,
+	LoadBalance:      true,
+	BulkMaxSize:      1600,
+	MaxRetries:       3,
+	Backoff: Backoff{
+		Init: 1 * time.Second,
+		Max:  60 * time.Second,
+	},
+	Transport: httpcommon.DefaultHTTPTransportSettings(),
+}
+
+func (c *httpConfig) Validate() error {
+	if c.BearerToken != "" && (c.Username != "" || c.Password != "") {
+		return errors.New("cannot set both bearer_token and username/password")
+	}
+
+	switch strings.ToLower(c.BodyFormat) {
+	case bodyFormatNDJSON, bodyFormatJSONArray:
+	default:
+		return fmt.Errorf("unsupported body_format '%v', expected one of %v or %v",
+			c.BodyFormat, bodyFormatNDJSON, bodyFormatJSONArray)
+	}
+
+	switch strings.ToUpper(c.Method) {
+	case http.MethodPost, http.MethodPut:
+	default:
+		return fmt.Errorf("unsupported method '%v', expected POST or PUT", c.Method)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package httpout
+
+import (
+	"strings"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+const (
+	logSelector = "http"
+	defaultPort = 80
+)
+
+func init() {
+	outputs.RegisterType("http", makeHTTP)
+}
+
+func makeHTTP(
+	_ outputs.IndexManager,
+	beatInfo beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	log := logp.NewLogger(logSelector)
+
+	httpCfg := defaultConfig
+	if err := cfg.Unpack(&httpCfg); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	if proxyURL := httpCfg.Transport.Proxy.URL; proxyURL != nil && !httpCfg.Transport.Proxy.Disable {
+		log.Infof("Using proxy URL: %s", proxyURL)
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		hostURL, err := common.MakeURL(httpCfg.Protocol, httpCfg.Path, host, defaultPort)
+		if err != nil {
+			log.Errorf("Invalid host param set: %s, Error: %+v", host, err)
+			return outputs.Fail(err)
+		}
+
+		enc, err := codec.CreateEncoder(beatInfo, httpCfg.Codec)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		client, err := newClient(clientSettings{
+			url:              hostURL,
+			method:           strings.ToUpper(httpCfg.Method),
+			params:           httpCfg.Params,
+			headers:          httpCfg.Headers,
+			username:         httpCfg.Username,
+			password:         httpCfg.Password,
+			bearerToken:      httpCfg.BearerToken,
+			bodyFormat:       strings.ToLower(httpCfg.BodyFormat),
+			compressionLevel: httpCfg.CompressionLevel,
+			transport:        httpCfg.Transport,
+			userAgent:        beatInfo.UserAgent,
+			index:            beatInfo.Beat,
+			codec:            enc,
+			observer:         observer,
+		})
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		clients[i] = outputs.WithBackoff(client, httpCfg.Backoff.Init, httpCfg.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(httpCfg.Queue, httpCfg.LoadBalance, httpCfg.BulkMaxSize, httpCfg.MaxRetries, nil, clients)
+}
@@ -19,9 +19,11 @@ package redis
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +31,7 @@ import (
 	"github.com/gomodule/redigo/redis"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
 	"github.com/elastic/beats/v7/libbeat/outputs"
 	"github.com/elastic/beats/v7/libbeat/outputs/codec"
 	"github.com/elastic/beats/v7/libbeat/outputs/outil"
@@ -53,6 +56,7 @@ type client struct {
 	observer outputs.Observer
 	index    string
 	dataType redisDataType
+	stream   streamConfig
 	db       int
 	key      outil.Selector
 	password string
@@ -66,6 +70,7 @@ type redisDataType uint16
 const (
 	redisListType redisDataType = iota
 	redisChannelType
+	redisStreamType
 )
 
 func newClient(
@@ -73,7 +78,7 @@ func newClient(
 	observer outputs.Observer,
 	timeout time.Duration,
 	pass string,
-	db int, key outil.Selector, dt redisDataType,
+	db int, key outil.Selector, dt redisDataType, stream streamConfig,
 	index string, codec codec.Codec,
 ) *client {
 	return &client{
@@ -85,6 +90,7 @@ func newClient(
 		index:    strings.ToLower(index),
 		db:       db,
 		dataType: dt,
+		stream:   stream,
 		key:      key,
 		codec:    codec,
 	}
@@ -164,8 +170,11 @@ func (c *client) String() string {
 func (c *client) makePublish(
 	conn redis.Conn,
 ) (publishFn, error) {
-	if c.dataType == redisChannelType {
+	switch c.dataType {
+	case redisChannelType:
 		return c.makePublishPUBLISH(conn)
+	case redisStreamType:
+		return c.publishEventsXADD(conn), nil
 	}
 	return c.makePublishRPUSH(conn)
 }
@@ -307,6 +316,140 @@ func (c *client) publishEventsPipeline(conn redis.Conn, command string) publishF
 	}
 }
 
+// publishEventsXADD adds the events to streams with XADD, using pipelining.
+// The stream of each event is selected with the key selector.
+func (c *client) publishEventsXADD(conn redis.Conn) publishFn {
+	return func(key outil.Selector, data []publisher.Event) ([]publisher.Event, error) {
+		now := time.Now()
+		sent := make([]publisher.Event, 0, len(data))
+		dropped := 0
+		for i := range data {
+			event := &data[i].Content
+			streamKey, err := key.Select(event)
+			if err != nil {
+				c.log.Errorf("Failed to set redis key: %+v", err)
+				dropped++
+				continue
+			}
+
+			fields, err := c.streamEntryFields(event)
+			if err != nil {
+				dropped++
+				continue
+			}
+
+			args := append(c.stream.xaddArgs(streamKey, now), fields...)
+			if err := conn.Send("XADD", args...); err != nil {
+				c.log.Errorf("Failed to execute XADD: %+v", err)
+				c.observer.PermanentErrors(dropped)
+				return append(sent, data[i:]...), err
+			}
+			sent = append(sent, data[i])
+		}
+		c.observer.PermanentErrors(dropped)
+		if len(sent) == 0 {
+			return nil, nil
+		}
+
+		start := time.Now()
+		if err := conn.Flush(); err != nil {
+			return sent, err
+		}
+
+		failed := sent[:0]
+		var lastErr error
+		for i := range sent {
+			_, err := conn.Receive()
+			if err != nil {
+				if _, ok := err.(redis.Error); ok { //nolint:errorlint //this line checks against a type, not an instance of an error
+					c.log.Errorf("Failed to XADD event to stream with %+v", err)
+					failed = append(failed, sent[i])
+					lastErr = err
+				} else {
+					c.log.Errorf("Failed to XADD multiple events to stream with %+v", err)
+					failed = append(failed, sent[i:]...)
+					lastErr = err
+					break
+				}
+			}
+		}
+		c.observer.ReportLatency(time.Since(start))
+
+		c.observer.AckedEvents(len(sent) - len(failed))
+		return failed, lastErr
+	}
+}
+
+// xaddArgs returns the arguments of XADD preceding the field/value pairs of
+// the entry, trimming the stream if configured.
+func (s *streamConfig) xaddArgs(key string, now time.Time) []interface{} {
+	args := []interface{}{key}
+	switch {
+	case s.MaxLen > 0:
+		args = append(args, "MAXLEN")
+		if !s.ExactTrim {
+			args = append(args, "~")
+		}
+		args = append(args, s.MaxLen)
+	case s.MaxAge > 0:
+		args = append(args, "MINID")
+		if !s.ExactTrim {
+			args = append(args, "~")
+		}
+		args = append(args, now.Add(-s.MaxAge).UnixMilli())
+	}
+	return append(args, "*")
+}
+
+// streamEntryFields returns the field/value pairs of the entry of an event.
+func (c *client) streamEntryFields(event *beat.Event) ([]interface{}, error) {
+	if c.stream.Mode == streamModeFields {
+		fields, err := eventFieldPairs(event)
+		if err != nil {
+			c.log.Errorf("Converting event to stream fields failed with error: %+v. Look at the event log file to view the event", err)
+			c.log.Errorw(fmt.Sprintf("Failed event: %v", *event), logp.TypeKey, logp.EventType)
+		}
+		return fields, err
+	}
+
+	serializedEvent, err := c.codec.Encode(c.index, event)
+	if err != nil {
+		c.log.Errorf("Encoding event failed with error: %+v. Look at the event log file to view the event", err)
+		c.log.Errorw(fmt.Sprintf("Failed event: %v", *event), logp.TypeKey, logp.EventType)
+		return nil, err
+	}
+	buf := make([]byte, len(serializedEvent))
+	copy(buf, serializedEvent)
+	return []interface{}{c.stream.EventField, buf}, nil
+}
+
+// eventFieldPairs returns the top-level fields of an event as field/value
+// pairs, sorted by field name after @timestamp. Strings are stored as is,
+// other values are JSON encoded.
+func eventFieldPairs(event *beat.Event) ([]interface{}, error) {
+	keys := make([]string, 0, len(event.Fields))
+	for k := range event.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]interface{}, 0, 2*len(keys)+2)
+	pairs = append(pairs, "@timestamp", common.Time(event.Timestamp).String())
+	for _, k := range keys {
+		switch v := event.Fields[k].(type) {
+		case string:
+			pairs = append(pairs, k, v)
+		default:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode field %s: %w", k, err)
+			}
+			pairs = append(pairs, k, b)
+		}
+	}
+	return pairs, nil
+}
+
 func serializeEvents(
 	log *logp.Logger,
 	to []interface{},
@@ -18,6 +18,7 @@
 package redis
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
@@ -40,10 +41,32 @@ type redisConfig struct {
 	Codec       codec.Config          `config:"codec"`
 	Db          int                   `config:"db"`
 	DataType    string                `config:"datatype"`
+	Stream      streamConfig          `config:"stream"`
 	Backoff     backoff               `config:"backoff"`
 	Queue       config.Namespace      `config:"queue"`
 }
 
+// streamConfig configures how events are added to streams when the data type
+// is stream.
+type streamConfig struct {
+	// Mode is "event" to store the encoded event in a single field of the
+	// entry, or "fields" to store each top-level field of the event as a
+	// field of the entry.
+	Mode       string `config:"mode"`
+	EventField string `config:"event_field"`
+
+	// MaxLen and MaxAge trim the stream with MAXLEN or MINID when adding
+	// entries. Trimming is approximate unless ExactTrim is set.
+	MaxLen    int64         `config:"max_len" validate:"min=0"`
+	MaxAge    time.Duration `config:"max_age" validate:"min=0"`
+	ExactTrim bool          `config:"exact_trim"`
+}
+
+const (
+	streamModeEvent  = "event"
+	streamModeFields = "fields"
+)
+
 type backoff struct {
 	Init time.Duration
 	Max  time.Duration
@@ -58,6 +81,10 @@ var (
 		TLS:         nil,
 		Db:          0,
 		DataType:    "list",
+		Stream: streamConfig{
+			Mode:       streamModeEvent,
+			EventField: "event",
+		},
 		Backoff: backoff{
 			Init: 1 * time.Second,
 			Max:  60 * time.Second,
@@ -67,10 +94,32 @@ var (
 
 func (c *redisConfig) Validate() error {
 	switch c.DataType {
-	case "", "list", "channel":
+	case "", "list", "channel", "stream":
 	default:
 		return fmt.Errorf("redis data type %v not supported", c.DataType)
 	}
 
+	if c.DataType == "stream" {
+		return c.Stream.Validate()
+	}
+	return nil
+}
+
+// Validate validates the stream settings. It is only called when the data
+// type is stream.
+func (c *streamConfig) Validate() error {
+	switch c.Mode {
+	case "", streamModeEvent:
+		if c.EventField == "" {
+			return errors.New("stream.event_field must be set when stream.mode is event")
+		}
+	case streamModeFields:
+	default:
+		return fmt.Errorf("redis stream mode %v not supported", c.Mode)
+	}
+
+	if c.MaxLen > 0 && c.MaxAge > 0 {
+		return errors.New("stream.max_len and stream.max_age cannot be used together")
+	}
 	return nil
 }
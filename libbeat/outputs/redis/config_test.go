@@ -19,6 +19,7 @@ package redis
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -37,6 +38,11 @@ func TestValidate(t *testing.T) {
 		{"Invalid Datatype", redisConfig{Key: "test", DataType: "something"}, false},
 		{"List Datatype", redisConfig{Key: "test", DataType: "list"}, true},
 		{"Channel Datatype", redisConfig{Key: "test", DataType: "channel"}, true},
+		{"Stream Datatype", redisConfig{Key: "test", DataType: "stream", Stream: defaultConfig.Stream}, true},
+		{"Stream fields mode", redisConfig{Key: "test", DataType: "stream", Stream: streamConfig{Mode: "fields"}}, true},
+		{"Invalid stream mode", redisConfig{Key: "test", DataType: "stream", Stream: streamConfig{Mode: "hash"}}, false},
+		{"Stream without event field", redisConfig{Key: "test", DataType: "stream", Stream: streamConfig{Mode: "event"}}, false},
+		{"Stream max_len and max_age", redisConfig{Key: "test", DataType: "stream", Stream: streamConfig{Mode: "fields", MaxLen: 10, MaxAge: time.Hour}}, false},
 	}
 
 	for _, test := range tests {
@@ -127,6 +127,77 @@ func TestWithSchema(t *testing.T) {
 
 }
 
+func TestPublishStreamTCP(t *testing.T) {
+	key := "test_publish_stream_tcp"
+	conn := dialTestStream(t, key)
+
+	out := newRedisTestingOutput(t, map[string]interface{}{
+		"hosts":    []string{getRedisAddr()},
+		"key":      key,
+		"datatype": "stream",
+		"timeout":  "5s",
+	})
+	err := sendTestEvents(out, 10, 100)
+	assert.NoError(t, err)
+
+	entries, err := redis.Values(conn.Do("XRANGE", key, "-", "+"))
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1000)
+	for i, entry := range entries {
+		values, err := redis.Values(entry, nil)
+		assert.NoError(t, err)
+		fields, err := redis.StringMap(values[1], nil)
+		assert.NoError(t, err)
+
+		evt := struct{ Message int }{}
+		err = json.Unmarshal([]byte(fields["event"]), &evt)
+		assert.NoError(t, err)
+		assert.Equal(t, i+1, evt.Message)
+		validateMeta(t, []byte(fields["event"]))
+	}
+}
+
+func TestPublishStreamFieldsWithMaxLen(t *testing.T) {
+	key := "test_publish_stream_fields"
+	conn := dialTestStream(t, key)
+
+	out := newRedisTestingOutput(t, map[string]interface{}{
+		"hosts":             []string{getRedisAddr()},
+		"key":               key,
+		"datatype":          "stream",
+		"timeout":           "5s",
+		"stream.mode":       "fields",
+		"stream.max_len":    10,
+		"stream.exact_trim": true,
+	})
+	err := sendTestEvents(out, 1, 100)
+	assert.NoError(t, err)
+
+	length, err := redis.Int(conn.Do("XLEN", key))
+	assert.NoError(t, err)
+	assert.Equal(t, 10, length)
+
+	entries, err := redis.Values(conn.Do("XRANGE", key, "-", "+", "COUNT", 1))
+	assert.NoError(t, err)
+	values, err := redis.Values(entries[0], nil)
+	assert.NoError(t, err)
+	fields, err := redis.StringMap(values[1], nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "91", fields["message"])
+	assert.Contains(t, fields, "@timestamp")
+}
+
+// dialTestStream connects to Redis and deletes the stream key.
+func dialTestStream(t *testing.T, key string) redis.Conn {
+	conn, err := redis.Dial("tcp", getRedisAddr())
+	if err != nil {
+		t.Fatalf("redis.Dial failed %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.Do("DEL", key)
+	return conn
+}
+
 func testPublishList(t *testing.T, cfg map[string]interface{}) {
 	batches := 100
 	batchSize := 1000
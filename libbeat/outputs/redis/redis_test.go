@@ -19,12 +19,16 @@ package redis
 
 import (
 	"testing"
+	"time"
 
+	"github.com/gomodule/redigo/redis"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
 	"github.com/elastic/beats/v7/libbeat/outputs"
-	_ "github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/outputs/codec/json"
+	"github.com/elastic/beats/v7/libbeat/publisher"
 	"github.com/elastic/elastic-agent-libs/config"
 	"github.com/elastic/elastic-agent-libs/mapstr"
 )
@@ -180,3 +184,105 @@ func TestKeySelection(t *testing.T) {
 		})
 	}
 }
+
+// fakeConn is a Redis connection recording the pipelined commands and
+// replying to them with scripted replies.
+type fakeConn struct {
+	commands [][]interface{}
+	replies  []error
+}
+
+func (c *fakeConn) Close() error { return nil }
+func (c *fakeConn) Err() error   { return nil }
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Do(string, ...interface{}) (interface{}, error) { return nil, nil }
+
+func (c *fakeConn) Send(cmd string, args ...interface{}) error {
+	c.commands = append(c.commands, append([]interface{}{cmd}, args...))
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	err := c.replies[0]
+	c.replies = c.replies[1:]
+	if err != nil {
+		return nil, err
+	}
+	return "1-0", nil
+}
+
+func TestPublishStream(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []publisher.Event{
+		{Content: beat.Event{Timestamp: ts, Fields: mapstr.M{"message": "hello", "stream": "a"}}},
+		{Content: beat.Event{Timestamp: ts, Fields: mapstr.M{"message": "world", "count": 2}}},
+		{Content: beat.Event{Timestamp: ts, Fields: mapstr.M{"message": "full", "stream": "b"}}},
+	}
+	key, err := buildKeySelector(config.MustNewConfigFrom(map[string]interface{}{
+		"key": "logs-%{[stream]:default}",
+	}))
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		stream   streamConfig
+		replies  []error
+		commands [][]interface{}
+		failed   int
+	}{
+		"event": {
+			stream:  streamConfig{Mode: streamModeEvent, EventField: "event", MaxLen: 1000},
+			replies: []error{nil, nil, nil},
+			commands: [][]interface{}{
+				{"XADD", "logs-a", "MAXLEN", "~", int64(1000), "*", "event", `{"@timestamp":"2024-05-01T12:00:00.000Z","@metadata":{"beat":"libbeat","type":"_doc","version":"1.2.3"},"message":"hello","stream":"a"}`},
+				{"XADD", "logs-default", "MAXLEN", "~", int64(1000), "*", "event", `{"@timestamp":"2024-05-01T12:00:00.000Z","@metadata":{"beat":"libbeat","type":"_doc","version":"1.2.3"},"count":2,"message":"world"}`},
+				{"XADD", "logs-b", "MAXLEN", "~", int64(1000), "*", "event", `{"@timestamp":"2024-05-01T12:00:00.000Z","@metadata":{"beat":"libbeat","type":"_doc","version":"1.2.3"},"message":"full","stream":"b"}`},
+			},
+		},
+		"fields": {
+			stream:  streamConfig{Mode: streamModeFields, MaxLen: 10, ExactTrim: true},
+			replies: []error{nil, nil, redis.Error("ERR stream is full")},
+			commands: [][]interface{}{
+				{"XADD", "logs-a", "MAXLEN", int64(10), "*", "@timestamp", "2024-05-01T12:00:00.000Z", "message", "hello", "stream", "a"},
+				{"XADD", "logs-default", "MAXLEN", int64(10), "*", "@timestamp", "2024-05-01T12:00:00.000Z", "count", []byte("2"), "message", "world"},
+				{"XADD", "logs-b", "MAXLEN", int64(10), "*", "@timestamp", "2024-05-01T12:00:00.000Z", "message", "full", "stream", "b"},
+			},
+			failed: 1,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			conn := &fakeConn{replies: test.replies}
+			c := newClient(nil, outputs.NewNilObserver(), time.Second, "", 0, key, redisStreamType, test.stream,
+				"libbeat", json.New("1.2.3", json.Config{}))
+			publish := c.publishEventsXADD(conn)
+
+			failed, err := publish(key, append([]publisher.Event(nil), events...))
+			require.Len(t, conn.commands, len(test.commands))
+			for i, cmd := range conn.commands {
+				if test.stream.Mode == streamModeEvent {
+					// Compare the encoded events as JSON, the order of
+					// the fields is not deterministic.
+					last := len(cmd) - 1
+					assert.JSONEq(t, test.commands[i][last].(string), string(cmd[last].([]byte)))
+					cmd, test.commands[i] = cmd[:last], test.commands[i][:last]
+				}
+				assert.Equal(t, test.commands[i], cmd)
+			}
+			assert.Len(t, failed, test.failed)
+			if test.failed > 0 {
+				assert.Error(t, err)
+				assert.Equal(t, "full", failed[0].Content.Fields["message"])
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestStreamMaxAge(t *testing.T) {
+	now := time.UnixMilli(1714564800000)
+	s := streamConfig{MaxAge: time.Hour}
+	assert.Equal(t, []interface{}{"logs", "MINID", "~", int64(1714561200000), "*"}, s.xaddArgs("logs", now))
+}
@@ -0,0 +1,138 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/transport"
+)
+
+type client struct {
+	log *logp.Logger
+	*transport.Client
+	observer  outputs.Observer
+	timeout   time.Duration
+	stream    bool
+	framing   framing
+	formatter *formatter
+	buf       bytes.Buffer
+}
+
+func newClient(
+	tc *transport.Client,
+	observer outputs.Observer,
+	timeout time.Duration,
+	stream bool,
+	framing framing,
+	formatter *formatter,
+) *client {
+	return &client{
+		log:       logp.NewLogger("syslog"),
+		Client:    tc,
+		observer:  observer,
+		timeout:   timeout,
+		stream:    stream,
+		framing:   framing,
+		formatter: formatter,
+	}
+}
+
+func (c *client) Connect(ctx context.Context) error {
+	c.log.Debug("connect")
+	return c.Client.ConnectContext(ctx)
+}
+
+func (c *client) Close() error {
+	c.log.Debug("close connection")
+	return c.Client.Close()
+}
+
+func (c *client) String() string {
+	return "syslog(" + c.Client.String() + ")"
+}
+
+// Publish writes the events of the batch one message at a time. On a write
+// error the unsent events are returned to the pipeline for retry and the
+// error is propagated, so the connection is re-established with backoff.
+func (c *client) Publish(_ context.Context, batch publisher.Batch) error {
+	events := batch.Events()
+	c.observer.NewBatch(len(events))
+
+	dropped, acked := 0, 0
+	start := time.Now()
+	for i := range events {
+		msg, err := c.formatter.Format(&events[i].Content)
+		if err != nil {
+			c.log.Errorf("Failed to format event: %+v", err)
+			c.log.Errorw(fmt.Sprintf("Failed event: %v", events[i].Content), logp.TypeKey, logp.EventType)
+			dropped++
+			continue
+		}
+
+		if err := c.write(msg); err != nil {
+			rest := events[i:]
+			c.log.Errorf("Failed to publish events: %v", err)
+			c.observer.PermanentErrors(dropped)
+			c.observer.AckedEvents(acked)
+			c.observer.RetryableErrors(len(rest))
+			batch.RetryEvents(rest)
+			return err
+		}
+		acked++
+	}
+	c.observer.ReportLatency(time.Since(start))
+	c.observer.PermanentErrors(dropped)
+	c.observer.AckedEvents(acked)
+	batch.ACK()
+	return nil
+}
+
+// write sends a single message. Datagram transports send one message per
+// packet, stream transports frame the message as configured.
+func (c *client) write(msg []byte) error {
+	c.buf.Reset()
+	if c.stream {
+		switch c.framing {
+		case framingNonTransparent:
+			c.buf.Write(msg)
+			c.buf.WriteByte('\n')
+		default:
+			c.buf.WriteString(strconv.Itoa(len(msg)))
+			c.buf.WriteByte(' ')
+			c.buf.Write(msg)
+		}
+	} else {
+		c.buf.Write(msg)
+	}
+
+	if c.timeout > 0 {
+		if err := c.Client.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := c.Client.Write(c.buf.Bytes())
+	return err
+}
@@ -0,0 +1,194 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package syslog
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/beats/v7/libbeat/outputs/outest"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/elastic-agent-libs/transport"
+)
+
+func events(msgs ...string) []beat.Event {
+	out := make([]beat.Event, len(msgs))
+	for i, msg := range msgs {
+		out[i] = beat.Event{Timestamp: time.Now(), Fields: mapstr.M{"message": msg}}
+	}
+	return out
+}
+
+func newTestClient(t *testing.T, network, addr string, framing framing) *client {
+	t.Helper()
+	conn, err := transport.NewClient(transport.Config{Timeout: time.Second}, network, addr, 0)
+	require.NoError(t, err)
+	c := newClient(conn, outputs.NewNilObserver(), time.Second, network == "tcp", framing, testFormatter(formatRFC5424))
+	require.NoError(t, c.Connect(context.Background()))
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestPublishTCPOctetCounting(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs []string
+		for len(msgs) < 2 {
+			length, err := r.ReadString(' ')
+			if err != nil {
+				break
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			buf := make([]byte, n)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				break
+			}
+			msgs = append(msgs, string(buf))
+		}
+		received <- msgs
+	}()
+
+	c := newTestClient(t, "tcp", l.Addr().String(), framingOctetCounting)
+	batch := outest.NewBatch(events("first line", "second\nline")...)
+	require.NoError(t, c.Publish(context.Background(), batch))
+
+	msgs := <-received
+	require.Len(t, msgs, 2)
+	assert.True(t, strings.HasSuffix(msgs[0], " first line"), msgs[0])
+	assert.True(t, strings.HasSuffix(msgs[1], " second\nline"), msgs[1])
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchACK, batch.Signals[0].Tag)
+}
+
+func TestPublishTCPNonTransparent(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		var lines []string
+		for len(lines) < 2 && scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+
+	c := newTestClient(t, "tcp", l.Addr().String(), framingNonTransparent)
+	require.NoError(t, c.Publish(context.Background(), outest.NewBatch(events("a", "b")...)))
+
+	lines := <-received
+	require.Len(t, lines, 2)
+	assert.True(t, strings.HasPrefix(lines[0], "<14>1 "))
+	assert.True(t, strings.HasSuffix(lines[1], " b"))
+}
+
+func TestPublishUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	c := newTestClient(t, "udp", pc.LocalAddr().String(), framingOctetCounting)
+	require.NoError(t, c.Publish(context.Background(), outest.NewBatch(events("datagram")...)))
+
+	require.NoError(t, pc.SetReadDeadline(time.Now().Add(5*time.Second)))
+	buf := make([]byte, 2048)
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(buf[:n]), "<14>1 "))
+	assert.True(t, strings.HasSuffix(string(buf[:n]), " datagram"))
+}
+
+func TestPublishRetriesOnWriteError(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Close()
+		}
+	}()
+
+	c := newTestClient(t, "tcp", l.Addr().String(), framingOctetCounting)
+	l.Close()
+	c.Client.Close()
+
+	batch := outest.NewBatch(events("a", "b")...)
+	assert.Error(t, c.Publish(context.Background(), batch))
+	require.Len(t, batch.Signals, 1)
+	assert.Equal(t, outest.BatchRetryEvents, batch.Signals[0].Tag)
+	assert.Len(t, batch.Signals[0].Events, 2)
+}
+
+func TestConfig(t *testing.T) {
+	tests := map[string]struct {
+		cfg   mapstr.M
+		valid bool
+	}{
+		"defaults":              {cfg: mapstr.M{}, valid: true},
+		"tcp with framing":      {cfg: mapstr.M{"network": "tcp", "framing": "non_transparent"}, valid: true},
+		"facility keyword":      {cfg: mapstr.M{"facility": "local3", "severity": "warning"}, valid: true},
+		"facility number":       {cfg: mapstr.M{"facility": 16, "severity": 3}, valid: true},
+		"facility out of range": {cfg: mapstr.M{"facility": 24}, valid: false},
+		"unknown format":        {cfg: mapstr.M{"format": "rfc1234"}, valid: false},
+		"unknown network":       {cfg: mapstr.M{"network": "sctp"}, valid: false},
+		"udp with ssl":          {cfg: mapstr.M{"network": "udp", "ssl.enabled": true}, valid: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			c := defaultConfig()
+			err := config.MustNewConfigFrom(test.cfg).Unpack(&c)
+			if test.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+type syslogConfig struct {
+	Network     string                    `config:"network"`
+	Format      format                    `config:"format"`
+	Framing     framing                   `config:"framing"`
+	Facility    facility                  `config:"facility"`
+	Severity    severity                  `config:"severity"`
+	Hostname    string                    `config:"hostname"`
+	AppName     string                    `config:"app_name"`
+	Message     *fmtstr.EventFormatString `config:"message"`
+	LoadBalance bool                      `config:"loadbalance"`
+	BulkMaxSize int                       `config:"bulk_max_size"`
+	MaxRetries  int                       `config:"max_retries" validate:"min=-1"`
+	Timeout     time.Duration             `config:"timeout"`
+	TLS         *tlscommon.Config         `config:"ssl"`
+	Backoff     Backoff                   `config:"backoff"`
+	Queue       config.Namespace          `config:"queue"`
+}
+
+type Backoff struct {
+	Init time.Duration
+	Max  time.Duration
+}
+
+func defaultConfig() syslogConfig {
+	return syslogConfig{
+		Network:     "udp",
+		Format:      formatRFC5424,
+		Framing:     framingOctetCounting,
+		Facility:    1, // user-level messages
+		Severity:    6, // informational
+		Message:     fmtstr.MustCompileEvent("%{[message]}"),
+		LoadBalance: false,
+		BulkMaxSize: 2048,
+		MaxRetries:  3,
+		Timeout:     30 * time.Second,
+		Backoff: Backoff{
+			Init: 1 * time.Second,
+			Max:  60 * time.Second,
+		},
+	}
+}
+
+func (c *syslogConfig) Validate() error {
+	switch c.Network {
+	case "udp", "udp4", "udp6":
+		if c.TLS.IsEnabled() {
+			return errors.New("ssl can not be used with the udp network")
+		}
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return fmt.Errorf("unsupported network '%v', expected tcp or udp", c.Network)
+	}
+	return nil
+}
+
+func (c *syslogConfig) isStream() bool {
+	switch c.Network {
+	case "tcp", "tcp4", "tcp6":
+		return true
+	}
+	return false
+}
+
+// format is the syslog message format produced by the output.
+type format int
+
+const (
+	formatRFC5424 format = iota
+	formatRFC3164
+)
+
+func (f *format) Unpack(value string) error {
+	switch value {
+	case "rfc5424":
+		*f = formatRFC5424
+	case "rfc3164":
+		*f = formatRFC3164
+	default:
+		return fmt.Errorf("invalid format: %q", value)
+	}
+	return nil
+}
+
+// framing is the method used to delimit messages on stream transports as
+// described in RFC 6587.
+type framing int
+
+const (
+	framingOctetCounting framing = iota
+	framingNonTransparent
+)
+
+func (f *framing) Unpack(value string) error {
+	switch value {
+	case "octet_counting":
+		*f = framingOctetCounting
+	case "non_transparent", "newline":
+		*f = framingNonTransparent
+	default:
+		return fmt.Errorf("invalid framing: %q", value)
+	}
+	return nil
+}
+
+// facility is the syslog facility code. It can be configured by number or by
+// its keyword.
+type facility int
+
+var facilityNames = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"ntp": 12, "security": 13, "console": 14, "solaris-cron": 15,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+func (f *facility) Unpack(value string) error {
+	v, err := lookupCode(value, facilityNames, 23)
+	if err != nil {
+		return fmt.Errorf("invalid facility: %w", err)
+	}
+	*f = facility(v)
+	return nil
+}
+
+// severity is the default syslog severity code. It can be configured by
+// number or by its keyword.
+type severity int
+
+func (s *severity) Unpack(value string) error {
+	v, err := lookupCode(value, severities, 7)
+	if err != nil {
+		return fmt.Errorf("invalid severity: %w", err)
+	}
+	*s = severity(v)
+	return nil
+}
+
+func lookupCode(value string, names map[string]int, max int) (int, error) {
+	if v, ok := names[value]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("unknown value %q", value)
+	}
+	if v < 0 || v > max {
+		return 0, fmt.Errorf("%d is out of range [0, %d]", v, max)
+	}
+	return v, nil
+}
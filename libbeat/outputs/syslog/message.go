@@ -0,0 +1,309 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	nilValue = "-"
+
+	// Maximum lengths of the RFC 5424 header fields.
+	maxHostnameLen = 255
+	maxAppNameLen  = 48
+	maxProcIDLen   = 128
+	maxMsgIDLen    = 32
+
+	// RFC 3164 limits the TAG to 32 characters.
+	maxTagLen = 32
+
+	rfc5424TimeLayout = "2006-01-02T15:04:05.000000Z07:00"
+	rfc3164TimeLayout = time.Stamp
+)
+
+// severities maps common log.level values to syslog severity codes.
+var severities = map[string]int{
+	"emergency": 0, "emerg": 0, "panic": 0,
+	"alert":    1,
+	"critical": 2, "crit": 2, "fatal": 2,
+	"error": 3, "err": 3,
+	"warning": 4, "warn": 4,
+	"notice":        5,
+	"informational": 6, "info": 6,
+	"debug": 7, "trace": 7,
+}
+
+// formatter renders events as syslog messages.
+type formatter struct {
+	format   format
+	facility int
+	severity int
+	hostname string
+	appName  string
+	message  *fmtstr.EventFormatString
+}
+
+// header holds the syslog header values extracted from an event.
+type header struct {
+	priority  int
+	timestamp time.Time
+	hostname  string
+	appName   string
+	procID    string
+	msgID     string
+	sd        string
+}
+
+// Format renders the event as a single syslog message without any framing.
+func (f *formatter) Format(event *beat.Event) ([]byte, error) {
+	msg, err := f.message.Run(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format message: %w", err)
+	}
+
+	h := f.header(event)
+	var b strings.Builder
+	switch f.format {
+	case formatRFC3164:
+		b.WriteString("<")
+		b.WriteString(strconv.Itoa(h.priority))
+		b.WriteString(">")
+		b.WriteString(h.timestamp.Format(rfc3164TimeLayout))
+		b.WriteString(" ")
+		b.WriteString(orNil(h.hostname))
+		b.WriteString(" ")
+		tag := truncate(h.appName, maxTagLen)
+		if tag == "" {
+			tag = "beat"
+		}
+		b.WriteString(tag)
+		if h.procID != "" {
+			b.WriteString("[")
+			b.WriteString(h.procID)
+			b.WriteString("]")
+		}
+		b.WriteString(": ")
+		b.WriteString(msg)
+	default:
+		b.WriteString("<")
+		b.WriteString(strconv.Itoa(h.priority))
+		b.WriteString(">1 ")
+		b.WriteString(h.timestamp.Format(rfc5424TimeLayout))
+		b.WriteString(" ")
+		b.WriteString(orNil(truncate(h.hostname, maxHostnameLen)))
+		b.WriteString(" ")
+		b.WriteString(orNil(truncate(h.appName, maxAppNameLen)))
+		b.WriteString(" ")
+		b.WriteString(orNil(truncate(h.procID, maxProcIDLen)))
+		b.WriteString(" ")
+		b.WriteString(orNil(truncate(h.msgID, maxMsgIDLen)))
+		b.WriteString(" ")
+		b.WriteString(orNil(h.sd))
+		if msg != "" {
+			b.WriteString(" ")
+			b.WriteString(msg)
+		}
+	}
+	return []byte(b.String()), nil
+}
+
+// header extracts the syslog header values from the event. Values present in
+// log.syslog.* take precedence over the ECS host and process fields, which in
+// turn take precedence over the configured defaults.
+func (f *formatter) header(event *beat.Event) header {
+	h := header{
+		timestamp: event.Timestamp,
+		hostname:  f.hostname,
+		appName:   f.appName,
+	}
+	if h.timestamp.IsZero() {
+		h.timestamp = time.Now()
+	}
+
+	fields := event.Fields
+	facility, severity := f.facility, f.severity
+	if v, ok := intField(fields, "log.syslog.facility.code"); ok && v >= 0 && v <= 23 {
+		facility = v
+	}
+	if v, ok := intField(fields, "log.syslog.severity.code"); ok && v >= 0 && v <= 7 {
+		severity = v
+	} else if level := stringField(fields, "log.level"); level != "" {
+		if v, ok := severities[strings.ToLower(level)]; ok {
+			severity = v
+		}
+	}
+	h.priority = facility*8 + severity
+	if v, ok := intField(fields, "log.syslog.priority"); ok && v >= 0 && v <= 191 {
+		h.priority = v
+	}
+
+	h.hostname = firstString(fields, h.hostname, "log.syslog.hostname", "host.hostname", "host.name")
+	h.appName = firstString(fields, h.appName, "log.syslog.appname", "process.name")
+	h.procID = firstString(fields, "", "log.syslog.procid", "process.pid")
+	h.msgID = firstString(fields, "", "log.syslog.msgid")
+	if f.format == formatRFC5424 {
+		if sd, err := fields.GetValue("log.syslog.structured_data"); err == nil {
+			h.sd = formatStructuredData(sd)
+		}
+	}
+	return h
+}
+
+// formatStructuredData renders the RFC 5424 STRUCTURED-DATA from a
+// map of SD-IDs to their parameters, the same layout produced by
+// the syslog parser. Elements and parameters are sorted to produce
+// stable output.
+func formatStructuredData(v interface{}) string {
+	elements, ok := toMap(v)
+	if !ok || len(elements) == 0 {
+		return ""
+	}
+
+	ids := make([]string, 0, len(elements))
+	for id := range elements {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var b strings.Builder
+	for _, id := range ids {
+		b.WriteString("[")
+		b.WriteString(id)
+		params, _ := toMap(elements[id])
+		names := make([]string, 0, len(params))
+		for name := range params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			b.WriteString(" ")
+			b.WriteString(name)
+			b.WriteString(`="`)
+			b.WriteString(escapeParamValue(fmt.Sprint(params[name])))
+			b.WriteString(`"`)
+		}
+		b.WriteString("]")
+	}
+	return b.String()
+}
+
+var paramValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+func escapeParamValue(s string) string {
+	return paramValueEscaper.Replace(s)
+}
+
+func toMap(v interface{}) (map[string]interface{}, bool) {
+	switch m := v.(type) {
+	case mapstr.M:
+		return m, true
+	case map[string]interface{}:
+		return m, true
+	case map[string]string:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[k] = v
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+func intField(fields mapstr.M, key string) (int, bool) {
+	v, err := fields.GetValue(key)
+	if err != nil {
+		return 0, false
+	}
+	switch n := v.(type) {
+	case int:
+		return n, true
+	case int8:
+		return int(n), true
+	case int16:
+		return int(n), true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case uint8:
+		return int(n), true
+	case uint16:
+		return int(n), true
+	case uint32:
+		return int(n), true
+	case uint64:
+		return int(n), true
+	case float64:
+		return int(n), true
+	case string:
+		i, err := strconv.Atoi(n)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func stringField(fields mapstr.M, key string) string {
+	v, err := fields.GetValue(key)
+	if err != nil || v == nil {
+		return ""
+	}
+	if s, ok := v.(string); ok {
+		return s
+	}
+	return fmt.Sprint(v)
+}
+
+func firstString(fields mapstr.M, def string, keys ...string) string {
+	for _, key := range keys {
+		if s := stringField(fields, key); s != "" {
+			return s
+		}
+	}
+	return def
+}
+
+// truncate shortens s to at most n bytes and replaces whitespace, which is
+// not allowed in syslog header fields.
+func truncate(s string, n int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 127 {
+			return '_'
+		}
+		return r
+	}, s)
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+func orNil(s string) string {
+	if s == "" {
+		return nilValue
+	}
+	return s
+}
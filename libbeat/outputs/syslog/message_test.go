@@ -0,0 +1,172 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package syslog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common/fmtstr"
+	syslogreader "github.com/elastic/beats/v7/libbeat/reader/syslog"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func testFormatter(f format) *formatter {
+	return &formatter{
+		format:   f,
+		facility: 1,
+		severity: 6,
+		hostname: "default-host",
+		appName:  "testbeat",
+		message:  fmtstr.MustCompileEvent("%{[message]}"),
+	}
+}
+
+func TestFormatRFC5424(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 123456000, time.UTC)
+	tests := map[string]struct {
+		fields mapstr.M
+		want   string
+	}{
+		"defaults": {
+			fields: mapstr.M{"message": "hello world"},
+			want:   "<14>1 2024-03-01T12:30:45.123456Z default-host testbeat - - - hello world",
+		},
+		"ecs fields": {
+			fields: mapstr.M{
+				"message": "hello",
+				"host":    mapstr.M{"name": "web-1"},
+				"process": mapstr.M{"name": "nginx", "pid": 42},
+				"log":     mapstr.M{"level": "error"},
+			},
+			want: "<11>1 2024-03-01T12:30:45.123456Z web-1 nginx 42 - - hello",
+		},
+		"syslog fields take precedence": {
+			fields: mapstr.M{
+				"message": "hello",
+				"host":    mapstr.M{"name": "web-1"},
+				"log": mapstr.M{"syslog": mapstr.M{
+					"priority": 165,
+					"hostname": "mymachine.example.com",
+					"appname":  "evntslog",
+					"msgid":    "ID47",
+					"structured_data": map[string]interface{}{
+						"exampleSDID@32473": map[string]interface{}{
+							"iut":         "3",
+							"eventSource": `App"lication`,
+						},
+					},
+				}},
+			},
+			want: `<165>1 2024-03-01T12:30:45.123456Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 eventSource="App\"lication" iut="3"] hello`,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			out, err := testFormatter(formatRFC5424).Format(&beat.Event{Timestamp: ts, Fields: test.fields})
+			require.NoError(t, err)
+			assert.Equal(t, test.want, string(out))
+		})
+	}
+}
+
+func TestFormatRFC3164(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 2, 3, 4, 0, time.UTC)
+	out, err := testFormatter(formatRFC3164).Format(&beat.Event{
+		Timestamp: ts,
+		Fields: mapstr.M{
+			"message": "connection closed",
+			"process": mapstr.M{"name": "sshd", "pid": 1234},
+			"log":     mapstr.M{"syslog": mapstr.M{"facility": mapstr.M{"code": 4}, "severity": mapstr.M{"code": 5}}},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "<37>Mar  1 02:03:04 default-host sshd[1234]: connection closed", string(out))
+}
+
+func TestRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 45, 0, time.UTC)
+	event := beat.Event{
+		Timestamp: ts,
+		Fields: mapstr.M{
+			"message": "disk almost full",
+			"host":    mapstr.M{"name": "db-2"},
+			"process": mapstr.M{"name": "monitor", "pid": "77"},
+			"log": mapstr.M{"syslog": mapstr.M{
+				"facility": mapstr.M{"code": 16},
+				"severity": mapstr.M{"code": 4},
+				"msgid":    "DISK",
+				"structured_data": map[string]interface{}{
+					"disk@1": map[string]interface{}{"used": "95"},
+				},
+			}},
+		},
+	}
+
+	t.Run("rfc5424", func(t *testing.T) {
+		out, err := testFormatter(formatRFC5424).Format(&event)
+		require.NoError(t, err)
+
+		fields, parsedTS, err := syslogreader.ParseMessage(string(out), syslogreader.FormatRFC5424, time.UTC)
+		require.NoError(t, err)
+		assert.True(t, ts.Equal(parsedTS))
+		assert.Equal(t, mapstr.M{
+			"message": "disk almost full",
+			"log": mapstr.M{"syslog": mapstr.M{
+				"priority": 132,
+				"facility": mapstr.M{"code": 16, "name": "local0"},
+				"severity": mapstr.M{"code": 4, "name": "Warning"},
+				"hostname": "db-2",
+				"appname":  "monitor",
+				"procid":   "77",
+				"msgid":    "DISK",
+				"version":  "1",
+				"structured_data": map[string]interface{}{
+					"disk@1": map[string]interface{}{"used": "95"},
+				},
+			}},
+		}, fields)
+	})
+
+	t.Run("rfc3164", func(t *testing.T) {
+		out, err := testFormatter(formatRFC3164).Format(&event)
+		require.NoError(t, err)
+
+		fields, parsedTS, err := syslogreader.ParseMessage(string(out), syslogreader.FormatRFC3164, time.UTC)
+		require.NoError(t, err)
+		assert.Equal(t, ts.Format(time.Stamp), parsedTS.Format(time.Stamp))
+		assert.Equal(t, mapstr.M{
+			"message": "disk almost full",
+			"log": mapstr.M{"syslog": mapstr.M{
+				"priority": 132,
+				"facility": mapstr.M{"code": 16, "name": "local0"},
+				"severity": mapstr.M{"code": 4, "name": "Warning"},
+				"hostname": "db-2",
+				"appname":  "monitor",
+				"procid":   "77",
+			}},
+		}, fields)
+	})
+}
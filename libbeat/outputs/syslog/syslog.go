@@ -0,0 +1,95 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package syslog
+
+import (
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/outputs"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/transport"
+	"github.com/elastic/elastic-agent-libs/transport/tlscommon"
+)
+
+const (
+	defaultPort    = 514
+	defaultTLSPort = 6514
+)
+
+func init() {
+	outputs.RegisterType("syslog", makeSyslog)
+}
+
+func makeSyslog(
+	_ outputs.IndexManager,
+	beat beat.Info,
+	observer outputs.Observer,
+	cfg *config.C,
+) (outputs.Group, error) {
+	sConfig := defaultConfig()
+	if err := cfg.Unpack(&sConfig); err != nil {
+		return outputs.Fail(err)
+	}
+
+	hosts, err := outputs.ReadHostList(cfg)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	tls, err := tlscommon.LoadTLSConfig(sConfig.TLS)
+	if err != nil {
+		return outputs.Fail(err)
+	}
+
+	port := defaultPort
+	if tls != nil {
+		port = defaultTLSPort
+	}
+
+	hostname := sConfig.Hostname
+	if hostname == "" {
+		hostname = beat.Hostname
+	}
+	appName := sConfig.AppName
+	if appName == "" {
+		appName = beat.Beat
+	}
+
+	clients := make([]outputs.NetworkClient, len(hosts))
+	for i, host := range hosts {
+		conn, err := transport.NewClient(transport.Config{
+			Timeout: sConfig.Timeout,
+			TLS:     tls,
+			Stats:   observer,
+		}, sConfig.Network, host, port)
+		if err != nil {
+			return outputs.Fail(err)
+		}
+
+		client := newClient(conn, observer, sConfig.Timeout, sConfig.isStream(), sConfig.Framing, &formatter{
+			format:   sConfig.Format,
+			facility: int(sConfig.Facility),
+			severity: int(sConfig.Severity),
+			hostname: hostname,
+			appName:  appName,
+			message:  sConfig.Message,
+		})
+		clients[i] = outputs.WithBackoff(client, sConfig.Backoff.Init, sConfig.Backoff.Max)
+	}
+
+	return outputs.SuccessNet(sConfig.Queue, sConfig.LoadBalance, sConfig.BulkMaxSize, sConfig.MaxRetries, nil, clients)
+}
@@ -90,10 +90,17 @@ func (p *fingerprint) String() string {
 }
 
 func (p *fingerprint) writeFields(to io.Writer, event *beat.Event) error {
-	for _, k := range p.fields {
+	return WriteFields(to, event, p.fields, p.config.IgnoreMissing)
+}
+
+// WriteFields writes the given fields of the event to the writer, in the
+// format hashed by the fingerprint processor. The fields must be sorted to
+// get the same output for the same set of fields.
+func WriteFields(to io.Writer, event *beat.Event, fields []string, ignoreMissing bool) error {
+	for _, k := range fields {
 		v, err := event.GetValue(k)
 		if err != nil {
-			if p.config.IgnoreMissing {
+			if ignoreMissing {
 				continue
 			}
 			return makeErrMissingField(k, err)
@@ -0,0 +1,158 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/checks"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+	"github.com/elastic/beats/v7/libbeat/reader/grok"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "grok"
+
+type config struct {
+	Field              string            `config:"field"`
+	Patterns           []string          `config:"patterns" validate:"required"`
+	PatternDefinitions map[string]string `config:"pattern_definitions"`
+	TargetPrefix       string            `config:"target_prefix"`
+	IgnoreMissing      bool              `config:"ignore_missing"`
+	IgnoreFailure      bool              `config:"ignore_failure"`
+	OverwriteKeys      bool              `config:"overwrite_keys"`
+	TagOnFailure       []string          `config:"tag_on_failure"`
+}
+
+func defaultConfig() config {
+	return config{
+		Field:        "message",
+		TagOnFailure: []string{grok.FlagParsingError},
+	}
+}
+
+type processor struct {
+	config config
+	grok   *grok.Grok
+}
+
+func init() {
+	processors.RegisterPlugin(procName,
+		checks.ConfigChecked(NewProcessor,
+			checks.RequireFields("patterns"),
+			checks.AllowedFields(
+				"field",
+				"patterns",
+				"pattern_definitions",
+				"target_prefix",
+				"ignore_missing",
+				"ignore_failure",
+				"overwrite_keys",
+				"tag_on_failure",
+				"when",
+			),
+		),
+	)
+	jsprocessor.RegisterPlugin("Grok", NewProcessor)
+}
+
+// NewProcessor constructs a new grok processor.
+func NewProcessor(c *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %s processor configuration: %w", procName, err)
+	}
+
+	g, err := grok.New(config.Patterns, config.PatternDefinitions)
+	if err != nil {
+		return nil, err
+	}
+
+	return &processor{config: config, grok: g}, nil
+}
+
+// Run matches the configured field against the patterns and adds the captures to the event.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.config.Field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return event, nil
+		}
+		return p.failure(event, err)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return p.failure(event, fmt.Errorf("field is not a string, value: `%v`, field: `%s`", v, p.config.Field))
+	}
+
+	m, err := p.grok.Match(s)
+	if err != nil {
+		return p.failure(event, err)
+	}
+
+	backup := event.Clone()
+	event, err = p.mapper(event, m)
+	if err != nil {
+		return backup, err
+	}
+
+	return event, nil
+}
+
+// failure flags the event as not parsed and returns err unless failures are ignored.
+func (p *processor) failure(event *beat.Event, err error) (*beat.Event, error) {
+	if len(p.config.TagOnFailure) > 0 {
+		if err := mapstr.AddTagsWithKey(event.Fields, beat.FlagField, p.config.TagOnFailure); err != nil {
+			return event, fmt.Errorf("cannot add new flag the event: %w", err)
+		}
+	}
+	if p.config.IgnoreFailure {
+		return event, nil
+	}
+	return event, err
+}
+
+func (p *processor) mapper(event *beat.Event, m mapstr.M) (*beat.Event, error) {
+	if p.config.TargetPrefix != "" {
+		m = mapstr.M{p.config.TargetPrefix: m}
+	}
+	for k, v := range m.Flatten() {
+		if _, err := event.GetValue(k); errors.Is(err, mapstr.ErrKeyNotFound) || p.config.OverwriteKeys {
+			_, _ = event.PutValue(k, v)
+		} else {
+			// When the target key exists but is a string instead of a map.
+			if err != nil {
+				return event, fmt.Errorf("cannot override existing key with `%s`: %w", k, err)
+			}
+			return event, fmt.Errorf("cannot override existing key with `%s`", k)
+		}
+	}
+
+	return event, nil
+}
+
+func (p *processor) String() string {
+	return procName + "=[" + p.grok.String() +
+		"],field=" + p.config.Field +
+		",target_prefix=" + p.config.TargetPrefix
+}
@@ -0,0 +1,174 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestProcessor(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		fields mapstr.M
+		want   mapstr.M
+		err    string
+	}{
+		"default field": {
+			config: map[string]interface{}{
+				"patterns": []string{`%{IP:source.ip} %{WORD:http.request.method} %{NUMBER:http.response.bytes:long}`},
+			},
+			fields: mapstr.M{"message": "10.0.0.1 GET 512"},
+			want: mapstr.M{
+				"message": "10.0.0.1 GET 512",
+				"source":  mapstr.M{"ip": "10.0.0.1"},
+				"http":    mapstr.M{"request": mapstr.M{"method": "GET"}, "response": mapstr.M{"bytes": int64(512)}},
+			},
+		},
+		"specific field and target prefix": {
+			config: map[string]interface{}{
+				"field":         "event.original",
+				"patterns":      []string{`%{WORD:level} %{GREEDYDATA:text}`},
+				"target_prefix": "grok.parsed",
+			},
+			fields: mapstr.M{"event": mapstr.M{"original": "INFO started"}},
+			want: mapstr.M{
+				"event": mapstr.M{"original": "INFO started"},
+				"grok":  mapstr.M{"parsed": mapstr.M{"level": "INFO", "text": "started"}},
+			},
+		},
+		"multiple patterns with custom definitions": {
+			config: map[string]interface{}{
+				"patterns": []string{
+					`^%{REQUEST_ID:request.id} %{GREEDYDATA:text}$`,
+					`^%{GREEDYDATA:text}$`,
+				},
+				"pattern_definitions": map[string]interface{}{
+					"REQUEST_ID": `req-[0-9]+`,
+				},
+			},
+			fields: mapstr.M{"message": "no request id"},
+			want:   mapstr.M{"message": "no request id", "text": "no request id"},
+		},
+		"overwrite keys": {
+			config: map[string]interface{}{
+				"patterns":       []string{`^%{WORD:level} %{GREEDYDATA:message}$`},
+				"overwrite_keys": true,
+			},
+			fields: mapstr.M{"message": "WARN low disk"},
+			want:   mapstr.M{"message": "low disk", "level": "WARN"},
+		},
+		"existing key": {
+			config: map[string]interface{}{
+				"patterns": []string{`^%{WORD:level} %{GREEDYDATA:message}$`},
+			},
+			fields: mapstr.M{"message": "WARN low disk"},
+			want:   mapstr.M{"message": "WARN low disk"},
+			err:    "cannot override existing key with `message`",
+		},
+		"no match": {
+			config: map[string]interface{}{
+				"patterns": []string{`^%{NUMBER:n}$`},
+			},
+			fields: mapstr.M{"message": "abc"},
+			want:   mapstr.M{"message": "abc", "log": mapstr.M{"flags": []string{"grok_parsing_error"}}},
+			err:    "text does not match any grok pattern",
+		},
+		"no match with ignore failure and custom tags": {
+			config: map[string]interface{}{
+				"patterns":       []string{`^%{NUMBER:n}$`},
+				"ignore_failure": true,
+				"tag_on_failure": []string{"not_a_number"},
+			},
+			fields: mapstr.M{"message": "abc"},
+			want:   mapstr.M{"message": "abc", "log": mapstr.M{"flags": []string{"not_a_number"}}},
+		},
+		"missing field": {
+			config: map[string]interface{}{
+				"patterns": []string{`%{NUMBER:n}`},
+			},
+			fields: mapstr.M{},
+			want:   mapstr.M{"log": mapstr.M{"flags": []string{"grok_parsing_error"}}},
+			err:    "key not found",
+		},
+		"ignore missing field": {
+			config: map[string]interface{}{
+				"patterns":       []string{`%{NUMBER:n}`},
+				"ignore_missing": true,
+			},
+			fields: mapstr.M{},
+			want:   mapstr.M{},
+		},
+		"field is not a string": {
+			config: map[string]interface{}{
+				"patterns": []string{`%{NUMBER:n}`},
+			},
+			fields: mapstr.M{"message": 42},
+			want:   mapstr.M{"message": 42, "log": mapstr.M{"flags": []string{"grok_parsing_error"}}},
+			err:    "field is not a string",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewProcessor(conf.MustNewConfigFrom(tc.config))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, event.Fields)
+		})
+	}
+}
+
+func TestProcessorConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"missing patterns": {
+			config: map[string]interface{}{"field": "message"},
+			err:    "missing required field",
+		},
+		"undefined pattern": {
+			config: map[string]interface{}{"patterns": []string{`%{UNDEFINED:x}`}},
+			err:    `pattern "UNDEFINED" is not defined`,
+		},
+		"invalid type": {
+			config: map[string]interface{}{"patterns": []string{`%{INT:x:integer}`}},
+			err:    `unsupported type "integer"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewProcessor(conf.MustNewConfigFrom(tc.config))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/checks"
+	jsprocessor "github.com/elastic/beats/v7/libbeat/processors/script/javascript/module/processor"
+	"github.com/elastic/beats/v7/libbeat/reader/kv"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const procName = "kv"
+
+type config struct {
+	kv.Config     `config:",inline"`
+	Field         string   `config:"field"`
+	TargetField   string   `config:"target_field"`
+	IgnoreMissing bool     `config:"ignore_missing"`
+	IgnoreFailure bool     `config:"ignore_failure"`
+	OverwriteKeys bool     `config:"overwrite_keys"`
+	TagOnFailure  []string `config:"tag_on_failure"`
+}
+
+func defaultConfig() config {
+	return config{
+		Config:       kv.DefaultConfig(),
+		Field:        "message",
+		TagOnFailure: []string{kv.FlagParsingError},
+	}
+}
+
+type processor struct {
+	config  config
+	decoder *kv.Decoder
+}
+
+func init() {
+	processors.RegisterPlugin(procName,
+		checks.ConfigChecked(NewProcessor,
+			checks.AllowedFields(
+				"field",
+				"target_field",
+				"field_split",
+				"value_split",
+				"prefix",
+				"include_keys",
+				"exclude_keys",
+				"convert",
+				"ignore_missing",
+				"ignore_failure",
+				"overwrite_keys",
+				"tag_on_failure",
+				"when",
+			),
+		),
+	)
+	jsprocessor.RegisterPlugin("KV", NewProcessor)
+}
+
+// NewProcessor constructs a new kv processor.
+func NewProcessor(c *conf.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := c.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("fail to unpack the %s processor configuration: %w", procName, err)
+	}
+
+	d, err := kv.NewDecoder(config.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &processor{config: config, decoder: d}, nil
+}
+
+// Run decodes the key-value pairs of the configured field and adds them to the event.
+func (p *processor) Run(event *beat.Event) (*beat.Event, error) {
+	v, err := event.GetValue(p.config.Field)
+	if err != nil {
+		if p.config.IgnoreMissing && errors.Is(err, mapstr.ErrKeyNotFound) {
+			return event, nil
+		}
+		return p.failure(event, err)
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return p.failure(event, fmt.Errorf("field is not a string, value: `%v`, field: `%s`", v, p.config.Field))
+	}
+
+	m, err := p.decoder.Decode(s)
+	if err != nil {
+		return p.failure(event, err)
+	}
+
+	backup := event.Clone()
+	event, err = p.mapper(event, m)
+	if err != nil {
+		return p.failure(backup, err)
+	}
+
+	return event, nil
+}
+
+// failure flags the event as not parsed and returns err unless failures are ignored.
+func (p *processor) failure(event *beat.Event, err error) (*beat.Event, error) {
+	if len(p.config.TagOnFailure) > 0 {
+		if err := mapstr.AddTagsWithKey(event.Fields, beat.FlagField, p.config.TagOnFailure); err != nil {
+			return event, fmt.Errorf("cannot add new flag the event: %w", err)
+		}
+	}
+	if p.config.IgnoreFailure {
+		return event, nil
+	}
+	return event, err
+}
+
+func (p *processor) mapper(event *beat.Event, m mapstr.M) (*beat.Event, error) {
+	if p.config.TargetField != "" {
+		target := mapstr.M{}
+		_, _ = target.Put(p.config.TargetField, m)
+		m = target
+	}
+	for k, v := range m.Flatten() {
+		if _, err := event.GetValue(k); errors.Is(err, mapstr.ErrKeyNotFound) || p.config.OverwriteKeys {
+			_, _ = event.PutValue(k, v)
+		} else {
+			// When the target key exists but is a string instead of a map.
+			if err != nil {
+				return event, fmt.Errorf("cannot override existing key with `%s`: %w", k, err)
+			}
+			return event, fmt.Errorf("cannot override existing key with `%s`", k)
+		}
+	}
+
+	return event, nil
+}
+
+func (p *processor) String() string {
+	return fmt.Sprintf("%s=[field=%s,target_field=%s,field_split=%q,value_split=%q]",
+		procName, p.config.Field, p.config.TargetField, p.config.FieldSplit, p.config.ValueSplit)
+}
@@ -0,0 +1,173 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestProcessor(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		fields mapstr.M
+		want   mapstr.M
+		err    string
+	}{
+		"default field": {
+			config: map[string]interface{}{},
+			fields: mapstr.M{"message": `source.ip=10.0.0.1 http.request.method=GET msg="request done"`},
+			want: mapstr.M{
+				"message": `source.ip=10.0.0.1 http.request.method=GET msg="request done"`,
+				"source":  mapstr.M{"ip": "10.0.0.1"},
+				"http":    mapstr.M{"request": mapstr.M{"method": "GET"}},
+				"msg":     "request done",
+			},
+		},
+		"specific field, target field and prefix": {
+			config: map[string]interface{}{
+				"field":        "event.original",
+				"target_field": "kv.parsed",
+				"prefix":       "arg_",
+				"field_split":  "&",
+			},
+			fields: mapstr.M{"event": mapstr.M{"original": "a=1&b=2"}},
+			want: mapstr.M{
+				"event": mapstr.M{"original": "a=1&b=2"},
+				"kv":    mapstr.M{"parsed": mapstr.M{"arg_a": "1", "arg_b": "2"}},
+			},
+		},
+		"include keys and convert": {
+			config: map[string]interface{}{
+				"include_keys": []string{"status", "size"},
+				"convert": []map[string]interface{}{
+					{"key": "status", "type": "integer"},
+					{"key": "size", "type": "long"},
+				},
+				"target_field": "http",
+			},
+			fields: mapstr.M{"message": "status=200 size=512 path=/"},
+			want: mapstr.M{
+				"message": "status=200 size=512 path=/",
+				"http":    mapstr.M{"status": int32(200), "size": int64(512)},
+			},
+		},
+		"overwrite keys": {
+			config: map[string]interface{}{
+				"overwrite_keys": true,
+			},
+			fields: mapstr.M{"message": "level=warn message='low disk'"},
+			want:   mapstr.M{"message": "low disk", "level": "warn"},
+		},
+		"existing key": {
+			config: map[string]interface{}{},
+			fields: mapstr.M{"message": "level=warn message='low disk'"},
+			want: mapstr.M{
+				"message": "level=warn message='low disk'",
+				"log":     mapstr.M{"flags": []string{"kv_parsing_error"}},
+			},
+			err: "cannot override existing key with `message`",
+		},
+		"existing key with ignore failure": {
+			config: map[string]interface{}{"ignore_failure": true},
+			fields: mapstr.M{"message": "level=warn message='low disk'"},
+			want: mapstr.M{
+				"message": "level=warn message='low disk'",
+				"log":     mapstr.M{"flags": []string{"kv_parsing_error"}},
+			},
+		},
+		"no pairs": {
+			config: map[string]interface{}{},
+			fields: mapstr.M{"message": "abc"},
+			want:   mapstr.M{"message": "abc", "log": mapstr.M{"flags": []string{"kv_parsing_error"}}},
+			err:    "text does not contain any key-value pair",
+		},
+		"conversion error with ignore failure and custom tags": {
+			config: map[string]interface{}{
+				"convert":        []map[string]interface{}{{"key": "n", "type": "long"}},
+				"ignore_failure": true,
+				"tag_on_failure": []string{"bad_number"},
+			},
+			fields: mapstr.M{"message": "n=abc"},
+			want:   mapstr.M{"message": "n=abc", "log": mapstr.M{"flags": []string{"bad_number"}}},
+		},
+		"missing field": {
+			config: map[string]interface{}{},
+			fields: mapstr.M{},
+			want:   mapstr.M{"log": mapstr.M{"flags": []string{"kv_parsing_error"}}},
+			err:    "key not found",
+		},
+		"ignore missing field": {
+			config: map[string]interface{}{"ignore_missing": true},
+			fields: mapstr.M{},
+			want:   mapstr.M{},
+		},
+		"field is not a string": {
+			config: map[string]interface{}{},
+			fields: mapstr.M{"message": 42},
+			want:   mapstr.M{"message": 42, "log": mapstr.M{"flags": []string{"kv_parsing_error"}}},
+			err:    "field is not a string",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, err := NewProcessor(conf.MustNewConfigFrom(tc.config))
+			require.NoError(t, err)
+
+			event, err := p.Run(&beat.Event{Fields: tc.fields})
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tc.want, event.Fields)
+		})
+	}
+}
+
+func TestProcessorConfigErrors(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"same separators": {
+			config: map[string]interface{}{"field_split": ":", "value_split": ":"},
+			err:    "field_split and value_split must be different",
+		},
+		"invalid type": {
+			config: map[string]interface{}{
+				"convert": []map[string]interface{}{{"key": "a", "type": "int"}},
+			},
+			err: `unsupported type "int"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := NewProcessor(conf.MustNewConfigFrom(tc.config))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
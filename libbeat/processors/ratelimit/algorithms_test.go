@@ -0,0 +1,170 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+)
+
+type clockedAlgorithm interface {
+	algorithm
+	setClock(clockwork.Clock)
+}
+
+func newTestAlgorithm(t *testing.T, name, limit string, settings map[string]interface{}) (clockedAlgorithm, clockwork.FakeClock) {
+	t.Helper()
+	var r rate
+	require.NoError(t, r.Unpack(limit))
+	algo, err := factory(name, algoConfig{limit: r, config: *conf.MustNewConfigFrom(settings)})
+	require.NoError(t, err)
+
+	// start in the middle of a minute, so fixed windows don't line up with
+	// the start of the test
+	clock := clockwork.NewFakeClockAt(time.Date(2024, 5, 1, 10, 0, 30, 0, time.UTC))
+	a := algo.(clockedAlgorithm)
+	a.setClock(clock)
+	return a, clock
+}
+
+func countAllowed(a algorithm, key uint64, n int) int {
+	allowed := 0
+	for i := 0; i < n; i++ {
+		if a.IsAllowed(key) {
+			allowed++
+		}
+	}
+	return allowed
+}
+
+func TestSlidingWindowLog(t *testing.T) {
+	a, clock := newTestAlgorithm(t, "sliding_window_log", "10/m", nil)
+
+	assert.Equal(t, 10, countAllowed(a, 1, 20))
+	assert.Equal(t, 10, countAllowed(a, 2, 20), "keys are limited separately")
+
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, 0, countAllowed(a, 1, 5))
+	assert.Equal(t, 5, countAllowed(a, 3, 5))
+
+	// the first 10 events leave the window, the 5 events of key 3 don't
+	clock.Advance(31 * time.Second)
+	assert.Equal(t, 10, countAllowed(a, 1, 20))
+	assert.Equal(t, 5, countAllowed(a, 3, 20))
+}
+
+func TestSlidingWindowCounter(t *testing.T) {
+	a, clock := newTestAlgorithm(t, "sliding_window_counter", "10/m", nil)
+
+	// the first window is 10:00:00 - 10:01:00
+	assert.Equal(t, 10, countAllowed(a, 1, 20))
+
+	// at 10:01:15 the previous window still covers 75% of the sliding window
+	clock.Advance(45 * time.Second)
+	assert.Equal(t, 2, countAllowed(a, 1, 20))
+
+	// at 10:01:45 25% of the previous window are left
+	clock.Advance(30 * time.Second)
+	assert.Equal(t, 5, countAllowed(a, 1, 20))
+
+	// after two windows without events the counters start from scratch
+	clock.Advance(3 * time.Minute)
+	assert.Equal(t, 10, countAllowed(a, 1, 20))
+}
+
+func TestSlidingWindowFractionalLimit(t *testing.T) {
+	for _, name := range []string{"sliding_window_log", "sliding_window_counter"} {
+		t.Run(name, func(t *testing.T) {
+			// 0.5/s allows 1 event in any 2 seconds
+			a, clock := newTestAlgorithm(t, name, "0.5/s", nil)
+			assert.Equal(t, 1, countAllowed(a, 1, 5))
+			clock.Advance(time.Second)
+			assert.Equal(t, 0, countAllowed(a, 1, 5))
+			clock.Advance(3 * time.Second)
+			assert.Equal(t, 1, countAllowed(a, 1, 5))
+
+			var r rate
+			require.NoError(t, r.Unpack("0/s"))
+			_, err := factory(name, algoConfig{limit: r, config: *conf.NewConfig()})
+			assert.ErrorContains(t, err, "requires a positive limit")
+		})
+	}
+}
+
+func TestGCRA(t *testing.T) {
+	t.Run("no burst", func(t *testing.T) {
+		a, clock := newTestAlgorithm(t, "gcra", "2/s", nil)
+
+		assert.Equal(t, 1, countAllowed(a, 1, 5))
+		clock.Advance(250 * time.Millisecond)
+		assert.Equal(t, 0, countAllowed(a, 1, 5))
+		clock.Advance(250 * time.Millisecond)
+		assert.Equal(t, 1, countAllowed(a, 1, 5))
+
+		// the rate is not saved up while idle
+		clock.Advance(10 * time.Second)
+		assert.Equal(t, 1, countAllowed(a, 1, 5))
+	})
+
+	t.Run("with burst", func(t *testing.T) {
+		a, clock := newTestAlgorithm(t, "gcra", "10/s", map[string]interface{}{"burst": 5})
+
+		assert.Equal(t, 5, countAllowed(a, 1, 20))
+		clock.Advance(100 * time.Millisecond)
+		assert.Equal(t, 1, countAllowed(a, 1, 20))
+		clock.Advance(time.Second)
+		assert.Equal(t, 5, countAllowed(a, 1, 20))
+	})
+
+	t.Run("invalid burst", func(t *testing.T) {
+		var r rate
+		require.NoError(t, r.Unpack("1/s"))
+		_, err := factory("gcra", algoConfig{limit: r, config: *conf.MustNewConfigFrom(map[string]interface{}{"burst": 0})})
+		assert.Error(t, err)
+	})
+}
+
+func TestKeyStatesGC(t *testing.T) {
+	for _, name := range []string{"sliding_window_log", "sliding_window_counter", "gcra"} {
+		t.Run(name, func(t *testing.T) {
+			a, clock := newTestAlgorithm(t, name, "1/s", map[string]interface{}{"gc.num_calls": 3})
+			a.IsAllowed(1)
+			a.IsAllowed(2)
+
+			clock.Advance(5 * time.Second)
+			a.IsAllowed(3)
+
+			var n int
+			switch a := a.(type) {
+			case *slidingWindowLog:
+				n = a.states.len()
+			case *slidingWindowCounter:
+				n = a.states.len()
+			case *gcra:
+				n = a.states.len()
+			}
+			assert.Equal(t, 1, n, "idle keys must be removed")
+		})
+	}
+}
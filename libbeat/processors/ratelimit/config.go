@@ -18,16 +18,62 @@
 package ratelimit
 
 import (
+	"errors"
 	"fmt"
+	"time"
 
 	cfg "github.com/elastic/elastic-agent-libs/config"
 )
 
+const (
+	actionDrop = "drop"
+	actionTag  = "tag"
+)
+
 // config for rate limit processor.
 type config struct {
 	Limit     rate          `config:"limit" validate:"required"`
 	Fields    []string      `config:"fields"`
 	Algorithm cfg.Namespace `config:"algorithm"`
+
+	// Action is applied to events exceeding the rate limit.
+	Action string `config:"action"`
+	// Tag is added to events exceeding the rate limit if Action is tag.
+	Tag string `config:"tag"`
+
+	Summary summaryConfig `config:"summary"`
+}
+
+// summaryConfig configures the summary events reporting dropped events.
+type summaryConfig struct {
+	Enabled  bool          `config:"enabled"`
+	Interval time.Duration `config:"interval" validate:"positive"`
+}
+
+func defaultConfig() config {
+	return config{
+		Action: actionDrop,
+		Tag:    "rate_limited",
+		Summary: summaryConfig{
+			Interval: time.Minute,
+		},
+	}
+}
+
+func (c *config) Validate() error {
+	switch c.Action {
+	case actionDrop:
+	case actionTag:
+		if c.Tag == "" {
+			return errors.New("tag must be set when action is 'tag'")
+		}
+		if c.Summary.Enabled {
+			return errors.New("summary events are only supported when action is 'drop'")
+		}
+	default:
+		return fmt.Errorf("unsupported action '%v', must be one of '%v' or '%v'", c.Action, actionDrop, actionTag)
+	}
+	return nil
 }
 
 func (c *config) setDefaults() error {
@@ -0,0 +1,100 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+func init() {
+	register("gcra", newGCRA)
+}
+
+type gcraConfig struct {
+	// Burst is the number of events that can be allowed at once.
+	Burst int `config:"burst" validate:"min=1"`
+
+	GC stateGCConfig `config:"gc"`
+}
+
+// gcra implements the generic cell rate algorithm. Instead of counting
+// events it tracks the theoretical arrival time (TAT) of the next event per
+// key. Events are spaced evenly at the configured rate, with up to burst
+// events allowed at once.
+type gcra struct {
+	// interval is the emission interval between two events.
+	interval time.Duration
+	// tolerance is how far ahead of the TAT an event may arrive.
+	tolerance time.Duration
+
+	states *keyStates[time.Time]
+	clock  clockwork.Clock
+}
+
+func newGCRA(config algoConfig) (algorithm, error) {
+	cfg := gcraConfig{
+		Burst: 1,
+		GC: stateGCConfig{
+			NumCalls: 10000,
+		},
+	}
+	if err := config.config.Unpack(&cfg); err != nil {
+		return nil, fmt.Errorf("could not unpack gcra algorithm configuration: %w", err)
+	}
+
+	var interval time.Duration
+	if perSecond := config.limit.valuePerSecond(); perSecond > 0 {
+		interval = time.Duration(float64(time.Second) / perSecond)
+	}
+
+	return &gcra{
+		interval:  interval,
+		tolerance: interval * time.Duration(cfg.Burst-1),
+		states: newKeyStates(cfg.GC,
+			func(now time.Time) *time.Time { return &now },
+			func(tat *time.Time, now time.Time) bool { return !tat.After(now) },
+		),
+		clock: clockwork.NewRealClock(),
+	}, nil
+}
+
+func (g *gcra) IsAllowed(key uint64) bool {
+	if g.interval <= 0 {
+		return false
+	}
+
+	now := g.clock.Now()
+	return g.states.with(key, now, func(tat *time.Time) bool {
+		if now.Before(tat.Add(-g.tolerance)) {
+			return false
+		}
+		if tat.Before(now) {
+			*tat = now
+		}
+		*tat = tat.Add(g.interval)
+		return true
+	})
+}
+
+// setClock allows test code to inject a fake clock
+func (g *gcra) setClock(c clockwork.Clock) {
+	g.clock = c
+}
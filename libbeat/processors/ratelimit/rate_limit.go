@@ -47,11 +47,13 @@ func init() {
 
 type metrics struct {
 	Dropped *monitoring.Int
+	Tagged  *monitoring.Int
 }
 
 type rateLimit struct {
 	config    config
 	algorithm algorithm
+	summary   *summarizer
 
 	logger  *logp.Logger
 	metrics metrics
@@ -59,7 +61,7 @@ type rateLimit struct {
 
 // new constructs a new rate limit processor.
 func new(cfg *c.C) (beat.Processor, error) {
-	var config config
+	config := defaultConfig()
 	if err := cfg.Unpack(&config); err != nil {
 		return nil, fmt.Errorf("could not unpack processor configuration: %w", err)
 	}
@@ -90,8 +92,12 @@ func new(cfg *c.C) (beat.Processor, error) {
 		logger:    log,
 		metrics: metrics{
 			Dropped: monitoring.NewInt(reg, "dropped"),
+			Tagged:  monitoring.NewInt(reg, "tagged"),
 		},
 	}
+	if config.Summary.Enabled {
+		p.summary = newSummarizer(config.Summary.Interval, config.Fields)
+	}
 
 	p.setClock(clockwork.NewRealClock())
 
@@ -99,7 +105,10 @@ func new(cfg *c.C) (beat.Processor, error) {
 }
 
 // Run applies the configured rate limit to the given event. If the event is within the
-// configured rate limit, it is returned as-is. If not, nil is returned.
+// configured rate limit, it is returned as-is. If not, the event is tagged or
+// dropped, depending on the configured action. With summaries enabled a dropped
+// event may be replaced by a summary event, and the summaries that are due are
+// attached to allowed events.
 func (p *rateLimit) Run(event *beat.Event) (*beat.Event, error) {
 	key, err := p.makeKey(event)
 	if err != nil {
@@ -107,18 +116,35 @@ func (p *rateLimit) Run(event *beat.Event) (*beat.Event, error) {
 	}
 
 	if p.algorithm.IsAllowed(key) {
+		if p.summary != nil {
+			p.summary.allow(event)
+		}
+		return event, nil
+	}
+
+	if p.config.Action == actionTag {
+		if event.Fields == nil {
+			event.Fields = mapstr.M{}
+		}
+		if err := mapstr.AddTags(event.Fields, []string{p.config.Tag}); err != nil {
+			return event, fmt.Errorf("could not tag rate limited event: %w", err)
+		}
+		p.metrics.Tagged.Inc()
 		return event, nil
 	}
 
 	p.logger.Debugf("event [%v] dropped by rate_limit processor", event)
 	p.metrics.Dropped.Inc()
+	if p.summary != nil {
+		return p.summary.drop(key, event), nil
+	}
 	return nil, nil
 }
 
 func (p *rateLimit) String() string {
 	return fmt.Sprintf(
-		"%v=[limit=[%v],fields=[%v],algorithm=[%v]]",
-		processorName, p.config.Limit, p.config.Fields, p.config.Algorithm.Name(),
+		"%v=[limit=[%v],fields=[%v],algorithm=[%v],action=[%v]]",
+		processorName, p.config.Limit, p.config.Fields, p.config.Algorithm.Name(), p.config.Action,
 	)
 }
 
@@ -148,6 +174,9 @@ func (p *rateLimit) makeKey(event *beat.Event) (uint64, error) {
 // setClock allows test code to inject a fake clock
 // TODO: remove this method and move tests that use it to algorithm level.
 func (p *rateLimit) setClock(c clockwork.Clock) {
+	if p.summary != nil {
+		p.summary.clock = c
+	}
 	if a, ok := p.algorithm.(interface{ setClock(clock clockwork.Clock) }); ok {
 		a.setClock(c)
 	}
@@ -18,10 +18,12 @@
 package ratelimit
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/jonboulle/clockwork"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/elastic/beats/v7/libbeat/beat"
@@ -46,6 +48,26 @@ func TestNew(t *testing.T) {
 			},
 			"rate limiting algorithm 'foobar' not implemented",
 		},
+		"sliding_window_log": {
+			mapstr.M{"limit": "10/m", "algorithm": mapstr.M{"sliding_window_log": mapstr.M{}}},
+			"",
+		},
+		"sliding_window_counter": {
+			mapstr.M{"limit": "10/m", "algorithm": mapstr.M{"sliding_window_counter": mapstr.M{}}},
+			"",
+		},
+		"gcra": {
+			mapstr.M{"limit": "10/s", "algorithm": mapstr.M{"gcra": mapstr.M{"burst": 5}}},
+			"",
+		},
+		"unknown_action": {
+			mapstr.M{"limit": "10/s", "action": "delay"},
+			"unsupported action 'delay'",
+		},
+		"summary_with_tag": {
+			mapstr.M{"limit": "10/s", "action": "tag", "summary.enabled": true},
+			"summary events are only supported when action is 'drop'",
+		},
 	}
 
 	for name, test := range cases {
@@ -176,3 +198,148 @@ func TestRateLimit(t *testing.T) {
 		})
 	}
 }
+
+func TestRateLimitTag(t *testing.T) {
+	p, err := new(conf.MustNewConfigFrom(mapstr.M{
+		"limit":  "2/m",
+		"action": "tag",
+	}))
+	require.NoError(t, err)
+	p.(*rateLimit).setClock(clockwork.NewFakeClock())
+
+	var tags []interface{}
+	for i := 0; i < 4; i++ {
+		o, err := p.Run(&beat.Event{Fields: mapstr.M{"event_number": i}})
+		require.NoError(t, err)
+		require.NotNil(t, o)
+		v, _ := o.GetValue("tags")
+		tags = append(tags, v)
+	}
+	assert.Equal(t, []interface{}{nil, nil, []string{"rate_limited"}, []string{"rate_limited"}}, tags)
+	assert.Equal(t, int64(2), p.(*rateLimit).metrics.Tagged.Get())
+}
+
+func TestRateLimitSummary(t *testing.T) {
+	p, err := new(conf.MustNewConfigFrom(mapstr.M{
+		"limit":            "1/s",
+		"fields":           []string{"host.name"},
+		"summary.enabled":  true,
+		"summary.interval": "10s",
+	}))
+	require.NoError(t, err)
+	fakeClock := clockwork.NewFakeClockAt(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	p.(*rateLimit).setClock(fakeClock)
+
+	run := func(host string) *beat.Event {
+		o, err := p.Run(&beat.Event{
+			Meta:   mapstr.M{"index": "logs"},
+			Fields: mapstr.M{"host": mapstr.M{"name": host}, "message": "line"},
+		})
+		require.NoError(t, err)
+		return o
+	}
+
+	// the first event of every second is allowed, the others are dropped
+	t0 := fakeClock.Now()
+	var summaries []interface{}
+	for i := 0; i < 12; i++ {
+		o := run("a")
+		require.NotNil(t, o)
+		if v, err := o.GetValue("rate_limit.summaries"); err == nil {
+			summaries = append(summaries, v)
+		}
+		for j := 0; j < 3; j++ {
+			assert.Nil(t, run("a"))
+		}
+		fakeClock.Advance(time.Second)
+	}
+	require.NotNil(t, run("b"))
+	assert.Nil(t, run("b"), "keys are summarized separately")
+
+	// the summary of key a is due after 10s and attached to the next
+	// allowed event
+	require.Len(t, summaries, 1)
+	assert.Equal(t, []mapstr.M{{
+		"key":     "host.name=a",
+		"dropped": 30,
+		"since":   t0,
+		"last":    t0.Add(9 * time.Second),
+	}}, summaries[0])
+	assert.Equal(t, int64(12*3+1), p.(*rateLimit).metrics.Dropped.Get())
+}
+
+func TestRateLimitSummaryEvent(t *testing.T) {
+	s := newSummarizer(10*time.Second, []string{"host.name"})
+	fakeClock := clockwork.NewFakeClockAt(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	s.clock = fakeClock
+	event := &beat.Event{
+		Meta:   mapstr.M{"index": "logs"},
+		Fields: mapstr.M{"host": mapstr.M{"name": "a"}, "message": "line"},
+	}
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, s.drop(1, event))
+		fakeClock.Advance(time.Second)
+	}
+	summary := s.drop(1, event)
+	require.NotNil(t, summary)
+	assert.Equal(t, mapstr.M{"index": "logs"}, summary.Meta)
+	assert.Equal(t, mapstr.M{
+		"host":    mapstr.M{"name": "a"},
+		"message": "11 events dropped for key host.name=a",
+		"rate_limit": mapstr.M{
+			"dropped": 11,
+			"since":   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			"last":    time.Date(2024, 5, 1, 10, 0, 10, 0, time.UTC),
+		},
+		"tags": []string{"rate_limit_summary"},
+	}, summary.Fields)
+	assert.Empty(t, s.dropped)
+}
+
+func TestRateLimitSummaryKeys(t *testing.T) {
+	s := newSummarizer(10*time.Second, []string{"host.name"})
+	fakeClock := clockwork.NewFakeClockAt(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+	s.clock = fakeClock
+	eventOf := func(host string) *beat.Event {
+		return &beat.Event{Fields: mapstr.M{"host": mapstr.M{"name": host}}}
+	}
+
+	reported := map[string]int{}
+	addSummary := func(e *beat.Event) {
+		if e == nil {
+			return
+		}
+		host, err := e.GetValue("host.name")
+		require.NoError(t, err)
+		n, err := e.GetValue("rate_limit.dropped")
+		require.NoError(t, err)
+		reported[host.(string)] += n.(int)
+	}
+
+	// key a drops continuously, key b drops in a burst and then stops,
+	// summaries of b are emitted in place of the dropped events of a
+	for i := 0; i < 25; i++ {
+		addSummary(s.drop(1, eventOf("a")))
+		if i < 3 {
+			addSummary(s.drop(2, eventOf("b")))
+		}
+		fakeClock.Advance(time.Second)
+	}
+	assert.Equal(t, 3, reported["b"], "the burst of key b is reported")
+	assert.Equal(t, 22, reported["a"])
+
+	// the remaining counts are attached to the next allowed event
+	fakeClock.Advance(10 * time.Second)
+	allowed := eventOf("c")
+	s.allow(allowed)
+	v, err := allowed.GetValue("rate_limit.summaries")
+	require.NoError(t, err)
+	summaries, ok := v.([]mapstr.M)
+	require.True(t, ok)
+	for _, summary := range summaries {
+		reported[strings.TrimPrefix(summary["key"].(string), "host.name=")] += summary["dropped"].(int)
+	}
+	assert.Equal(t, map[string]int{"a": 25, "b": 3}, reported, "no dropped event is lost")
+	assert.Empty(t, s.dropped)
+}
@@ -0,0 +1,189 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+)
+
+func init() {
+	register("sliding_window_log", newSlidingWindowLog)
+	register("sliding_window_counter", newSlidingWindowCounter)
+}
+
+type slidingWindowConfig struct {
+	GC stateGCConfig `config:"gc"`
+}
+
+// newSlidingWindow reads the window and the number of events allowed per
+// window from the configured rate, e.g. 100/m allows 100 events in any
+// minute. Fractional limits are rounded up and the window is scaled to keep
+// the rate, e.g. 0.5/s allows 1 event in any 2 seconds.
+func newSlidingWindow(name string, config algoConfig) (time.Duration, int, stateGCConfig, error) {
+	cfg := slidingWindowConfig{
+		GC: stateGCConfig{
+			NumCalls: 10000,
+		},
+	}
+	if err := config.config.Unpack(&cfg); err != nil {
+		return 0, 0, cfg.GC, fmt.Errorf("could not unpack %s algorithm configuration: %w", name, err)
+	}
+	if config.limit.value <= 0 {
+		return 0, 0, cfg.GC, fmt.Errorf("%s algorithm requires a positive limit", name)
+	}
+	limit := math.Ceil(config.limit.value)
+	window := time.Duration(float64(config.limit.window()) * limit / config.limit.value)
+	return window, int(limit), cfg.GC, nil
+}
+
+// slidingWindowLog records the time of every allowed event and allows an
+// event if less than limit events were allowed during the last window. It is
+// exact, but keeps up to limit timestamps per key.
+type slidingWindowLog struct {
+	window time.Duration
+	limit  int
+	states *keyStates[[]time.Time]
+	clock  clockwork.Clock
+}
+
+func newSlidingWindowLog(config algoConfig) (algorithm, error) {
+	window, limit, gc, err := newSlidingWindow("sliding_window_log", config)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &slidingWindowLog{
+		window: window,
+		limit:  limit,
+		clock:  clockwork.NewRealClock(),
+	}
+	a.states = newKeyStates(gc,
+		func(time.Time) *[]time.Time { return &[]time.Time{} },
+		func(log *[]time.Time, now time.Time) bool {
+			a.evict(log, now)
+			return len(*log) == 0
+		},
+	)
+	return a, nil
+}
+
+func (a *slidingWindowLog) IsAllowed(key uint64) bool {
+	now := a.clock.Now()
+	return a.states.with(key, now, func(log *[]time.Time) bool {
+		a.evict(log, now)
+		if len(*log) >= a.limit {
+			return false
+		}
+		*log = append(*log, now)
+		return true
+	})
+}
+
+// evict removes the timestamps that are outside of the window ending at now.
+func (a *slidingWindowLog) evict(log *[]time.Time, now time.Time) {
+	start := now.Add(-a.window)
+	i := 0
+	for i < len(*log) && !(*log)[i].After(start) {
+		i++
+	}
+	*log = (*log)[i:]
+}
+
+// setClock allows test code to inject a fake clock
+func (a *slidingWindowLog) setClock(c clockwork.Clock) {
+	a.clock = c
+}
+
+// windowCounter counts the allowed events of the current and the previous
+// fixed window.
+type windowCounter struct {
+	start    time.Time
+	current  int
+	previous int
+}
+
+// slidingWindowCounter approximates a sliding window by weighting the count
+// of the previous fixed window by its overlap with the sliding window. It
+// keeps constant state per key.
+type slidingWindowCounter struct {
+	window time.Duration
+	limit  int
+	states *keyStates[windowCounter]
+	clock  clockwork.Clock
+}
+
+func newSlidingWindowCounter(config algoConfig) (algorithm, error) {
+	window, limit, gc, err := newSlidingWindow("sliding_window_counter", config)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &slidingWindowCounter{
+		window: window,
+		limit:  limit,
+		clock:  clockwork.NewRealClock(),
+	}
+	a.states = newKeyStates(gc,
+		func(now time.Time) *windowCounter {
+			return &windowCounter{start: now.Truncate(window)}
+		},
+		func(c *windowCounter, now time.Time) bool {
+			return now.Sub(c.start) >= 2*window
+		},
+	)
+	return a, nil
+}
+
+func (a *slidingWindowCounter) IsAllowed(key uint64) bool {
+	now := a.clock.Now()
+	return a.states.with(key, now, func(c *windowCounter) bool {
+		a.advance(c, now)
+
+		overlap := float64(a.window-now.Sub(c.start)) / float64(a.window)
+		estimate := float64(c.previous)*overlap + float64(c.current)
+		if estimate+1 > float64(a.limit) {
+			return false
+		}
+		c.current++
+		return true
+	})
+}
+
+// advance moves the fixed windows forward so the current window contains now.
+func (a *slidingWindowCounter) advance(c *windowCounter, now time.Time) {
+	elapsed := now.Sub(c.start)
+	switch {
+	case elapsed >= 2*a.window:
+		c.start = now.Truncate(a.window)
+		c.previous = 0
+		c.current = 0
+	case elapsed >= a.window:
+		c.start = c.start.Add(a.window)
+		c.previous = c.current
+		c.current = 0
+	}
+}
+
+// setClock allows test code to inject a fake clock
+func (a *slidingWindowCounter) setClock(c clockwork.Clock) {
+	a.clock = c
+}
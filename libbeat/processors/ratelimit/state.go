@@ -0,0 +1,80 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// stateGCConfig governs when idle per-key state is removed.
+type stateGCConfig struct {
+	// NumCalls is the number of calls made to IsAllowed. When more than
+	// the specified number of calls are made, GC is performed.
+	NumCalls uint `config:"num_calls"`
+}
+
+// keyStates holds the per-key state of a rate limiting algorithm. State that
+// no longer affects the rate limit is removed every gc.NumCalls calls.
+type keyStates[S any] struct {
+	mu     sync.Mutex
+	states map[uint64]*S
+	calls  uint
+
+	gc       stateGCConfig
+	newState func(now time.Time) *S
+	isIdle   func(s *S, now time.Time) bool
+}
+
+func newKeyStates[S any](gc stateGCConfig, newState func(time.Time) *S, isIdle func(*S, time.Time) bool) *keyStates[S] {
+	return &keyStates[S]{
+		states:   make(map[uint64]*S),
+		gc:       gc,
+		newState: newState,
+		isIdle:   isIdle,
+	}
+}
+
+// with calls fn with the state of key, creating it if necessary.
+func (k *keyStates[S]) with(key uint64, now time.Time, fn func(*S) bool) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.calls++
+	if k.calls >= k.gc.NumCalls {
+		k.calls = 0
+		for key, s := range k.states {
+			if k.isIdle(s, now) {
+				delete(k.states, key)
+			}
+		}
+	}
+
+	s, ok := k.states[key]
+	if !ok {
+		s = k.newState(now)
+		k.states[key] = s
+	}
+	return fn(s)
+}
+
+func (k *keyStates[S]) len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.states)
+}
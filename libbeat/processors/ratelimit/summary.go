@@ -0,0 +1,193 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jonboulle/clockwork"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const summaryTag = "rate_limit_summary"
+
+// summarizer aggregates the number of dropped events per key. Processors
+// cannot create events on their own, so summaries are emitted in place of
+// the events going through the processor once the summary interval of a key
+// has passed since its first dropped event: a dropped event of any key is
+// replaced with a summary event, and the summaries that are due when an event
+// is allowed are attached to it under rate_limit.summaries. Counts are only
+// removed once they have been summarized.
+type summarizer struct {
+	interval time.Duration
+	fields   []string
+	clock    clockwork.Clock
+
+	mu      sync.Mutex
+	dropped map[uint64]*dropCount
+	// nextDue is the earliest time a count becomes due, it is the zero
+	// time if no count is pending.
+	nextDue time.Time
+}
+
+type dropCount struct {
+	count int
+	since time.Time // time of the first dropped event
+	last  time.Time // time of the last dropped event
+	meta  mapstr.M
+	keys  mapstr.M // values of the key fields
+	key   string
+}
+
+func newSummarizer(interval time.Duration, fields []string) *summarizer {
+	return &summarizer{
+		interval: interval,
+		fields:   fields,
+		clock:    clockwork.NewRealClock(),
+		dropped:  make(map[uint64]*dropCount),
+	}
+}
+
+// drop records a dropped event and returns a summary event if the summary
+// interval of the key or of another key has passed, nil otherwise.
+func (s *summarizer) drop(key uint64, event *beat.Event) *beat.Event {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.dropped[key]
+	if !ok {
+		d = s.newDropCount(event, now)
+		s.dropped[key] = d
+		if s.nextDue.IsZero() || d.since.Add(s.interval).Before(s.nextDue) {
+			s.nextDue = d.since.Add(s.interval)
+		}
+	}
+	d.count++
+	d.last = now
+
+	if s.isDue(d, now) {
+		s.remove(key)
+		return s.makeSummary(d, now)
+	}
+	if s.nextDue.IsZero() || now.Before(s.nextDue) {
+		return nil
+	}
+	for k, d := range s.dropped {
+		if s.isDue(d, now) {
+			s.remove(k)
+			return s.makeSummary(d, now)
+		}
+	}
+	return nil
+}
+
+// allow attaches the summaries that are due to an allowed event.
+func (s *summarizer) allow(event *beat.Event) {
+	now := s.clock.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.nextDue.IsZero() || now.Before(s.nextDue) {
+		return
+	}
+	var summaries []mapstr.M
+	for k, d := range s.dropped {
+		if s.isDue(d, now) {
+			s.remove(k)
+			summaries = append(summaries, mapstr.M{
+				"key":     d.key,
+				"dropped": d.count,
+				"since":   d.since,
+				"last":    d.last,
+			})
+		}
+	}
+	if len(summaries) == 0 {
+		return
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i]["since"].(time.Time).Before(summaries[j]["since"].(time.Time))
+	})
+	if event.Fields == nil {
+		event.Fields = mapstr.M{}
+	}
+	_, _ = event.PutValue("rate_limit.summaries", summaries)
+}
+
+func (s *summarizer) isDue(d *dropCount, now time.Time) bool {
+	return now.Sub(d.since) >= s.interval
+}
+
+// remove removes the count of a key and updates nextDue. Callers must hold
+// s.mu.
+func (s *summarizer) remove(key uint64) {
+	delete(s.dropped, key)
+	s.nextDue = time.Time{}
+	for _, d := range s.dropped {
+		if due := d.since.Add(s.interval); s.nextDue.IsZero() || due.Before(s.nextDue) {
+			s.nextDue = due
+		}
+	}
+}
+
+func (s *summarizer) newDropCount(event *beat.Event, now time.Time) *dropCount {
+	d := &dropCount{
+		since: now,
+		meta:  event.Meta.Clone(),
+		keys:  mapstr.M{},
+	}
+	keyParts := make([]string, 0, len(s.fields))
+	for _, field := range s.fields {
+		value, err := event.GetValue(field)
+		if err != nil {
+			continue
+		}
+		_, _ = d.keys.Put(field, value)
+		keyParts = append(keyParts, fmt.Sprintf("%v=%v", field, value))
+	}
+	d.key = "all events"
+	if len(keyParts) > 0 {
+		d.key = strings.Join(keyParts, ",")
+	}
+	return d
+}
+
+func (s *summarizer) makeSummary(d *dropCount, now time.Time) *beat.Event {
+	summary := &beat.Event{
+		Timestamp: now,
+		Meta:      d.meta,
+		Fields:    d.keys,
+	}
+	summary.Fields["message"] = fmt.Sprintf("%d events dropped for key %v", d.count, d.key)
+	summary.Fields["rate_limit"] = mapstr.M{
+		"dropped": d.count,
+		"since":   d.since,
+		"last":    d.last,
+	}
+	_ = mapstr.AddTags(summary.Fields, []string{summaryTag})
+	return summary
+}
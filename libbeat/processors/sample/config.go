@@ -0,0 +1,47 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Config for sample processor.
+type Config struct {
+	Percentage    float64  `config:"percentage" validate:"required"` // Percentage of keys to keep
+	Fields        []string `config:"fields" validate:"required"`     // Fields to compute the sampling key from
+	IgnoreMissing bool     `config:"ignore_missing"`                 // Ignore missing fields?
+	RateField     string   `config:"rate_field"`                     // Field to store the sampling rate in kept events
+}
+
+func defaultConfig() Config {
+	return Config{
+		IgnoreMissing: false,
+	}
+}
+
+func (c *Config) Validate() error {
+	if c.Percentage <= 0 || c.Percentage > 100 {
+		return fmt.Errorf("percentage must be greater than 0 and at most 100, got %v", c.Percentage)
+	}
+	if len(c.Fields) == 0 {
+		return errors.New("must specify at least one field")
+	}
+	return nil
+}
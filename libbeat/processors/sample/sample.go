@@ -0,0 +1,98 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/cespare/xxhash/v2"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/libbeat/processors"
+	"github.com/elastic/beats/v7/libbeat/processors/fingerprint"
+	"github.com/elastic/elastic-agent-libs/config"
+)
+
+const (
+	procName = "sample"
+)
+
+func init() {
+	processors.RegisterPlugin(procName, New)
+}
+
+type sample struct {
+	config Config
+	fields []string
+	rate   float64
+	// threshold is compared to the hash of the sampling key, events with
+	// a lower hash are kept.
+	threshold uint64
+	keepAll   bool
+}
+
+// New constructs a new sample processor.
+func New(cfg *config.C) (beat.Processor, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, fmt.Errorf("failed to unpack %v processor configuration: %w", procName, err)
+	}
+
+	rate := config.Percentage / 100
+	p := &sample{
+		config: config,
+		// The fields are sorted to compute the same key on every host,
+		// regardless of the configured order.
+		fields:  common.MakeStringSet(config.Fields...).ToSlice(),
+		rate:    rate,
+		keepAll: rate >= 1,
+	}
+	if !p.keepAll {
+		p.threshold = uint64(rate * math.MaxUint64)
+	}
+	return p, nil
+}
+
+// Run keeps or drops the event based on the hash of its sampling key. Events
+// with the same key are always kept or dropped together.
+func (p *sample) Run(event *beat.Event) (*beat.Event, error) {
+	var buf bytes.Buffer
+	if err := fingerprint.WriteFields(&buf, event, p.fields, p.config.IgnoreMissing); err != nil {
+		return event, fmt.Errorf("failed to compute sampling key: %w", err)
+	}
+
+	if !p.keepAll && xxhash.Sum64(buf.Bytes()) >= p.threshold {
+		return nil, nil
+	}
+
+	if p.config.RateField != "" {
+		if _, err := event.PutValue(p.config.RateField, p.rate); err != nil {
+			return event, fmt.Errorf("failed to set %v: %w", p.config.RateField, err)
+		}
+	}
+	return event, nil
+}
+
+func (p *sample) String() string {
+	json, _ := json.Marshal(&p.config)
+	return procName + "=" + string(json)
+}
@@ -0,0 +1,133 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package sample
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func newTestSample(t *testing.T, cfg mapstr.M) beat.Processor {
+	t.Helper()
+	p, err := New(config.MustNewConfigFrom(cfg))
+	require.NoError(t, err)
+	return p
+}
+
+func traceEvent(traceID string, i int) *beat.Event {
+	return &beat.Event{Fields: mapstr.M{
+		"trace":   mapstr.M{"id": traceID},
+		"message": fmt.Sprintf("span %d", i),
+	}}
+}
+
+func TestSamplePercentage(t *testing.T) {
+	p := newTestSample(t, mapstr.M{"percentage": 10, "fields": []string{"trace.id"}})
+
+	kept := 0
+	for i := 0; i < 10000; i++ {
+		out, err := p.Run(traceEvent(fmt.Sprintf("trace-%d", i), 0))
+		require.NoError(t, err)
+		if out != nil {
+			kept++
+		}
+	}
+	assert.InDelta(t, 1000, kept, 150)
+}
+
+func TestSampleKeepsKeysTogether(t *testing.T) {
+	cfg := mapstr.M{"percentage": 50, "fields": []string{"trace.id", "service.name"}}
+	p := newTestSample(t, cfg)
+	// a second instance, with the fields in a different order, stands in
+	// for another host
+	other := newTestSample(t, mapstr.M{"percentage": 50, "fields": []string{"service.name", "trace.id"}})
+
+	for i := 0; i < 100; i++ {
+		id := fmt.Sprintf("trace-%d", i)
+		var decisions []bool
+		for j := 0; j < 5; j++ {
+			for _, proc := range []beat.Processor{p, other} {
+				event := traceEvent(id, j)
+				event.Fields["service"] = mapstr.M{"name": "frontend"}
+				out, err := proc.Run(event)
+				require.NoError(t, err)
+				decisions = append(decisions, out != nil)
+			}
+		}
+		for _, d := range decisions {
+			assert.Equal(t, decisions[0], d, "all events of %v must share one decision", id)
+		}
+	}
+}
+
+func TestSampleRateField(t *testing.T) {
+	p := newTestSample(t, mapstr.M{"percentage": 100, "fields": []string{"trace.id"}, "rate_field": "sample.rate"})
+
+	out, err := p.Run(traceEvent("abc", 0))
+	require.NoError(t, err)
+	require.NotNil(t, out)
+	v, err := out.GetValue("sample.rate")
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, v)
+
+	p = newTestSample(t, mapstr.M{"percentage": 25, "fields": []string{"trace.id"}, "rate_field": "sample.rate"})
+	for i := 0; ; i++ {
+		out, err := p.Run(traceEvent(fmt.Sprintf("trace-%d", i), 0))
+		require.NoError(t, err)
+		if out != nil {
+			v, err := out.GetValue("sample.rate")
+			require.NoError(t, err)
+			assert.Equal(t, 0.25, v)
+			break
+		}
+	}
+}
+
+func TestSampleMissingField(t *testing.T) {
+	p := newTestSample(t, mapstr.M{"percentage": 0.001, "fields": []string{"session.id"}})
+	event := traceEvent("abc", 0)
+	out, err := p.Run(event)
+	assert.Error(t, err)
+	assert.Equal(t, event, out, "events without a sampling key are kept")
+
+	p = newTestSample(t, mapstr.M{"percentage": 100, "fields": []string{"session.id"}, "ignore_missing": true})
+	out, err = p.Run(traceEvent("abc", 0))
+	assert.NoError(t, err)
+	assert.NotNil(t, out)
+}
+
+func TestSampleConfig(t *testing.T) {
+	for name, cfg := range map[string]mapstr.M{
+		"no fields":          {"percentage": 10},
+		"no percentage":      {"fields": []string{"trace.id"}},
+		"zero percentage":    {"percentage": 0, "fields": []string{"trace.id"}},
+		"percentage too big": {"percentage": 101, "fields": []string{"trace.id"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(config.MustNewConfigFrom(cfg))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -79,6 +79,10 @@ type diskQueue struct {
 	// waiting for free space in the queue.
 	blockedProducers []producerWriteRequest
 
+	// pendingOnStartup is the number of events that were still pending when
+	// the queue was opened.
+	pendingOnStartup int
+
 	// The channel to signal our goroutines to shut down, used by
 	// (*diskQueue).Close.
 	close chan struct{}
@@ -231,6 +235,8 @@ func NewQueue(
 
 		producerWriteRequestChan: make(chan producerWriteRequest),
 
+		pendingOnStartup: activeFrameCount,
+
 		close: make(chan struct{}),
 		done:  make(chan struct{}),
 	}
@@ -264,6 +270,12 @@ func (dq *diskQueue) QueueType() string {
 	return QueueType
 }
 
+// PendingOnStartup returns the number of events left from a previous run,
+// that will be read before any event added after the queue was opened.
+func (dq *diskQueue) PendingOnStartup() int {
+	return dq.pendingOnStartup
+}
+
 func (dq *diskQueue) BufferConfig() queue.BufferConfig {
 	return queue.BufferConfig{MaxEvents: 0}
 }
@@ -0,0 +1,40 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package diskqueue
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderLoopMissingSegment(t *testing.T) {
+	settings := DefaultSettings()
+	settings.Path = t.TempDir()
+	rl := newReaderLoop(settings, nil)
+
+	// A segment that can't be opened must be reported as error instead of
+	// dereferencing the missing reader.
+	response := rl.processRequest(readerLoopRequest{
+		segment:     &queueSegment{id: 1},
+		endPosition: 100,
+	})
+	assert.Error(t, response.err)
+	assert.Zero(t, response.frameCount)
+	assert.Zero(t, response.byteCount)
+}
@@ -0,0 +1,66 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package hybridqueue
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	c "github.com/elastic/elastic-agent-libs/config"
+)
+
+type config struct {
+	// Events is the number of events held in memory before spilling to disk.
+	Events int `config:"events" validate:"min=32"`
+
+	// Disk contains the disk queue settings of the spill queue.
+	Disk *c.C `config:"disk"`
+}
+
+var defaultConfig = config{
+	Events: 3200,
+}
+
+func (c *config) Validate() error {
+	if c.Disk == nil {
+		return errors.New("hybrid queue requires the disk section to be configured")
+	}
+	return nil
+}
+
+// SettingsForUserConfig unpacks a ucfg config from a Beats queue
+// configuration and returns the equivalent hybridqueue.Settings object.
+func SettingsForUserConfig(cfg *c.C) (Settings, error) {
+	if cfg == nil {
+		cfg = c.NewConfig()
+	}
+	config := defaultConfig
+	if err := cfg.Unpack(&config); err != nil {
+		return Settings{}, fmt.Errorf("couldn't unpack hybrid queue config: %w", err)
+	}
+
+	disk, err := diskqueue.SettingsForUserConfig(config.Disk)
+	if err != nil {
+		return Settings{}, err
+	}
+	return Settings{
+		Events: config.Events,
+		Disk:   disk,
+	}, nil
+}
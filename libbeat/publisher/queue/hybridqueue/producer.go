@@ -0,0 +1,206 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package hybridqueue
+
+import (
+	"sync"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+)
+
+// producer writes events to the in-memory buffer or the disk queue of a
+// hybrid queue.
+//
+// Events in memory are acknowledged when the consumer is done with them,
+// events on disk once they have been written. To report ACKs to the producer
+// in publishing order, every event is assigned a sequence number and only
+// the completed prefix of the sequence is reported.
+type producer struct {
+	queue   *hybridQueue
+	ackCB   func(int)
+	encoder queue.Encoder
+	disk    queue.Producer
+
+	// pubMu serializes publishing, so the events of this producer are
+	// written to the disk queue in sequence order.
+	pubMu   sync.Mutex
+	nextSeq uint64
+	closed  bool
+
+	// ACK state, guarded by ackMu.
+	ackMu sync.Mutex
+	// ackBase is the sequence number of the first entry in completed.
+	ackBase   uint64
+	completed []bool
+	// diskSeqs lists the sequence numbers of events written to the disk
+	// queue that have not been acknowledged by it yet.
+	diskSeqs []uint64
+}
+
+func newProducer(q *hybridQueue, cfg queue.ProducerConfig) *producer {
+	p := &producer{queue: q, ackCB: cfg.ACK}
+	if q.encoderFactory != nil {
+		p.encoder = q.encoderFactory()
+	}
+
+	diskCfg := queue.ProducerConfig{}
+	if p.ackCB != nil {
+		diskCfg.ACK = p.diskACK
+	}
+	p.disk = q.disk.Producer(diskCfg)
+	return p
+}
+
+func (p *producer) Publish(entry queue.Entry) (queue.EntryID, bool) {
+	return p.publish(entry, true)
+}
+
+func (p *producer) TryPublish(entry queue.Entry) (queue.EntryID, bool) {
+	return p.publish(entry, false)
+}
+
+func (p *producer) Close() {
+	p.pubMu.Lock()
+	p.closed = true
+	p.pubMu.Unlock()
+
+	p.disk.Close()
+}
+
+func (p *producer) publish(entry queue.Entry, shouldBlock bool) (queue.EntryID, bool) {
+	p.pubMu.Lock()
+	defer p.pubMu.Unlock()
+
+	if p.closed {
+		return 0, false
+	}
+
+	q := p.queue
+	e := memEntry{event: entry}
+	if p.ackCB != nil {
+		e.producer = p
+		e.seq = p.nextSeq
+	}
+	// Encode outside of the lock, unless the event is likely to be spilled.
+	encoded := false
+	if p.encoder != nil && !q.spilling.Load() {
+		e.event, e.size = p.encoder.EncodeEntry(entry)
+		encoded = true
+	}
+
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return 0, false
+	}
+	if !q.acceptMem() {
+		q.diskWritten++
+		q.mu.Unlock()
+		return p.publishDisk(entry, shouldBlock)
+	}
+	if p.encoder != nil && !encoded {
+		e.event, e.size = p.encoder.EncodeEntry(entry)
+	}
+	q.pushMem(e)
+	q.mu.Unlock()
+
+	p.nextSeq++
+	return queue.EntryID(q.nextID.Add(1)), true
+}
+
+// publishDisk writes the raw event to the disk queue. Must be called with
+// pubMu held, after the event has been accounted for in diskWritten.
+func (p *producer) publishDisk(entry queue.Entry, shouldBlock bool) (queue.EntryID, bool) {
+	q := p.queue
+	if p.ackCB != nil {
+		p.ackMu.Lock()
+		p.diskSeqs = append(p.diskSeqs, p.nextSeq)
+		p.ackMu.Unlock()
+	}
+
+	var ok bool
+	if shouldBlock {
+		_, ok = p.disk.Publish(entry)
+	} else {
+		_, ok = p.disk.TryPublish(entry)
+	}
+
+	if !ok {
+		if p.ackCB != nil {
+			p.ackMu.Lock()
+			p.diskSeqs = p.diskSeqs[:len(p.diskSeqs)-1]
+			p.ackMu.Unlock()
+		}
+		q.mu.Lock()
+		q.diskWritten--
+		q.updateSpilling()
+		q.mu.Unlock()
+		return 0, false
+	}
+
+	p.nextSeq++
+	return queue.EntryID(q.nextID.Add(1)), true
+}
+
+// diskACK is called by the disk queue with the number of events written.
+func (p *producer) diskACK(count int) {
+	p.ackMu.Lock()
+	seqs := p.diskSeqs[:count]
+	p.diskSeqs = p.diskSeqs[count:]
+	n := 0
+	for _, seq := range seqs {
+		n += p.complete(seq)
+	}
+	p.reportACK(n)
+	p.ackMu.Unlock()
+}
+
+// ackSeq is called when an in-memory event has been consumed.
+func (p *producer) ackSeq(seq uint64) {
+	p.ackMu.Lock()
+	p.reportACK(p.complete(seq))
+	p.ackMu.Unlock()
+}
+
+// reportACK forwards n acknowledged events to the producer callback. It is
+// called with ackMu held, so callbacks are never run concurrently.
+func (p *producer) reportACK(n int) {
+	if n > 0 {
+		p.ackCB(n)
+	}
+}
+
+// complete marks seq as done and returns the number of events that can be
+// acknowledged in order. Must be called with ackMu held.
+func (p *producer) complete(seq uint64) int {
+	idx := int(seq - p.ackBase)
+	for len(p.completed) <= idx {
+		p.completed = append(p.completed, false)
+	}
+	p.completed[idx] = true
+
+	n := 0
+	for n < len(p.completed) && p.completed[n] {
+		n++
+	}
+	if n > 0 {
+		p.completed = append(p.completed[:0], p.completed[n:]...)
+		p.ackBase += uint64(n)
+	}
+	return n
+}
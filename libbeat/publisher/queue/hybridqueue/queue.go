@@ -0,0 +1,370 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package hybridqueue
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// The string used to specify this queue in beats configurations.
+const QueueType = "hybrid"
+
+// Settings configures a hybrid queue.
+type Settings struct {
+	// The number of events the queue holds in memory. Events that have been
+	// consumed but not yet acknowledged count against this limit.
+	Events int
+
+	// Settings of the disk queue events are spilled to.
+	Disk diskqueue.Settings
+}
+
+// hybridQueue holds events in memory while there is room and spills them to a
+// disk queue when the memory buffer is full, for example because the output
+// is slow or unavailable.
+//
+// Events are always consumed in the order they have been accepted. Once the
+// queue starts spilling, all new events are written to disk until every
+// spilled event has been read back. Events that are left in the disk queue
+// from a previous run are read before any new event.
+type hybridQueue struct {
+	logger   *logp.Logger
+	observer queue.Observer
+	settings Settings
+
+	encoderFactory queue.EncoderFactory
+
+	disk diskQueue
+
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	// In-memory ring buffer of events that have not been consumed yet.
+	buf        []memEntry
+	head, size int
+
+	// memEvents is the number of in-memory events that have not been
+	// acknowledged yet, including the events in buf.
+	memEvents int
+
+	// spilling is true while new events are written to the disk queue. It is
+	// only updated with mu held, but producers read it without the lock to
+	// skip encoding events that will not be stored in memory.
+	spilling atomic.Bool
+
+	// diskWritten and diskRead count the events written to and read from
+	// the disk queue. While diskRead is behind diskWritten, there are
+	// events on disk that must be consumed before any new in-memory event.
+	diskWritten, diskRead uint64
+
+	// diskBatch is a batch read from the disk queue that has not been
+	// returned to a consumer yet. diskRequested is true while the disk
+	// reader is waiting for a batch.
+	diskBatch     queue.Batch
+	diskRequested bool
+	diskRequests  chan int
+
+	// closing is closed by Close to stop the disk reader.
+	closed  bool
+	closing chan struct{}
+
+	nextID atomic.Uint64
+}
+
+// diskQueue is the subset of the disk queue API used by the hybrid queue.
+type diskQueue interface {
+	queue.Queue
+	PendingOnStartup() int
+}
+
+type memEntry struct {
+	event queue.Entry
+	size  int
+
+	producer *producer
+	seq      uint64
+}
+
+// FactoryForSettings is a simple wrapper around NewQueue so a concrete
+// Settings object can be wrapped in a queue-agnostic interface for
+// later use by the pipeline.
+func FactoryForSettings(settings Settings) queue.QueueFactory {
+	return func(
+		logger *logp.Logger,
+		observer queue.Observer,
+		inputQueueSize int,
+		encoderFactory queue.EncoderFactory,
+	) (queue.Queue, error) {
+		return NewQueue(logger, observer, settings, encoderFactory)
+	}
+}
+
+// NewQueue creates a new hybrid queue. The disk queue is opened immediately,
+// events left from a previous run are consumed first.
+func NewQueue(
+	logger *logp.Logger,
+	observer queue.Observer,
+	settings Settings,
+	encoderFactory queue.EncoderFactory,
+) (queue.Queue, error) {
+	if logger == nil {
+		logger = logp.NewLogger("hybridqueue")
+	}
+	logger = logger.Named("hybridqueue")
+	if observer == nil {
+		observer = queue.NewQueueObserver(nil)
+	}
+
+	disk, err := diskqueue.NewQueue(logger, diskObserver{observer}, settings.Disk, encoderFactory)
+	if err != nil {
+		return nil, err
+	}
+	return newQueue(logger, observer, settings, encoderFactory, disk), nil
+}
+
+func newQueue(
+	logger *logp.Logger,
+	observer queue.Observer,
+	settings Settings,
+	encoderFactory queue.EncoderFactory,
+	disk diskQueue,
+) *hybridQueue {
+	q := &hybridQueue{
+		logger:         logger,
+		observer:       observer,
+		settings:       settings,
+		encoderFactory: encoderFactory,
+		disk:           disk,
+		buf:            make([]memEntry, settings.Events),
+		diskRequests:   make(chan int),
+		closing:        make(chan struct{}),
+	}
+	q.cond = sync.NewCond(&q.mu)
+
+	if pending := disk.PendingOnStartup(); pending > 0 {
+		logger.Infof("Reading %v events left in the disk queue before accepting new events in memory", pending)
+		q.diskWritten = uint64(pending)
+		q.spilling.Store(true)
+	}
+	observer.MaxEvents(settings.Events)
+
+	go q.readDisk()
+	return q
+}
+
+func (q *hybridQueue) Close() error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.closing)
+	}
+	q.cond.Broadcast()
+	q.mu.Unlock()
+
+	return q.disk.Close()
+}
+
+func (q *hybridQueue) Done() <-chan struct{} {
+	return q.disk.Done()
+}
+
+func (q *hybridQueue) QueueType() string {
+	return QueueType
+}
+
+// BufferConfig reports no fixed limit, as the disk queue can hold more events
+// than the in-memory buffer.
+func (q *hybridQueue) BufferConfig() queue.BufferConfig {
+	return queue.BufferConfig{MaxEvents: 0}
+}
+
+func (q *hybridQueue) Producer(cfg queue.ProducerConfig) queue.Producer {
+	return newProducer(q, cfg)
+}
+
+// Get returns the oldest events in the queue. In-memory events are returned
+// first, as they are always older than the events currently on disk. If
+// eventCount <= 0 all available events of the source are returned.
+func (q *hybridQueue) Get(eventCount int) (queue.Batch, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if q.closed {
+			return nil, io.EOF
+		}
+
+		if q.size > 0 {
+			return q.getMem(eventCount), nil
+		}
+
+		if q.diskBatch != nil {
+			b := q.diskBatch
+			q.diskBatch = nil
+			q.updateSpilling()
+			return b, nil
+		}
+
+		if q.diskRead < q.diskWritten && !q.diskRequested {
+			q.diskRequested = true
+			select {
+			case q.diskRequests <- eventCount:
+			case <-q.closing:
+				return nil, io.EOF
+			}
+		}
+
+		q.cond.Wait()
+	}
+}
+
+// getMem removes up to count events from the in-memory buffer. Must be
+// called with mu held.
+func (q *hybridQueue) getMem(count int) *batch {
+	if count <= 0 || count > q.size {
+		count = q.size
+	}
+
+	entries := make([]memEntry, count)
+	bytes := 0
+	for i := range entries {
+		idx := (q.head + i) % len(q.buf)
+		entries[i] = q.buf[idx]
+		bytes += entries[i].size
+		q.buf[idx] = memEntry{}
+	}
+	q.head = (q.head + count) % len(q.buf)
+	q.size -= count
+
+	q.observer.ConsumeEvents(count, bytes)
+	return &batch{queue: q, entries: entries}
+}
+
+// readDisk reads batches from the disk queue on request of Get, so Get never
+// blocks on the disk queue while holding the lock.
+func (q *hybridQueue) readDisk() {
+	for {
+		var count int
+		select {
+		case count = <-q.diskRequests:
+		case <-q.closing:
+			return
+		}
+
+		b, err := q.disk.Get(count)
+
+		q.mu.Lock()
+		q.diskRequested = false
+		if err == nil {
+			q.diskRead += uint64(b.Count())
+			q.diskBatch = b
+		}
+		q.cond.Broadcast()
+		q.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// updateSpilling switches back to the in-memory buffer once all spilled
+// events have been read from disk. Must be called with mu held.
+func (q *hybridQueue) updateSpilling() {
+	if q.spilling.Load() && q.diskRead >= q.diskWritten && q.diskBatch == nil && q.size == 0 {
+		q.logger.Debug("All spilled events have been read, continue with in-memory buffer")
+		q.spilling.Store(false)
+	}
+}
+
+// acceptMem reports if a new event can be added to the in-memory buffer. It
+// starts spilling when the buffer is full. Must be called with mu held.
+func (q *hybridQueue) acceptMem() bool {
+	if q.spilling.Load() {
+		return false
+	}
+	if q.memEvents >= len(q.buf) {
+		q.logger.Debug("In-memory buffer is full, spilling events to disk")
+		q.spilling.Store(true)
+		return false
+	}
+	return true
+}
+
+// pushMem adds an event to the in-memory buffer. Must be called with mu held
+// after acceptMem returned true.
+func (q *hybridQueue) pushMem(e memEntry) {
+	q.buf[(q.head+q.size)%len(q.buf)] = e
+	q.size++
+	q.memEvents++
+	q.observer.AddEvent(e.size)
+	q.cond.Broadcast()
+}
+
+func (q *hybridQueue) memDone(count, bytes int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.memEvents -= count
+	q.observer.RemoveEvents(count, bytes)
+}
+
+// batch is a batch of in-memory events.
+type batch struct {
+	queue   *hybridQueue
+	entries []memEntry
+}
+
+func (b *batch) Count() int {
+	return len(b.entries)
+}
+
+func (b *batch) Entry(i int) queue.Entry {
+	return b.entries[i].event
+}
+
+func (b *batch) FreeEntries() {
+	for i := range b.entries {
+		b.entries[i].event = nil
+	}
+}
+
+func (b *batch) Done() {
+	bytes := 0
+	for _, e := range b.entries {
+		bytes += e.size
+		if e.producer != nil {
+			e.producer.ackSeq(e.seq)
+		}
+	}
+	b.queue.memDone(len(b.entries), bytes)
+}
+
+// diskObserver forwards the event metrics of the disk queue. The limits of the
+// hybrid queue are reported by the hybrid queue itself.
+type diskObserver struct {
+	queue.Observer
+}
+
+func (diskObserver) MaxEvents(int) {}
+func (diskObserver) MaxBytes(int)  {}
@@ -0,0 +1,207 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package hybridqueue
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/publisher"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/diskqueue"
+	"github.com/elastic/beats/v7/libbeat/publisher/queue/queuetest"
+	c "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var seed int64
+
+func init() {
+	flag.Int64Var(&seed, "seed", time.Now().UnixNano(), "test random seed")
+}
+
+func testSettings(t *testing.T, dir string, events int) Settings {
+	t.Helper()
+	disk := diskqueue.DefaultSettings()
+	disk.Path = dir
+	return Settings{Events: events, Disk: disk}
+}
+
+func openTestQueue(t *testing.T, settings Settings) *hybridQueue {
+	t.Helper()
+	q, err := NewQueue(logp.L(), nil, settings, nil)
+	require.NoError(t, err)
+	return q.(*hybridQueue) //nolint:errcheck // NewQueue always returns a hybridQueue
+}
+
+func TestProduceConsumer(t *testing.T) {
+	maxEvents := 1024
+	minEvents := 32
+
+	rand.Seed(seed)
+	events := rand.Intn(maxEvents-minEvents) + minEvents
+	batchSize := rand.Intn(events-8) + 4
+
+	t.Log("seed: ", seed)
+	t.Log("events: ", events)
+	t.Log("batchSize: ", batchSize)
+
+	factory := func(t *testing.T) queue.Queue {
+		// a small in-memory buffer, so events are spilled to disk
+		return openTestQueue(t, testSettings(t, t.TempDir(), 32))
+	}
+
+	t.Run("single", func(t *testing.T) {
+		queuetest.TestSingleProducerConsumer(t, events, batchSize, factory)
+	})
+	t.Run("multi", func(t *testing.T) {
+		queuetest.TestMultiProducerConsumer(t, events, batchSize, factory)
+	})
+}
+
+func publishN(t *testing.T, p queue.Producer, from, to int) {
+	t.Helper()
+	for i := from; i < to; i++ {
+		_, ok := p.Publish(queuetest.MakeEvent(mapstr.M{"i": i}))
+		require.True(t, ok)
+	}
+}
+
+func consumeN(t *testing.T, q queue.Queue, n int) []int {
+	t.Helper()
+	var out []int
+	for len(out) < n {
+		b, err := q.Get(7)
+		require.NoError(t, err)
+		for i := 0; i < b.Count(); i++ {
+			v, err := b.Entry(i).(publisher.Event).Content.Fields.GetValue("i")
+			require.NoError(t, err)
+			// decoded events hold the smallest fitting integer type
+			n, err := strconv.Atoi(fmt.Sprint(v))
+			require.NoError(t, err)
+			out = append(out, n)
+		}
+		b.Done()
+	}
+	return out
+}
+
+func sequence(from, to int) []int {
+	out := make([]int, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, i)
+	}
+	return out
+}
+
+func TestSpillInOrder(t *testing.T) {
+	q := openTestQueue(t, testSettings(t, t.TempDir(), 32))
+	defer q.Close()
+	p := q.Producer(queue.ProducerConfig{})
+
+	publishN(t, p, 0, 100)
+	q.mu.Lock()
+	assert.True(t, q.spilling.Load())
+	assert.Equal(t, 32, q.size)
+	assert.Equal(t, uint64(68), q.diskWritten)
+	q.mu.Unlock()
+
+	assert.Equal(t, sequence(0, 100), consumeN(t, q, 100))
+
+	// all spilled events have been read, new events are held in memory
+	publishN(t, p, 100, 110)
+	q.mu.Lock()
+	assert.False(t, q.spilling.Load())
+	assert.Equal(t, 10, q.size)
+	q.mu.Unlock()
+	assert.Equal(t, sequence(100, 110), consumeN(t, q, 10))
+}
+
+func TestACKInOrder(t *testing.T) {
+	q := openTestQueue(t, testSettings(t, t.TempDir(), 32))
+	defer q.Close()
+
+	var acked atomic.Int64
+	p := q.Producer(queue.ProducerConfig{ACK: func(n int) { acked.Add(int64(n)) }})
+	publishN(t, p, 0, 40)
+
+	// the spilled events are written to disk, but must not be acknowledged
+	// before the in-memory events
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int64(0), acked.Load())
+
+	b, err := q.Get(32)
+	require.NoError(t, err)
+	require.Equal(t, 32, b.Count())
+	b.Done()
+	assert.Eventually(t, func() bool { return acked.Load() == 40 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRestoreSpilledEvents(t *testing.T) {
+	dir := t.TempDir()
+	q := openTestQueue(t, testSettings(t, dir, 32))
+	var acked atomic.Int64
+	p := q.Producer(queue.ProducerConfig{ACK: func(n int) { acked.Add(int64(n)) }})
+	publishN(t, p, 0, 50)
+	// wait until the spilled events are on disk
+	assert.Eventually(t, func() bool { return acked.Load() == 0 && q.diskWritten == 18 }, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	p.Close()
+	require.NoError(t, q.Close())
+
+	// in-memory events are lost, the spilled events are read before new ones
+	q = openTestQueue(t, testSettings(t, dir, 32))
+	defer q.Close()
+	assert.True(t, q.spilling.Load())
+
+	p = q.Producer(queue.ProducerConfig{})
+	publishN(t, p, 100, 105)
+	assert.Equal(t, append(sequence(32, 50), sequence(100, 105)...), consumeN(t, q, 23))
+}
+
+func TestGetAfterClose(t *testing.T) {
+	q := openTestQueue(t, testSettings(t, t.TempDir(), 32))
+	require.NoError(t, q.Close())
+	_, err := q.Get(1)
+	assert.Error(t, err)
+}
+
+func TestSettingsForUserConfig(t *testing.T) {
+	_, err := SettingsForUserConfig(c.MustNewConfigFrom(mapstr.M{"events": 100}))
+	assert.Error(t, err, "disk section is required")
+
+	settings, err := SettingsForUserConfig(c.MustNewConfigFrom(mapstr.M{
+		"events": 100,
+		"disk":   mapstr.M{"max_size": "1GB", "path": "/tmp/queue"},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 100, settings.Events)
+	assert.Equal(t, "/tmp/queue", settings.Disk.Path)
+	assert.Equal(t, uint64(1e9), settings.Disk.MaxBufferSize)
+}
@@ -0,0 +1,283 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package grok implements grok, a way to match text against named regular
+// expression patterns and to extract the matched parts into fields.
+//
+// A grok pattern is a regular expression in which %{SYNTAX:SEMANTIC:TYPE}
+// references are replaced with the pattern named SYNTAX. If SEMANTIC is set,
+// the text matched by the pattern is stored in a field of that name,
+// converted to TYPE if it is set.
+package grok
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// ErrNoMatch is returned when text does not match any pattern.
+var ErrNoMatch = errors.New("text does not match any grok pattern")
+
+// tokenRegexp matches pattern references, %{SYNTAX}, %{SYNTAX:SEMANTIC} or
+// %{SYNTAX:SEMANTIC:TYPE}, and the start of named groups, (?<name>...) or
+// (?P<name>...).
+var tokenRegexp = regexp.MustCompile(`%\{(\w+)(?::([\w.@\[\]-]+))?(?::(\w+))?\}|\(\?P?<([A-Za-z_@\[][\w.@\[\]-]*)>`)
+
+// maxDepth limits how deep pattern references are expanded.
+const maxDepth = 64
+
+type captureType uint8
+
+const (
+	typeString captureType = iota
+	typeInt
+	typeLong
+	typeFloat
+	typeDouble
+	typeBoolean
+)
+
+var captureTypes = map[string]captureType{
+	"string":  typeString,
+	"int":     typeInt,
+	"long":    typeLong,
+	"float":   typeFloat,
+	"double":  typeDouble,
+	"boolean": typeBoolean,
+}
+
+// Grok matches text against a list of compiled grok patterns.
+type Grok struct {
+	patterns []*pattern
+}
+
+type pattern struct {
+	raw      string
+	re       *regexp.Regexp
+	captures []capture
+}
+
+// capture is a named group of a compiled pattern.
+type capture struct {
+	field string
+	typ   captureType
+	group int
+}
+
+// New compiles patterns with the standard pattern library. The definitions
+// add custom patterns to the library, or replace standard ones of the same name.
+func New(patterns []string, definitions map[string]string) (*Grok, error) {
+	if len(patterns) == 0 {
+		return nil, errors.New("no grok pattern is configured")
+	}
+
+	library := make(map[string]string, len(standardPatterns)+len(definitions))
+	for name, def := range standardPatterns {
+		library[name] = def
+	}
+	for name, def := range definitions {
+		library[name] = def
+	}
+
+	g := &Grok{patterns: make([]*pattern, 0, len(patterns))}
+	for _, raw := range patterns {
+		p, err := compile(raw, library)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile grok pattern %q: %w", raw, err)
+		}
+		g.patterns = append(g.patterns, p)
+	}
+	return g, nil
+}
+
+// Match matches text against the patterns in order and returns the captured
+// fields of the first pattern that matches. Captures that do not match any
+// text are omitted. ErrNoMatch is returned if no pattern matches.
+func (g *Grok) Match(text string) (mapstr.M, error) {
+	for _, p := range g.patterns {
+		loc := p.re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			continue
+		}
+
+		fields := mapstr.M{}
+		for _, c := range p.captures {
+			start, end := loc[2*c.group], loc[2*c.group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if ok, _ := fields.HasKey(c.field); ok {
+				continue
+			}
+			v, err := c.convert(text[start:end])
+			if err != nil {
+				return nil, err
+			}
+			_, _ = fields.Put(c.field, v)
+		}
+		return fields, nil
+	}
+	return nil, ErrNoMatch
+}
+
+// String returns the configured patterns.
+func (g *Grok) String() string {
+	raw := make([]string, len(g.patterns))
+	for i, p := range g.patterns {
+		raw[i] = p.raw
+	}
+	return strings.Join(raw, ", ")
+}
+
+func (c capture) convert(s string) (interface{}, error) {
+	var (
+		v   interface{}
+		err error
+	)
+	switch c.typ {
+	case typeInt:
+		var i int64
+		i, err = strconv.ParseInt(s, 10, 32)
+		v = int32(i)
+	case typeLong:
+		v, err = strconv.ParseInt(s, 10, 64)
+	case typeFloat:
+		var f float64
+		f, err = strconv.ParseFloat(s, 32)
+		v = float32(f)
+	case typeDouble:
+		v, err = strconv.ParseFloat(s, 64)
+	case typeBoolean:
+		v, err = strconv.ParseBool(s)
+	default:
+		v = s
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot convert %q of field %q: %w", s, c.field, err)
+	}
+	return v, nil
+}
+
+// compiler expands the references of a pattern into a regular expression.
+type compiler struct {
+	library  map[string]string
+	captures []capture
+	// stack holds the names of the patterns being expanded, to detect cycles
+	stack []string
+}
+
+func compile(raw string, library map[string]string) (*pattern, error) {
+	c := &compiler{library: library}
+	expr, err := c.expand(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pattern{raw: raw, re: re, captures: c.captures}
+	for i := range p.captures {
+		p.captures[i].group = re.SubexpIndex(groupName(i))
+	}
+	return p, nil
+}
+
+func (c *compiler) expand(expr string) (string, error) {
+	if len(c.stack) > maxDepth {
+		return "", fmt.Errorf("patterns are nested deeper than %d levels", maxDepth)
+	}
+
+	var (
+		b    strings.Builder
+		last int
+	)
+	for _, loc := range tokenRegexp.FindAllStringSubmatchIndex(expr, -1) {
+		b.WriteString(expr[last:loc[0]])
+		last = loc[1]
+
+		// named group, its name is replaced with a generated one
+		if loc[8] >= 0 {
+			b.WriteString("(?P<" + c.addCapture(expr[loc[8]:loc[9]], typeString) + ">")
+			continue
+		}
+
+		name := expr[loc[2]:loc[3]]
+		def, ok := c.library[name]
+		if !ok {
+			return "", fmt.Errorf("pattern %q is not defined", name)
+		}
+		for _, parent := range c.stack {
+			if parent == name {
+				return "", fmt.Errorf("pattern %q references itself", name)
+			}
+		}
+
+		var group string
+		if loc[4] >= 0 {
+			typ := typeString
+			if loc[6] >= 0 {
+				typeName := expr[loc[6]:loc[7]]
+				if typ, ok = captureTypes[typeName]; !ok {
+					return "", fmt.Errorf("unsupported type %q of field %q", typeName, expr[loc[4]:loc[5]])
+				}
+			}
+			group = c.addCapture(expr[loc[4]:loc[5]], typ)
+		}
+
+		c.stack = append(c.stack, name)
+		expanded, err := c.expand(def)
+		c.stack = c.stack[:len(c.stack)-1]
+		if err != nil {
+			return "", err
+		}
+
+		if group != "" {
+			b.WriteString("(?P<" + group + ">" + expanded + ")")
+		} else {
+			b.WriteString("(?:" + expanded + ")")
+		}
+	}
+	b.WriteString(expr[last:])
+	return b.String(), nil
+}
+
+// addCapture registers a capture for field and returns the name of its group.
+func (c *compiler) addCapture(field string, typ captureType) string {
+	c.captures = append(c.captures, capture{field: fieldName(field), typ: typ})
+	return groupName(len(c.captures) - 1)
+}
+
+func groupName(i int) string {
+	return "_grok" + strconv.Itoa(i)
+}
+
+// fieldName converts field references like [source][ip] into dotted keys.
+func fieldName(name string) string {
+	if !strings.HasPrefix(name, "[") {
+		return name
+	}
+	name = strings.TrimSuffix(strings.TrimPrefix(name, "["), "]")
+	return strings.ReplaceAll(name, "][", ".")
+}
@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestMatch(t *testing.T) {
+	tests := map[string]struct {
+		patterns    []string
+		definitions map[string]string
+		text        string
+		want        mapstr.M
+	}{
+		"simple": {
+			patterns: []string{`%{IP:client.ip} %{WORD:http.request.method} %{URIPATHPARAM:url.original} %{NUMBER:http.response.bytes:long} %{NUMBER:event.duration:double}`},
+			text:     "55.3.244.1 GET /index.html 15824 0.043",
+			want: mapstr.M{
+				"client": mapstr.M{"ip": "55.3.244.1"},
+				"http":   mapstr.M{"request": mapstr.M{"method": "GET"}, "response": mapstr.M{"bytes": int64(15824)}},
+				"url":    mapstr.M{"original": "/index.html"},
+				"event":  mapstr.M{"duration": 0.043},
+			},
+		},
+		"types": {
+			patterns: []string{`%{INT:i:int} %{INT:l:long} %{NUMBER:f:float} %{NUMBER:d:double} %{WORD:b:boolean} %{WORD:s:string}`},
+			text:     "1 2 3.5 4.25 true five",
+			want: mapstr.M{
+				"i": int32(1),
+				"l": int64(2),
+				"f": float32(3.5),
+				"d": 4.25,
+				"b": true,
+				"s": "five",
+			},
+		},
+		"patterns tried in order": {
+			patterns: []string{
+				`^%{NUMBER:number:long}$`,
+				`^%{WORD:word}$`,
+				`^%{GREEDYDATA:rest}$`,
+			},
+			text: "hello",
+			want: mapstr.M{"word": "hello"},
+		},
+		"optional parts are omitted": {
+			patterns: []string{`^%{WORD:level}(?: \[%{DATA:thread}\])? %{GREEDYDATA:message}$`},
+			text:     "INFO started",
+			want:     mapstr.M{"level": "INFO", "message": "started"},
+		},
+		"alternation": {
+			patterns: []string{`^(?:%{NUMBER:bytes:long}|-) %{WORD:status}$`},
+			text:     "- ok",
+			want:     mapstr.M{"status": "ok"},
+		},
+		"custom definitions": {
+			patterns:    []string{`%{SESSION:session.id} %{USERNAME:user.name}`},
+			definitions: map[string]string{"SESSION": `[a-f0-9]{8}`, "USERNAME": `[a-z]+`},
+			text:        "deadbeef alice",
+			want:        mapstr.M{"session": mapstr.M{"id": "deadbeef"}, "user": mapstr.M{"name": "alice"}},
+		},
+		"named groups": {
+			patterns: []string{`(?<process.pid>\d+) (?P<process.name>\w+)`},
+			text:     "42 beat",
+			want:     mapstr.M{"process": mapstr.M{"pid": "42", "name": "beat"}},
+		},
+		"field references": {
+			patterns: []string{`%{IP:[source][ip]}:%{POSINT:[source][port]:int}`},
+			text:     "10.0.0.1:5601",
+			want:     mapstr.M{"source": mapstr.M{"ip": "10.0.0.1", "port": int32(5601)}},
+		},
+		"nested captures": {
+			patterns: []string{`%{SYSLOGBASE} %{GREEDYDATA:message}`},
+			text:     "Mar  7 12:01:02 host sshd[1234]: Accepted password",
+			want: mapstr.M{
+				"timestamp": "Mar  7 12:01:02",
+				"logsource": "host",
+				"program":   "sshd",
+				"pid":       "1234",
+				"message":   "Accepted password",
+			},
+		},
+		"combined apache log": {
+			patterns: []string{`%{COMBINEDAPACHELOG}`},
+			text:     `127.0.0.1 - frank [10/Oct/2000:13:55:36 -0700] "GET /apache_pb.gif HTTP/1.0" 200 2326 "http://www.example.com/start.html" "Mozilla/4.08"`,
+			want: mapstr.M{
+				"clientip":    "127.0.0.1",
+				"ident":       "-",
+				"auth":        "frank",
+				"timestamp":   "10/Oct/2000:13:55:36 -0700",
+				"verb":        "GET",
+				"request":     "/apache_pb.gif",
+				"httpversion": "1.0",
+				"response":    "200",
+				"bytes":       "2326",
+				"referrer":    `"http://www.example.com/start.html"`,
+				"agent":       `"Mozilla/4.08"`,
+			},
+		},
+		"ipv6": {
+			patterns: []string{`^%{IP:ip}$`},
+			text:     "2001:db8::ff00:42:8329",
+			want:     mapstr.M{"ip": "2001:db8::ff00:42:8329"},
+		},
+		"iso8601 timestamp": {
+			patterns: []string{`^%{TIMESTAMP_ISO8601:ts} %{LOGLEVEL:level}`},
+			text:     "2024-05-01T10:11:12.123Z WARN disk almost full",
+			want:     mapstr.M{"ts": "2024-05-01T10:11:12.123Z", "level": "WARN"},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			g, err := New(tc.patterns, tc.definitions)
+			require.NoError(t, err)
+
+			got, err := g.Match(tc.text)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestNoMatch(t *testing.T) {
+	g, err := New([]string{`^%{NUMBER:n}$`, `^%{IP:ip}$`}, nil)
+	require.NoError(t, err)
+
+	_, err = g.Match("not a number")
+	require.ErrorIs(t, err, ErrNoMatch)
+}
+
+func TestConversionError(t *testing.T) {
+	g, err := New([]string{`%{WORD:n:int}`}, nil)
+	require.NoError(t, err)
+
+	_, err = g.Match("abc")
+	require.ErrorContains(t, err, `cannot convert "abc" of field "n"`)
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]struct {
+		patterns    []string
+		definitions map[string]string
+		err         string
+	}{
+		"no patterns": {
+			err: "no grok pattern is configured",
+		},
+		"undefined pattern": {
+			patterns: []string{`%{NOPE:x}`},
+			err:      `pattern "NOPE" is not defined`,
+		},
+		"unsupported type": {
+			patterns: []string{`%{INT:x:bigint}`},
+			err:      `unsupported type "bigint" of field "x"`,
+		},
+		"recursive definitions": {
+			patterns:    []string{`%{A}`},
+			definitions: map[string]string{"A": `a%{B}`, "B": `b%{A}`},
+			err:         `pattern "A" references itself`,
+		},
+		"invalid regular expression": {
+			patterns: []string{`%{INT:x}(`},
+			err:      "missing closing )",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tc.patterns, tc.definitions)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestStandardPatterns(t *testing.T) {
+	for name := range standardPatterns {
+		_, err := New([]string{"%{" + name + ":field}"}, nil)
+		assert.NoError(t, err, name)
+	}
+}
@@ -0,0 +1,106 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package grok
+
+import (
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// FlagParsingError is the default flag added to messages that do not match any pattern.
+const FlagParsingError = "grok_parsing_error"
+
+// Config is the configuration of the grok parser.
+type Config struct {
+	// Patterns are tried in order, the first one that matches is used.
+	Patterns []string `config:"patterns" validate:"required"`
+	// PatternDefinitions are custom patterns that can be referenced by Patterns.
+	PatternDefinitions map[string]string `config:"pattern_definitions"`
+	// Target is the field the captures are stored under. If empty, captures
+	// are stored at the root of the message fields.
+	Target string `config:"target"`
+	// TagOnFailure are the flags added to log.flags if no pattern matches.
+	TagOnFailure []string `config:"tag_on_failure"`
+}
+
+// DefaultConfig returns the default configuration of the grok parser.
+func DefaultConfig() Config {
+	return Config{
+		TagOnFailure: []string{FlagParsingError},
+	}
+}
+
+// Validate compiles the patterns to check they are valid.
+func (c *Config) Validate() error {
+	_, err := New(c.Patterns, c.PatternDefinitions)
+	return err
+}
+
+// Parser matches the content of messages against grok patterns and adds the
+// captures to the message fields.
+type Parser struct {
+	reader reader.Reader
+	grok   *Grok
+	cfg    *Config
+}
+
+// NewParser creates a new grok parser reading from r.
+func NewParser(r reader.Reader, cfg *Config) (*Parser, error) {
+	g, err := New(cfg.Patterns, cfg.PatternDefinitions)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{reader: r, grok: g, cfg: cfg}, nil
+}
+
+// Next reads the next message and matches its content against the patterns.
+// If the patterns capture a message field at the root of the fields, it
+// replaces the content of the message.
+func (p *Parser) Next() (reader.Message, error) {
+	msg, err := p.reader.Next()
+	if err != nil || len(msg.Content) == 0 {
+		return msg, err
+	}
+
+	fields, err := p.grok.Match(string(msg.Content))
+	if err != nil {
+		if len(p.cfg.TagOnFailure) > 0 {
+			_ = msg.AddFlagsWithKey("log.flags", p.cfg.TagOnFailure...)
+		}
+		return msg, nil
+	}
+
+	if p.cfg.Target != "" {
+		target := mapstr.M{}
+		_, _ = target.Put(p.cfg.Target, fields)
+		msg.AddFields(target)
+		return msg, nil
+	}
+
+	if text, ok := fields["message"].(string); ok {
+		msg.Content = []byte(text)
+		delete(fields, "message")
+	}
+	msg.AddFields(fields)
+	return msg, nil
+}
+
+// Close closes the underlying reader.
+func (p *Parser) Close() error {
+	return p.reader.Close()
+}
@@ -0,0 +1,243 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package kv decodes key-value formatted text, such as logfmt, into fields.
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// ErrNoPairs is returned when text does not contain any key-value pair.
+var ErrNoPairs = errors.New("text does not contain any key-value pair")
+
+// Config configures how text is split into key-value pairs and how the
+// pairs are turned into fields.
+type Config struct {
+	// FieldSplit separates the key-value pairs.
+	FieldSplit string `config:"field_split" validate:"required"`
+	// ValueSplit separates a key from its value.
+	ValueSplit string `config:"value_split" validate:"required"`
+	// Prefix is prepended to every key.
+	Prefix string `config:"prefix"`
+	// IncludeKeys are the only keys that are kept, if set.
+	IncludeKeys []string `config:"include_keys"`
+	// ExcludeKeys are keys that are dropped.
+	ExcludeKeys []string `config:"exclude_keys"`
+	// Convert converts the values of keys to other types.
+	Convert []Conversion `config:"convert"`
+}
+
+// Conversion converts the value of a key to a type.
+type Conversion struct {
+	Key  string `config:"key" validate:"required"`
+	Type string `config:"type" validate:"required"`
+}
+
+// DefaultConfig returns a configuration that decodes logfmt.
+func DefaultConfig() Config {
+	return Config{
+		FieldSplit: " ",
+		ValueSplit: "=",
+	}
+}
+
+// Validate checks the conversions are supported.
+func (c *Config) Validate() error {
+	if c.FieldSplit == c.ValueSplit {
+		return fmt.Errorf("field_split and value_split must be different, both are %q", c.FieldSplit)
+	}
+	for _, conv := range c.Convert {
+		if _, ok := converters[conv.Type]; !ok {
+			return fmt.Errorf("unsupported type %q for key %q", conv.Type, conv.Key)
+		}
+	}
+	return nil
+}
+
+type converter func(string) (interface{}, error)
+
+var converters = map[string]converter{
+	"integer": func(s string) (interface{}, error) {
+		i, err := strconv.ParseInt(s, 10, 32)
+		return int32(i), err
+	},
+	"long": func(s string) (interface{}, error) {
+		return strconv.ParseInt(s, 10, 64)
+	},
+	"float": func(s string) (interface{}, error) {
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	},
+	"double": func(s string) (interface{}, error) {
+		return strconv.ParseFloat(s, 64)
+	},
+	"boolean": func(s string) (interface{}, error) {
+		return strconv.ParseBool(s)
+	},
+	"string": func(s string) (interface{}, error) {
+		return s, nil
+	},
+}
+
+// Decoder decodes key-value pairs from text.
+type Decoder struct {
+	fieldSplit string
+	valueSplit string
+	prefix     string
+	include    map[string]struct{}
+	exclude    map[string]struct{}
+	convert    map[string]converter
+}
+
+// NewDecoder creates a decoder from the configuration.
+func NewDecoder(c Config) (*Decoder, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	d := &Decoder{
+		fieldSplit: c.FieldSplit,
+		valueSplit: c.ValueSplit,
+		prefix:     c.Prefix,
+		include:    toSet(c.IncludeKeys),
+		exclude:    toSet(c.ExcludeKeys),
+		convert:    make(map[string]converter, len(c.Convert)),
+	}
+	for _, conv := range c.Convert {
+		d.convert[conv.Key] = converters[conv.Type]
+	}
+	return d, nil
+}
+
+// Decode splits text into key-value pairs and returns them as fields.
+// Values can be quoted with double or single quotes, a backslash escapes
+// the quote character inside a quoted value. Whitespace around keys and
+// unquoted values is removed. Tokens without a value separator are
+// ignored. If a key appears more than once, the last value is kept.
+// ErrNoPairs is returned if text does not contain any pair.
+func (d *Decoder) Decode(text string) (mapstr.M, error) {
+	fields := mapstr.M{}
+	found := false
+
+	for pos := 0; pos < len(text); {
+		// skip consecutive field separators
+		if strings.HasPrefix(text[pos:], d.fieldSplit) {
+			pos += len(d.fieldSplit)
+			continue
+		}
+
+		fieldEnd := indexFrom(text, d.fieldSplit, pos)
+		valueSplit := indexFrom(text, d.valueSplit, pos)
+		if valueSplit >= fieldEnd {
+			// token without a value separator
+			pos = fieldEnd
+			continue
+		}
+
+		key := strings.TrimSpace(text[pos:valueSplit])
+		pos = valueSplit + len(d.valueSplit)
+
+		var value string
+		if pos < len(text) && (text[pos] == '"' || text[pos] == '\'') {
+			value, pos = unquote(text, pos)
+		} else {
+			end := indexFrom(text, d.fieldSplit, pos)
+			value, pos = strings.TrimSpace(text[pos:end]), end
+		}
+
+		if key == "" {
+			continue
+		}
+		found = true
+		if !d.keep(key) {
+			continue
+		}
+
+		var v interface{} = value
+		if convert, ok := d.convert[key]; ok {
+			var err error
+			if v, err = convert(value); err != nil {
+				return nil, fmt.Errorf("cannot convert value %q of key %q: %w", value, key, err)
+			}
+		}
+		if _, err := fields.Put(d.prefix+key, v); err != nil {
+			return nil, fmt.Errorf("cannot add key %q: %w", d.prefix+key, err)
+		}
+	}
+
+	if !found {
+		return nil, ErrNoPairs
+	}
+	return fields, nil
+}
+
+func (d *Decoder) keep(key string) bool {
+	if len(d.include) > 0 {
+		if _, ok := d.include[key]; !ok {
+			return false
+		}
+	}
+	_, excluded := d.exclude[key]
+	return !excluded
+}
+
+// indexFrom returns the index of sep in text after pos, or the length of
+// text if sep is not found.
+func indexFrom(text, sep string, pos int) int {
+	i := strings.Index(text[pos:], sep)
+	if i < 0 {
+		return len(text)
+	}
+	return pos + i
+}
+
+// unquote reads the quoted value starting at pos and returns it with the
+// position after the closing quote. An unterminated value extends to the
+// end of text.
+func unquote(text string, pos int) (string, int) {
+	quote := text[pos]
+	var b strings.Builder
+	for i := pos + 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\' && i+1 < len(text) && (text[i+1] == quote || text[i+1] == '\\'):
+			i++
+			b.WriteByte(text[i])
+		case c == quote:
+			return b.String(), i + 1
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), len(text)
+}
+
+func toSet(keys []string) map[string]struct{} {
+	if len(keys) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return set
+}
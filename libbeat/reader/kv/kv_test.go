@@ -0,0 +1,168 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestDecode(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		text   string
+		fields mapstr.M
+		err    string
+	}{
+		"logfmt": {
+			text: `level=info msg="request done" duration=12ms ok`,
+			fields: mapstr.M{
+				"level":    "info",
+				"msg":      "request done",
+				"duration": "12ms",
+			},
+		},
+		"quotes and escapes": {
+			text: `a='single quoted' b="say \"hi\"" c="back\\slash" d=""`,
+			fields: mapstr.M{
+				"a": "single quoted",
+				"b": `say "hi"`,
+				"c": `back\slash`,
+				"d": "",
+			},
+		},
+		"unterminated quote": {
+			text:   `a=1 b="open`,
+			fields: mapstr.M{"a": "1", "b": "open"},
+		},
+		"custom separators": {
+			config: map[string]interface{}{"field_split": "&", "value_split": ":"},
+			text:   "a:1&&b:two words&c:x=y",
+			fields: mapstr.M{"a": "1", "b": "two words", "c": "x=y"},
+		},
+		"multi character separators": {
+			config: map[string]interface{}{"field_split": ", ", "value_split": " => "},
+			text:   "a => 1, b => 2 ",
+			fields: mapstr.M{"a": "1", "b": "2"},
+		},
+		"dotted keys and prefix": {
+			config: map[string]interface{}{"prefix": "kv."},
+			text:   "user.name=alice user.id=1",
+			fields: mapstr.M{"kv": mapstr.M{"user": mapstr.M{"name": "alice", "id": "1"}}},
+		},
+		"last duplicate wins": {
+			text:   "a=1 a=2",
+			fields: mapstr.M{"a": "2"},
+		},
+		"include and exclude keys": {
+			config: map[string]interface{}{
+				"include_keys": []string{"a", "b"},
+				"exclude_keys": []string{"b"},
+			},
+			text:   "a=1 b=2 c=3",
+			fields: mapstr.M{"a": "1"},
+		},
+		"convert": {
+			config: map[string]interface{}{
+				"convert": []map[string]interface{}{
+					{"key": "i", "type": "integer"},
+					{"key": "l", "type": "long"},
+					{"key": "f", "type": "float"},
+					{"key": "d", "type": "double"},
+					{"key": "b", "type": "boolean"},
+					{"key": "s", "type": "string"},
+				},
+			},
+			text: "i=1 l=5000000000 f=1.5 d=2.25 b=true s=3",
+			fields: mapstr.M{
+				"i": int32(1),
+				"l": int64(5000000000),
+				"f": float32(1.5),
+				"d": 2.25,
+				"b": true,
+				"s": "3",
+			},
+		},
+		"conversion error": {
+			config: map[string]interface{}{
+				"convert": []map[string]interface{}{{"key": "n", "type": "long"}},
+			},
+			text: "n=abc",
+			err:  `cannot convert value "abc" of key "n"`,
+		},
+		"no pairs": {
+			text: "just some text",
+			err:  ErrNoPairs.Error(),
+		},
+		"excluded pairs are still pairs": {
+			config: map[string]interface{}{"exclude_keys": []string{"a"}},
+			text:   "a=1",
+			fields: mapstr.M{},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			if tc.config != nil {
+				require.NoError(t, conf.MustNewConfigFrom(tc.config).Unpack(&cfg))
+			}
+			d, err := NewDecoder(cfg)
+			require.NoError(t, err)
+
+			fields, err := d.Decode(tc.text)
+			if tc.err != "" {
+				require.ErrorContains(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.fields, fields)
+		})
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tests := map[string]struct {
+		config map[string]interface{}
+		err    string
+	}{
+		"same separators": {
+			config: map[string]interface{}{"field_split": "=", "value_split": "="},
+			err:    "field_split and value_split must be different",
+		},
+		"unsupported type": {
+			config: map[string]interface{}{
+				"convert": []map[string]interface{}{{"key": "a", "type": "date"}},
+			},
+			err: `unsupported type "date" for key "a"`,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			err := conf.MustNewConfigFrom(tc.config).Unpack(&cfg)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
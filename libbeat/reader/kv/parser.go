@@ -0,0 +1,99 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"github.com/elastic/beats/v7/libbeat/reader"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// FlagParsingError is the default flag added to messages that do not contain
+// any key-value pair or whose values cannot be converted.
+const FlagParsingError = "kv_parsing_error"
+
+// ParserConfig is the configuration of the kv parser.
+type ParserConfig struct {
+	Config `config:",inline"`
+	// Target is the field the pairs are stored under. If empty, pairs are
+	// stored at the root of the message fields.
+	Target string `config:"target"`
+	// TagOnFailure are the flags added to log.flags if decoding fails.
+	TagOnFailure []string `config:"tag_on_failure"`
+}
+
+// DefaultParserConfig returns the default configuration of the kv parser.
+func DefaultParserConfig() ParserConfig {
+	return ParserConfig{
+		Config:       DefaultConfig(),
+		TagOnFailure: []string{FlagParsingError},
+	}
+}
+
+// Parser decodes the content of messages as key-value pairs and adds them
+// to the message fields.
+type Parser struct {
+	reader  reader.Reader
+	decoder *Decoder
+	cfg     *ParserConfig
+}
+
+// NewParser creates a new kv parser reading from r.
+func NewParser(r reader.Reader, cfg *ParserConfig) (*Parser, error) {
+	d, err := NewDecoder(cfg.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{reader: r, decoder: d, cfg: cfg}, nil
+}
+
+// Next reads the next message and decodes its content. If the content has
+// a message key that is stored at the root of the fields, its value
+// replaces the content of the message.
+func (p *Parser) Next() (reader.Message, error) {
+	msg, err := p.reader.Next()
+	if err != nil || len(msg.Content) == 0 {
+		return msg, err
+	}
+
+	fields, err := p.decoder.Decode(string(msg.Content))
+	if err != nil {
+		if len(p.cfg.TagOnFailure) > 0 {
+			_ = msg.AddFlagsWithKey("log.flags", p.cfg.TagOnFailure...)
+		}
+		return msg, nil
+	}
+
+	if p.cfg.Target != "" {
+		target := mapstr.M{}
+		_, _ = target.Put(p.cfg.Target, fields)
+		msg.AddFields(target)
+		return msg, nil
+	}
+
+	if text, ok := fields["message"].(string); ok {
+		msg.Content = []byte(text)
+		delete(fields, "message")
+	}
+	msg.AddFields(fields)
+	return msg, nil
+}
+
+// Close closes the underlying reader.
+func (p *Parser) Close() error {
+	return p.reader.Close()
+}
@@ -0,0 +1,102 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package kv
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/reader"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type testReader struct {
+	lines []string
+}
+
+func (r *testReader) Next() (reader.Message, error) {
+	if len(r.lines) == 0 {
+		return reader.Message{}, io.EOF
+	}
+	line := r.lines[0]
+	r.lines = r.lines[1:]
+	return reader.Message{Content: []byte(line), Bytes: len(line) + 1, Fields: mapstr.M{}}, nil
+}
+
+func (r *testReader) Close() error { return nil }
+
+func TestParser(t *testing.T) {
+	tests := map[string]struct {
+		config  map[string]interface{}
+		line    string
+		content string
+		fields  mapstr.M
+	}{
+		"pairs at the root": {
+			line:    `level=error message="something failed"`,
+			content: "something failed",
+			fields:  mapstr.M{"level": "error"},
+		},
+		"pairs under target": {
+			config:  map[string]interface{}{"target": "kv"},
+			line:    `level=error message="something failed"`,
+			content: `level=error message="something failed"`,
+			fields:  mapstr.M{"kv": mapstr.M{"level": "error", "message": "something failed"}},
+		},
+		"no pairs": {
+			line:    "something failed",
+			content: "something failed",
+			fields:  mapstr.M{"log": mapstr.M{"flags": []string{FlagParsingError}}},
+		},
+		"conversion error with custom tags": {
+			config: map[string]interface{}{
+				"convert":        []map[string]interface{}{{"key": "status", "type": "integer"}},
+				"tag_on_failure": []string{"bad_status"},
+			},
+			line:    "status=ok",
+			content: "status=ok",
+			fields:  mapstr.M{"log": mapstr.M{"flags": []string{"bad_status"}}},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cfg := DefaultParserConfig()
+			if tc.config != nil {
+				require.NoError(t, conf.MustNewConfigFrom(tc.config).Unpack(&cfg))
+			}
+
+			p, err := NewParser(&testReader{lines: []string{tc.line}}, &cfg)
+			require.NoError(t, err)
+
+			msg, err := p.Next()
+			require.NoError(t, err)
+			assert.Equal(t, tc.content, string(msg.Content))
+			assert.Equal(t, len(tc.line)+1, msg.Bytes, "the size of the message must not change")
+			assert.Equal(t, tc.fields, msg.Fields)
+
+			_, err = p.Next()
+			assert.True(t, errors.Is(err, io.EOF))
+		})
+	}
+}
@@ -27,6 +27,8 @@ import (
 	"github.com/elastic/beats/v7/libbeat/common/cfgtype"
 	"github.com/elastic/beats/v7/libbeat/reader"
 	"github.com/elastic/beats/v7/libbeat/reader/filter"
+	"github.com/elastic/beats/v7/libbeat/reader/grok"
+	"github.com/elastic/beats/v7/libbeat/reader/kv"
 	"github.com/elastic/beats/v7/libbeat/reader/multiline"
 	"github.com/elastic/beats/v7/libbeat/reader/readfile"
 	"github.com/elastic/beats/v7/libbeat/reader/readjson"
@@ -128,6 +130,20 @@ func NewConfig(pCfg CommonConfig, parsers []config.Namespace) (*Config, error) {
 			if err != nil {
 				return nil, fmt.Errorf("error while parsing include_message parser config: %w", err)
 			}
+		case "grok":
+			config := grok.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing grok parser config: %w", err)
+			}
+		case "kv":
+			config := kv.DefaultParserConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing kv parser config: %w", err)
+			}
 		default:
 			return nil, fmt.Errorf("%s: %w", name, ErrNoSuchParser)
 		}
@@ -189,6 +205,30 @@ func (c *Config) Create(in reader.Reader) Parser {
 				return p
 			}
 			p = filter.NewParser(p, &config)
+		case "grok":
+			config := grok.DefaultConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return p
+			}
+			gp, err := grok.NewParser(p, &config)
+			if err != nil {
+				return p
+			}
+			p = gp
+		case "kv":
+			config := kv.DefaultParserConfig()
+			cfg := ns.Config()
+			err := cfg.Unpack(&config)
+			if err != nil {
+				return p
+			}
+			kp, err := kv.NewParser(p, &config)
+			if err != nil {
+				return p
+			}
+			p = kp
 		default:
 			return p
 		}
@@ -301,6 +301,62 @@ func TestParsersConfigAndReading(t *testing.T) {
 `,
 			},
 		},
+		"grok": {
+			lines: "INFO started\nERROR failed to connect\nnot a log line\n",
+			parsers: map[string]interface{}{
+				"parsers": []map[string]interface{}{
+					{
+						"grok": map[string]interface{}{
+							"patterns": []string{`^%{LOGLEVEL:log.level} %{GREEDYDATA:message}`},
+						},
+					},
+				},
+			},
+			expectedMessages: []string{
+				"started",
+				"failed to connect",
+				"not a log line\n",
+			},
+		},
+		"grok with undefined pattern": {
+			parsers: map[string]interface{}{
+				"parsers": []map[string]interface{}{
+					{
+						"grok": map[string]interface{}{
+							"patterns": []string{`%{UNDEFINED:field}`},
+						},
+					},
+				},
+			},
+			expectedError: `pattern "UNDEFINED" is not defined`,
+		},
+		"kv": {
+			lines: "level=info message=started\nlevel=error message=\"failed to connect\"\nnot a log line\n",
+			parsers: map[string]interface{}{
+				"parsers": []map[string]interface{}{
+					{
+						"kv": map[string]interface{}{},
+					},
+				},
+			},
+			expectedMessages: []string{
+				"started",
+				"failed to connect",
+				"not a log line\n",
+			},
+		},
+		"kv with unsupported conversion": {
+			parsers: map[string]interface{}{
+				"parsers": []map[string]interface{}{
+					{
+						"kv": map[string]interface{}{
+							"convert": []map[string]interface{}{{"key": "a", "type": "date"}},
+						},
+					},
+				},
+			},
+			expectedError: `unsupported type "date" for key "a"`,
+		},
 	}
 
 	for name, test := range tests {
@@ -0,0 +1,146 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bolt
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+// Registry configures access to bolt based stores.
+type Registry struct {
+	log *logp.Logger
+
+	mu     sync.Mutex
+	active bool
+
+	settings Settings
+}
+
+// Settings configures a new Registry.
+type Settings struct {
+	// Registry root directory. Stores will be single sub-directories.
+	Root string
+
+	// FileMode is used to configure the file mode for new files generated by the
+	// registry.  File mode 0600 will be used if this field is not set.
+	FileMode os.FileMode
+
+	// Timeout configures how long to wait for the lock of a database file that
+	// is in use by another process. Defaults to 5s if not set.
+	Timeout time.Duration
+
+	// SyncInterval configures how often updates are synced to disk. Updates
+	// are not synced on commit if set, so they can be lost on an operating
+	// system crash or power loss. If not set, every update is synced.
+	SyncInterval time.Duration
+}
+
+const (
+	defaultFileMode os.FileMode = 0600
+	defaultTimeout              = 5 * time.Second
+
+	dbFileName = "store.db"
+)
+
+// New configures a bolt Registry that can be used to open stores.
+func New(log *logp.Logger, settings Settings) (*Registry, error) {
+	if settings.FileMode == 0 {
+		settings.FileMode = defaultFileMode
+	}
+	if settings.Timeout == 0 {
+		settings.Timeout = defaultTimeout
+	}
+
+	root, err := filepath.Abs(settings.Root)
+	if err != nil {
+		return nil, err
+	}
+
+	settings.Root = root
+	return &Registry{
+		log:      log,
+		active:   true,
+		settings: settings,
+	}, nil
+}
+
+// Access creates or opens a new store. A new sub-directory for the store is
+// created, if the store does not exist. Existing memlog stores are migrated
+// when the store is opened for the first time.
+func (r *Registry) Access(name string) (backend.Store, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.active {
+		return nil, errRegClosed
+	}
+
+	logger := r.log.With("store", name)
+
+	home := filepath.Join(r.settings.Root, name)
+	if err := os.MkdirAll(home, os.ModeDir|0770); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(home, dbFileName)
+	if _, err := os.Stat(path); os.IsNotExist(err) && hasMemlogStore(home) {
+		if err := migrateMemlog(logger, r.settings, name, path); err != nil {
+			return nil, fmt.Errorf("failed to migrate memlog store '%v': %w", name, err)
+		}
+	}
+
+	return openStore(path, r.settings)
+}
+
+// Close closes the registry. No new store can be accessed after close.
+func (r *Registry) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = false
+	return nil
+}
+
+func openDB(path string, settings Settings) (*bbolt.DB, error) {
+	db, err := bbolt.Open(path, settings.FileMode, &bbolt.Options{
+		Timeout:        settings.Timeout,
+		FreelistType:   bbolt.FreelistMapType,
+		NoFreelistSync: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database file '%v': %w", path, err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucketName)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
@@ -0,0 +1,202 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+//go:build !integration
+
+package bolt
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/beats/v7/libbeat/statestore/internal/storecompliance"
+	"github.com/elastic/elastic-agent-libs/logp"
+)
+
+func init() {
+	logp.DevelopmentSetup()
+}
+
+func TestCompliance(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		return New(logp.NewLogger("test"), Settings{Root: testPath})
+	})
+}
+
+func TestComplianceSyncInterval(t *testing.T) {
+	storecompliance.TestBackendCompliance(t, func(testPath string) (backend.Registry, error) {
+		return New(logp.NewLogger("test"), Settings{Root: testPath, SyncInterval: 10 * time.Millisecond})
+	})
+}
+
+type testState struct {
+	Offset uint64 `struct:"offset"`
+	Source string `struct:"source"`
+}
+
+func writeMemlogStore(t *testing.T, root string, entries map[string]testState) {
+	t.Helper()
+	reg, err := memlog.New(logp.NewLogger("test"), memlog.Settings{Root: root})
+	require.NoError(t, err)
+	defer reg.Close()
+
+	store, err := reg.Access("filebeat")
+	require.NoError(t, err)
+	defer store.Close()
+
+	for k, v := range entries {
+		require.NoError(t, store.Set(k, v))
+	}
+}
+
+func readAll(t *testing.T, store backend.Store) map[string]testState {
+	t.Helper()
+	out := map[string]testState{}
+	err := store.Each(func(key string, dec backend.ValueDecoder) (bool, error) {
+		var st testState
+		if err := dec.Decode(&st); err != nil {
+			return false, err
+		}
+		out[key] = st
+		return true, nil
+	})
+	require.NoError(t, err)
+	return out
+}
+
+func TestMigrateMemlog(t *testing.T) {
+	root := t.TempDir()
+	entries := map[string]testState{
+		"filestream::a::native::1-2": {Offset: 100, Source: "/var/log/a.log"},
+		"filestream::b::native::3-4": {Offset: 42, Source: "/var/log/b.log"},
+	}
+	writeMemlogStore(t, root, entries)
+
+	reg, err := New(logp.NewLogger("test"), Settings{Root: root})
+	require.NoError(t, err)
+	store, err := reg.Access("filebeat")
+	require.NoError(t, err)
+	assert.Equal(t, entries, readAll(t, store))
+
+	// updates after the migration must not be overwritten by the memlog state
+	require.NoError(t, store.Set("filestream::a::native::1-2", testState{Offset: 200, Source: "/var/log/a.log"}))
+	require.NoError(t, store.Remove("filestream::b::native::3-4"))
+	require.NoError(t, store.Close())
+
+	store, err = reg.Access("filebeat")
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, map[string]testState{
+		"filestream::a::native::1-2": {Offset: 200, Source: "/var/log/a.log"},
+	}, readAll(t, store))
+
+	home := filepath.Join(root, "filebeat")
+	assert.NoFileExists(t, filepath.Join(home, dbFileName+".migrate"))
+
+	// the memlog files are moved aside, so a memlog registry does not resume
+	// from the state at the time of the migration
+	assert.NoFileExists(t, filepath.Join(home, memlogMetaFileName))
+	assert.NoFileExists(t, filepath.Join(home, "log.json"))
+	assert.FileExists(t, filepath.Join(home, memlogBackupDir, memlogMetaFileName))
+	assert.FileExists(t, filepath.Join(home, memlogBackupDir, "log.json"))
+}
+
+func TestMigrateMemlogInterrupted(t *testing.T) {
+	root := t.TempDir()
+	entries := map[string]testState{"key": {Offset: 1, Source: "/var/log/c.log"}}
+	writeMemlogStore(t, root, entries)
+
+	// a left over file of an interrupted migration is replaced
+	home := filepath.Join(root, "filebeat")
+	require.NoError(t, os.WriteFile(filepath.Join(home, dbFileName+".migrate"), []byte("garbage"), 0600))
+
+	reg, err := New(logp.NewLogger("test"), Settings{Root: root})
+	require.NoError(t, err)
+	store, err := reg.Access("filebeat")
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, entries, readAll(t, store))
+}
+
+func TestSyncInterval(t *testing.T) {
+	root := t.TempDir()
+	reg, err := New(logp.NewLogger("test"), Settings{Root: root, SyncInterval: time.Hour})
+	require.NoError(t, err)
+
+	st, err := reg.Access("filebeat")
+	require.NoError(t, err)
+	require.NoError(t, st.Set("key", testState{Offset: 1, Source: "/var/log/d.log"}))
+	assert.True(t, st.(*store).dirty.Load())
+	require.NoError(t, st.Close())
+
+	st, err = reg.Access("filebeat")
+	require.NoError(t, err)
+	defer st.Close()
+	assert.Equal(t, map[string]testState{
+		"key": {Offset: 1, Source: "/var/log/d.log"},
+	}, readAll(t, st))
+}
+
+func TestAccessAfterClose(t *testing.T) {
+	reg, err := New(logp.NewLogger("test"), Settings{Root: t.TempDir()})
+	require.NoError(t, err)
+	require.NoError(t, reg.Close())
+
+	_, err = reg.Access("filebeat")
+	assert.ErrorIs(t, err, errRegClosed)
+}
+
+func BenchmarkSet(b *testing.B) {
+	registries := map[string]func(root string) (backend.Registry, error){
+		"memlog": func(root string) (backend.Registry, error) {
+			return memlog.New(logp.NewLogger("bench"), memlog.Settings{Root: root})
+		},
+		"bolt": func(root string) (backend.Registry, error) {
+			return New(logp.NewLogger("bench"), Settings{Root: root})
+		},
+		"bolt sync interval": func(root string) (backend.Registry, error) {
+			return New(logp.NewLogger("bench"), Settings{Root: root, SyncInterval: time.Second})
+		},
+	}
+
+	for name, newRegistry := range registries {
+		b.Run(name, func(b *testing.B) {
+			reg, err := newRegistry(b.TempDir())
+			require.NoError(b, err)
+			defer reg.Close()
+			store, err := reg.Access("filebeat")
+			require.NoError(b, err)
+			defer store.Close()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := "filestream::bench::native::" + strconv.Itoa(i%1000)
+				if err := store.Set(key, testState{Offset: uint64(i), Source: "/var/log/bench.log"}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
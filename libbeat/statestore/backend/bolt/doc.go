@@ -0,0 +1,33 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package bolt implements a disk based statestore backend on top of bbolt,
+// a pure Go B+tree key-value store.
+//
+// Unlike memlog, the bolt backend does not hold the key-value pairs in memory.
+// Each store is a single database file in the store directory. Every update is
+// committed in its own transaction, so no replay is required on startup.
+// Updates are synced to disk on commit, or periodically if a sync interval is
+// configured. Values are serialized to JSON, restricting them to the same
+// primitive types memlog supports.
+//
+// When a store is accessed for the first time and the store directory
+// contains a memlog store, all key-value pairs are migrated to the new
+// database. The memlog files are moved into the memlog.migrated
+// sub-directory afterwards, they can be removed once the migration
+// succeeded.
+package bolt
@@ -0,0 +1,197 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bolt
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend/memlog"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// memlogMetaFileName is the meta file every memlog store directory contains.
+const memlogMetaFileName = "meta.json"
+
+// memlogBackupDir is the sub-directory of the store directory the memlog
+// files are moved to after a successful migration.
+const memlogBackupDir = "memlog.migrated"
+
+// migrateBatchSize limits the number of key-value pairs written in a single
+// transaction during migration.
+const migrateBatchSize = 10000
+
+func hasMemlogStore(home string) bool {
+	_, err := os.Stat(filepath.Join(home, memlogMetaFileName))
+	return err == nil
+}
+
+// migrateMemlog copies all key-value pairs of the memlog store name into a
+// new database at path. The database is written to a temporary file first
+// and moved into place once complete, so an interrupted migration is
+// restarted on the next access. Afterwards the memlog files are moved into
+// the memlogBackupDir sub-directory, so switching back to memlog does not
+// silently resume from the state at the time of the migration.
+func migrateMemlog(log *logp.Logger, settings Settings, name, path string) error {
+	log.Infof("Migrating memlog store to %v", path)
+
+	tmpPath := path + ".migrate"
+	count, err := copyMemlog(log, settings, name, tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	log.Infof("Migrated %d entries from memlog store.", count)
+
+	home := filepath.Dir(path)
+	if err := backupMemlogFiles(home); err != nil {
+		log.Warnf("Failed to move the memlog files in %v aside: %v", home, err)
+		return nil
+	}
+	log.Infof("The memlog files have been moved to %v.", filepath.Join(home, memlogBackupDir))
+	return nil
+}
+
+func copyMemlog(log *logp.Logger, settings Settings, name, path string) (int, error) {
+	reg, err := memlog.New(log, memlog.Settings{
+		Root:     settings.Root,
+		FileMode: settings.FileMode,
+		// Never checkpoint, the memlog files are left untouched.
+		Checkpoint: func(uint64) bool { return false },
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer reg.Close()
+
+	src, err := reg.Access(name)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return 0, err
+	}
+	db, err := openDB(path, settings)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := copyEntries(db, src)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return count, err
+}
+
+// backupMemlogFiles moves all memlog files in home into memlogBackupDir. The
+// meta file is moved last, so the directory is not detected as memlog store
+// anymore once all files are moved.
+func backupMemlogFiles(home string) error {
+	backup := filepath.Join(home, memlogBackupDir)
+	if err := os.MkdirAll(backup, os.ModeDir|0770); err != nil {
+		return err
+	}
+
+	files, err := filepath.Glob(filepath.Join(home, "*.json"))
+	if err != nil {
+		return err
+	}
+	files = append(files, filepath.Join(home, "active.dat"), filepath.Join(home, "active.dat.new"))
+
+	move := func(f string) error {
+		err := os.Rename(f, filepath.Join(backup, filepath.Base(f)))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var errs []error
+	for _, f := range files {
+		if filepath.Base(f) == memlogMetaFileName {
+			continue
+		}
+		if err := move(f); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return move(filepath.Join(home, memlogMetaFileName))
+}
+
+func copyEntries(db *bbolt.DB, src backend.Store) (int, error) {
+	type entry struct {
+		key string
+		raw []byte
+	}
+
+	count := 0
+	batch := make([]entry, 0, migrateBatchSize)
+	flush := func() error {
+		err := db.Update(func(tx *bbolt.Tx) error {
+			bucket := tx.Bucket(bucketName)
+			for _, e := range batch {
+				if err := bucket.Put([]byte(e.key), e.raw); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		count += len(batch)
+		batch = batch[:0]
+		return err
+	}
+
+	err := src.Each(func(key string, dec backend.ValueDecoder) (bool, error) {
+		var value mapstr.M
+		if err := dec.Decode(&value); err != nil {
+			return false, fmt.Errorf("failed to decode key '%v': %w", key, err)
+		}
+		raw, err := encodeValue(value)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode key '%v': %w", key, err)
+		}
+
+		batch = append(batch, entry{key: key, raw: raw})
+		if len(batch) == migrateBatchSize {
+			if err := flush(); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err == nil && len(batch) > 0 {
+		err = flush()
+	}
+	return count, err
+}
@@ -0,0 +1,187 @@
+// Licensed to Elasticsearch B.V. under one or more contributor
+// license agreements. See the NOTICE file distributed with
+// this work for additional information regarding copyright
+// ownership. Elasticsearch B.V. licenses this file to you under
+// the Apache License, Version 2.0 (the "License"); you may
+// not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package bolt
+
+import (
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.etcd.io/bbolt"
+
+	"github.com/elastic/beats/v7/libbeat/common/transform/typeconv"
+	"github.com/elastic/beats/v7/libbeat/statestore/backend"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// store implements a bolt based store. All key-value pairs are stored in a
+// single bucket with the values encoded as JSON documents.
+//
+// If a sync interval is configured, transactions are committed without fsync
+// and the database file is synced periodically and on Close instead.
+type store struct {
+	db *bbolt.DB
+
+	dirty atomic.Bool
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// valueDecoder decodes a raw value. It is only valid within the transaction
+// the value has been read in.
+type valueDecoder []byte
+
+var bucketName = []byte("state")
+
+func openStore(path string, settings Settings) (*store, error) {
+	db, err := openDB(path, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &store{db: db, done: make(chan struct{})}
+	if settings.SyncInterval > 0 {
+		db.NoSync = true
+		s.wg.Add(1)
+		go s.syncLoop(settings.SyncInterval)
+	}
+	return s, nil
+}
+
+// Close syncs pending updates and closes the database file.
+func (s *store) Close() error {
+	close(s.done)
+	s.wg.Wait()
+
+	err := s.sync()
+	if closeErr := s.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func (s *store) syncLoop(interval time.Duration) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			// Errors are reported again by the next sync or on Close.
+			_ = s.sync()
+		}
+	}
+}
+
+// sync flushes the database file to disk if it has been updated since the
+// last sync.
+func (s *store) sync() error {
+	if !s.dirty.Swap(false) {
+		return nil
+	}
+	if err := s.db.Sync(); err != nil {
+		s.dirty.Store(true)
+		return err
+	}
+	return nil
+}
+
+// Has checks if the key is known.
+func (s *store) Has(key string) (bool, error) {
+	var exists bool
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		exists = tx.Bucket(bucketName).Get([]byte(key)) != nil
+		return nil
+	})
+	return exists, err
+}
+
+// Get retrieves and decodes the key-value pair into to.
+func (s *store) Get(key string, to interface{}) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		raw := tx.Bucket(bucketName).Get([]byte(key))
+		if raw == nil {
+			return errKeyUnknown
+		}
+		return valueDecoder(raw).Decode(to)
+	})
+}
+
+// Set inserts or overwrites a key-value pair. The value is normalized and
+// encoded before the transaction is started.
+func (s *store) Set(key string, value interface{}) error {
+	raw, err := encodeValue(value)
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).Put([]byte(key), raw)
+	})
+}
+
+// Remove removes a key from the store. The operation does not check if the
+// key exists.
+func (s *store) Remove(key string) error {
+	return s.update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(bucketName).Delete([]byte(key))
+	})
+}
+
+func (s *store) update(fn func(*bbolt.Tx) error) error {
+	err := s.db.Update(fn)
+	if err == nil && s.db.NoSync {
+		s.dirty.Store(true)
+	}
+	return err
+}
+
+// Each iterates over all key-value pairs in the store in key order. The store
+// must not be updated from within fn.
+func (s *store) Each(fn func(string, backend.ValueDecoder) (bool, error)) error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(bucketName).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			cont, err := fn(string(k), valueDecoder(v))
+			if !cont || err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func encodeValue(value interface{}) ([]byte, error) {
+	var tmp mapstr.M
+	if err := typeconv.Convert(&tmp, value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(tmp)
+}
+
+func (d valueDecoder) Decode(to interface{}) error {
+	var tmp map[string]interface{}
+	if err := json.Unmarshal(d, &tmp); err != nil {
+		return err
+	}
+	return typeconv.Convert(to, tmp)
+}
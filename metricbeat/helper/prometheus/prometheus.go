@@ -33,6 +33,10 @@ import (
 
 const acceptHeader = `text/plain;version=0.0.4;q=0.5,*/*;q=0.1`
 
+// protobufAcceptHeader prefers the protobuf format, the only one with native histograms,
+// and falls back to the text format.
+const protobufAcceptHeader = ProtobufType + `;proto=` + ProtobufMessageName + `;encoding=delimited;q=0.7,` + acceptHeader
+
 // Prometheus helper retrieves prometheus formatted metrics
 type Prometheus interface {
 	// GetFamilies requests metric families from prometheus endpoint and returns them
@@ -56,12 +60,22 @@ type httpfetcher interface {
 
 // NewPrometheusClient creates new prometheus helper
 func NewPrometheusClient(base mb.BaseMetricSet) (Prometheus, error) {
+	return newPrometheusClient(base, acceptHeader)
+}
+
+// NewPrometheusProtobufClient creates new prometheus helper that negotiates the protobuf
+// format with the endpoint, to also receive native histograms
+func NewPrometheusProtobufClient(base mb.BaseMetricSet) (Prometheus, error) {
+	return newPrometheusClient(base, protobufAcceptHeader)
+}
+
+func newPrometheusClient(base mb.BaseMetricSet, accept string) (Prometheus, error) {
 	http, err := helper.NewHTTP(base)
 	if err != nil {
 		return nil, err
 	}
 
-	http.SetHeaderDefault("Accept", acceptHeader)
+	http.SetHeaderDefault("Accept", accept)
 	http.SetHeaderDefault("Accept-Encoding", "gzip")
 	return &prometheus{http, base.Logger()}, nil
 }
@@ -29,6 +29,7 @@ import (
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/prometheus/prometheus/model/textparse"
 	"github.com/prometheus/prometheus/model/timestamp"
@@ -42,6 +43,9 @@ const (
 	TextVersion                  = "0.0.4"
 	OpenMetricsType              = `application/openmetrics-text`
 	ContentTypeTextFormat string = `text/plain; version=` + TextVersion + `; charset=utf-8`
+	ProtobufType                 = `application/vnd.google.protobuf`
+	ProtobufMessageName          = `io.prometheus.client.MetricFamily`
+	ContentTypeProtobuf   string = ProtobufType + `; proto=` + ProtobufMessageName + `; encoding=delimited`
 )
 
 type Gauge struct {
@@ -178,6 +182,9 @@ type Histogram struct {
 	SampleSum        *float64
 	Bucket           []*Bucket
 	IsGaugeHistogram bool
+	// Native is the native histogram, only available in the protobuf format.
+	// A histogram can have both classic buckets and a native histogram.
+	Native *histogram.FloatHistogram
 }
 
 func (m *Histogram) GetSampleCount() uint64 {
@@ -201,6 +208,13 @@ func (m *Histogram) GetBucket() []*Bucket {
 	return nil
 }
 
+func (m *Histogram) GetNative() *histogram.FloatHistogram {
+	if m != nil {
+		return m.Native
+	}
+	return nil
+}
+
 type OpenMetric struct {
 	Label       []*labels.Label
 	Exemplar    *exemplar.Exemplar
@@ -479,8 +493,35 @@ func histogramMetricName(name string, s float64, qv string, lbls string, t *int6
 	return name, metric
 }
 
+/*
+nativeHistogramMetric returns a metric with the native histogram h. The classic buckets of the same histogram, that
+the protobuf parser returns after the native histogram, are added to this metric by histogramMetricName.
+*/
+func nativeHistogramMetric(name string, h *histogram.FloatHistogram, lbls string, isGaugeHistogram bool, histogramsByName map[string]map[string]*OpenMetric) *OpenMetric {
+	count := uint64(h.Count)
+	sum := h.Sum
+	metric := &OpenMetric{
+		Name: &name,
+		Histogram: &Histogram{
+			SampleCount:      &count,
+			SampleSum:        &sum,
+			Bucket:           []*Bucket{},
+			IsGaugeHistogram: isGaugeHistogram,
+			Native:           h,
+		},
+	}
+
+	if _, ok := histogramsByName[name]; !ok {
+		histogramsByName[name] = make(map[string]*OpenMetric)
+	}
+	histogramsByName[name][lbls] = metric
+	return metric
+}
+
 func ParseMetricFamilies(b []byte, contentType string, ts time.Time, logger *logp.Logger) ([]*MetricFamily, error) {
-	parser, err := textparse.New(b, contentType, false, labels.NewSymbolTable())
+	// Classic histograms are also parsed when they are present with native histograms, this only applies to the
+	// protobuf format.
+	parser, err := textparse.New(b, contentType, true, labels.NewSymbolTable())
 	if err != nil {
 		return nil, err
 	}
@@ -565,7 +606,20 @@ func ParseMetricFamilies(b []byte, contentType string, ts time.Time, logger *log
 		}
 
 		t := defTime
-		_, tp, v := parser.Series()
+		var (
+			tp     *int64
+			v      float64
+			native *histogram.FloatHistogram
+		)
+		if et == textparse.EntryHistogram {
+			var h *histogram.Histogram
+			_, tp, h, native = parser.Histogram()
+			if h != nil {
+				native = h.ToFloat(nil)
+			}
+		} else {
+			_, tp, v = parser.Series()
+		}
 
 		var (
 			lset labels.Labels
@@ -669,12 +723,20 @@ func ParseMetricFamilies(b []byte, contentType string, ts time.Time, logger *log
 			metric = &OpenMetric{Name: &metricName, Info: info, Label: labelPairs}
 		case model.MetricTypeSummary:
 			lookupMetricName, metric = summaryMetricName(metricName, v, qv, lbls.String(), summariesByName)
-			metric.Label = labelPairs
+			if isSum(metricName) || isCount(metricName) {
+				// Quantiles have the quantile label, and come after the sum and count in the protobuf format.
+				metric.Label = labelPairs
+			}
 			if !isSum(metricName) {
 				// Avoid registering the metric multiple times.
 				continue
 			}
 		case model.MetricTypeHistogram:
+			if native != nil {
+				metric = nativeHistogramMetric(metricName, native, lbls.String(), false, histogramsByName)
+				metric.Label = labelPairs
+				break
+			}
 			if hasExemplar := parser.Exemplar(&e); hasExemplar {
 				exm = &e
 			}
@@ -682,12 +744,21 @@ func ParseMetricFamilies(b []byte, contentType string, ts time.Time, logger *log
 			if metric == nil {
 				continue
 			}
-			metric.Label = labelPairs
-			if !isSum(metricName) {
-				// Avoid registering the metric multiple times.
+			if !isBucket(metricName) {
+				// Buckets have the le label, and come after the sum and count in the protobuf format.
+				metric.Label = labelPairs
+			}
+			if !isSum(metricName) || metric.Histogram.Native != nil {
+				// Avoid registering the metric multiple times, histograms with a native histogram
+				// are registered with it.
 				continue
 			}
 		case model.MetricTypeGaugeHistogram:
+			if native != nil {
+				metric = nativeHistogramMetric(metricName, native, lbls.String(), true, histogramsByName)
+				metric.Label = labelPairs
+				break
+			}
 			if hasExemplar := parser.Exemplar(&e); hasExemplar {
 				exm = &e
 			}
@@ -695,9 +766,13 @@ func ParseMetricFamilies(b []byte, contentType string, ts time.Time, logger *log
 			if metric == nil { // metric name does not have a suffix supported for the type gauge histogram
 				continue
 			}
-			metric.Label = labelPairs
+			if !isBucket(metricName) {
+				// Buckets have the le label, and come after the sum and count in the protobuf format.
+				metric.Label = labelPairs
+			}
 			metric.Histogram.IsGaugeHistogram = true
-			if !isGSum(metricName) {
+			// The protobuf format has the _sum suffix instead of _gsum for gauge histograms.
+			if !(isGSum(metricName) || isSum(metricName)) || metric.Histogram.Native != nil {
 				// Avoid registering the metric multiple times.
 				continue
 			}
@@ -771,6 +846,12 @@ func GetContentType(h http.Header) string {
 			return ""
 		}
 		return ContentTypeTextFormat
+
+	case ProtobufType:
+		if params["proto"] != ProtobufMessageName || params["encoding"] != "delimited" {
+			return ""
+		}
+		return ContentTypeProtobuf
 	}
 
 	return ""
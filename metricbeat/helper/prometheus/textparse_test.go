@@ -18,11 +18,19 @@
 package prometheus
 
 import (
+	"bytes"
+	"math"
+	"net/http"
 	"testing"
 	"time"
 
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
 
 	"github.com/elastic/elastic-agent-libs/logp"
 )
@@ -899,3 +907,234 @@ redis_connected_clients{instance="rough-snowflake-web"} 10.0`
 	}
 	require.ElementsMatch(t, expected, result)
 }
+
+// encodeProtobuf encodes the metric families in the delimited protobuf format.
+func encodeProtobuf(t *testing.T, families ...*dto.MetricFamily) []byte {
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeProtoDelim))
+	for _, family := range families {
+		require.NoError(t, encoder.Encode(family))
+	}
+	return buf.Bytes()
+}
+
+func TestCounterAndGaugeProtobuf(t *testing.T) {
+	input := encodeProtobuf(t,
+		&dto.MetricFamily{
+			Name: proto.String("http_requests_total"),
+			Help: proto.String("Some help."),
+			Type: dto.MetricType_COUNTER.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label:   []*dto.LabelPair{{Name: proto.String("method"), Value: proto.String("GET")}},
+					Counter: &dto.Counter{Value: proto.Float64(42)},
+				},
+			},
+		},
+		&dto.MetricFamily{
+			Name: proto.String("temperature"),
+			Type: dto.MetricType_GAUGE.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Gauge:       &dto.Gauge{Value: proto.Float64(21.5)},
+					TimestampMs: proto.Int64(1727784000000),
+				},
+			},
+		},
+	)
+
+	expected := []*MetricFamily{
+		{
+			Name: stringp("http_requests_total"),
+			Help: stringp("Some help."),
+			Type: "counter",
+			Metric: []*OpenMetric{
+				{
+					Label: []*labels.Label{{Name: "method", Value: "GET"}},
+					Name:  stringp("http_requests_total"),
+					Counter: &Counter{
+						Value: float64p(42),
+					},
+				},
+			},
+		},
+		{
+			Name: stringp("temperature"),
+			Help: stringp(""),
+			Type: "gauge",
+			Metric: []*OpenMetric{
+				{
+					Label: []*labels.Label{},
+					Name:  stringp("temperature"),
+					Gauge: &Gauge{
+						Value: float64p(21.5),
+					},
+					TimestampMs: int64p(1727784000000),
+				},
+			},
+		},
+	}
+
+	result, err := ParseMetricFamilies(input, ContentTypeProtobuf, time.Now(), nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, expected, result)
+}
+
+func TestSummaryProtobuf(t *testing.T) {
+	input := encodeProtobuf(t,
+		&dto.MetricFamily{
+			Name: proto.String("rpc_duration_seconds"),
+			Help: proto.String("A summary."),
+			Type: dto.MetricType_SUMMARY.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("service"), Value: proto.String("api")}},
+					Summary: &dto.Summary{
+						SampleCount: proto.Uint64(100),
+						SampleSum:   proto.Float64(25),
+						Quantile: []*dto.Quantile{
+							{Quantile: proto.Float64(0.5), Value: proto.Float64(0.2)},
+							{Quantile: proto.Float64(0.99), Value: proto.Float64(0.9)},
+						},
+					},
+				},
+			},
+		},
+	)
+
+	expected := []*MetricFamily{
+		{
+			Name: stringp("rpc_duration_seconds"),
+			Help: stringp("A summary."),
+			Type: "summary",
+			Metric: []*OpenMetric{
+				{
+					Label: []*labels.Label{{Name: "service", Value: "api"}},
+					Name:  stringp("rpc_duration_seconds"),
+					Summary: &Summary{
+						SampleCount: uint64p(100),
+						SampleSum:   float64p(25),
+						Quantile: []*Quantile{
+							{Quantile: float64p(0.5), Value: float64p(0.2)},
+							{Quantile: float64p(0.99), Value: float64p(0.9)},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	result, err := ParseMetricFamilies(input, ContentTypeProtobuf, time.Now(), nil)
+	require.NoError(t, err)
+	require.ElementsMatch(t, expected, result)
+}
+
+func TestHistogramProtobuf(t *testing.T) {
+	input := encodeProtobuf(t,
+		&dto.MetricFamily{
+			Name: proto.String("classic_seconds"),
+			Help: proto.String("A classic histogram."),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("uri"), Value: proto.String("/")}},
+					Histogram: &dto.Histogram{
+						SampleCount: proto.Uint64(5),
+						SampleSum:   proto.Float64(2.5),
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(0.5), CumulativeCount: proto.Uint64(2)},
+							{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(4)},
+						},
+					},
+				},
+			},
+		},
+		&dto.MetricFamily{
+			Name: proto.String("native_seconds"),
+			Help: proto.String("A native histogram with classic buckets."),
+			Type: dto.MetricType_HISTOGRAM.Enum(),
+			Metric: []*dto.Metric{
+				{
+					Label: []*dto.LabelPair{{Name: proto.String("uri"), Value: proto.String("/")}},
+					Histogram: &dto.Histogram{
+						SampleCount:   proto.Uint64(11),
+						SampleSum:     proto.Float64(3.5),
+						Schema:        proto.Int32(0),
+						ZeroThreshold: proto.Float64(0.001),
+						ZeroCount:     proto.Uint64(1),
+						// Buckets (0.5, 1], (1, 2] and (2, 4] with counts 2, 3 and 1.
+						PositiveSpan:  []*dto.BucketSpan{{Offset: proto.Int32(0), Length: proto.Uint32(3)}},
+						PositiveDelta: []int64{2, 1, -2},
+						// Bucket [-2, -1) with count 4.
+						NegativeSpan:  []*dto.BucketSpan{{Offset: proto.Int32(1), Length: proto.Uint32(1)}},
+						NegativeDelta: []int64{4},
+						Bucket: []*dto.Bucket{
+							{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(8)},
+						},
+					},
+				},
+			},
+		},
+	)
+
+	result, err := ParseMetricFamilies(input, ContentTypeProtobuf, time.Now(), nil)
+	require.NoError(t, err)
+	require.Len(t, result, 2)
+
+	families := map[string]*MetricFamily{}
+	for _, family := range result {
+		families[family.GetName()] = family
+	}
+
+	classic := families["classic_seconds"]
+	require.NotNil(t, classic)
+	require.Len(t, classic.GetMetric(), 1)
+	require.Equal(t, []*labels.Label{{Name: "uri", Value: "/"}}, classic.GetMetric()[0].GetLabel())
+	require.Equal(t, &Histogram{
+		SampleCount: uint64p(5),
+		SampleSum:   float64p(2.5),
+		Bucket: []*Bucket{
+			{UpperBound: float64p(0.5), CumulativeCount: uint64p(2)},
+			{UpperBound: float64p(1), CumulativeCount: uint64p(4)},
+			{UpperBound: float64p(math.Inf(1)), CumulativeCount: uint64p(5)},
+		},
+	}, classic.GetMetric()[0].GetHistogram())
+
+	native := families["native_seconds"]
+	require.NotNil(t, native)
+	require.Equal(t, model.MetricTypeHistogram, native.Type)
+	require.Len(t, native.GetMetric(), 1)
+	require.Equal(t, []*labels.Label{{Name: "uri", Value: "/"}}, native.GetMetric()[0].GetLabel())
+	h := native.GetMetric()[0].GetHistogram()
+	require.Equal(t, uint64(11), h.GetSampleCount())
+	require.Equal(t, 3.5, h.GetSampleSum())
+	require.Equal(t, []*Bucket{
+		{UpperBound: float64p(1), CumulativeCount: uint64p(8)},
+		{UpperBound: float64p(math.Inf(1)), CumulativeCount: uint64p(11)},
+	}, h.GetBucket())
+	require.Equal(t, &histogram.FloatHistogram{
+		Schema:          0,
+		ZeroThreshold:   0.001,
+		ZeroCount:       1,
+		Count:           11,
+		Sum:             3.5,
+		PositiveSpans:   []histogram.Span{{Offset: 0, Length: 3}},
+		PositiveBuckets: []float64{2, 3, 1},
+		NegativeSpans:   []histogram.Span{{Offset: 1, Length: 1}},
+		NegativeBuckets: []float64{4},
+	}, h.GetNative())
+}
+
+func TestGetContentType(t *testing.T) {
+	for contentType, expected := range map[string]string{
+		"text/plain; version=0.0.4; charset=utf-8": ContentTypeTextFormat,
+		"text/plain": ContentTypeTextFormat,
+		"application/openmetrics-text; version=1.0.0; charset=utf-8":                                   OpenMetricsType,
+		"application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=delimited": ContentTypeProtobuf,
+		"application/vnd.google.protobuf; proto=io.prometheus.client.MetricFamily; encoding=text":      "",
+		"application/vnd.google.protobuf; proto=io.prometheus.client.Metric; encoding=delimited":       "",
+		"application/json": "",
+	} {
+		require.Equal(t, expected, GetContentType(http.Header{"Content-Type": []string{contentType}}), contentType)
+	}
+}
@@ -55,8 +55,13 @@ func NewUdpServer(base mb.BaseMetricSet) (server.Server, error) {
 		return nil, err
 	}
 
-	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", config.Host, config.Port))
+	return NewUdpServerWithConfig(config)
+}
 
+// NewUdpServerWithConfig creates a UDP server with the given configuration,
+// for metricsets that listen on UDP in addition to other protocols.
+func NewUdpServerWithConfig(config UdpConfig) (server.Server, error) {
+	addr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", config.Host, config.Port))
 	if err != nil {
 		return nil, err
 	}
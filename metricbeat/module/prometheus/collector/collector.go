@@ -95,7 +95,13 @@ func MetricSetBuilder(namespace string, genFactory PromEventsGeneratorFactory) f
 		if err := base.Module().UnpackConfig(&config); err != nil {
 			return nil, err
 		}
-		prometheus, err := p.NewPrometheusClient(base)
+		var prometheus p.Prometheus
+		var err error
+		if config.UseProtobuf {
+			prometheus, err = p.NewPrometheusProtobufClient(base)
+		} else {
+			prometheus, err = p.NewPrometheusClient(base)
+		}
 		if err != nil {
 			return nil, err
 		}
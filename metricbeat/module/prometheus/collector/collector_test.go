@@ -20,15 +20,19 @@
 package collector
 
 import (
+	"bytes"
 	"net/http"
 	"net/http/httptest"
 	"sort"
 	"strings"
 	"testing"
 
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
 	"github.com/prometheus/common/model"
 	pl "github.com/prometheus/prometheus/model/labels"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"google.golang.org/protobuf/proto"
 
 	"github.com/elastic/elastic-agent-libs/mapstr"
@@ -521,3 +525,56 @@ test_summary_count{environment="prod",service="api"} 1000`)
 	}))
 	return server
 }
+
+// TestFetchProtobuf tests that the protobuf format is negotiated with the endpoint when use_protobuf is enabled.
+func TestFetchProtobuf(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := expfmt.NewEncoder(&buf, expfmt.NewFormat(expfmt.TypeProtoDelim))
+	require.NoError(t, encoder.Encode(&dto.MetricFamily{
+		Name: proto.String("test_histogram"),
+		Type: dto.MetricType_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{
+			{
+				Label: []*dto.LabelPair{{Name: proto.String("service"), Value: proto.String("api")}},
+				Histogram: &dto.Histogram{
+					SampleCount:   proto.Uint64(3),
+					SampleSum:     proto.Float64(2.5),
+					Schema:        proto.Int32(0),
+					ZeroThreshold: proto.Float64(0.001),
+					PositiveSpan:  []*dto.BucketSpan{{Offset: proto.Int32(0), Length: proto.Uint32(2)}},
+					PositiveDelta: []int64{2, -1},
+				},
+			},
+		},
+	}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Accept"), p.ProtobufType) {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", p.ContentTypeProtobuf)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	config := map[string]interface{}{
+		"module":       "prometheus",
+		"metricsets":   []string{"collector"},
+		"hosts":        []string{server.URL},
+		"use_protobuf": true,
+	}
+
+	f := mbtest.NewReportingMetricSetV2Error(t, config)
+	events, errs := mbtest.ReportingFetchV2Error(f)
+	require.Empty(t, errs)
+	require.Len(t, events, 2)
+
+	sortPromEvents(events)
+	assert.Equal(t, "api", events[0].RootFields["prometheus"].(mapstr.M)["labels"].(mapstr.M)["service"])
+	assert.Equal(t, mapstr.M{
+		"test_histogram_sum":   2.5,
+		"test_histogram_count": uint64(3),
+	}, events[0].RootFields["prometheus"].(mapstr.M)["metrics"])
+}
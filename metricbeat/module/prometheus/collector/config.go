@@ -19,6 +19,7 @@ package collector
 
 type metricsetConfig struct {
 	MetricsCount   bool          `config:"metrics_count"`
+	UseProtobuf    bool          `config:"use_protobuf"`
 	MetricsFilters MetricFilters `config:"metrics_filters" yaml:"metrics_filters,omitempty"`
 }
 
@@ -26,6 +26,7 @@ import (
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/netflow"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/o365audit"
 	"github.com/elastic/beats/v7/x-pack/filebeat/input/salesforce"
+	"github.com/elastic/beats/v7/x-pack/filebeat/input/snmptrap"
 	"github.com/elastic/elastic-agent-libs/logp"
 )
 
@@ -45,6 +46,7 @@ func xpackInputs(info beat.Info, log *logp.Logger, store beater.StateStore) []v2
 		lumberjack.Plugin(),
 		etw.Plugin(),
 		netflow.Plugin(log),
+		snmptrap.Plugin(),
 		salesforce.Plugin(log, store),
 		benchmark.Plugin(),
 	}
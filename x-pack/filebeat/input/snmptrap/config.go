@@ -0,0 +1,81 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmptrap
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+
+	"github.com/elastic/beats/v7/filebeat/inputsource/udp"
+	snmpcommon "github.com/elastic/beats/v7/x-pack/libbeat/common/snmp"
+)
+
+type config struct {
+	udp.Config `config:",inline"`
+
+	// Communities accepted in v1 and v2c messages, all communities are
+	// accepted when empty.
+	Communities []string `config:"communities"`
+
+	// Users accepted in v3 messages.
+	Users []snmpcommon.User `config:"users"`
+
+	// EngineID is the hex encoded ID of the SNMP engine of the input, which
+	// is the authoritative engine of the v3 informs it receives. A random
+	// one is used when empty.
+	EngineID string `config:"engine_id"`
+
+	// MIBPaths are the files or directories with the MIB modules used to
+	// translate OIDs to names.
+	MIBPaths []string `config:"mib_paths"`
+}
+
+func defaultConfig() config {
+	return config{
+		Config: udp.Config{
+			MaxMessageSize: 64 * humanize.KiByte,
+			Host:           ":162",
+			Timeout:        time.Minute * 5,
+		},
+	}
+}
+
+func (c *config) Validate() error {
+	usernames := make(map[string]bool, len(c.Users))
+	for i := range c.Users {
+		user := &c.Users[i]
+		if _, _, err := user.USM(); err != nil {
+			return fmt.Errorf("invalid user %d: %w", i, err)
+		}
+		if usernames[user.Username] {
+			return fmt.Errorf("duplicated user %s", user.Username)
+		}
+		usernames[user.Username] = true
+	}
+	if c.EngineID != "" {
+		if _, err := c.engineID(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// engineID returns the decoded engine ID of the configuration.
+func (c *config) engineID() (string, error) {
+	id, err := hex.DecodeString(strings.TrimPrefix(c.EngineID, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("invalid engine_id: %w", err)
+	}
+	// RFC 3411 defines snmpEngineID as an OCTET STRING of 5 to 32 octets.
+	if len(id) < 5 || len(id) > 32 {
+		return "", errors.New("invalid engine_id: it must have between 5 and 32 octets")
+	}
+	return string(id), nil
+}
@@ -0,0 +1,23 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Code generated by beats/dev-tools/cmd/asset/asset.go - DO NOT EDIT.
+
+package snmptrap
+
+import (
+	"github.com/elastic/beats/v7/libbeat/asset"
+)
+
+func init() {
+	if err := asset.SetFields("filebeat", "snmptrap", asset.ModuleFieldsPri, AssetSnmptrap); err != nil {
+		panic(err)
+	}
+}
+
+// AssetSnmptrap returns asset data.
+// This is the base64 encoded zlib format compressed contents of input/snmptrap.
+func AssetSnmptrap() string {
+	return "eJyslkFP3DwQhu/5Fa84w0p8fJWqPVRqaWk5QKtCua688SQZkYwj21nY/vrKTkLDbmBTBWlPjjPPMzPecU5wT9slnFS1t6pOAM++pCWObq6vfiCsHSWAJpdarj0bWeJDAgAXTKV2yKyp4AvC034HJRosmbGVg6WUeEMa623c1pPAUjd+kQCWSlKOlliTVwmQxcDLCDmBqIpav0UnGNb9tqYlcmuafmXEcNcyGorxnHGqQipu0e0bIofYDVnHRp7We/A9bR+M1YP1F/DhF7FdJJgsVmFocQwjFB6cHuO/FMbibJHsqQTwPI/bbU3j/FDYwG17NgI3rOexv19+HkMvcFtQ/3Bz2h0fdqhUXZOGcs+itIA1hdOFnxfnOHv3/v8R3dC8eb7XqnqhVg8FCTRlLK1GSCk1knHeWNK4uvzkRoya2vOIU2kknyZ0yxXBsaQUnRyJJosH5cDCnlXJv0kfB6GiEW1J+8KFBBQcpUb0iBOJJ1tbdjNr9eUpTt9J1fdyhKpyEr9SWltyw+62YK6nMT+27/ctikHhC+WRk5BVnvSrFnEXp6vBSBlIiKec7DSTr22kmG7s6qECuJpSzt6EfdOFiiikRh+EN47svG7/cmQ7ytnz//I+jSRnodXc4fGNHkES0tP4O0dU4wtj2SvPG+pQU8VSI54e/Wr+oDhvI8XyTqVvlGW1Lmn/+A8vtAPguz4I1qYRDW/2p+vghd0b7uXp/lohDjjtTPo+z0Uyit0p/kzucGL34H+d1q/ctzPt4jdAPx0m1GajyuYN8Xch3D76zwBGML0G"
+}
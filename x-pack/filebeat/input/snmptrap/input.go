@@ -0,0 +1,135 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmptrap
+
+import (
+	"net"
+	"time"
+
+	"github.com/elastic/beats/v7/filebeat/input/netmetrics"
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	stateless "github.com/elastic/beats/v7/filebeat/input/v2/input-stateless"
+	"github.com/elastic/beats/v7/filebeat/inputsource"
+	"github.com/elastic/beats/v7/filebeat/inputsource/udp"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	"github.com/elastic/beats/v7/libbeat/feature"
+	snmpcommon "github.com/elastic/beats/v7/x-pack/libbeat/common/snmp"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+	"github.com/elastic/go-concert/ctxtool"
+)
+
+const inputName = "snmptrap"
+
+func Plugin() input.Plugin {
+	return input.Plugin{
+		Name:       inputName,
+		Stability:  feature.Beta,
+		Deprecated: false,
+		Info:       "receive and decode SNMP traps and informs",
+		Manager:    stateless.NewInputManager(configure),
+	}
+}
+
+func configure(cfg *conf.C) (stateless.Input, error) {
+	config := defaultConfig()
+	if err := cfg.Unpack(&config); err != nil {
+		return nil, err
+	}
+
+	return newServer(config)
+}
+
+type server struct {
+	config
+	mib *snmpcommon.MIB
+}
+
+func newServer(config config) (*server, error) {
+	s := &server{config: config}
+	if len(config.MIBPaths) > 0 {
+		var err error
+		s.mib, err = snmpcommon.LoadMIB(config.MIBPaths...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *server) Name() string { return inputName }
+
+func (s *server) Test(_ input.TestContext) error {
+	l, err := net.ListenPacket("udp", s.config.Config.Host)
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
+
+func (s *server) Run(ctx input.Context, publisher stateless.Publisher) error {
+	log := ctx.Logger.With("host", s.config.Config.Host)
+
+	log.Info("starting snmptrap input")
+	defer log.Info("snmptrap input stopped")
+
+	receiver, err := newReceiver(s.config, s.mib)
+	if err != nil {
+		return err
+	}
+
+	const pollInterval = time.Minute
+	metrics := netmetrics.NewUDP(inputName, ctx.ID, s.config.Host, uint64(s.config.ReadBuffer), pollInterval, log)
+	defer metrics.Close()
+
+	server := udp.New(&s.config.Config, func(data []byte, metadata inputsource.NetworkMetadata) {
+		now := time.Now()
+		notification, reply, err := receiver.handle(data)
+		if err != nil {
+			log.Debugw("Dropping SNMP message", "error", err, "remote_address", metadata.RemoteAddr)
+			return
+		}
+
+		if reply != nil && metadata.PacketConn != nil {
+			msg, err := reply.MarshalMsg()
+			if err == nil {
+				_, err = metadata.PacketConn.WriteTo(msg, metadata.RemoteAddr)
+			}
+			if err != nil {
+				log.Warnw("Failed to reply to SNMP message", "error", err, "remote_address", metadata.RemoteAddr)
+			}
+		}
+		if notification == nil {
+			return
+		}
+
+		evt := beat.Event{
+			Timestamp: now,
+			Fields:    receiver.fields(notification),
+		}
+		if metadata.RemoteAddr != nil {
+			evt.Fields["log"] = mapstr.M{
+				"source": mapstr.M{
+					"address": metadata.RemoteAddr.String(),
+				},
+			}
+		}
+
+		publisher.Publish(evt)
+
+		// This must be called after publisher.Publish to measure
+		// the processing time metric.
+		metrics.Log(data, evt.Timestamp)
+	})
+
+	log.Debug("snmptrap input initialized")
+
+	err = server.Run(ctxtool.FromCanceller(ctx.Cancelation))
+	// Ignore error from 'Run' in case shutdown was signaled.
+	if ctxerr := ctx.Cancelation.Err(); ctxerr != nil {
+		err = ctxerr
+	}
+	return err
+}
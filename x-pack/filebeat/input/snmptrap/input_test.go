@@ -0,0 +1,329 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !integration
+
+package snmptrap
+
+import (
+	"context"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	input "github.com/elastic/beats/v7/filebeat/input/v2"
+	"github.com/elastic/beats/v7/libbeat/beat"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/logp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const engineID = "80001f8880e9630000d61ff449"
+
+type publisher chan beat.Event
+
+func (p publisher) Publish(event beat.Event) { p <- event }
+
+// runInput runs the input with the settings, and returns the address it
+// listens on and the events it publishes.
+func runInput(t *testing.T, settings map[string]interface{}) (*net.UDPAddr, <-chan beat.Event) {
+	t.Helper()
+
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	require.NoError(t, conn.Close())
+
+	settings["host"] = addr.String()
+	in, err := configure(conf.MustNewConfigFrom(settings))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan beat.Event, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := in.Run(input.Context{Logger: logp.NewLogger(inputName), Cancelation: ctx}, publisher(events))
+		assert.ErrorIs(t, err, context.Canceled)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Wait for the input to listen on the address.
+	require.Eventually(t, func() bool {
+		conn, err := net.ListenPacket("udp4", addr.String())
+		if err != nil {
+			return true
+		}
+		conn.Close()
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return addr, events
+}
+
+func newClient(t *testing.T, addr *net.UDPAddr, client *gosnmp.GoSNMP) *gosnmp.GoSNMP {
+	t.Helper()
+
+	client.Target = addr.IP.String()
+	client.Port = uint16(addr.Port)
+	client.Timeout = time.Second
+	client.Retries = 2
+	require.NoError(t, client.Connect())
+	t.Cleanup(func() { client.Conn.Close() })
+	return client
+}
+
+func receive(t *testing.T, events <-chan beat.Event) mapstr.M {
+	t.Helper()
+
+	select {
+	case event := <-events:
+		trap, err := event.Fields.GetValue("snmp.trap")
+		require.NoError(t, err)
+		return trap.(mapstr.M)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for event")
+		return nil
+	}
+}
+
+var linkDown = []gosnmp.SnmpPDU{
+	{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(4200)},
+	{Name: ".1.3.6.1.6.3.1.1.4.1.0", Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.6.3.1.1.5.3"},
+	{Name: ".1.3.6.1.2.1.2.2.1.1.2", Type: gosnmp.Integer, Value: 2},
+	{Name: ".1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: 2},
+	{Name: ".1.3.6.1.4.1.9999.1", Type: gosnmp.OctetString, Value: []byte("eth1")},
+}
+
+func TestTrapV2c(t *testing.T) {
+	addr, events := runInput(t, map[string]interface{}{
+		"communities": []string{"public"},
+	})
+
+	client := newClient(t, addr, &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "private"})
+	_, err := client.SendTrap(gosnmp.SnmpTrap{Variables: linkDown})
+	require.NoError(t, err)
+	client.Community = "public"
+	_, err = client.SendTrap(gosnmp.SnmpTrap{Variables: linkDown})
+	require.NoError(t, err)
+
+	// The trap of the unknown community is dropped.
+	assert.Equal(t, mapstr.M{
+		"version": "2c",
+		"type":    "trap",
+		"oid":     "1.3.6.1.6.3.1.1.5.3",
+		"uptime":  uint64(4200),
+		"variables": []mapstr.M{
+			{"oid": "1.3.6.1.2.1.2.2.1.1.2", "type": "Integer", "value": int64(2)},
+			{"oid": "1.3.6.1.2.1.2.2.1.8.2", "type": "Integer", "value": int64(2)},
+			{"oid": "1.3.6.1.4.1.9999.1", "type": "OctetString", "value": "eth1"},
+		},
+	}, receive(t, events))
+}
+
+func TestInformV2c(t *testing.T) {
+	addr, events := runInput(t, map[string]interface{}{
+		"mib_paths": []string{"testdata"},
+	})
+
+	client := newClient(t, addr, &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public"})
+	response, err := client.SendTrap(gosnmp.SnmpTrap{Variables: linkDown, IsInform: true})
+	require.NoError(t, err)
+	assert.Equal(t, gosnmp.GetResponse, response.PDUType)
+	assert.Len(t, response.Variables, len(linkDown))
+
+	trap := receive(t, events)
+	assert.Equal(t, "inform", trap["type"])
+	assert.Equal(t, "linkDown", trap["name"])
+	assert.Equal(t, []mapstr.M{
+		{"oid": "1.3.6.1.2.1.2.2.1.1.2", "name": "ifIndex.2", "type": "Integer", "value": int64(2)},
+		{"oid": "1.3.6.1.2.1.2.2.1.8.2", "name": "ifOperStatus.2", "type": "Integer", "value": int64(2)},
+		{"oid": "1.3.6.1.4.1.9999.1", "name": "enterprises.9999.1", "type": "OctetString", "value": "eth1"},
+	}, trap["variables"])
+}
+
+func TestTrapV1(t *testing.T) {
+	addr, events := runInput(t, map[string]interface{}{})
+
+	client := newClient(t, addr, &gosnmp.GoSNMP{Version: gosnmp.Version1, Community: "public"})
+	_, err := client.SendTrap(gosnmp.SnmpTrap{
+		Enterprise:   ".1.3.6.1.4.1.9999",
+		AgentAddress: "192.0.2.1",
+		GenericTrap:  2,
+		Timestamp:    300,
+		Variables:    linkDown[2:3],
+	})
+	require.NoError(t, err)
+	_, err = client.SendTrap(gosnmp.SnmpTrap{
+		Enterprise:   ".1.3.6.1.4.1.9999",
+		AgentAddress: "192.0.2.1",
+		GenericTrap:  6,
+		SpecificTrap: 17,
+		Timestamp:    400,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, mapstr.M{
+		"version":       "1",
+		"type":          "trap",
+		"oid":           "1.3.6.1.6.3.1.1.5.3",
+		"enterprise":    "1.3.6.1.4.1.9999",
+		"agent_address": "192.0.2.1",
+		"generic_trap":  2,
+		"specific_trap": 0,
+		"uptime":        uint64(300),
+		"variables": []mapstr.M{
+			{"oid": "1.3.6.1.2.1.2.2.1.1.2", "type": "Integer", "value": int64(2)},
+		},
+	}, receive(t, events))
+
+	trap := receive(t, events)
+	assert.Equal(t, "1.3.6.1.4.1.9999.0.17", trap["oid"])
+	assert.NotContains(t, trap, "variables")
+}
+
+func TestInformV3(t *testing.T) {
+	users := []map[string]interface{}{
+		{"username": "noauth"},
+		{
+			"username":       "sha-aes",
+			"security_level": "authPriv",
+			"auth_protocol":  "SHA",
+			"auth_password":  "authpassword",
+			"priv_protocol":  "AES",
+			"priv_password":  "privpassword",
+		},
+		{
+			"username":       "md5-des",
+			"security_level": "authPriv",
+			"auth_protocol":  "MD5",
+			"auth_password":  "authpassword",
+			"priv_protocol":  "DES",
+			"priv_password":  "privpassword",
+		},
+	}
+	addr, events := runInput(t, map[string]interface{}{
+		"engine_id": engineID,
+		"users":     users,
+	})
+
+	for _, usm := range []*gosnmp.UsmSecurityParameters{
+		{UserName: "noauth"},
+		{
+			UserName:                 "sha-aes",
+			AuthenticationProtocol:   gosnmp.SHA,
+			AuthenticationPassphrase: "authpassword",
+			PrivacyProtocol:          gosnmp.AES,
+			PrivacyPassphrase:        "privpassword",
+		},
+		{
+			UserName:                 "md5-des",
+			AuthenticationProtocol:   gosnmp.MD5,
+			AuthenticationPassphrase: "authpassword",
+			PrivacyProtocol:          gosnmp.DES,
+			PrivacyPassphrase:        "privpassword",
+		},
+	} {
+		t.Run(usm.UserName, func(t *testing.T) {
+			flags := gosnmp.AuthPriv
+			if usm.AuthenticationProtocol == gosnmp.NoAuth || usm.AuthenticationProtocol == 0 {
+				flags = gosnmp.NoAuthNoPriv
+			}
+			// The client discovers the engine ID of the input before
+			// sending the inform.
+			client := newClient(t, addr, &gosnmp.GoSNMP{
+				Version:            gosnmp.Version3,
+				SecurityModel:      gosnmp.UserSecurityModel,
+				MsgFlags:           flags | gosnmp.Reportable,
+				SecurityParameters: usm,
+				ContextName:        "public",
+			})
+			response, err := client.SendTrap(gosnmp.SnmpTrap{Variables: linkDown, IsInform: true})
+			require.NoError(t, err)
+			assert.Equal(t, gosnmp.GetResponse, response.PDUType)
+
+			trap := receive(t, events)
+			assert.Equal(t, "3", trap["version"])
+			assert.Equal(t, "inform", trap["type"])
+			assert.Equal(t, usm.UserName, trap["user"])
+			assert.Equal(t, engineID, trap["engine_id"])
+			assert.Equal(t, "public", trap["context_name"])
+			assert.Equal(t, "1.3.6.1.6.3.1.1.5.3", trap["oid"])
+		})
+	}
+}
+
+func TestTrapV3(t *testing.T) {
+	addr, events := runInput(t, map[string]interface{}{
+		"users": []map[string]interface{}{{
+			"username":       "sha-aes",
+			"security_level": "authPriv",
+			"auth_protocol":  "SHA256",
+			"auth_password":  "authpassword",
+			"priv_protocol":  "AES",
+			"priv_password":  "privpassword",
+		}},
+	})
+
+	// The sender is the authoritative engine of traps.
+	const senderEngineID = "\x80\x00\x00\x00\x05sender"
+	send := func(flags gosnmp.SnmpV3MsgFlags, usm *gosnmp.UsmSecurityParameters) {
+		usm.AuthoritativeEngineID = senderEngineID
+		client := newClient(t, addr, &gosnmp.GoSNMP{
+			Version:            gosnmp.Version3,
+			SecurityModel:      gosnmp.UserSecurityModel,
+			MsgFlags:           flags,
+			SecurityParameters: usm,
+		})
+		_, err := client.SendTrap(gosnmp.SnmpTrap{Variables: linkDown})
+		require.NoError(t, err)
+	}
+
+	// Dropped, the user must use authPriv.
+	send(gosnmp.NoAuthNoPriv, &gosnmp.UsmSecurityParameters{UserName: "sha-aes"})
+	// Dropped, wrong password.
+	send(gosnmp.AuthPriv, &gosnmp.UsmSecurityParameters{
+		UserName:                 "sha-aes",
+		AuthenticationProtocol:   gosnmp.SHA256,
+		AuthenticationPassphrase: "wrongpassword",
+		PrivacyProtocol:          gosnmp.AES,
+		PrivacyPassphrase:        "privpassword",
+	})
+	send(gosnmp.AuthPriv, &gosnmp.UsmSecurityParameters{
+		UserName:                 "sha-aes",
+		AuthenticationProtocol:   gosnmp.SHA256,
+		AuthenticationPassphrase: "authpassword",
+		PrivacyProtocol:          gosnmp.AES,
+		PrivacyPassphrase:        "privpassword",
+	})
+
+	trap := receive(t, events)
+	assert.Equal(t, "trap", trap["type"])
+	assert.Equal(t, "sha-aes", trap["user"])
+	assert.Equal(t, hex.EncodeToString([]byte(senderEngineID)), trap["engine_id"])
+	assert.Len(t, trap["variables"], 3)
+}
+
+func TestConfigValidate(t *testing.T) {
+	for name, settings := range map[string]map[string]interface{}{
+		"invalid engine_id": {"engine_id": "zz"},
+		"short engine_id":   {"engine_id": "80001f88"},
+		"invalid user":      {"users": []map[string]interface{}{{"username": "u", "security_level": "authNoPriv"}}},
+		"duplicated user":   {"users": []map[string]interface{}{{"username": "u"}, {"username": "u"}}},
+		"invalid network":   {"network": "tcp"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config := defaultConfig()
+			assert.Error(t, conf.MustNewConfigFrom(settings).Unpack(&config))
+		})
+	}
+}
@@ -0,0 +1,347 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmptrap
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	snmpcommon "github.com/elastic/beats/v7/x-pack/libbeat/common/snmp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+const (
+	// sysUpTime.0 and snmpTrapOID.0 are the first variables of v2c and v3
+	// notifications.
+	sysUpTimeOID   = "1.3.6.1.2.1.1.3.0"
+	snmpTrapOIDOID = "1.3.6.1.6.3.1.1.4.1.0"
+
+	// snmpTraps is the prefix of the OIDs of the generic v1 traps, as
+	// defined in RFC 3584 section 3.1.
+	snmpTraps = "1.3.6.1.6.3.1.1.5"
+
+	usmStatsUnknownEngineIDs = "1.3.6.1.6.3.15.1.1.4.0"
+
+	// engineBoots is the number of times the engine of the input has been
+	// restarted. It isn't persisted, so it is always 1.
+	engineBoots = 1
+
+	maxMessageSize = 65507
+)
+
+var (
+	errUnknownCommunity = errors.New("unknown community")
+	errSecurityLevel    = errors.New("message security level lower than the one of the user")
+)
+
+// receiver decodes the SNMP messages received by the input, and builds the
+// replies to the ones that expect one.
+type receiver struct {
+	mib         *snmpcommon.MIB
+	communities map[string]bool
+	levels      map[string]gosnmp.SnmpV3MsgFlags // security level of each user
+	engineID    string
+	started     time.Time
+
+	// decoder decodes the messages of the configured users, probe decodes
+	// the unauthenticated v3 messages sent to discover the engine ID.
+	decoder *gosnmp.GoSNMP
+	probe   *gosnmp.GoSNMP
+
+	unknownEngineIDs atomic.Uint32
+	salt             atomic.Uint64
+}
+
+func newReceiver(cfg config, mib *snmpcommon.MIB) (*receiver, error) {
+	r := &receiver{
+		mib:         mib,
+		communities: make(map[string]bool, len(cfg.Communities)),
+		levels:      make(map[string]gosnmp.SnmpV3MsgFlags, len(cfg.Users)),
+		started:     time.Now(),
+		decoder: &gosnmp.GoSNMP{
+			Version:       gosnmp.Version3,
+			SecurityModel: gosnmp.UserSecurityModel,
+		},
+		probe: &gosnmp.GoSNMP{
+			Version:            gosnmp.Version3,
+			SecurityModel:      gosnmp.UserSecurityModel,
+			SecurityParameters: &gosnmp.UsmSecurityParameters{},
+		},
+	}
+	for _, community := range cfg.Communities {
+		r.communities[community] = true
+	}
+
+	users := gosnmp.NewSnmpV3SecurityParametersTable(gosnmp.Logger{})
+	for i := range cfg.Users {
+		usm, flags, err := cfg.Users[i].USM()
+		if err != nil {
+			return nil, err
+		}
+		if err := users.Add(usm.UserName, usm); err != nil {
+			return nil, err
+		}
+		r.levels[usm.UserName] = flags
+	}
+	r.decoder.TrapSecurityParametersTable = users
+
+	var err error
+	if cfg.EngineID != "" {
+		r.engineID, err = cfg.engineID()
+	} else {
+		r.engineID, err = randomEngineID()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var salt [8]byte
+	if _, err := rand.Read(salt[:]); err != nil {
+		return nil, err
+	}
+	r.salt.Store(binary.BigEndian.Uint64(salt[:]))
+	return r, nil
+}
+
+// randomEngineID returns an engine ID in the format of RFC 3411, with
+// random octets.
+func randomEngineID() (string, error) {
+	id := make([]byte, 13)
+	// No enterprise number, and octets format.
+	id[0], id[4] = 0x80, 5
+	if _, err := rand.Read(id[5:]); err != nil {
+		return "", err
+	}
+	return string(id), nil
+}
+
+// handle decodes a message. It returns the trap or inform it contains, and
+// the reply to send back if the sender expects one. Reports sent to discover
+// the engine ID of the input are answered without returning a notification.
+func (r *receiver) handle(data []byte) (notification, reply *gosnmp.SnmpPacket, err error) {
+	// The decoder modifies the data of v3 messages.
+	raw := append([]byte(nil), data...)
+	packet, err := r.decoder.UnmarshalTrap(data, false)
+	if err != nil {
+		if report := r.discovery(raw); report != nil {
+			return nil, report, nil
+		}
+		return nil, nil, err
+	}
+
+	switch packet.Version {
+	case gosnmp.Version1, gosnmp.Version2c:
+		if len(r.communities) > 0 && !r.communities[packet.Community] {
+			return nil, nil, errUnknownCommunity
+		}
+	case gosnmp.Version3:
+		usm, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+		if !ok {
+			return nil, nil, errors.New("unsupported security model")
+		}
+		if packet.MsgFlags&gosnmp.AuthPriv < r.levels[usm.UserName] {
+			return nil, nil, errSecurityLevel
+		}
+		// The input is the authoritative engine of informs, senders need
+		// to use its engine ID.
+		if packet.PDUType == gosnmp.InformRequest && usm.AuthoritativeEngineID != r.engineID {
+			return nil, r.report(packet), nil
+		}
+	}
+
+	switch packet.PDUType {
+	case gosnmp.Trap, gosnmp.SNMPv2Trap:
+		return packet, nil, nil
+	case gosnmp.InformRequest:
+		reply, err := r.response(packet)
+		return packet, reply, err
+	default:
+		return nil, nil, fmt.Errorf("unexpected PDU type %s", packet.PDUType)
+	}
+}
+
+// discovery returns a report with the engine ID of the input if the message
+// is an unauthenticated v3 message used to discover it.
+func (r *receiver) discovery(data []byte) *gosnmp.SnmpPacket {
+	packet, err := r.probe.UnmarshalTrap(data, true)
+	if err != nil || packet.Version != gosnmp.Version3 || packet.MsgFlags&gosnmp.Reportable == 0 {
+		return nil
+	}
+	usm, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if !ok || usm.AuthoritativeEngineID == r.engineID {
+		return nil
+	}
+	return r.report(packet)
+}
+
+// report returns the usmStatsUnknownEngineIDs report of a v3 message sent
+// with an engine ID that is not the one of the input.
+func (r *receiver) report(packet *gosnmp.SnmpPacket) *gosnmp.SnmpPacket {
+	var username string
+	if usm, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok {
+		username = usm.UserName
+	}
+	return &gosnmp.SnmpPacket{
+		Version:       gosnmp.Version3,
+		MsgFlags:      gosnmp.NoAuthNoPriv,
+		SecurityModel: gosnmp.UserSecurityModel,
+		SecurityParameters: &gosnmp.UsmSecurityParameters{
+			AuthoritativeEngineID:    r.engineID,
+			AuthoritativeEngineBoots: engineBoots,
+			AuthoritativeEngineTime:  r.engineTime(),
+			UserName:                 username,
+			AuthenticationProtocol:   gosnmp.NoAuth,
+			PrivacyProtocol:          gosnmp.NoPriv,
+		},
+		ContextEngineID: r.engineID,
+		ContextName:     packet.ContextName,
+		PDUType:         gosnmp.Report,
+		MsgID:           packet.MsgID,
+		RequestID:       packet.RequestID,
+		MsgMaxSize:      maxMessageSize,
+		Variables: []gosnmp.SnmpPDU{{
+			Name:  usmStatsUnknownEngineIDs,
+			Type:  gosnmp.Counter32,
+			Value: uint(r.unknownEngineIDs.Add(1)),
+		}},
+	}
+}
+
+// response returns the response to an inform, with the same variables.
+func (r *receiver) response(inform *gosnmp.SnmpPacket) (*gosnmp.SnmpPacket, error) {
+	response := &gosnmp.SnmpPacket{
+		Version:         inform.Version,
+		Community:       inform.Community,
+		MsgFlags:        inform.MsgFlags &^ gosnmp.Reportable,
+		SecurityModel:   inform.SecurityModel,
+		ContextEngineID: inform.ContextEngineID,
+		ContextName:     inform.ContextName,
+		PDUType:         gosnmp.GetResponse,
+		MsgID:           inform.MsgID,
+		RequestID:       inform.RequestID,
+		MsgMaxSize:      maxMessageSize,
+		Variables:       inform.Variables,
+	}
+	if inform.Version != gosnmp.Version3 {
+		return response, nil
+	}
+
+	usm, ok := inform.SecurityParameters.Copy().(*gosnmp.UsmSecurityParameters)
+	if !ok {
+		return nil, errors.New("unsupported security model")
+	}
+	usm.AuthoritativeEngineBoots = engineBoots
+	usm.AuthoritativeEngineTime = r.engineTime()
+	if response.MsgFlags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		// The salt must be different in every encrypted message.
+		salt := make([]byte, 8)
+		if usm.PrivacyProtocol == gosnmp.DES {
+			binary.BigEndian.PutUint32(salt, usm.AuthoritativeEngineBoots)
+			binary.BigEndian.PutUint32(salt[4:], uint32(r.salt.Add(1)))
+		} else {
+			binary.BigEndian.PutUint64(salt, r.salt.Add(1))
+		}
+		usm.PrivacyParameters = salt
+	}
+	response.SecurityParameters = usm
+	return response, nil
+}
+
+func (r *receiver) engineTime() uint32 {
+	return uint32(time.Since(r.started) / time.Second)
+}
+
+// fields returns the fields of the event of a notification.
+func (r *receiver) fields(packet *gosnmp.SnmpPacket) mapstr.M {
+	trap := mapstr.M{}
+	variables := packet.Variables
+
+	switch packet.Version {
+	case gosnmp.Version1:
+		trap["version"] = "1"
+		enterprise := strings.TrimPrefix(packet.Enterprise, ".")
+		trap["enterprise"] = enterprise
+		trap["agent_address"] = packet.AgentAddress
+		trap["generic_trap"] = packet.GenericTrap
+		trap["specific_trap"] = packet.SpecificTrap
+		trap["uptime"] = uint64(packet.Timestamp)
+		// RFC 3584 section 3.1 maps v1 traps to the OIDs of v2 notifications.
+		if packet.GenericTrap == 6 {
+			trap["oid"] = enterprise + ".0." + strconv.Itoa(packet.SpecificTrap)
+		} else {
+			trap["oid"] = snmpTraps + "." + strconv.Itoa(packet.GenericTrap+1)
+		}
+	case gosnmp.Version2c:
+		trap["version"] = "2c"
+	case gosnmp.Version3:
+		trap["version"] = "3"
+		if usm, ok := packet.SecurityParameters.(*gosnmp.UsmSecurityParameters); ok {
+			trap["user"] = usm.UserName
+			trap["engine_id"] = hex.EncodeToString([]byte(usm.AuthoritativeEngineID))
+		}
+		if packet.ContextName != "" {
+			trap["context_name"] = packet.ContextName
+		}
+	}
+
+	if packet.Version != gosnmp.Version1 {
+		// Take the notification OID and uptime out of the variables.
+		for len(variables) > 0 {
+			oid := strings.TrimPrefix(variables[0].Name, ".")
+			if oid != sysUpTimeOID && oid != snmpTrapOIDOID {
+				break
+			}
+			if value, ok := snmpcommon.Value(variables[0]); ok {
+				if oid == sysUpTimeOID {
+					trap["uptime"] = value
+				} else {
+					trap["oid"] = value
+				}
+			}
+			variables = variables[1:]
+		}
+	}
+
+	if packet.PDUType == gosnmp.InformRequest {
+		trap["type"] = "inform"
+	} else {
+		trap["type"] = "trap"
+	}
+	if oid, ok := trap["oid"].(string); ok {
+		if name, ok := r.mib.Name(oid); ok {
+			trap["name"] = name
+		}
+	}
+
+	if len(variables) > 0 {
+		vars := make([]mapstr.M, 0, len(variables))
+		for _, pdu := range variables {
+			oid := strings.TrimPrefix(pdu.Name, ".")
+			variable := mapstr.M{
+				"oid":  oid,
+				"type": pdu.Type.String(),
+			}
+			if name, ok := r.mib.Name(oid); ok {
+				variable["name"] = name
+			}
+			if value, ok := snmpcommon.Value(pdu); ok {
+				variable["value"] = value
+			}
+			vars = append(vars, variable)
+		}
+		trap["variables"] = vars
+	}
+
+	return mapstr.M{"snmp": mapstr.M{"trap": trap}}
+}
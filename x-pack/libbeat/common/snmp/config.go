@@ -0,0 +1,175 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package snmp contains the settings, value conversion and MIB name
+// translation shared by the Beats that speak SNMP.
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Security levels of SNMP v3.
+const (
+	NoAuthNoPriv = "noAuthNoPriv"
+	AuthNoPriv   = "authNoPriv"
+	AuthPriv     = "authPriv"
+)
+
+var (
+	authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+		"MD5":    gosnmp.MD5,
+		"SHA":    gosnmp.SHA,
+		"SHA224": gosnmp.SHA224,
+		"SHA256": gosnmp.SHA256,
+		"SHA384": gosnmp.SHA384,
+		"SHA512": gosnmp.SHA512,
+	}
+	privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+		"DES":     gosnmp.DES,
+		"AES":     gosnmp.AES,
+		"AES192":  gosnmp.AES192,
+		"AES256":  gosnmp.AES256,
+		"AES192C": gosnmp.AES192C,
+		"AES256C": gosnmp.AES256C,
+	}
+)
+
+// Config contains the protocol version and the credentials used to talk
+// to SNMP agents.
+type Config struct {
+	Version   string        `config:"version"`
+	Community string        `config:"community"`
+	Timeout   time.Duration `config:"timeout" validate:"positive"`
+	Retries   int           `config:"retries" validate:"min=0"`
+
+	// SNMP v3 settings.
+	User        `config:",inline"`
+	ContextName string `config:"context_name"`
+}
+
+// User contains the credentials of a user of the SNMP v3 User-based
+// Security Model.
+type User struct {
+	SecurityLevel string `config:"security_level"`
+	Username      string `config:"username"`
+	AuthProtocol  string `config:"auth_protocol"`
+	AuthPassword  string `config:"auth_password"`
+	PrivProtocol  string `config:"priv_protocol"`
+	PrivPassword  string `config:"priv_password"`
+}
+
+// DefaultConfig returns the default settings, SNMP v2c with the public
+// community.
+func DefaultConfig() Config {
+	return Config{
+		Version:   "2c",
+		Community: "public",
+		Timeout:   5 * time.Second,
+		Retries:   3,
+		User:      User{SecurityLevel: NoAuthNoPriv},
+	}
+}
+
+// Validate checks that the settings are complete for the configured version.
+func (c *Config) Validate() error {
+	switch c.Version {
+	case "1", "2c":
+		if c.Community == "" {
+			return fmt.Errorf("community is required for SNMP v%s", c.Version)
+		}
+	case "3":
+		_, _, err := c.USM()
+		return err
+	default:
+		return fmt.Errorf("unsupported SNMP version %q, expected 1, 2c or 3", c.Version)
+	}
+	return nil
+}
+
+// SnmpVersion returns the gosnmp version of the configuration.
+func (c *Config) SnmpVersion() gosnmp.SnmpVersion {
+	switch c.Version {
+	case "1":
+		return gosnmp.Version1
+	case "3":
+		return gosnmp.Version3
+	default:
+		return gosnmp.Version2c
+	}
+}
+
+// USM returns the User-based Security Model parameters and the message
+// flags of the user.
+func (u *User) USM() (*gosnmp.UsmSecurityParameters, gosnmp.SnmpV3MsgFlags, error) {
+	if u.Username == "" {
+		return nil, 0, errors.New("username is required for SNMP v3")
+	}
+	usm := &gosnmp.UsmSecurityParameters{
+		UserName:               u.Username,
+		AuthenticationProtocol: gosnmp.NoAuth,
+		PrivacyProtocol:        gosnmp.NoPriv,
+	}
+
+	var flags gosnmp.SnmpV3MsgFlags
+	switch u.SecurityLevel {
+	case "", NoAuthNoPriv:
+		return usm, gosnmp.NoAuthNoPriv, nil
+	case AuthNoPriv:
+		flags = gosnmp.AuthNoPriv
+	case AuthPriv:
+		flags = gosnmp.AuthPriv
+	default:
+		return nil, 0, fmt.Errorf("unsupported security_level %q, expected %s, %s or %s", u.SecurityLevel, NoAuthNoPriv, AuthNoPriv, AuthPriv)
+	}
+
+	auth, ok := authProtocols[strings.ToUpper(u.AuthProtocol)]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported auth_protocol %q", u.AuthProtocol)
+	}
+	if u.AuthPassword == "" {
+		return nil, 0, fmt.Errorf("auth_password is required with security_level %s", u.SecurityLevel)
+	}
+	usm.AuthenticationProtocol = auth
+	usm.AuthenticationPassphrase = u.AuthPassword
+
+	if flags == gosnmp.AuthPriv {
+		priv, ok := privProtocols[strings.ToUpper(u.PrivProtocol)]
+		if !ok {
+			return nil, 0, fmt.Errorf("unsupported priv_protocol %q", u.PrivProtocol)
+		}
+		if u.PrivPassword == "" {
+			return nil, 0, fmt.Errorf("priv_password is required with security_level %s", u.SecurityLevel)
+		}
+		usm.PrivacyProtocol = priv
+		usm.PrivacyPassphrase = u.PrivPassword
+	}
+	return usm, flags, nil
+}
+
+// Apply sets the version and credentials of the configuration on a client.
+func (c *Config) Apply(client *gosnmp.GoSNMP) error {
+	client.Version = c.SnmpVersion()
+	client.Community = c.Community
+	client.Timeout = c.Timeout
+	client.Retries = c.Retries
+	if client.Version != gosnmp.Version3 {
+		return nil
+	}
+
+	usm, flags, err := c.USM()
+	if err != nil {
+		return err
+	}
+	client.SecurityModel = gosnmp.UserSecurityModel
+	client.MsgFlags = flags
+	client.SecurityParameters = usm
+	client.ContextName = c.ContextName
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  func(*Config)
+		wantErr string
+	}{
+		{
+			name:   "default",
+			config: func(c *Config) {},
+		},
+		{
+			name:    "unsupported version",
+			config:  func(c *Config) { c.Version = "4" },
+			wantErr: "unsupported SNMP version",
+		},
+		{
+			name:    "missing community",
+			config:  func(c *Config) { c.Community = "" },
+			wantErr: "community is required",
+		},
+		{
+			name:    "v3 without username",
+			config:  func(c *Config) { c.Version = "3" },
+			wantErr: "username is required",
+		},
+		{
+			name: "v3 noAuthNoPriv",
+			config: func(c *Config) {
+				c.Version = "3"
+				c.Username = "user"
+			},
+		},
+		{
+			name: "v3 authPriv",
+			config: func(c *Config) {
+				c.Version = "3"
+				c.Username = "user"
+				c.SecurityLevel = AuthPriv
+				c.AuthProtocol = "sha256"
+				c.AuthPassword = "authpass"
+				c.PrivProtocol = "AES"
+				c.PrivPassword = "privpass"
+			},
+		},
+		{
+			name: "v3 authPriv without priv password",
+			config: func(c *Config) {
+				c.Version = "3"
+				c.Username = "user"
+				c.SecurityLevel = AuthPriv
+				c.AuthProtocol = "SHA"
+				c.AuthPassword = "authpass"
+				c.PrivProtocol = "AES"
+			},
+			wantErr: "priv_password is required",
+		},
+		{
+			name: "v3 unsupported auth protocol",
+			config: func(c *Config) {
+				c.Version = "3"
+				c.Username = "user"
+				c.SecurityLevel = AuthNoPriv
+				c.AuthProtocol = "CRC32"
+				c.AuthPassword = "authpass"
+			},
+			wantErr: "unsupported auth_protocol",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := DefaultConfig()
+			test.config(&c)
+			err := c.Validate()
+			if test.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestConfigApply(t *testing.T) {
+	c := DefaultConfig()
+	c.Version = "3"
+	c.Username = "user"
+	c.SecurityLevel = AuthPriv
+	c.AuthProtocol = "SHA"
+	c.AuthPassword = "authpass"
+	c.PrivProtocol = "AES"
+	c.PrivPassword = "privpass"
+	c.ContextName = "ctx"
+
+	var client gosnmp.GoSNMP
+	require.NoError(t, c.Apply(&client))
+	assert.Equal(t, gosnmp.Version3, client.Version)
+	assert.Equal(t, gosnmp.AuthPriv, client.MsgFlags)
+	assert.Equal(t, "ctx", client.ContextName)
+
+	usm, ok := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	require.True(t, ok)
+	assert.Equal(t, "user", usm.UserName)
+	assert.Equal(t, gosnmp.SHA, usm.AuthenticationProtocol)
+	assert.Equal(t, gosnmp.AES, usm.PrivacyProtocol)
+}
@@ -0,0 +1,285 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// baseOIDs are the roots of the OID tree defined in SNMPv2-SMI, so that
+// MIBs can be resolved without loading it.
+var baseOIDs = map[string]string{
+	"ccitt":           "0",
+	"iso":             "1",
+	"joint-iso-ccitt": "2",
+	"org":             "1.3",
+	"dod":             "1.3.6",
+	"internet":        "1.3.6.1",
+	"directory":       "1.3.6.1.1",
+	"mgmt":            "1.3.6.1.2",
+	"mib-2":           "1.3.6.1.2.1",
+	"transmission":    "1.3.6.1.2.1.10",
+	"experimental":    "1.3.6.1.3",
+	"private":         "1.3.6.1.4",
+	"enterprises":     "1.3.6.1.4.1",
+	"security":        "1.3.6.1.5",
+	"snmpV2":          "1.3.6.1.6",
+	"snmpDomains":     "1.3.6.1.6.1",
+	"snmpProxys":      "1.3.6.1.6.2",
+	"snmpModules":     "1.3.6.1.6.3",
+}
+
+var (
+	// definitionRe matches the definitions that assign an OID to a name,
+	// e.g. `ifDescr OBJECT-TYPE ... ::= { ifEntry 2 }`.
+	definitionRe = regexp.MustCompile(`(?s)\b([a-z][\w-]*)\s+(?:OBJECT-TYPE|OBJECT-IDENTITY|MODULE-IDENTITY|NOTIFICATION-TYPE|TRAP-TYPE|OBJECT-GROUP|NOTIFICATION-GROUP|MODULE-COMPLIANCE|AGENT-CAPABILITIES|OBJECT\s+IDENTIFIER)\b(.*?)::=\s*(\{[^}]*\}|\d+)`)
+	importsRe    = regexp.MustCompile(`(?s)\bIMPORTS\b.*?;`)
+	macroRe      = regexp.MustCompile(`(?s)\bMACRO\s*::=\s*BEGIN\b.*?\bEND\b`)
+	enterpriseRe = regexp.MustCompile(`\bENTERPRISE\s+([a-zA-Z][\w-]*)`)
+	namedNumRe   = regexp.MustCompile(`^([a-zA-Z][\w-]*)\((\d+)\)$`)
+)
+
+// MIB translates between OIDs and the names of the objects defined in MIB
+// files. A nil MIB translates nothing.
+type MIB struct {
+	oids  map[string]string // name to OID
+	names map[string]string // OID to name
+}
+
+type definition struct {
+	parent string
+	subIDs []string
+}
+
+// LoadMIB parses the MIB files in paths. Paths can be files or directories,
+// in which case all the files in the directory are parsed.
+func LoadMIB(paths ...string) (*MIB, error) {
+	var sources []string
+	for _, path := range paths {
+		files, err := mibFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			data, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read MIB file: %w", err)
+			}
+			sources = append(sources, string(data))
+		}
+	}
+	return ParseMIB(sources...)
+}
+
+func mibFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MIB path: %w", err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MIB directory: %w", err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// ParseMIB parses the content of MIB modules. Only the OID assignments are
+// read, the names that cannot be resolved to an OID are ignored.
+func ParseMIB(sources ...string) (*MIB, error) {
+	defs := map[string]definition{}
+	for _, src := range sources {
+		src = stripComments(src)
+		src = importsRe.ReplaceAllString(src, "")
+		src = macroRe.ReplaceAllString(src, "")
+		for _, m := range definitionRe.FindAllStringSubmatch(src, -1) {
+			name, body, value := m[1], m[2], m[3]
+			if strings.HasPrefix(value, "{") {
+				parseOIDValue(defs, name, value)
+				continue
+			}
+			// SMIv1 traps are identified by their enterprise and
+			// specific trap number, as in RFC 3584.
+			if e := enterpriseRe.FindStringSubmatch(body); e != nil {
+				defs[name] = definition{parent: e[1], subIDs: []string{"0", value}}
+			}
+		}
+	}
+
+	mib := &MIB{
+		oids:  make(map[string]string, len(defs)+len(baseOIDs)),
+		names: make(map[string]string, len(defs)+len(baseOIDs)),
+	}
+	for name, oid := range baseOIDs {
+		mib.oids[name] = oid
+	}
+	for name := range defs {
+		mib.resolve(defs, name, 0)
+	}
+	for name, oid := range mib.oids {
+		mib.names[oid] = name
+	}
+	return mib, nil
+}
+
+// parseOIDValue parses values like `{ ifEntry 2 }` or
+// `{ iso org(3) dod(6) 1 }`, registering the named intermediate nodes.
+func parseOIDValue(defs map[string]definition, name, value string) {
+	fields := strings.Fields(strings.Trim(value, "{}"))
+	if len(fields) < 2 {
+		return
+	}
+
+	parent := fields[0]
+	if m := namedNumRe.FindStringSubmatch(parent); m != nil {
+		parent = m[1]
+	}
+	var subIDs []string
+	for _, f := range fields[1:] {
+		if m := namedNumRe.FindStringSubmatch(f); m != nil {
+			subIDs = append(subIDs, m[2])
+			if _, exists := defs[m[1]]; !exists {
+				defs[m[1]] = definition{parent: parent, subIDs: append([]string(nil), subIDs...)}
+			}
+			continue
+		}
+		if _, err := strconv.ParseUint(f, 10, 32); err != nil {
+			return
+		}
+		subIDs = append(subIDs, f)
+	}
+	defs[name] = definition{parent: parent, subIDs: subIDs}
+}
+
+func (m *MIB) resolve(defs map[string]definition, name string, depth int) (string, bool) {
+	if oid, ok := m.oids[name]; ok {
+		return oid, true
+	}
+	def, ok := defs[name]
+	// The depth limit protects against definition loops.
+	if !ok || depth > 128 {
+		return "", false
+	}
+
+	parent, ok := m.resolve(defs, def.parent, depth+1)
+	if !ok {
+		if _, err := strconv.ParseUint(def.parent, 10, 32); err != nil {
+			return "", false
+		}
+		parent = def.parent
+	}
+	oid := strings.Join(append([]string{parent}, def.subIDs...), ".")
+	m.oids[name] = oid
+	return oid, true
+}
+
+// stripComments removes the ASN.1 comments, which start with `--` and end at
+// the end of the line or at the next `--`, and the content of the quoted
+// strings, which may contain anything.
+func stripComments(src string) string {
+	var sb strings.Builder
+	sb.Grow(len(src))
+	inString, inComment := false, false
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case inComment:
+			if c == '\n' {
+				inComment = false
+				sb.WriteByte(c)
+			} else if c == '-' && i+1 < len(src) && src[i+1] == '-' {
+				inComment = false
+				i++
+			}
+		case inString:
+			if c == '"' {
+				inString = false
+				sb.WriteByte(c)
+			}
+		case c == '"':
+			inString = true
+			sb.WriteByte(c)
+		case c == '-' && i+1 < len(src) && src[i+1] == '-':
+			inComment = true
+			i++
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// Name returns the name of the object of the OID, followed by the remaining
+// sub-identifiers of the OID if it is an instance, e.g. `ifDescr.1`.
+func (m *MIB) Name(oid string) (string, bool) {
+	if m == nil {
+		return "", false
+	}
+	oid = strings.TrimPrefix(oid, ".")
+	for prefix := oid; prefix != ""; {
+		if name, ok := m.names[prefix]; ok {
+			return name + oid[len(prefix):], true
+		}
+		i := strings.LastIndexByte(prefix, '.')
+		if i < 0 {
+			break
+		}
+		prefix = prefix[:i]
+	}
+	return "", false
+}
+
+// OID returns the OID of a name like `ifDescr`, `IF-MIB::ifDescr` or
+// `ifDescr.1`. Numeric OIDs are returned as they are.
+func (m *MIB) OID(name string) (string, bool) {
+	name = strings.TrimPrefix(name, ".")
+	if isNumericOID(name) {
+		return name, true
+	}
+	if m == nil {
+		return "", false
+	}
+	if _, n, found := strings.Cut(name, "::"); found {
+		name = n
+	}
+	object, instance, _ := strings.Cut(name, ".")
+	oid, ok := m.oids[object]
+	if !ok {
+		return "", false
+	}
+	if instance != "" {
+		if !isNumericOID(instance) {
+			return "", false
+		}
+		oid += "." + instance
+	}
+	return oid, true
+}
+
+func isNumericOID(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, part := range strings.Split(s, ".") {
+		if _, err := strconv.ParseUint(part, 10, 32); err != nil {
+			return false
+		}
+	}
+	return true
+}
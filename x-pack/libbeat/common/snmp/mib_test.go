@@ -0,0 +1,153 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMIB = `
+IF-MIB DEFINITIONS ::= BEGIN
+
+IMPORTS
+    MODULE-IDENTITY, OBJECT-TYPE, Counter32, Gauge32, Counter64,
+    Integer32, TimeTicks, mib-2,
+    NOTIFICATION-TYPE                        FROM SNMPv2-SMI
+    DisplayString, PhysAddress, TruthValue, RowStatus,
+    TimeStamp, AutonomousType, TestAndIncr   FROM SNMPv2-TC;
+
+ifMIB MODULE-IDENTITY
+    LAST-UPDATED "200006140000Z"
+    DESCRIPTION
+            "The MIB module to describe generic objects for network
+            interface sub-layers. -- not a comment ::= { fake 1 }"
+    ::= { mib-2 31 }
+
+interfaces   OBJECT IDENTIFIER ::= { mib-2 2 } -- a comment ::= { fake 2 }
+
+ifTable OBJECT-TYPE
+    SYNTAX      SEQUENCE OF IfEntry
+    MAX-ACCESS  not-accessible
+    STATUS      current
+    ::= { interfaces 2 }
+
+ifEntry OBJECT-TYPE
+    SYNTAX      IfEntry
+    INDEX   { ifIndex }
+    ::= { ifTable 1 }
+
+IfEntry ::=
+    SEQUENCE {
+        ifIndex                 InterfaceIndex,
+        ifDescr                 DisplayString
+    }
+
+ifDescr OBJECT-TYPE
+    SYNTAX      DisplayString (SIZE (0..255))
+    MAX-ACCESS  read-only
+    STATUS      current
+    DESCRIPTION
+            "A textual string containing information about the
+            interface."
+    ::= { ifEntry 2 }
+
+ifOperStatus OBJECT-TYPE
+    SYNTAX  INTEGER { up(1), down(2) }
+    DEFVAL  { 1 }
+    ::= { ifEntry 8 }
+
+linkDown NOTIFICATION-TYPE
+    OBJECTS { ifIndex, ifAdminStatus, ifOperStatus }
+    ::= { snmpTraps 3 }
+
+snmpTraps OBJECT IDENTIFIER ::= { iso(1) org(3) dod(6) internet(1) snmpV2(6) snmpModules(3) 1 1 5 }
+
+acme OBJECT IDENTIFIER ::= { enterprises 9999 }
+
+acmeTrap TRAP-TYPE
+    ENTERPRISE  acme
+    VARIABLES   { ifIndex }
+    ::= 7
+
+END
+`
+
+func TestParseMIB(t *testing.T) {
+	mib, err := ParseMIB(testMIB)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		oid  string
+	}{
+		{"ifMIB", "1.3.6.1.2.1.31"},
+		{"interfaces", "1.3.6.1.2.1.2"},
+		{"ifDescr", "1.3.6.1.2.1.2.2.1.2"},
+		{"ifOperStatus", "1.3.6.1.2.1.2.2.1.8"},
+		{"linkDown", "1.3.6.1.6.3.1.1.5.3"},
+		{"acmeTrap", "1.3.6.1.4.1.9999.0.7"},
+		{"enterprises", "1.3.6.1.4.1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oid, ok := mib.OID(test.name)
+			assert.True(t, ok)
+			assert.Equal(t, test.oid, oid)
+
+			name, ok := mib.Name(test.oid)
+			assert.True(t, ok)
+			assert.Equal(t, test.name, name)
+		})
+	}
+
+	_, ok := mib.OID("fake")
+	assert.False(t, ok)
+}
+
+func TestMIBName(t *testing.T) {
+	mib, err := ParseMIB(testMIB)
+	require.NoError(t, err)
+
+	name, ok := mib.Name(".1.3.6.1.2.1.2.2.1.2.10")
+	assert.True(t, ok)
+	assert.Equal(t, "ifDescr.10", name)
+
+	name, ok = mib.Name("1.3.6.1.2.1.1.5.0")
+	assert.True(t, ok)
+	assert.Equal(t, "mib-2.1.5.0", name)
+
+	_, ok = mib.Name("3.1")
+	assert.False(t, ok)
+
+	var nilMIB *MIB
+	_, ok = nilMIB.Name("1.3.6.1")
+	assert.False(t, ok)
+}
+
+func TestMIBOID(t *testing.T) {
+	mib, err := ParseMIB(testMIB)
+	require.NoError(t, err)
+
+	for name, expected := range map[string]string{
+		"IF-MIB::ifDescr":    "1.3.6.1.2.1.2.2.1.2",
+		"ifDescr.10":         "1.3.6.1.2.1.2.2.1.2.10",
+		".1.3.6.1.2.1.1.5.0": "1.3.6.1.2.1.1.5.0",
+	} {
+		oid, ok := mib.OID(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, oid, name)
+	}
+
+	_, ok := mib.OID("ifDescr.foo")
+	assert.False(t, ok)
+
+	var nilMIB *MIB
+	oid, ok := nilMIB.OID("1.3.6.1")
+	assert.True(t, ok)
+	assert.Equal(t, "1.3.6.1", oid)
+}
@@ -0,0 +1,73 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Value converts the value of a variable binding to a type that can be
+// used in an event. It returns false for the variables without value, like
+// noSuchObject or endOfMibView.
+func Value(pdu gosnmp.SnmpPDU) (interface{}, bool) {
+	switch pdu.Type {
+	case gosnmp.Null, gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.UnknownType:
+		return nil, false
+	case gosnmp.OctetString, gosnmp.Opaque, gosnmp.BitString:
+		b, ok := pdu.Value.([]byte)
+		if !ok {
+			return pdu.Value, pdu.Value != nil
+		}
+		return OctetString(b), true
+	case gosnmp.ObjectIdentifier:
+		s, _ := pdu.Value.(string)
+		return strings.TrimPrefix(s, "."), true
+	case gosnmp.Integer:
+		return gosnmp.ToBigInt(pdu.Value).Int64(), true
+	case gosnmp.Counter32, gosnmp.Counter64, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		return gosnmp.ToBigInt(pdu.Value).Uint64(), true
+	default:
+		return pdu.Value, pdu.Value != nil
+	}
+}
+
+// OctetString returns the string of an OCTET STRING value if it is
+// printable, and its hexadecimal representation with colon separated bytes
+// otherwise, as used for MAC addresses.
+func OctetString(b []byte) string {
+	if isPrintable(b) {
+		return string(b)
+	}
+	var sb strings.Builder
+	for i, c := range b {
+		if i > 0 {
+			sb.WriteByte(':')
+		}
+		fmt.Fprintf(&sb, "%02x", c)
+	}
+	return sb.String()
+}
+
+func isPrintable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCounter reports whether the variable is a counter.
+func IsCounter(pdu gosnmp.SnmpPDU) bool {
+	return pdu.Type == gosnmp.Counter32 || pdu.Type == gosnmp.Counter64
+}
@@ -0,0 +1,39 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValue(t *testing.T) {
+	tests := []struct {
+		pdu      gosnmp.SnmpPDU
+		expected interface{}
+		ok       bool
+	}{
+		{gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte("eth0")}, "eth0", true},
+		{gosnmp.SnmpPDU{Type: gosnmp.OctetString, Value: []byte{0x00, 0x1b, 0x21, 0x3a, 0x4f, 0xff}}, "00:1b:21:3a:4f:ff", true},
+		{gosnmp.SnmpPDU{Type: gosnmp.Integer, Value: -5}, int64(-5), true},
+		{gosnmp.SnmpPDU{Type: gosnmp.Counter32, Value: uint(42)}, uint64(42), true},
+		{gosnmp.SnmpPDU{Type: gosnmp.Counter64, Value: uint64(1 << 40)}, uint64(1 << 40), true},
+		{gosnmp.SnmpPDU{Type: gosnmp.TimeTicks, Value: uint32(100)}, uint64(100), true},
+		{gosnmp.SnmpPDU{Type: gosnmp.ObjectIdentifier, Value: ".1.3.6.1.4.1.9"}, "1.3.6.1.4.1.9", true},
+		{gosnmp.SnmpPDU{Type: gosnmp.IPAddress, Value: "10.0.0.1"}, "10.0.0.1", true},
+		{gosnmp.SnmpPDU{Type: gosnmp.OpaqueFloat, Value: float32(1.5)}, float32(1.5), true},
+		{gosnmp.SnmpPDU{Type: gosnmp.NoSuchObject}, nil, false},
+		{gosnmp.SnmpPDU{Type: gosnmp.EndOfMibView}, nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.pdu.Type.String(), func(t *testing.T) {
+			v, ok := Value(test.pdu)
+			assert.Equal(t, test.ok, ok)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
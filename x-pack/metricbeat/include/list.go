@@ -44,6 +44,8 @@ import (
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/ibmmq"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/iis"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/iis/application_pool"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/influxdb"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/influxdb/write"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/istio"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/istio/citadel"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/istio/galley"
@@ -59,6 +61,8 @@ import (
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/oracle/performance"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/oracle/sysmetric"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/oracle/tablespace"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/otlp"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/otlp/receiver"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/panw"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/panw/interfaces"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/panw/routing"
@@ -68,6 +72,9 @@ import (
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/prometheus/collector"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/prometheus/remote_write"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/redisenterprise"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp/get"
+	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp/table"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/sql"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/sql/query"
 	_ "github.com/elastic/beats/v7/x-pack/metricbeat/module/stan"
@@ -0,0 +1,23 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Code generated by beats/dev-tools/cmd/asset/asset.go - DO NOT EDIT.
+
+package influxdb
+
+import (
+	"github.com/elastic/beats/v7/libbeat/asset"
+)
+
+func init() {
+	if err := asset.SetFields("metricbeat", "influxdb", asset.ModuleFieldsPri, AssetInfluxdb); err != nil {
+		panic(err)
+	}
+}
+
+// AssetInfluxdb returns asset data.
+// This is the base64 encoded zlib format compressed contents of module/influxdb.
+func AssetInfluxdb() string {
+	return "eJysk81q6zAQhfd+ioOX4cYPoMWFeymFLAJddFdKIltjZxpZEpLcNG9f7DiNjFualMKsjubn42hmiT0dBdjUuntTZQZEjpoE8tUg3f3PM0BRqDy7yNYI/M0AYE3RcxXgqSJ+JQU2iDvCuQyaDcF5G21ldZEBnjTJQAIlRZkBgWJk0wSBpzwEnT9nQM2kVRDDhCWMbGnC1svx6Eig8bZzo/IJXR/bc+EWlTVRsgkDYXszOTAlS+lakqHz1JKJH29nyD0dD9arRP8CtY/1pRFsPRA5yyaGYjZTy5J0KBZJ/WmgLV+oSjlOwuYHOI+yCd9xjE4WixtRNk562SZm9rFMMwSU7UpNk4xpj1Y6x6YZ03NtTZP/ZsMTQH6dW/fD3k79+oPOKPLDT/Z+ja/s052Zm3rwHFPM+b4D82O6gnF2sXHnbdfspss/jMe/h9WFDKiZtAoiex8AtSc24g=="
+}
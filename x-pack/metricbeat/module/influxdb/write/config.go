@@ -0,0 +1,36 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package write
+
+import "github.com/elastic/beats/v7/metricbeat/helper/server/udp"
+
+type config struct {
+	// UDP configures the optional UDP listener, as the one of InfluxDB 1.x.
+	UDP udpConfig `config:"udp"`
+}
+
+type udpConfig struct {
+	Enabled       bool   `config:"enabled"`
+	Precision     string `config:"precision"`
+	udp.UdpConfig `config:",inline"`
+}
+
+func defaultConfig() config {
+	return config{
+		UDP: udpConfig{
+			UdpConfig: udp.UdpConfig{
+				Host:              "localhost",
+				Port:              8089,
+				ReceiveBufferSize: 65536,
+			},
+		},
+	}
+}
+
+// Validate checks that the precision of the UDP listener is valid.
+func (c *udpConfig) Validate() error {
+	_, err := parsePrecision(c.Precision)
+	return err
+}
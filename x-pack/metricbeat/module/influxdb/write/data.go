@@ -0,0 +1,71 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package write
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// pointsToEvents groups the points with the same measurement, tags and
+// timestamp in a single event.
+func pointsToEvents(points []point) []mb.Event {
+	var events []mb.Event
+	index := map[string]int{}
+	for _, p := range points {
+		key := groupKey(p)
+		if i, found := index[key]; found {
+			metrics := events[i].ModuleFields["metrics"].(mapstr.M)
+			metrics[p.measurement].(mapstr.M).Update(p.fields)
+			continue
+		}
+
+		fields := mapstr.M{
+			"measurement": p.measurement,
+			"metrics": mapstr.M{
+				p.measurement: p.fields,
+			},
+		}
+		if len(p.tags) > 0 {
+			labels := mapstr.M{}
+			for k, v := range p.tags {
+				labels[common.DeDot(k)] = v
+			}
+			fields["labels"] = labels
+		}
+
+		index[key] = len(events)
+		events = append(events, mb.Event{
+			Timestamp:    p.timestamp,
+			ModuleFields: fields,
+		})
+	}
+	return events
+}
+
+func groupKey(p point) string {
+	keys := make([]string, 0, len(p.tags))
+	for k := range p.tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(p.measurement)
+	for _, k := range keys {
+		b.WriteString("\x00")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(p.tags[k])
+	}
+	b.WriteString("\x00")
+	b.WriteString(p.timestamp.Format(time.RFC3339Nano))
+	return b.String()
+}
@@ -0,0 +1,223 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package write
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// point is a data point of the InfluxDB line protocol.
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      mapstr.M
+	timestamp   time.Time
+}
+
+// parsePrecision returns the duration of the timestamp unit of the given
+// precision, nanoseconds by default.
+func parsePrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ", "µs":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("invalid precision %q", precision)
+	}
+}
+
+// parseLines parses the points of the lines of data, skipping empty lines and
+// comments. Points without timestamp get the given time. The returned error
+// joins the errors of all the invalid lines, the points of the valid lines
+// are returned anyway.
+func parseLines(data []byte, precision time.Duration, now time.Time) ([]point, error) {
+	var points []point
+	var errs []error
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		p, err := parseLine(line, precision, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("unable to parse line %d: %w", n+1, err))
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, errors.Join(errs...)
+}
+
+// parseLine parses a line with the syntax:
+//
+//	measurement[,tag_key=tag_value...] field_key=field_value[,field_key=field_value...] [timestamp]
+func parseLine(line string, precision time.Duration, now time.Time) (point, error) {
+	p := point{timestamp: now}
+
+	end := scan(line, 0, ", ")
+	p.measurement = unescape(line[:end], ", ")
+	if p.measurement == "" {
+		return p, errors.New("missing measurement")
+	}
+
+	i := end
+	for i < len(line) && line[i] == ',' {
+		key, value, next, err := parsePair(line, i+1, false)
+		if err != nil {
+			return p, fmt.Errorf("invalid tag: %w", err)
+		}
+		if p.tags == nil {
+			p.tags = map[string]string{}
+		}
+		p.tags[unescape(key, ",= ")] = unescape(value, ",= ")
+		i = next
+	}
+
+	if i >= len(line) || line[i] != ' ' {
+		return p, errors.New("missing fields")
+	}
+	for i < len(line) && line[i] == ' ' {
+		i++
+	}
+	p.fields = mapstr.M{}
+	for {
+		key, raw, next, err := parsePair(line, i, true)
+		if err != nil {
+			return p, fmt.Errorf("invalid field: %w", err)
+		}
+		key = unescape(key, ",= ")
+		value, err := parseFieldValue(raw)
+		if err != nil {
+			return p, fmt.Errorf("invalid value of field %q: %w", key, err)
+		}
+		p.fields[key] = value
+
+		i = next
+		if i >= len(line) || line[i] != ',' {
+			break
+		}
+		i++
+	}
+
+	rest := strings.TrimSpace(line[i:])
+	if rest != "" {
+		ts, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("invalid timestamp %q", rest)
+		}
+		p.timestamp = time.Unix(0, ts*int64(precision)).UTC()
+	}
+
+	return p, nil
+}
+
+// parsePair parses a key=value pair starting at i, and returns the index
+// after it. Quoted values are only allowed in fields, and are returned with
+// their quotes.
+func parsePair(line string, i int, quoted bool) (key, value string, next int, err error) {
+	end := scan(line, i, ",= ")
+	if end == i || end >= len(line) || line[end] != '=' {
+		return "", "", 0, fmt.Errorf("invalid key at position %d", i+1)
+	}
+	key = line[i:end]
+
+	i = end + 1
+	if quoted && i < len(line) && line[i] == '"' {
+		end = i + 1
+		for ; end < len(line) && line[end] != '"'; end++ {
+			if line[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(line) {
+			return "", "", 0, errors.New("unterminated string")
+		}
+		end++
+	} else {
+		end = scan(line, i, ", ")
+	}
+	if end == i {
+		return "", "", 0, fmt.Errorf("missing value of %q", key)
+	}
+	return key, line[i:end], end, nil
+}
+
+// parseFieldValue parses a field value, which is a float by default, an
+// integer with the i suffix, an unsigned integer with the u suffix, a quoted
+// string or a boolean.
+func parseFieldValue(raw string) (interface{}, error) {
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return false, nil
+	}
+
+	if raw[0] == '"' {
+		return strings.NewReplacer(`\"`, `"`, `\\`, `\`).Replace(raw[1 : len(raw)-1]), nil
+	}
+
+	switch raw[len(raw)-1] {
+	case 'i':
+		return strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+	case 'u':
+		return strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+	}
+
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("invalid number %q", raw)
+	}
+	return value, nil
+}
+
+// scan returns the index of the first byte of line from i that is one of the
+// stop bytes and is not escaped with a backslash, or the length of the line.
+func scan(line string, i int, stops string) int {
+	for ; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && strings.IndexByte(stops, line[i+1]) >= 0 {
+			i++
+			continue
+		}
+		if strings.IndexByte(stops, line[i]) >= 0 {
+			return i
+		}
+	}
+	return i
+}
+
+// unescape removes the backslashes that escape any of the given bytes.
+func unescape(s string, escaped string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(escaped, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
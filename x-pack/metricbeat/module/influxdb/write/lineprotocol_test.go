@@ -0,0 +1,148 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !integration
+
+package write
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var now = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func TestParseLine(t *testing.T) {
+	for name, tc := range map[string]struct {
+		line      string
+		precision time.Duration
+		expected  point
+	}{
+		"minimal": {
+			line: "cpu value=1",
+			expected: point{
+				measurement: "cpu",
+				fields:      mapstr.M{"value": float64(1)},
+				timestamp:   now,
+			},
+		},
+		"tags and timestamp": {
+			line: "cpu,host=server01,region=us-west usage_idle=92.5,usage_user=3.25 1727784000000000000",
+			expected: point{
+				measurement: "cpu",
+				tags:        map[string]string{"host": "server01", "region": "us-west"},
+				fields:      mapstr.M{"usage_idle": 92.5, "usage_user": 3.25},
+				timestamp:   now,
+			},
+		},
+		"precision": {
+			line:      "cpu value=1 1727784000",
+			precision: time.Second,
+			expected: point{
+				measurement: "cpu",
+				fields:      mapstr.M{"value": float64(1)},
+				timestamp:   now,
+			},
+		},
+		"field types": {
+			line: `system n_cpus=8i,uptime=1234u,load1=0.5,up=t,down=FALSE,version="1.2 \"beta\", \\ final"`,
+			expected: point{
+				measurement: "system",
+				fields: mapstr.M{
+					"n_cpus":  int64(8),
+					"uptime":  uint64(1234),
+					"load1":   0.5,
+					"up":      true,
+					"down":    false,
+					"version": `1.2 "beta", \ final`,
+				},
+				timestamp: now,
+			},
+		},
+		"escaped characters": {
+			line: `disk\ io,path=/mnt/my\ disk,opt\=s=a\,b read\ bytes=10i`,
+			expected: point{
+				measurement: "disk io",
+				tags:        map[string]string{"path": "/mnt/my disk", "opt=s": "a,b"},
+				fields:      mapstr.M{"read bytes": int64(10)},
+				timestamp:   now,
+			},
+		},
+		"quoted tag value": {
+			line: `cpu,host="server01" value=1`,
+			expected: point{
+				measurement: "cpu",
+				tags:        map[string]string{"host": `"server01"`},
+				fields:      mapstr.M{"value": float64(1)},
+				timestamp:   now,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			precision := tc.precision
+			if precision == 0 {
+				precision = time.Nanosecond
+			}
+			p, err := parseLine(tc.line, precision, now)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, p)
+		})
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for name, line := range map[string]string{
+		"missing fields":       "cpu",
+		"missing field value":  "cpu value=",
+		"missing field key":    "cpu =1",
+		"empty tag":            "cpu, value=1",
+		"unterminated string":  `cpu value="abc`,
+		"invalid integer":      "cpu value=1.5i",
+		"invalid float":        "cpu value=abc",
+		"not a number":         "cpu value=NaN",
+		"invalid timestamp":    "cpu value=1 yesterday",
+		"missing measurement":  ",host=a value=1",
+		"trailing field comma": "cpu value=1,",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseLine(line, time.Nanosecond, now)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseLines(t *testing.T) {
+	data := []byte("# comment\ncpu value=1\n\ncpu value=\r\nmem used=2i\n")
+
+	points, err := parseLines(data, time.Nanosecond, now)
+	assert.ErrorContains(t, err, "unable to parse line 4")
+	require.Len(t, points, 2)
+	assert.Equal(t, "cpu", points[0].measurement)
+	assert.Equal(t, "mem", points[1].measurement)
+}
+
+func TestParsePrecision(t *testing.T) {
+	for precision, expected := range map[string]time.Duration{
+		"":   time.Nanosecond,
+		"ns": time.Nanosecond,
+		"u":  time.Microsecond,
+		"us": time.Microsecond,
+		"ms": time.Millisecond,
+		"s":  time.Second,
+		"m":  time.Minute,
+		"h":  time.Hour,
+	} {
+		d, err := parsePrecision(precision)
+		require.NoError(t, err)
+		assert.Equal(t, expected, d, precision)
+	}
+
+	_, err := parsePrecision("d")
+	assert.Error(t, err)
+}
@@ -0,0 +1,198 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package write
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	serverhelper "github.com/elastic/beats/v7/metricbeat/helper/server"
+	httpserver "github.com/elastic/beats/v7/metricbeat/helper/server/http"
+	"github.com/elastic/beats/v7/metricbeat/helper/server/udp"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/beats/v7/metricbeat/mb/parse"
+)
+
+const (
+	writePathV1 = "/write"
+	writePathV2 = "/api/v2/write"
+	pingPath    = "/ping"
+)
+
+func init() {
+	mb.Registry.MustAddMetricSet("influxdb", "write", New,
+		mb.WithHostParser(parse.EmptyHostParser),
+		mb.DefaultMetricSet(),
+	)
+}
+
+// MetricSet receives points in the InfluxDB line protocol through the write
+// endpoints of the InfluxDB HTTP API, and optionally through UDP, and reports
+// them as events.
+type MetricSet struct {
+	mb.BaseMetricSet
+	server       serverhelper.Server
+	udpServer    serverhelper.Server
+	udpPrecision time.Duration
+	events       chan mb.Event
+}
+
+// New creates a new instance of the MetricSet.
+func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
+	cfgwarn.Beta("The influxdb write metricset is beta.")
+
+	config := defaultConfig()
+	if err := base.Module().UnpackConfig(&config); err != nil {
+		return nil, err
+	}
+
+	m := &MetricSet{
+		BaseMetricSet: base,
+		events:        make(chan mb.Event),
+	}
+
+	svc, err := httpserver.NewHttpServerWithHandler(base, m.handleFunc)
+	if err != nil {
+		return nil, err
+	}
+	m.server = svc
+
+	if config.UDP.Enabled {
+		m.udpServer, err = udp.NewUdpServerWithConfig(config.UDP.UdpConfig)
+		if err != nil {
+			return nil, err
+		}
+		m.udpPrecision, _ = parsePrecision(config.UDP.Precision)
+	}
+	return m, nil
+}
+
+// Run starts the servers and reports the events of the points they receive
+// until the reporter is done.
+func (m *MetricSet) Run(reporter mb.PushReporterV2) {
+	_ = m.server.Start()
+	defer m.server.Stop()
+
+	// A nil channel is never ready, so UDP is ignored when it is disabled.
+	var udpEvents chan serverhelper.Event
+	if m.udpServer != nil {
+		if err := m.udpServer.Start(); err != nil {
+			reporter.Error(fmt.Errorf("failed to start UDP server: %w", err))
+			return
+		}
+		defer m.udpServer.Stop()
+		udpEvents = m.udpServer.GetEvents()
+	}
+
+	for {
+		select {
+		case <-reporter.Done():
+			return
+		case e := <-m.events:
+			reporter.Event(e)
+		case msg := <-udpEvents:
+			data, ok := msg.GetEvent()[serverhelper.EventDataKey].([]byte)
+			if !ok {
+				continue
+			}
+			points, err := parseLines(data, m.udpPrecision, time.Now())
+			if err != nil {
+				reporter.Error(err)
+			}
+			for _, e := range pointsToEvents(points) {
+				reporter.Event(e)
+			}
+		}
+	}
+}
+
+func (m *MetricSet) handleFunc(writer http.ResponseWriter, req *http.Request) {
+	switch req.URL.Path {
+	case pingPath:
+		writer.WriteHeader(http.StatusNoContent)
+		return
+	case writePathV1, writePathV2:
+	default:
+		http.NotFound(writer, req)
+		return
+	}
+
+	if req.Method != http.MethodPost {
+		writeError(writer, req, http.StatusMethodNotAllowed, "InfluxDB write endpoints accept data via POST")
+		return
+	}
+
+	precision, err := parsePrecision(req.URL.Query().Get("precision"))
+	if err != nil {
+		writeError(writer, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	body := req.Body
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			m.Logger().Errorf("Decode error %v", err)
+			writeError(writer, req, http.StatusBadRequest, err.Error())
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		writeError(writer, req, http.StatusUnsupportedMediaType, fmt.Sprintf("unsupported content encoding %q", encoding))
+		return
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		m.Logger().Errorf("Read error %v", err)
+		writeError(writer, req, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Points of valid lines are reported even if other lines are invalid,
+	// as InfluxDB does.
+	points, parseErr := parseLines(data, precision, time.Now())
+	for _, e := range pointsToEvents(points) {
+		select {
+		case <-req.Context().Done():
+			return
+		case m.events <- e:
+		}
+	}
+
+	if parseErr != nil {
+		m.Logger().Debugf("Parse error %v", parseErr)
+		writeError(writer, req, http.StatusBadRequest, parseErr.Error())
+		return
+	}
+	writer.WriteHeader(http.StatusNoContent)
+}
+
+// writeError writes an error response in the format of the version of the
+// API of the request.
+func writeError(writer http.ResponseWriter, req *http.Request, status int, message string) {
+	var body interface{}
+	if req.URL.Path == writePathV2 {
+		code := "invalid"
+		if status == http.StatusMethodNotAllowed {
+			code = "method not allowed"
+		}
+		body = map[string]string{"code": code, "message": message}
+	} else {
+		body = map[string]string{"error": message}
+	}
+
+	writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+	writer.WriteHeader(status)
+	_ = json.NewEncoder(writer).Encode(body)
+}
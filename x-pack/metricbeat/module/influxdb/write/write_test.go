@@ -0,0 +1,185 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !integration
+
+package write
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	mbtest "github.com/elastic/beats/v7/metricbeat/mb/testing"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestPointsToEvents(t *testing.T) {
+	points, err := parseLines([]byte(
+		"cpu,host=a,cpu.id=0 usage_idle=90 1727784000000000000\n"+
+			"cpu,cpu.id=0,host=a usage_user=5 1727784000000000000\n"+
+			"cpu,host=b,cpu.id=0 usage_idle=80 1727784000000000000\n"+
+			"cpu,host=a,cpu.id=0 usage_idle=91 1727784010000000000\n"+
+			"mem used=1024i 1727784000000000000\n",
+	), time.Nanosecond, now)
+	require.NoError(t, err)
+
+	events := pointsToEvents(points)
+	require.Len(t, events, 4)
+
+	assert.Equal(t, now, events[0].Timestamp)
+	assert.Equal(t, mapstr.M{
+		"measurement": "cpu",
+		"labels":      mapstr.M{"host": "a", "cpu_id": "0"},
+		"metrics": mapstr.M{
+			"cpu": mapstr.M{"usage_idle": float64(90), "usage_user": float64(5)},
+		},
+	}, events[0].ModuleFields)
+
+	assert.Equal(t, "b", events[1].ModuleFields["labels"].(mapstr.M)["host"])
+	assert.Equal(t, now.Add(10*time.Second), events[2].Timestamp)
+
+	assert.Equal(t, mapstr.M{
+		"measurement": "mem",
+		"metrics": mapstr.M{
+			"mem": mapstr.M{"used": int64(1024)},
+		},
+	}, events[3].ModuleFields)
+}
+
+func TestHandleFunc(t *testing.T) {
+	ms := mbtest.NewPushMetricSetV2(t, map[string]interface{}{
+		"module":     "influxdb",
+		"metricsets": []string{"write"},
+	}).(*MetricSet)
+	ms.events = make(chan mb.Event, 10)
+
+	body := []byte("cpu,host=a usage_idle=90\n")
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	_, err := w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	for name, tc := range map[string]struct {
+		method   string
+		path     string
+		body     []byte
+		encoding string
+		status   int
+		events   int
+		error    string
+	}{
+		"v1":                   {path: "/write?db=telegraf", body: body, status: http.StatusNoContent, events: 1},
+		"v2":                   {path: "/api/v2/write?org=o&bucket=b&precision=s", body: body, status: http.StatusNoContent, events: 1},
+		"gzip":                 {path: "/write", body: gzipped.Bytes(), encoding: "gzip", status: http.StatusNoContent, events: 1},
+		"partial v1":           {path: "/write", body: []byte("cpu value=\nmem used=1i"), status: http.StatusBadRequest, events: 1, error: "error"},
+		"partial v2":           {path: "/api/v2/write", body: []byte("cpu value=\nmem used=1i"), status: http.StatusBadRequest, events: 1, error: "message"},
+		"invalid precision":    {path: "/write?precision=d", body: body, status: http.StatusBadRequest, error: "error"},
+		"invalid gzip":         {path: "/write", body: body, encoding: "gzip", status: http.StatusBadRequest, error: "error"},
+		"unsupported encoding": {path: "/write", body: body, encoding: "br", status: http.StatusUnsupportedMediaType, error: "error"},
+		"get":                  {method: http.MethodGet, path: "/api/v2/write", status: http.StatusMethodNotAllowed, error: "message"},
+		"ping":                 {method: http.MethodGet, path: "/ping", status: http.StatusNoContent},
+		"unknown path":         {path: "/query", body: body, status: http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = http.MethodPost
+			}
+			req := httptest.NewRequest(method, tc.path, bytes.NewReader(tc.body))
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			rec := httptest.NewRecorder()
+			ms.handleFunc(rec, req)
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.error != "" {
+				var response map[string]string
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &response))
+				assert.NotEmpty(t, response[tc.error])
+			}
+
+			require.Len(t, ms.events, tc.events)
+			for i := 0; i < tc.events; i++ {
+				<-ms.events
+			}
+		})
+	}
+}
+
+func TestRunUDP(t *testing.T) {
+	port := freeUDPPort(t)
+	ms := mbtest.NewPushMetricSetV2(t, map[string]interface{}{
+		"module":     "influxdb",
+		"metricsets": []string{"write"},
+		"host":       "127.0.0.1",
+		"port":       0,
+		"udp": map[string]interface{}{
+			"enabled":   true,
+			"host":      "127.0.0.1",
+			"port":      port,
+			"precision": "s",
+		},
+	})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := net.Dial("udp", net.JoinHostPort("127.0.0.1", port))
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+				_, _ = conn.Write([]byte("cpu,host=a usage_idle=90 1727784000\n"))
+			}
+		}
+	}()
+
+	events := mbtest.RunPushMetricSetV2(10*time.Second, 1, ms)
+	require.NotEmpty(t, events)
+	assert.Equal(t, now, events[0].Timestamp)
+	assert.Equal(t, mapstr.M{"usage_idle": float64(90)}, events[0].ModuleFields["metrics"].(mapstr.M)["cpu"])
+}
+
+func freeUDPPort(t *testing.T) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	_, port, err := net.SplitHostPort(conn.LocalAddr().String())
+	require.NoError(t, err)
+	return port
+}
+
+func TestData(t *testing.T) {
+	points, err := parseLines([]byte(
+		"cpu,cpu=cpu-total,host=server01 usage_idle=92.5,usage_system=2.25,usage_user=5.25 1727784000000000000\n",
+	), time.Nanosecond, now)
+	require.NoError(t, err)
+	events := pointsToEvents(points)
+	require.Len(t, events, 1)
+
+	ms := mbtest.NewPushMetricSetV2(t, map[string]interface{}{
+		"module":     "influxdb",
+		"metricsets": []string{"write"},
+	})
+	for _, e := range events {
+		mbtest.WriteEventToDataJSON(t, mbtest.StandardizeEvent(ms, e), "")
+	}
+}
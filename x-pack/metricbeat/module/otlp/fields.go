@@ -0,0 +1,23 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Code generated by beats/dev-tools/cmd/asset/asset.go - DO NOT EDIT.
+
+package otlp
+
+import (
+	"github.com/elastic/beats/v7/libbeat/asset"
+)
+
+func init() {
+	if err := asset.SetFields("metricbeat", "otlp", asset.ModuleFieldsPri, AssetOtlp); err != nil {
+		panic(err)
+	}
+}
+
+// AssetOtlp returns asset data.
+// This is the base64 encoded zlib format compressed contents of module/otlp.
+func AssetOtlp() string {
+	return "eJzMlM9qGzEQxu/7FB97DLEfYA+FQg+FpiRQ00spiaz9vFaj1SyaWad++yL/SZzaMUnpIaCLZyTN7zfWzgT3XDcQi0MFWLDIBvX17OqmroCW6nMYLEhq8KECgK+0HLwi0zOs2GKRpcf1wDRjZE/La3z79EXhUgsvMdKbZMVDsCXKvdMKyIx0ygZzmqsApVlInTb4UavG+mcFLAJjq82m6ATJ9XzELCFbD2zQZRn3kROwZd2VQ3fwksyFpLAl0f8t8YwOeF79kCC6OaNOLx4TexKZ/6K3g/A2cLvN3nP9ILk9SL9AW9ZHsxzmo1Ehiw1vpsqYPS+hXgZuets6cxgkJNvv2llNj6D3iYvpysWRb2RvZZxHns7e9m4YQup2W+uL+nWK3wtH4Xbo3NjxEpK3P5OkSS9JTFLw8GM/RmdhRejYnzPzMiZjfl9u/yKSnfF9WbSM5gr25l8Kyefy8Z43hIbkuXm8Q+YqyKjg70GynXNfBjXpsuvf2IBT5/5DDz7vry3ehT4xWXDxqd6xzG6kHD/Ew1EFHM/AV/C8MHtnVze73jLrExGwCIytNtWfAQC1Wbyc"
+}
@@ -0,0 +1,23 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package receiver
+
+import "time"
+
+type config struct {
+	// RateCounters adds the increase since the previous export to cumulative
+	// monotonic sums.
+	RateCounters bool `config:"rate_counters"`
+
+	// Period is the expected interval between exports, cumulative values not
+	// updated in five periods are forgotten.
+	Period time.Duration `config:"period" validate:"positive"`
+}
+
+func defaultConfig() config {
+	return config{
+		Period: 60 * time.Second,
+	}
+}
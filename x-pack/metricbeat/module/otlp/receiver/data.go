@@ -0,0 +1,287 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package receiver
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+
+	"github.com/elastic/beats/v7/libbeat/common"
+	p "github.com/elastic/beats/v7/metricbeat/helper/prometheus"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/prometheus/collector"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+type eventGenerator struct {
+	// mu serializes the access to the counter cache, which is not safe for
+	// concurrent use, from the requests handled concurrently.
+	mu           sync.Mutex
+	counterCache collector.CounterCache
+	rateCounters bool
+}
+
+// GenerateEvents converts the data points of the metrics to events:
+//  1. the attributes of the resource, the scope and the point are the labels
+//  2. the point is converted according to the type and temporality of its metric
+//  3. histograms are converted to ES histograms
+//  4. points with the same set of labels and timestamp are grouped into same events
+func (g *eventGenerator) GenerateEvents(metrics pmetric.Metrics) map[string]mb.Event {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	eventList := map[string]mb.Event{}
+
+	resourceMetrics := metrics.ResourceMetrics()
+	for i := 0; i < resourceMetrics.Len(); i++ {
+		resource := resourceMetrics.At(i).Resource().Attributes()
+		scopeMetrics := resourceMetrics.At(i).ScopeMetrics()
+		for j := 0; j < scopeMetrics.Len(); j++ {
+			scope := scopeMetrics.At(j).Scope().Attributes()
+			metricSlice := scopeMetrics.At(j).Metrics()
+			for k := 0; k < metricSlice.Len(); k++ {
+				metric := metricSlice.At(k)
+				name := metric.Name()
+
+				// add puts the data of the point in the event of its labels
+				// and timestamp.
+				add := func(attributes pcommon.Map, ts pcommon.Timestamp, data func(labels mapstr.M) mapstr.M) {
+					labels := attributesToLabels(resource, scope, attributes)
+					d := data(labels)
+					if d == nil {
+						return
+					}
+
+					timestamp := now
+					if ts != 0 {
+						timestamp = ts.AsTime()
+					}
+
+					labelsHash := labels.String() + timestamp.String()
+					e, ok := eventList[labelsHash]
+					if !ok {
+						e = mb.Event{
+							ModuleFields: mapstr.M{
+								"metrics": mapstr.M{},
+							},
+							Timestamp: timestamp,
+						}
+						if len(labels) > 0 {
+							e.ModuleFields["labels"] = labels
+						}
+						eventList[labelsHash] = e
+					}
+					e.ModuleFields["metrics"].(mapstr.M)[name] = d
+				}
+
+				switch metric.Type() {
+				case pmetric.MetricTypeGauge:
+					points := metric.Gauge().DataPoints()
+					for l := 0; l < points.Len(); l++ {
+						point := points.At(l)
+						add(point.Attributes(), point.Timestamp(), func(mapstr.M) mapstr.M {
+							return numberData("value", point)
+						})
+					}
+				case pmetric.MetricTypeSum:
+					sum := metric.Sum()
+					points := sum.DataPoints()
+					for l := 0; l < points.Len(); l++ {
+						point := points.At(l)
+						add(point.Attributes(), point.Timestamp(), func(labels mapstr.M) mapstr.M {
+							switch {
+							case sum.AggregationTemporality() == pmetric.AggregationTemporalityDelta:
+								// Delta sums are already the increase since the previous export.
+								return numberData("rate", point)
+							case sum.AggregationTemporality() == pmetric.AggregationTemporalityCumulative && sum.IsMonotonic():
+								d := numberData("counter", point)
+								if d != nil && g.rateCounters {
+									d["rate"], _ = g.counterCache.RateFloat64(name+labels.String(), d["counter"].(float64))
+								}
+								return d
+							default:
+								// Non-monotonic cumulative sums are reported as gauges.
+								return numberData("value", point)
+							}
+						})
+					}
+				case pmetric.MetricTypeHistogram:
+					histogram := metric.Histogram()
+					points := histogram.DataPoints()
+					for l := 0; l < points.Len(); l++ {
+						point := points.At(l)
+						add(point.Attributes(), point.Timestamp(), func(labels mapstr.M) mapstr.M {
+							cumulative := histogram.AggregationTemporality() == pmetric.AggregationTemporalityCumulative
+							return g.histogramData(name, labels, point, cumulative)
+						})
+					}
+				case pmetric.MetricTypeExponentialHistogram:
+					histogram := metric.ExponentialHistogram()
+					points := histogram.DataPoints()
+					for l := 0; l < points.Len(); l++ {
+						point := points.At(l)
+						add(point.Attributes(), point.Timestamp(), func(labels mapstr.M) mapstr.M {
+							cumulative := histogram.AggregationTemporality() == pmetric.AggregationTemporalityCumulative
+							return g.exponentialHistogramData(name, labels, point, cumulative)
+						})
+					}
+				}
+			}
+		}
+	}
+
+	return eventList
+}
+
+// attributesToLabels merges the attributes of the resource, scope and point,
+// in this order of precedence, into labels. Dots in their keys are replaced by
+// underscores.
+func attributesToLabels(attributes ...pcommon.Map) mapstr.M {
+	labels := mapstr.M{}
+	for _, attrs := range attributes {
+		attrs.Range(func(k string, v pcommon.Value) bool {
+			labels[common.DeDot(k)] = v.AsString()
+			return true
+		})
+	}
+	return labels
+}
+
+// numberData returns the value of the point in the given field, or nil when it
+// has no valid value.
+func numberData(field string, point pmetric.NumberDataPoint) mapstr.M {
+	if point.Flags().NoRecordedValue() {
+		return nil
+	}
+
+	var val float64
+	switch point.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		val = float64(point.IntValue())
+	case pmetric.NumberDataPointValueTypeDouble:
+		val = point.DoubleValue()
+	default:
+		return nil
+	}
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		return nil
+	}
+
+	return mapstr.M{field: val}
+}
+
+// histogramData converts a histogram point with explicit bounds to an ES
+// histogram. Cumulative histograms are converted like Prometheus histograms,
+// so their counts are the increase since the previous export.
+func (g *eventGenerator) histogramData(name string, labels mapstr.M, point pmetric.HistogramDataPoint, cumulative bool) mapstr.M {
+	bounds := point.ExplicitBounds().AsRaw()
+	counts := point.BucketCounts().AsRaw()
+	if point.Flags().NoRecordedValue() || len(counts) == 0 || len(counts) != len(bounds)+1 {
+		return nil
+	}
+
+	if cumulative {
+		hist := p.Histogram{}
+		var cumulativeCount uint64
+		for i, count := range counts {
+			cumulativeCount += count
+			v := cumulativeCount
+			upperBound := math.Inf(1)
+			if i < len(bounds) {
+				upperBound = bounds[i]
+			}
+			hist.Bucket = append(hist.Bucket, &p.Bucket{
+				CumulativeCount: &v,
+				UpperBound:      &upperBound,
+			})
+		}
+		return mapstr.M{
+			"histogram": collector.PromHistogramToES(g.counterCache, name, labels, &hist),
+		}
+	}
+
+	// Use the same centroids as collector.PromHistogramToES.
+	values := make([]float64, 0, len(counts))
+	var lastUpper float64
+	for i := range counts {
+		switch {
+		case i == len(bounds):
+			values = append(values, lastUpper)
+		case bounds[i] < 0 && i == 0:
+			values = append(values, bounds[i])
+			lastUpper = bounds[i]
+		default:
+			values = append(values, lastUpper+(bounds[i]-lastUpper)/2.0)
+			lastUpper = bounds[i]
+		}
+	}
+	return mapstr.M{
+		"histogram": mapstr.M{
+			"values": values,
+			"counts": counts,
+		},
+	}
+}
+
+// exponentialHistogramData converts an exponential histogram point to an ES
+// histogram, with the midpoints of the populated buckets as values. Counts of
+// cumulative histograms are the increase since the previous export.
+func (g *eventGenerator) exponentialHistogramData(name string, labels mapstr.M, point pmetric.ExponentialHistogramDataPoint, cumulative bool) mapstr.M {
+	if point.Flags().NoRecordedValue() {
+		return nil
+	}
+
+	scale := point.Scale()
+	key := name + labels.String() + "/" + strconv.Itoa(int(scale)) + "/"
+
+	var values []float64
+	var counts []uint64
+	add := func(value float64, id string, count uint64) {
+		if cumulative {
+			count, _ = g.counterCache.RateUint64(key+id, count)
+		}
+		if count == 0 || math.IsInf(value, 0) {
+			return
+		}
+		values = append(values, value)
+		counts = append(counts, count)
+	}
+
+	// The bucket of index i holds the values in (base^i, base^(i+1)], where
+	// base is 2^(2^-scale).
+	midpoint := func(index int) float64 {
+		lower := math.Exp2(float64(index) * math.Exp2(-float64(scale)))
+		upper := math.Exp2(float64(index+1) * math.Exp2(-float64(scale)))
+		return lower + (upper-lower)/2.0
+	}
+
+	negative := point.Negative()
+	for i := negative.BucketCounts().Len() - 1; i >= 0; i-- {
+		index := int(negative.Offset()) + i
+		add(-midpoint(index), "-"+strconv.Itoa(index), negative.BucketCounts().At(i))
+	}
+	add(0, "0", point.ZeroCount())
+	positive := point.Positive()
+	for i := 0; i < positive.BucketCounts().Len(); i++ {
+		index := int(positive.Offset()) + i
+		add(midpoint(index), "+"+strconv.Itoa(index), positive.BucketCounts().At(i))
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return mapstr.M{
+		"histogram": mapstr.M{
+			"values": values,
+			"counts": counts,
+		},
+	}
+}
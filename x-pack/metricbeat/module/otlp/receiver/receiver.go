@@ -0,0 +1,162 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package receiver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	serverhelper "github.com/elastic/beats/v7/metricbeat/helper/server"
+	httpserver "github.com/elastic/beats/v7/metricbeat/helper/server/http"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/beats/v7/metricbeat/mb/parse"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/prometheus/collector"
+)
+
+const (
+	protobufContentType = "application/x-protobuf"
+	jsonContentType     = "application/json"
+)
+
+func init() {
+	mb.Registry.MustAddMetricSet("otlp", "receiver", New,
+		mb.WithHostParser(parse.EmptyHostParser),
+		mb.DefaultMetricSet(),
+	)
+}
+
+// MetricSet receives OTLP/HTTP metric exports and reports their points as
+// events.
+type MetricSet struct {
+	mb.BaseMetricSet
+	server    serverhelper.Server
+	events    chan mb.Event
+	generator *eventGenerator
+}
+
+// New creates a new instance of the MetricSet.
+func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
+	cfgwarn.Beta("The otlp receiver metricset is beta.")
+
+	config := defaultConfig()
+	if err := base.Module().UnpackConfig(&config); err != nil {
+		return nil, err
+	}
+
+	m := &MetricSet{
+		BaseMetricSet: base,
+		events:        make(chan mb.Event),
+		generator: &eventGenerator{
+			// Use a counter cache with a timeout of 5x the period, as a safe
+			// value to make sure that all counters are available between exports.
+			counterCache: collector.NewCounterCache(config.Period * 5),
+			rateCounters: config.RateCounters,
+		},
+	}
+
+	svc, err := httpserver.NewHttpServerWithHandler(base, m.handleFunc)
+	if err != nil {
+		return nil, err
+	}
+	m.server = svc
+	return m, nil
+}
+
+// Run starts the HTTP server and reports the events of the exports it
+// receives until the reporter is done.
+func (m *MetricSet) Run(reporter mb.PushReporterV2) {
+	m.generator.counterCache.Start()
+	defer m.generator.counterCache.Stop()
+
+	_ = m.server.Start()
+
+	for {
+		select {
+		case <-reporter.Done():
+			m.server.Stop()
+			return
+		case e := <-m.events:
+			reporter.Event(e)
+		}
+	}
+}
+
+func (m *MetricSet) handleFunc(writer http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(writer, "OTLP receiver accepts data via POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || (contentType != protobufContentType && contentType != jsonContentType) {
+		http.Error(writer, fmt.Sprintf("unsupported content type %q", req.Header.Get("Content-Type")), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body := req.Body
+	switch encoding := req.Header.Get("Content-Encoding"); encoding {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			m.Logger().Errorf("Decode error %v", err)
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		http.Error(writer, fmt.Sprintf("unsupported content encoding %q", encoding), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		m.Logger().Errorf("Read error %v", err)
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	exportReq := pmetricotlp.NewExportRequest()
+	if contentType == jsonContentType {
+		err = exportReq.UnmarshalJSON(data)
+	} else {
+		err = exportReq.UnmarshalProto(data)
+	}
+	if err != nil {
+		m.Logger().Errorf("Unmarshal error %v", err)
+		http.Error(writer, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	events := m.generator.GenerateEvents(exportReq.Metrics())
+	for _, e := range events {
+		select {
+		case <-req.Context().Done():
+			return
+		case m.events <- e:
+		}
+	}
+
+	var resp []byte
+	if contentType == jsonContentType {
+		resp, err = pmetricotlp.NewExportResponse().MarshalJSON()
+	} else {
+		resp, err = pmetricotlp.NewExportResponse().MarshalProto()
+	}
+	if err != nil {
+		http.Error(writer, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(resp)
+}
@@ -0,0 +1,300 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+//go:build !integration
+
+package receiver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
+
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	mbtest "github.com/elastic/beats/v7/metricbeat/mb/testing"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/prometheus/collector"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var timestamp = time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// newMetrics returns metrics of a resource and scope with attributes, with
+// the metrics added by the given function.
+func newMetrics(add func(metrics pmetric.MetricSlice)) pmetric.Metrics {
+	metrics := pmetric.NewMetrics()
+	resourceMetrics := metrics.ResourceMetrics().AppendEmpty()
+	resourceMetrics.Resource().Attributes().PutStr("service.name", "checkout")
+	resourceMetrics.Resource().Attributes().PutStr("host.name", "host-1")
+	scopeMetrics := resourceMetrics.ScopeMetrics().AppendEmpty()
+	scopeMetrics.Scope().SetName("checkout/metrics")
+	scopeMetrics.Scope().Attributes().PutStr("host.name", "host-2")
+	add(scopeMetrics.Metrics())
+	return metrics
+}
+
+func newGenerator(rateCounters bool) *eventGenerator {
+	return &eventGenerator{
+		counterCache: collector.NewCounterCache(time.Minute),
+		rateCounters: rateCounters,
+	}
+}
+
+func TestGenerateEventsNumbers(t *testing.T) {
+	export := func(requests int64, inflight float64) pmetric.Metrics {
+		return newMetrics(func(metrics pmetric.MetricSlice) {
+			counter := metrics.AppendEmpty()
+			counter.SetName("http.server.requests")
+			counter.SetEmptySum().SetIsMonotonic(true)
+			counter.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			point := counter.Sum().DataPoints().AppendEmpty()
+			point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			point.SetIntValue(requests)
+			point.Attributes().PutStr("http.method", "GET")
+			point.Attributes().PutInt("http.status_code", 200)
+
+			delta := metrics.AppendEmpty()
+			delta.SetName("http.server.errors")
+			delta.SetEmptySum().SetIsMonotonic(true)
+			delta.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+			point = delta.Sum().DataPoints().AppendEmpty()
+			point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			point.SetIntValue(3)
+			point.Attributes().PutStr("http.method", "GET")
+			point.Attributes().PutInt("http.status_code", 200)
+
+			upDown := metrics.AppendEmpty()
+			upDown.SetName("http.server.active_requests")
+			upDown.SetEmptySum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+			point = upDown.Sum().DataPoints().AppendEmpty()
+			point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			point.SetDoubleValue(inflight)
+
+			gauge := metrics.AppendEmpty()
+			gauge.SetName("process.memory.usage")
+			point = gauge.SetEmptyGauge().DataPoints().AppendEmpty()
+			point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			point.SetIntValue(1024)
+			// Dropped, points without a value are not reported.
+			point = gauge.Gauge().DataPoints().AppendEmpty()
+			point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			point.SetFlags(pmetric.DefaultDataPointFlags.WithNoRecordedValue(true))
+			point.Attributes().PutStr("state", "none")
+		})
+	}
+
+	g := newGenerator(true)
+	g.GenerateEvents(export(10, 2))
+	events := g.GenerateEvents(export(15, 1))
+	require.Len(t, events, 2)
+
+	for _, e := range events {
+		assert.Equal(t, timestamp, e.Timestamp)
+		labels := e.ModuleFields["labels"].(mapstr.M)
+		// Scope attributes take precedence over resource ones.
+		assert.Equal(t, "host-2", labels["host_name"])
+		assert.Equal(t, "checkout", labels["service_name"])
+
+		if _, ok := labels["http_method"]; ok {
+			assert.Equal(t, mapstr.M{
+				"service_name":     "checkout",
+				"host_name":        "host-2",
+				"http_method":      "GET",
+				"http_status_code": "200",
+			}, labels)
+			assert.Equal(t, mapstr.M{
+				"http.server.requests": mapstr.M{"counter": float64(15), "rate": float64(5)},
+				"http.server.errors":   mapstr.M{"rate": float64(3)},
+			}, e.ModuleFields["metrics"])
+		} else {
+			assert.Equal(t, mapstr.M{
+				"http.server.active_requests": mapstr.M{"value": float64(1)},
+				"process.memory.usage":        mapstr.M{"value": float64(1024)},
+			}, e.ModuleFields["metrics"])
+		}
+	}
+}
+
+func TestGenerateEventsHistograms(t *testing.T) {
+	export := func(temporality pmetric.AggregationTemporality, counts []uint64, zero uint64, positive []uint64) pmetric.Metrics {
+		return newMetrics(func(metrics pmetric.MetricSlice) {
+			histogram := metrics.AppendEmpty()
+			histogram.SetName("http.server.duration")
+			histogram.SetEmptyHistogram().SetAggregationTemporality(temporality)
+			point := histogram.Histogram().DataPoints().AppendEmpty()
+			point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			point.ExplicitBounds().FromRaw([]float64{0.25, 0.5, 1})
+			point.BucketCounts().FromRaw(counts)
+
+			exponential := metrics.AppendEmpty()
+			exponential.SetName("rpc.server.duration")
+			exponential.SetEmptyExponentialHistogram().SetAggregationTemporality(temporality)
+			expPoint := exponential.ExponentialHistogram().DataPoints().AppendEmpty()
+			expPoint.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+			// Buckets of base 2: (1, 2], (2, 4] and (4, 8]
+			expPoint.SetScale(0)
+			expPoint.SetZeroCount(zero)
+			expPoint.Positive().SetOffset(0)
+			expPoint.Positive().BucketCounts().FromRaw(positive)
+			// Bucket (-2, -1]
+			expPoint.Negative().SetOffset(0)
+			expPoint.Negative().BucketCounts().FromRaw([]uint64{1})
+		})
+	}
+
+	histograms := func(events map[string]mb.Event) mapstr.M {
+		require.Len(t, events, 1)
+		for _, e := range events {
+			return e.ModuleFields["metrics"].(mapstr.M)
+		}
+		return nil
+	}
+
+	t.Run("delta", func(t *testing.T) {
+		g := newGenerator(false)
+		metrics := histograms(g.GenerateEvents(export(pmetric.AggregationTemporalityDelta, []uint64{1, 2, 3, 4}, 2, []uint64{5, 0, 6})))
+		assert.Equal(t, mapstr.M{
+			"http.server.duration": mapstr.M{
+				"histogram": mapstr.M{
+					"values": []float64{0.125, 0.375, 0.75, 1},
+					"counts": []uint64{1, 2, 3, 4},
+				},
+			},
+			"rpc.server.duration": mapstr.M{
+				"histogram": mapstr.M{
+					"values": []float64{-1.5, 0, 1.5, 6},
+					"counts": []uint64{1, 2, 5, 6},
+				},
+			},
+		}, metrics)
+	})
+
+	t.Run("cumulative", func(t *testing.T) {
+		g := newGenerator(false)
+		g.GenerateEvents(export(pmetric.AggregationTemporalityCumulative, []uint64{1, 2, 3, 4}, 2, []uint64{5, 0, 6}))
+		metrics := histograms(g.GenerateEvents(export(pmetric.AggregationTemporalityCumulative, []uint64{2, 2, 5, 4}, 2, []uint64{5, 1, 9})))
+		assert.Equal(t, mapstr.M{
+			"http.server.duration": mapstr.M{
+				"histogram": mapstr.M{
+					"values": []float64{0.125, 0.375, 0.75, 1},
+					"counts": []uint64{1, 0, 2, 0},
+				},
+			},
+			"rpc.server.duration": mapstr.M{
+				"histogram": mapstr.M{
+					"values": []float64{3, 6},
+					"counts": []uint64{1, 3},
+				},
+			},
+		}, metrics)
+	})
+}
+
+func TestHandleFunc(t *testing.T) {
+	ms := mbtest.NewPushMetricSetV2(t, map[string]interface{}{
+		"module":     "otlp",
+		"metricsets": []string{"receiver"},
+	}).(*MetricSet)
+	ms.events = make(chan mb.Event, 10)
+
+	metrics := newMetrics(func(metrics pmetric.MetricSlice) {
+		gauge := metrics.AppendEmpty()
+		gauge.SetName("process.memory.usage")
+		point := gauge.SetEmptyGauge().DataPoints().AppendEmpty()
+		point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+		point.SetIntValue(1024)
+	})
+	request := pmetricotlp.NewExportRequestFromMetrics(metrics)
+	protobuf, err := request.MarshalProto()
+	require.NoError(t, err)
+	json, err := request.MarshalJSON()
+	require.NoError(t, err)
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	_, err = w.Write(protobuf)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	for name, tc := range map[string]struct {
+		body        []byte
+		contentType string
+		encoding    string
+		status      int
+	}{
+		"protobuf":             {body: protobuf, contentType: protobufContentType, status: http.StatusOK},
+		"gzip":                 {body: gzipped.Bytes(), contentType: protobufContentType, encoding: "gzip", status: http.StatusOK},
+		"json":                 {body: json, contentType: "application/json; charset=utf-8", status: http.StatusOK},
+		"invalid body":         {body: []byte("{"), contentType: jsonContentType, status: http.StatusBadRequest},
+		"invalid gzip":         {body: protobuf, contentType: protobufContentType, encoding: "gzip", status: http.StatusBadRequest},
+		"unsupported type":     {body: json, contentType: "text/plain", status: http.StatusUnsupportedMediaType},
+		"unsupported encoding": {body: protobuf, contentType: protobufContentType, encoding: "br", status: http.StatusUnsupportedMediaType},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/metrics", bytes.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			if tc.encoding != "" {
+				req.Header.Set("Content-Encoding", tc.encoding)
+			}
+			rec := httptest.NewRecorder()
+			ms.handleFunc(rec, req)
+
+			require.Equal(t, tc.status, rec.Code, rec.Body.String())
+			if tc.status != http.StatusOK {
+				assert.Empty(t, ms.events)
+				return
+			}
+
+			response := pmetricotlp.NewExportResponse()
+			if tc.contentType == protobufContentType {
+				assert.Equal(t, protobufContentType, rec.Header().Get("Content-Type"))
+				assert.NoError(t, response.UnmarshalProto(rec.Body.Bytes()))
+			} else {
+				assert.Equal(t, jsonContentType, rec.Header().Get("Content-Type"))
+				assert.NoError(t, response.UnmarshalJSON(rec.Body.Bytes()))
+			}
+
+			require.Len(t, ms.events, 1)
+			e := <-ms.events
+			assert.Equal(t, mapstr.M{"process.memory.usage": mapstr.M{"value": float64(1024)}}, e.ModuleFields["metrics"])
+		})
+	}
+}
+
+func TestData(t *testing.T) {
+	g := newGenerator(false)
+	events := g.GenerateEvents(newMetrics(func(metrics pmetric.MetricSlice) {
+		counter := metrics.AppendEmpty()
+		counter.SetName("http.server.requests")
+		counter.SetEmptySum().SetIsMonotonic(true)
+		counter.Sum().SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		point := counter.Sum().DataPoints().AppendEmpty()
+		point.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+		point.SetIntValue(42)
+
+		histogram := metrics.AppendEmpty()
+		histogram.SetName("http.server.duration")
+		histogram.SetEmptyHistogram().SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		hpoint := histogram.Histogram().DataPoints().AppendEmpty()
+		hpoint.SetTimestamp(pcommon.NewTimestampFromTime(timestamp))
+		hpoint.ExplicitBounds().FromRaw([]float64{0.25, 0.5, 1})
+		hpoint.BucketCounts().FromRaw([]uint64{10, 25, 6, 1})
+	}))
+	require.Len(t, events, 1)
+
+	ms := mbtest.NewPushMetricSetV2(t, map[string]interface{}{
+		"module":     "otlp",
+		"metricsets": []string{"receiver"},
+	})
+	for _, e := range events {
+		mbtest.WriteEventToDataJSON(t, mbtest.StandardizeEvent(ms, e), "")
+	}
+}
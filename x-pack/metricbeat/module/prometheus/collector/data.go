@@ -134,7 +134,19 @@ func (g *typedGenerator) GeneratePromEvents(mf *p.MetricFamily) []collector.Prom
 		}
 
 		histogram := metric.GetHistogram()
-		if histogram != nil {
+		if native := histogram.GetNative(); native != nil {
+			// Native histograms take precedence over the classic buckets of the same histogram
+			if h := NativeHistogramToES(g.counterCache, name, labels, native); h != nil {
+				events = append(events, collector.PromEvent{
+					Data: mapstr.M{
+						name: mapstr.M{
+							"histogram": h,
+						},
+					},
+					Labels: labels,
+				})
+			}
+		} else if histogram != nil {
 			events = append(events, collector.PromEvent{
 				Data: mapstr.M{
 					name: mapstr.M{
@@ -7,6 +7,9 @@ package collector
 import (
 	"fmt"
 	"math"
+	"strconv"
+
+	"github.com/prometheus/prometheus/model/histogram"
 
 	p "github.com/elastic/beats/v7/metricbeat/helper/prometheus"
 
@@ -91,3 +94,62 @@ func PromHistogramToES(cc CounterCache, name string, labels mapstr.M, histogram
 
 	return res
 }
+
+// NativeHistogramToES converts a Prometheus native histogram into an ES histogram, consistently with PromHistogramToES:
+//
+//   - values are the midpoints of the buckets, or the finite bound of buckets with an infinite bound
+//   - counts are the increase of each bucket since the previous fetch, so buckets are reported from
+//     the second time they are seen
+//
+// Buckets without increase are skipped, as native histograms can have many buckets. It returns nil
+// when no bucket has increased.
+func NativeHistogramToES(cc CounterCache, name string, labels mapstr.M, h *histogram.FloatHistogram) mapstr.M {
+	var values []float64
+	var counts []uint64
+
+	// Buckets are identified by their schema, as the bucket of an index changes with it
+	key := name + labels.String() + "/" + strconv.Itoa(int(h.Schema)) + "/"
+	add := func(id string, bucket histogram.Bucket[float64]) {
+		if math.IsNaN(bucket.Count) || math.IsInf(bucket.Count, 0) {
+			return
+		}
+		countRate, found := cc.RateFloat64(key+id, bucket.Count)
+		count := uint64(math.Round(countRate))
+		if !found || count == 0 {
+			return
+		}
+
+		var value float64
+		switch {
+		case math.IsInf(bucket.Upper, 1):
+			value = bucket.Lower
+		case math.IsInf(bucket.Lower, -1):
+			value = bucket.Upper
+		default:
+			value = bucket.Lower + (bucket.Upper-bucket.Lower)/2.0
+		}
+		values = append(values, value)
+		counts = append(counts, count)
+	}
+
+	// Values must be in ascending order, from the lowest negative bucket to the highest positive one
+	it := h.NegativeReverseBucketIterator()
+	for it.Next() {
+		add("-"+strconv.Itoa(int(it.At().Index)), it.At())
+	}
+	if !h.UsesCustomBuckets() {
+		add("0", h.ZeroBucket())
+	}
+	it = h.PositiveBucketIterator()
+	for it.Next() {
+		add("+"+strconv.Itoa(int(it.At().Index)), it.At())
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+	return mapstr.M{
+		"values": values,
+		"counts": counts,
+	}
+}
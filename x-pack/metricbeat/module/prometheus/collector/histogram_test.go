@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/protobuf/proto"
 
@@ -464,3 +465,99 @@ func TestPromHistogramToES(t *testing.T) {
 		})
 	}
 }
+
+// TestNativeHistogramToES tests that calling NativeHistogramToES multiple
+// times with the same cache produces each time the expected results.
+func TestNativeHistogramToES(t *testing.T) {
+	type sample struct {
+		histogram histogram.FloatHistogram
+		expected  mapstr.M
+	}
+
+	cases := map[string]struct {
+		samples []sample
+	}{
+		"exponential buckets": {
+			samples: []sample{
+				{
+					histogram: histogram.FloatHistogram{
+						Schema:        0,
+						ZeroThreshold: 0.001,
+						ZeroCount:     1,
+						// (0.5, 1] and (1, 2]
+						PositiveSpans:   []histogram.Span{{Offset: 0, Length: 2}},
+						PositiveBuckets: []float64{2, 3},
+						// [-2, -1)
+						NegativeSpans:   []histogram.Span{{Offset: 1, Length: 1}},
+						NegativeBuckets: []float64{4},
+					},
+					// no previous values to calculate rates
+					expected: nil,
+				},
+				{
+					histogram: histogram.FloatHistogram{
+						Schema:        0,
+						ZeroThreshold: 0.001,
+						ZeroCount:     2,
+						// (0.5, 1], (1, 2] and the new bucket (4, 8]
+						PositiveSpans:   []histogram.Span{{Offset: 0, Length: 2}, {Offset: 1, Length: 1}},
+						PositiveBuckets: []float64{3, 3, 5},
+						NegativeSpans:   []histogram.Span{{Offset: 1, Length: 1}},
+						NegativeBuckets: []float64{6},
+					},
+					expected: mapstr.M{
+						// buckets without increase and the new bucket are skipped
+						"counts": []uint64{2, 1, 1},
+						"values": []float64{-1.5, 0, 0.75},
+					},
+				},
+			},
+		},
+		"custom buckets": {
+			samples: []sample{
+				{
+					histogram: histogram.FloatHistogram{
+						Schema: histogram.CustomBucketsSchema,
+						// (-Inf, 1], (1, 2] and (2, +Inf]
+						PositiveSpans:   []histogram.Span{{Offset: 0, Length: 3}},
+						PositiveBuckets: []float64{1, 1, 1},
+						CustomValues:    []float64{1, 2},
+					},
+					expected: nil,
+				},
+				{
+					histogram: histogram.FloatHistogram{
+						Schema:          histogram.CustomBucketsSchema,
+						PositiveSpans:   []histogram.Span{{Offset: 0, Length: 3}},
+						PositiveBuckets: []float64{2, 1, 3},
+						CustomValues:    []float64{1, 2},
+					},
+					expected: mapstr.M{
+						// buckets with an infinite bound use their finite bound
+						"counts": []uint64{1, 2},
+						"values": []float64{1, 2},
+					},
+				},
+			},
+		},
+	}
+
+	metricName := "somemetric"
+	labels := mapstr.M{}
+
+	for title, c := range cases {
+		t.Run(title, func(t *testing.T) {
+			cache := NewCounterCache(120 * time.Minute)
+
+			for i, s := range c.samples {
+				t.Logf("#%d: %+v", i, s.histogram)
+				result := NativeHistogramToES(cache, metricName, labels, &s.histogram)
+				if s.expected == nil {
+					assert.Nil(t, result)
+					continue
+				}
+				assert.EqualValues(t, s.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const defaultPort = 161
+
+// Client polls an SNMP agent. It connects on the first request and after
+// errors.
+type Client struct {
+	snmp      *gosnmp.GoSNMP
+	connected bool
+}
+
+// NewClient returns a client for the agent at host, which has the format
+// `[udp://|tcp://]address[:port]`.
+func NewClient(host string, config Config) (*Client, error) {
+	transport := "udp"
+	if scheme, address, found := strings.Cut(host, "://"); found {
+		if scheme != "udp" && scheme != "tcp" {
+			return nil, fmt.Errorf("unsupported transport %s in host %s", scheme, host)
+		}
+		transport, host = scheme, address
+	}
+
+	address, port := host, defaultPort
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		address = h
+		port, err = strconv.Atoi(p)
+		if err != nil || port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid port in host %s", host)
+		}
+	}
+
+	client := &gosnmp.GoSNMP{
+		Target:         address,
+		Port:           uint16(port),
+		Transport:      transport,
+		MaxOids:        config.MaxOIDs,
+		MaxRepetitions: config.MaxRepetitions,
+	}
+	if err := config.Apply(client); err != nil {
+		return nil, err
+	}
+	return &Client{snmp: client}, nil
+}
+
+func (c *Client) connect() error {
+	if c.connected {
+		return nil
+	}
+	if err := c.snmp.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", c.snmp.Target, err)
+	}
+	c.connected = true
+	return nil
+}
+
+// Get returns the variables of the OIDs, requested in batches of at most
+// max_oids OIDs.
+func (c *Client) Get(oids []string) ([]gosnmp.SnmpPDU, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	pdus := make([]gosnmp.SnmpPDU, 0, len(oids))
+	for start := 0; start < len(oids); start += c.snmp.MaxOids {
+		end := start + c.snmp.MaxOids
+		if end > len(oids) {
+			end = len(oids)
+		}
+		packet, err := c.snmp.Get(oids[start:end])
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		if packet.Error != gosnmp.NoError {
+			return nil, fmt.Errorf("agent returned error %s for OID %s", packet.Error, errorOID(oids[start:end], packet.ErrorIndex))
+		}
+		pdus = append(pdus, packet.Variables...)
+	}
+	return pdus, nil
+}
+
+// errorOID returns the OID of the variable with the error, indexes start
+// at 1.
+func errorOID(oids []string, index uint8) string {
+	if index == 0 || int(index) > len(oids) {
+		return "unknown"
+	}
+	return oids[index-1]
+}
+
+// Walk returns the variables in the subtree of the OID. GETBULK requests
+// are used, except with SNMP v1, which only supports GETNEXT.
+func (c *Client) Walk(oid string) ([]gosnmp.SnmpPDU, error) {
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+
+	var pdus []gosnmp.SnmpPDU
+	var err error
+	if c.snmp.Version == gosnmp.Version1 {
+		pdus, err = c.snmp.WalkAll(oid)
+	} else {
+		pdus, err = c.snmp.BulkWalkAll(oid)
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return pdus, nil
+}
+
+// Close closes the connection to the agent.
+func (c *Client) Close() error {
+	if !c.connected {
+		return nil
+	}
+	c.connected = false
+	return c.snmp.Conn.Close()
+}
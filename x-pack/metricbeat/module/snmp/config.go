@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"fmt"
+	"strings"
+
+	snmpcommon "github.com/elastic/beats/v7/x-pack/libbeat/common/snmp"
+)
+
+// Config contains the settings shared by the snmp metricsets.
+type Config struct {
+	snmpcommon.Config `config:",inline"`
+
+	// MaxOIDs is the maximum number of OIDs requested with a single GET.
+	MaxOIDs int `config:"max_oids" validate:"min=1"`
+	// MaxRepetitions is the number of variables requested with each GETBULK
+	// when walking a table.
+	MaxRepetitions uint32 `config:"max_repetitions" validate:"min=1"`
+	// MIBPaths are the MIB files and directories used to translate between
+	// names and OIDs.
+	MIBPaths []string `config:"mib_paths"`
+}
+
+// DefaultConfig returns the default settings of the snmp metricsets.
+func DefaultConfig() Config {
+	return Config{
+		Config:         snmpcommon.DefaultConfig(),
+		MaxOIDs:        60,
+		MaxRepetitions: 25,
+	}
+}
+
+// LoadMIB loads the configured MIBs, it returns nil if there are none.
+func (c *Config) LoadMIB() (*snmpcommon.MIB, error) {
+	if len(c.MIBPaths) == 0 {
+		return nil, nil
+	}
+	return snmpcommon.LoadMIB(c.MIBPaths...)
+}
+
+// Mapping maps the value of an OID to a field of the events.
+type Mapping struct {
+	// OID is a numeric OID or an object name defined in the MIBs.
+	OID   string `config:"oid" validate:"required"`
+	Field string `config:"field"`
+	// Rate reports the per second rate of a counter instead of its value.
+	Rate bool `config:"rate"`
+}
+
+// Resolve sets the numeric OID of the mapping, and its field from the name
+// of the OID in the MIBs if it is not set.
+func (m *Mapping) Resolve(mib *snmpcommon.MIB) error {
+	oid, ok := mib.OID(m.OID)
+	if !ok {
+		return fmt.Errorf("unknown OID %s", m.OID)
+	}
+	m.OID = oid
+
+	if m.Field != "" {
+		return nil
+	}
+	name, ok := mib.Name(oid)
+	if !ok {
+		return fmt.Errorf("field is required for OID %s, it has no name in the MIBs", oid)
+	}
+	// Scalar objects are requested with the instance 0, which is not part
+	// of the name of the object.
+	name = strings.TrimSuffix(name, ".0")
+	m.Field = strings.ReplaceAll(name, ".", "_")
+	return nil
+}
@@ -0,0 +1,23 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License 2.0;
+// you may not use this file except in compliance with the Elastic License 2.0.
+
+// Code generated by beats/dev-tools/cmd/asset/asset.go - DO NOT EDIT.
+
+package snmp
+
+import (
+	"github.com/elastic/beats/v7/libbeat/asset"
+)
+
+func init() {
+	if err := asset.SetFields("metricbeat", "snmp", asset.ModuleFieldsPri, AssetSnmp); err != nil {
+		panic(err)
+	}
+}
+
+// AssetSnmp returns asset data.
+// This is the base64 encoded zlib format compressed contents of module/snmp.
+func AssetSnmp() string {
+	return "eJzEks1u6jAQhfd5iqNskBDkAbK4q7thcblVK3WLTDIJLv5J7UlT3r6yqaNAo6pISMUbfDzjM9+J1zjSqYQ3ussAlqyoRP60/feQZ0BNvnKyY2lNiT8ZAIQjaFv3ijLAkSLhqcSeWGRAI0nVvoyVaxihabw7SHzqqETrbJ+UacO0qSUetbk+4Kt5+s1MndazUD152AZ8IPzf/PVw9NqTZ6rROKujLloyvMIg+RD3cchIk1qlgxZdJ03ri4nBNc2UaLFcXOiJyu5fqJrChnUWd6Fi92kUNyXyZX5V+w3uiBzGFiYAF9n1YCz28VvePexHO4xRRxOPQagj1ZDm5zlXVvXa3Bx3YJvN+0inwbr6thC3QtMFSjFrKk1N7/dz3YTrkq2zQ8otTrCKkXXCcaqIz9k2kOzxdn7oomFyF4fh/zlSP8/w++8UlVW9NkX2MQDIWTx5"
+}
@@ -0,0 +1,109 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package get
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// init registers the MetricSet with the central registry.
+func init() {
+	mb.Registry.MustAddMetricSet("snmp", "get", New, mb.DefaultMetricSet())
+}
+
+type config struct {
+	snmp.Config `config:",inline"`
+	OIDs        []snmp.Mapping `config:"oids"`
+}
+
+// MetricSet gets the configured OIDs from an SNMP agent and reports them in
+// a single event.
+type MetricSet struct {
+	mb.BaseMetricSet
+	client   *snmp.Client
+	oids     []string
+	mappings map[string]snmp.Mapping
+	rates    *snmp.Rates
+}
+
+// New creates a new instance of the MetricSet.
+func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
+	cfgwarn.Beta("The snmp get metricset is beta.")
+
+	config := config{Config: snmp.DefaultConfig()}
+	if err := base.Module().UnpackConfig(&config); err != nil {
+		return nil, err
+	}
+	if len(config.OIDs) == 0 {
+		return nil, errors.New("no oids configured for the get metricset")
+	}
+
+	mib, err := config.LoadMIB()
+	if err != nil {
+		return nil, err
+	}
+
+	m := &MetricSet{
+		BaseMetricSet: base,
+		mappings:      make(map[string]snmp.Mapping, len(config.OIDs)),
+		rates:         snmp.NewRates(),
+	}
+	for _, mapping := range config.OIDs {
+		if err := mapping.Resolve(mib); err != nil {
+			return nil, err
+		}
+		if _, exists := m.mappings[mapping.OID]; exists {
+			return nil, fmt.Errorf("duplicated OID %s", mapping.OID)
+		}
+		m.oids = append(m.oids, mapping.OID)
+		m.mappings[mapping.OID] = mapping
+	}
+
+	m.client, err = snmp.NewClient(base.Host(), config.Config)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Fetch gets the OIDs and reports their values.
+func (m *MetricSet) Fetch(r mb.ReporterV2) error {
+	pdus, err := m.client.Get(m.oids)
+	if err != nil {
+		return fmt.Errorf("error getting OIDs: %w", err)
+	}
+
+	now := time.Now()
+	fields := mapstr.M{}
+	for _, pdu := range pdus {
+		mapping, ok := m.mappings[strings.TrimPrefix(pdu.Name, ".")]
+		if !ok {
+			continue
+		}
+		if value, ok := m.rates.Value(mapping.OID, mapping, pdu, now); ok {
+			_, _ = fields.Put(mapping.Field, value)
+		}
+	}
+	m.rates.Prune(now)
+
+	if len(fields) == 0 {
+		return nil
+	}
+	r.Event(mb.Event{MetricSetFields: fields})
+	return nil
+}
+
+// Close closes the connection to the agent.
+func (m *MetricSet) Close() error {
+	return m.client.Close()
+}
@@ -0,0 +1,131 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package get
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	mbtest "github.com/elastic/beats/v7/metricbeat/mb/testing"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp/mtest"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+func TestFetch(t *testing.T) {
+	agent := mtest.NewAgent(t, "public",
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("router1")},
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(123456)},
+		gosnmp.SnmpPDU{Name: "1.3.6.1.4.1.9999.1.0", Type: gosnmp.Integer, Value: 42},
+	)
+
+	config := mtest.GetConfig("get", agent)
+	config["max_oids"] = 2
+	config["oids"] = []map[string]interface{}{
+		{"oid": "1.3.6.1.2.1.1.5.0", "field": "name"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "field": "uptime"},
+		{"oid": ".1.3.6.1.4.1.9999.1.0", "field": "temperature.celsius"},
+		{"oid": "1.3.6.1.4.1.9999.2.0", "field": "missing"},
+	}
+
+	ms := mbtest.NewReportingMetricSetV2Error(t, config)
+	events, errs := mbtest.ReportingFetchV2Error(ms)
+	require.Empty(t, errs)
+	require.Len(t, events, 1)
+
+	assert.Equal(t, mapstr.M{
+		"name":        "router1",
+		"uptime":      uint64(123456),
+		"temperature": mapstr.M{"celsius": int64(42)},
+	}, events[0].MetricSetFields)
+	// The 4 OIDs are requested in batches of 2.
+	assert.Equal(t, 2, agent.Requests())
+}
+
+func TestFetchRate(t *testing.T) {
+	agent := mtest.NewAgent(t, "public",
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.4.3.0", Type: gosnmp.Counter32, Value: uint(math.MaxUint32 - 99)},
+	)
+
+	config := mtest.GetConfig("get", agent)
+	config["oids"] = []map[string]interface{}{
+		{"oid": "1.3.6.1.2.1.4.3.0", "field": "in_receives", "rate": true},
+	}
+
+	ms := mbtest.NewReportingMetricSetV2Error(t, config)
+	events, errs := mbtest.ReportingFetchV2Error(ms)
+	require.Empty(t, errs)
+	assert.Empty(t, events, "no rate on the first fetch")
+
+	time.Sleep(100 * time.Millisecond)
+	// The counter wraps around.
+	agent.Set(gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.4.3.0", Type: gosnmp.Counter32, Value: uint(100)})
+	events, errs = mbtest.ReportingFetchV2Error(ms)
+	require.Empty(t, errs)
+	require.Len(t, events, 1)
+
+	rate, ok := events[0].MetricSetFields["in_receives"].(float64)
+	require.True(t, ok)
+	assert.Greater(t, rate, 0.0)
+	assert.Less(t, rate, 200/0.1)
+}
+
+func TestFetchV1NoSuchName(t *testing.T) {
+	agent := mtest.NewAgent(t, "private",
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("router1")},
+	)
+
+	config := mtest.GetConfig("get", agent)
+	config["version"] = "1"
+	config["oids"] = []map[string]interface{}{
+		{"oid": "1.3.6.1.2.1.1.5.0", "field": "name"},
+		{"oid": "1.3.6.1.2.1.1.6.0", "field": "location"},
+	}
+
+	ms := mbtest.NewReportingMetricSetV2Error(t, config)
+	_, errs := mbtest.ReportingFetchV2Error(ms)
+	require.Len(t, errs, 1)
+	assert.ErrorContains(t, errs[0], "1.3.6.1.2.1.1.6.0")
+}
+
+func TestMIBNames(t *testing.T) {
+	agent := mtest.NewAgent(t, "public",
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("router1")},
+	)
+
+	config := mtest.GetConfig("get", agent)
+	config["mib_paths"] = []string{"../_meta/testdata/SNMPv2-MIB.txt"}
+	config["oids"] = []map[string]interface{}{
+		{"oid": "SNMPv2-MIB::sysName.0"},
+	}
+
+	ms := mbtest.NewReportingMetricSetV2Error(t, config)
+	events, errs := mbtest.ReportingFetchV2Error(ms)
+	require.Empty(t, errs)
+	require.Len(t, events, 1)
+	assert.Equal(t, mapstr.M{"sysName": "router1"}, events[0].MetricSetFields)
+}
+
+func TestData(t *testing.T) {
+	agent := mtest.NewAgent(t, "public",
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.5.0", Type: gosnmp.OctetString, Value: []byte("router1")},
+		gosnmp.SnmpPDU{Name: "1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(123456)},
+	)
+
+	config := mtest.GetConfig("get", agent)
+	config["oids"] = []map[string]interface{}{
+		{"oid": "1.3.6.1.2.1.1.5.0", "field": "name"},
+		{"oid": "1.3.6.1.2.1.1.3.0", "field": "uptime"},
+	}
+
+	ms := mbtest.NewReportingMetricSetV2Error(t, config)
+	if err := mbtest.WriteEventsReporterV2Error(ms, t, ""); err != nil {
+		t.Fatal("write", err)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+// Package mtest contains a minimal SNMP agent to test the snmp metricsets.
+package mtest
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Agent is an SNMP v1 and v2c agent answering GET, GETNEXT and GETBULK
+// requests with a fixed set of variables.
+type Agent struct {
+	t         testing.TB
+	conn      net.PacketConn
+	community string
+
+	mu        sync.Mutex
+	variables []gosnmp.SnmpPDU
+	requests  int
+
+	wg sync.WaitGroup
+}
+
+// NewAgent starts an agent on a random local port. It is stopped when the
+// test finishes.
+func NewAgent(t testing.TB, community string, variables ...gosnmp.SnmpPDU) *Agent {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to start SNMP agent: %v", err)
+	}
+
+	a := &Agent{t: t, conn: conn, community: community}
+	a.Set(variables...)
+	a.wg.Add(1)
+	go a.serve()
+	t.Cleanup(func() {
+		conn.Close()
+		a.wg.Wait()
+	})
+	return a
+}
+
+// Addr returns the address of the agent.
+func (a *Agent) Addr() string {
+	return a.conn.LocalAddr().String()
+}
+
+// Set replaces the variables of the agent.
+func (a *Agent) Set(variables ...gosnmp.SnmpPDU) {
+	sorted := make([]gosnmp.SnmpPDU, len(variables))
+	copy(sorted, variables)
+	for i := range sorted {
+		sorted[i].Name = "." + strings.TrimPrefix(sorted[i].Name, ".")
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return compareOIDs(sorted[i].Name, sorted[j].Name) < 0
+	})
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.variables = sorted
+}
+
+// Requests returns the number of requests received by the agent.
+func (a *Agent) Requests() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.requests
+}
+
+func (a *Agent) serve() {
+	defer a.wg.Done()
+
+	decoder := &gosnmp.GoSNMP{}
+	buf := make([]byte, 65535)
+	for {
+		n, addr, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		request, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil {
+			a.t.Logf("failed to decode SNMP request: %v", err)
+			continue
+		}
+		if request.Community != a.community {
+			continue
+		}
+
+		response := a.handle(request)
+		out, err := response.MarshalMsg()
+		if err != nil {
+			a.t.Logf("failed to encode SNMP response: %v", err)
+			continue
+		}
+		_, _ = a.conn.WriteTo(out, addr)
+	}
+}
+
+func (a *Agent) handle(request *gosnmp.SnmpPacket) *gosnmp.SnmpPacket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests++
+
+	response := &gosnmp.SnmpPacket{
+		Version:   request.Version,
+		Community: request.Community,
+		PDUType:   gosnmp.GetResponse,
+		RequestID: request.RequestID,
+		Logger:    gosnmp.NewLogger(nil),
+	}
+
+	switch request.PDUType {
+	case gosnmp.GetRequest:
+		for i, v := range request.Variables {
+			pdu, ok := a.get(v.Name)
+			if !ok && request.Version == gosnmp.Version1 {
+				response.Error = gosnmp.NoSuchName
+				response.ErrorIndex = uint8(i + 1)
+				response.Variables = request.Variables
+				return response
+			}
+			response.Variables = append(response.Variables, pdu)
+		}
+	case gosnmp.GetNextRequest:
+		for i, v := range request.Variables {
+			pdu, ok := a.next(v.Name)
+			if !ok && request.Version == gosnmp.Version1 {
+				response.Error = gosnmp.NoSuchName
+				response.ErrorIndex = uint8(i + 1)
+				response.Variables = request.Variables
+				return response
+			}
+			response.Variables = append(response.Variables, pdu)
+		}
+	case gosnmp.GetBulkRequest:
+		name := request.Variables[0].Name
+		for i := uint32(0); i < request.MaxRepetitions; i++ {
+			pdu, ok := a.next(name)
+			response.Variables = append(response.Variables, pdu)
+			if !ok {
+				break
+			}
+			name = pdu.Name
+		}
+	}
+	return response
+}
+
+func (a *Agent) get(name string) (gosnmp.SnmpPDU, bool) {
+	for _, v := range a.variables {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return gosnmp.SnmpPDU{Name: name, Type: gosnmp.NoSuchObject}, false
+}
+
+func (a *Agent) next(name string) (gosnmp.SnmpPDU, bool) {
+	for _, v := range a.variables {
+		if compareOIDs(v.Name, name) > 0 {
+			return v, true
+		}
+	}
+	return gosnmp.SnmpPDU{Name: name, Type: gosnmp.EndOfMibView}, false
+}
+
+func compareOIDs(a, b string) int {
+	as := strings.Split(strings.TrimPrefix(a, "."), ".")
+	bs := strings.Split(strings.TrimPrefix(b, "."), ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.ParseUint(as[i], 10, 32)
+		y, _ := strconv.ParseUint(bs[i], 10, 32)
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// GetConfig returns the configuration of a metricset polling the agent.
+func GetConfig(metricset string, agent *Agent) map[string]interface{} {
+	return map[string]interface{}{
+		"module":     "snmp",
+		"metricsets": []string{metricset},
+		"hosts":      []string{agent.Addr()},
+		"community":  agent.community,
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package snmp
+
+import (
+	"math"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+
+	snmpcommon "github.com/elastic/beats/v7/x-pack/libbeat/common/snmp"
+)
+
+type sample struct {
+	value     uint64
+	timestamp time.Time
+}
+
+// Rates converts the values of counters to per second rates, using the
+// value of the previous fetch.
+type Rates struct {
+	samples map[string]sample
+}
+
+// NewRates returns an empty Rates.
+func NewRates() *Rates {
+	return &Rates{samples: map[string]sample{}}
+}
+
+// Value returns the value of the variable for the mapping. If the mapping
+// has rate enabled, the per second rate since the last call for the same key
+// is returned instead, there is no value on the first call or when the
+// counter has been reset.
+func (r *Rates) Value(key string, mapping Mapping, pdu gosnmp.SnmpPDU, now time.Time) (interface{}, bool) {
+	value, ok := snmpcommon.Value(pdu)
+	if !ok || !mapping.Rate {
+		return value, ok
+	}
+	counter, ok := value.(uint64)
+	if !ok {
+		return nil, false
+	}
+
+	prev, found := r.samples[key]
+	r.samples[key] = sample{value: counter, timestamp: now}
+	elapsed := now.Sub(prev.timestamp).Seconds()
+	if !found || elapsed <= 0 {
+		return nil, false
+	}
+
+	var delta uint64
+	switch {
+	case counter >= prev.value:
+		delta = counter - prev.value
+	case pdu.Type == gosnmp.Counter32:
+		// Counter32 wraps around at 2^32.
+		delta = counter + math.MaxUint32 + 1 - prev.value
+	default:
+		// Counter64 values do not wrap in practice, the agent restarted.
+		return nil, false
+	}
+	return float64(delta) / elapsed, true
+}
+
+// Prune removes the samples not updated since t, like the ones of table
+// rows that disappeared.
+func (r *Rates) Prune(t time.Time) {
+	for key, s := range r.samples {
+		if s.timestamp.Before(t) {
+			delete(r.samples, key)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package table
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/elastic/beats/v7/libbeat/common/cfgwarn"
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+// init registers the MetricSet with the central registry.
+func init() {
+	mb.Registry.MustAddMetricSet("snmp", "table", New)
+}
+
+type config struct {
+	snmp.Config `config:",inline"`
+	Tables      []tableConfig `config:"tables"`
+}
+
+type tableConfig struct {
+	Name    string         `config:"name" validate:"required"`
+	Columns []snmp.Mapping `config:"columns" validate:"required"`
+}
+
+// MetricSet walks the columns of the configured tables and reports an event
+// per row.
+type MetricSet struct {
+	mb.BaseMetricSet
+	client *snmp.Client
+	tables []tableConfig
+	rates  *snmp.Rates
+}
+
+// New creates a new instance of the MetricSet.
+func New(base mb.BaseMetricSet) (mb.MetricSet, error) {
+	cfgwarn.Beta("The snmp table metricset is beta.")
+
+	config := config{Config: snmp.DefaultConfig()}
+	if err := base.Module().UnpackConfig(&config); err != nil {
+		return nil, err
+	}
+	if len(config.Tables) == 0 {
+		return nil, errors.New("no tables configured for the table metricset")
+	}
+
+	mib, err := config.LoadMIB()
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range config.Tables {
+		for i := range table.Columns {
+			column := &table.Columns[i]
+			if err := column.Resolve(mib); err != nil {
+				return nil, fmt.Errorf("invalid column in table %s: %w", table.Name, err)
+			}
+			if column.Field == "name" || column.Field == "index" {
+				return nil, fmt.Errorf("invalid column in table %s: field %s is reserved", table.Name, column.Field)
+			}
+		}
+	}
+
+	client, err := snmp.NewClient(base.Host(), config.Config)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricSet{
+		BaseMetricSet: base,
+		client:        client,
+		tables:        config.Tables,
+		rates:         snmp.NewRates(),
+	}, nil
+}
+
+// Fetch walks the tables and reports their rows.
+func (m *MetricSet) Fetch(r mb.ReporterV2) error {
+	now := time.Now()
+	var errs []error
+	for _, table := range m.tables {
+		rows, err := m.fetchTable(table, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error walking table %s: %w", table.Name, err))
+			continue
+		}
+		for _, index := range sortedIndexes(rows) {
+			fields := rows[index]
+			fields["name"] = table.Name
+			fields["index"] = index
+			if !r.Event(mb.Event{MetricSetFields: fields}) {
+				return nil
+			}
+		}
+	}
+	m.rates.Prune(now)
+	return errors.Join(errs...)
+}
+
+// fetchTable walks the columns of the table and groups their values by the
+// index of the row, which is the part of the OID after the column OID.
+func (m *MetricSet) fetchTable(table tableConfig, now time.Time) (map[string]mapstr.M, error) {
+	rows := map[string]mapstr.M{}
+	for _, column := range table.Columns {
+		pdus, err := m.client.Walk(column.OID)
+		if err != nil {
+			return nil, err
+		}
+		for _, pdu := range pdus {
+			index, found := strings.CutPrefix(strings.TrimPrefix(pdu.Name, "."), column.OID+".")
+			if !found {
+				continue
+			}
+			value, ok := m.rates.Value(table.Name+"/"+column.OID+"."+index, column, pdu, now)
+			if !ok {
+				continue
+			}
+			row, exists := rows[index]
+			if !exists {
+				row = mapstr.M{}
+				rows[index] = row
+			}
+			_, _ = row.Put(column.Field, value)
+		}
+	}
+	return rows, nil
+}
+
+// sortedIndexes returns the indexes of the rows in the order of the agent.
+func sortedIndexes(rows map[string]mapstr.M) []string {
+	indexes := make([]string, 0, len(rows))
+	for index := range rows {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return compareOIDs(indexes[i], indexes[j]) < 0
+	})
+	return indexes
+}
+
+// compareOIDs compares OIDs by their numeric sub-identifiers.
+func compareOIDs(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, errX := strconv.ParseUint(as[i], 10, 32)
+		y, errY := strconv.ParseUint(bs[i], 10, 32)
+		if errX != nil || errY != nil {
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+			continue
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(as) - len(bs)
+}
+
+// Close closes the connection to the agent.
+func (m *MetricSet) Close() error {
+	return m.client.Close()
+}
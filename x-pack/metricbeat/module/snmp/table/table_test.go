@@ -0,0 +1,118 @@
+// Copyright Elasticsearch B.V. and/or licensed to Elasticsearch B.V. under one
+// or more contributor license agreements. Licensed under the Elastic License;
+// you may not use this file except in compliance with the Elastic License.
+
+package table
+
+import (
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/elastic/beats/v7/metricbeat/mb"
+	mbtest "github.com/elastic/beats/v7/metricbeat/mb/testing"
+	"github.com/elastic/beats/v7/x-pack/metricbeat/module/snmp/mtest"
+	conf "github.com/elastic/elastic-agent-libs/config"
+	"github.com/elastic/elastic-agent-libs/mapstr"
+)
+
+var interfaces = []gosnmp.SnmpPDU{
+	{Name: "1.3.6.1.2.1.2.2.1.2.1", Type: gosnmp.OctetString, Value: []byte("lo")},
+	{Name: "1.3.6.1.2.1.2.2.1.2.2", Type: gosnmp.OctetString, Value: []byte("eth0")},
+	{Name: "1.3.6.1.2.1.2.2.1.2.10", Type: gosnmp.OctetString, Value: []byte("eth1")},
+	{Name: "1.3.6.1.2.1.2.2.1.6.2", Type: gosnmp.OctetString, Value: []byte{0x02, 0x42, 0xac, 0x11, 0x00, 0x02}},
+	{Name: "1.3.6.1.2.1.2.2.1.8.1", Type: gosnmp.Integer, Value: 1},
+	{Name: "1.3.6.1.2.1.2.2.1.8.2", Type: gosnmp.Integer, Value: 1},
+	{Name: "1.3.6.1.2.1.2.2.1.8.10", Type: gosnmp.Integer, Value: 2},
+	{Name: "1.3.6.1.2.1.2.2.1.10.1", Type: gosnmp.Counter32, Value: uint(1000)},
+	{Name: "1.3.6.1.2.1.2.2.1.10.2", Type: gosnmp.Counter32, Value: uint(2000)},
+	{Name: "1.3.6.1.2.1.2.2.1.10.10", Type: gosnmp.Counter32, Value: uint(0)},
+	{Name: "1.3.6.1.2.1.31.1.1.1.1.1", Type: gosnmp.OctetString, Value: []byte("lo")},
+}
+
+func TestFetch(t *testing.T) {
+	for _, version := range []string{"1", "2c"} {
+		t.Run(version, func(t *testing.T) {
+			agent := mtest.NewAgent(t, "public", interfaces...)
+
+			config := mtest.GetConfig("table", agent)
+			config["version"] = version
+			config["max_repetitions"] = 2
+			config["tables"] = []map[string]interface{}{
+				{
+					"name": "interfaces",
+					"columns": []map[string]interface{}{
+						{"oid": "1.3.6.1.2.1.2.2.1.2", "field": "descr"},
+						{"oid": "1.3.6.1.2.1.2.2.1.6", "field": "mac"},
+						{"oid": "1.3.6.1.2.1.2.2.1.8", "field": "oper_status"},
+						{"oid": "1.3.6.1.2.1.2.2.1.10", "field": "in.octets"},
+					},
+				},
+			}
+
+			ms := mbtest.NewReportingMetricSetV2Error(t, config)
+			events, errs := mbtest.ReportingFetchV2Error(ms)
+			require.Empty(t, errs)
+			require.Len(t, events, 3)
+
+			expected := []mapstr.M{
+				{"name": "interfaces", "index": "1", "descr": "lo", "oper_status": int64(1), "in": mapstr.M{"octets": uint64(1000)}},
+				{"name": "interfaces", "index": "2", "descr": "eth0", "mac": "02:42:ac:11:00:02", "oper_status": int64(1), "in": mapstr.M{"octets": uint64(2000)}},
+				{"name": "interfaces", "index": "10", "descr": "eth1", "oper_status": int64(2), "in": mapstr.M{"octets": uint64(0)}},
+			}
+			for i, event := range events {
+				assert.Equal(t, expected[i], event.MetricSetFields)
+			}
+		})
+	}
+}
+
+func TestReservedField(t *testing.T) {
+	agent := mtest.NewAgent(t, "public", interfaces...)
+
+	config := mtest.GetConfig("table", agent)
+	config["tables"] = []map[string]interface{}{
+		{
+			"name": "interfaces",
+			"columns": []map[string]interface{}{
+				{"oid": "1.3.6.1.2.1.2.2.1.2", "field": "name"},
+			},
+		},
+	}
+
+	c, err := conf.NewConfigFrom(config)
+	require.NoError(t, err)
+	_, _, err = mb.NewModule(c, mb.Registry)
+	assert.ErrorContains(t, err, "reserved")
+}
+
+func TestCompareOIDs(t *testing.T) {
+	assert.Negative(t, compareOIDs("2", "10"))
+	assert.Negative(t, compareOIDs("1.2", "1.10"))
+	assert.Negative(t, compareOIDs("1", "1.1"))
+	assert.Zero(t, compareOIDs("10.0.0.1", "10.0.0.1"))
+	assert.Positive(t, compareOIDs("10.0.0.2", "10.0.0.1"))
+}
+
+func TestData(t *testing.T) {
+	agent := mtest.NewAgent(t, "public", interfaces...)
+
+	config := mtest.GetConfig("table", agent)
+	config["tables"] = []map[string]interface{}{
+		{
+			"name": "interfaces",
+			"columns": []map[string]interface{}{
+				{"oid": "1.3.6.1.2.1.2.2.1.2", "field": "descr"},
+				{"oid": "1.3.6.1.2.1.2.2.1.8", "field": "oper_status"},
+				{"oid": "1.3.6.1.2.1.2.2.1.10", "field": "in.octets"},
+			},
+		},
+	}
+
+	ms := mbtest.NewReportingMetricSetV2Error(t, config)
+	if err := mbtest.WriteEventsReporterV2Error(ms, t, ""); err != nil {
+		t.Fatal("write", err)
+	}
+}